@@ -1,27 +1,208 @@
 package config
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"text/template"
+	"time"
 
 	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
 
 	"github.com/go-kit/kit/log/level"
 	"github.com/pkg/errors"
+	"github.com/robfig/cron/v3"
 	"gopkg.in/yaml.v2"
 )
 
 type Config struct {
-	APIOptions     *RestAPIOptions   `yaml:"api_options"`
-	JobsFromConfig []*JobsFromConfig `yaml:"jobs,flow"`
-	Bots           *Bots             `yaml:"bots,flow"`
-	HealthCheck    *HealthCheck      `yaml:"health_check,flow"`
+	APIOptions         *RestAPIOptions      `yaml:"api_options"`
+	JobsFromConfig     []*JobsFromConfig    `yaml:"jobs,flow"`
+	Bots               *Bots                `yaml:"bots,flow"`
+	HealthCheck        *HealthCheck         `yaml:"health_check,flow"`
+	MaintenanceWindows []*MaintenanceWindow `yaml:"maintenance_windows,omitempty"`
+	Cache              *Cache               `yaml:"cache,omitempty"`
+	Messages           *Messages            `yaml:"messages,omitempty"`
+	Recover            *Recover             `yaml:"recover,omitempty"`
+	Policies           []*Policy            `yaml:"policies,omitempty"`
+	Auth               *Auth                `yaml:"auth,omitempty"`
+	TargetGroups       map[string][]string  `yaml:"target_groups,omitempty"`
+	TargetLabels       map[string]Labels    `yaml:"target_labels,omitempty"`
+	NetworkLimits      *NetworkLimits       `yaml:"network_limits,omitempty"`
+	GlobalLabels       map[string]string    `yaml:"global_labels,omitempty"`
+	Defaults           *Defaults            `yaml:"defaults,omitempty"`
+	ActionAliases      ActionAliases        `yaml:"action_aliases,omitempty"`
+	Limits             *Limits              `yaml:"limits,omitempty"`
+	Grafana            *Grafana             `yaml:"grafana,omitempty"`
+	RandomSelection    *RandomSelection     `yaml:"random_selection,omitempty"`
+	Docker             *DockerOptions       `yaml:"docker,omitempty"`
+	Metrics            *Metrics             `yaml:"metrics,omitempty"`
 }
 
+const (
+	// TargetLabelKeep keeps the target label of the injection metrics
+	TargetLabelKeep = "keep"
+	// TargetLabelDrop removes the target label of the injection metrics
+	TargetLabelDrop = "drop"
+	// TargetLabelBucket replaces the target label of the injection metrics with the bucket of the target
+	TargetLabelBucket = "bucket"
+
+	defaultTargetBuckets = 10
+)
+
+// Metrics contains the target label of the injection metrics. With many targets the label can be dropped, or
+// replaced with one of a fixed number of buckets that the targets are hashed to, to bound the number of series
+type Metrics struct {
+	TargetLabel   string `yaml:"target_label,omitempty"`
+	TargetBuckets int    `yaml:"target_buckets,omitempty"`
+}
+
+// TargetLabelMode returns how the target label of the injection metrics is emitted, keep if it is not set
+func (metrics *Metrics) TargetLabelMode() string {
+	if metrics == nil || metrics.TargetLabel == "" {
+		return TargetLabelKeep
+	}
+	return metrics.TargetLabel
+}
+
+// Buckets returns the number of the buckets that the targets are hashed to, 10 if it is not set
+func (metrics *Metrics) Buckets() int {
+	if metrics == nil || metrics.TargetBuckets == 0 {
+		return defaultTargetBuckets
+	}
+	return metrics.TargetBuckets
+}
+
+// RandomSelection contains how the random target of a job is selected. A positive health bias prefers the targets
+// whose bots were serving in the latest health checks, a negative one prefers the unhealthy targets, and zero or
+// no health checks select every target with the same probability
+type RandomSelection struct {
+	HealthBias float64 `yaml:"health_bias,omitempty"`
+}
+
+// Bias returns the health bias of the random selection, which is between -1 and 1
+func (selection *RandomSelection) Bias() float64 {
+	if selection == nil {
+		return 0
+	}
+	return selection.HealthBias
+}
+
+// DockerOptions contains the options of the docker injections. Allow random false rejects the injections
+// on a random target of a job, e.g. do=random, with 403
+type DockerOptions struct {
+	AllowRandom *bool `yaml:"allow_random,omitempty"`
+}
+
+// RandomAllowed returns true if the injections on a random target are allowed, which is the default
+func (docker *DockerOptions) RandomAllowed() bool {
+	if docker == nil || docker.AllowRandom == nil {
+		return true
+	}
+	return *docker.AllowRandom
+}
+
+// Grafana contains the url of the grafana that annotates the start and the end of every failure,
+// and the token of the api. The tags are added to every annotation
+type Grafana struct {
+	URL   string             `yaml:"url"`
+	Token chaoslogger.Secret `yaml:"token,omitempty"`
+	Tags  []string           `yaml:"tags,omitempty"`
+}
+
+const defaultMaxNameLength = 128
+
+// namePattern is the charset of the job and component names, which are logged and used in the keys of the cache
+var namePattern = regexp.MustCompile(`^[A-Za-z0-9 _.:@/-]+$`)
+
+// Limits contains the limits of the names of the config. The max name length defaults to 128.
+// The min healthy targets is the number of healthy targets that an injection on several or random targets of a job
+// should leave, which requires the health check to be active
+type Limits struct {
+	MaxNameLength     int `yaml:"max_name_length,omitempty"`
+	MinHealthyTargets int `yaml:"min_healthy_targets,omitempty"`
+}
+
+// NameLength returns the maximum length of the job and component names
+func (limits *Limits) NameLength() int {
+	if limits == nil || limits.MaxNameLength == 0 {
+		return defaultMaxNameLength
+	}
+	return limits.MaxNameLength
+}
+
+// ActionAliases maps the names accepted by the action query parameter to the canonical names of the actions,
+// e.g. enable: start. The canonical names are always accepted
+type ActionAliases map[string]string
+
+// Resolve returns the canonical name of the action, or the value itself if it is not an alias
+func (aliases ActionAliases) Resolve(value string) string {
+	for alias, canonical := range aliases {
+		if strings.EqualFold(alias, value) {
+			return canonical
+		}
+	}
+	return value
+}
+
+// Defaults contains the values applied to the requests that omit them. The duration by type is the duration
+// after which the failures of the type are recovered automatically. Zero does not recover them automatically
+type Defaults struct {
+	DurationByType map[FailureType]time.Duration `yaml:"duration_by_type,omitempty"`
+}
+
+// NetworkLimits contains the maximum values of the netem fields of network injections.
+// Zero values do not bound the field
+type NetworkLimits struct {
+	MaxLimit  uint32 `yaml:"max_limit,omitempty"`
+	MaxGap    uint32 `yaml:"max_gap,omitempty"`
+	MaxJitter uint32 `yaml:"max_jitter,omitempty"`
+}
+
+// Labels of a target, e.g. env: prod
+type Labels map[string]string
+
+// IsProduction returns true if the target is labeled env: prod. Injections on production
+// targets have to be confirmed with the query parameter confirmProduction=true
+func (labels Labels) IsProduction() bool {
+	return labels["env"] == "prod"
+}
+
+// RestAPIOptions contains the port and scheme of the api. If the handler timeout is set,
+// requests that do not complete within it are answered with 503.
+// If the read only port is set, the routes that can not inject or recover failures are also served on it.
+// The responses of injections with an Idempotency-Key header are replayed for the idempotency ttl.
+// The X-Forwarded-For header is only used for the client ip of requests from the trusted proxies, ips or cidrs.
+// If debug errors are enabled, the 500 responses contain the stack traces of the errors
 type RestAPIOptions struct {
-	Port   string `yaml:"port"`
-	Scheme string `yaml:"scheme"`
+	Port           string        `yaml:"port"`
+	Scheme         string        `yaml:"scheme"`
+	HandlerTimeout time.Duration `yaml:"handler_timeout,omitempty"`
+	ReadOnlyPort   string        `yaml:"read_only_port,omitempty"`
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl,omitempty"`
+	TrustedProxies []string      `yaml:"trusted_proxies,omitempty"`
+	MaxConnections int           `yaml:"max_connections,omitempty"`
+	DebugErrors    bool          `yaml:"debug_errors,omitempty"`
+	DebugEndpoints bool          `yaml:"debug_endpoints,omitempty"`
+}
+
+// IsTrustedProxy returns true if the ip is one of the trusted proxies
+func (options *RestAPIOptions) IsTrustedProxy(ip net.IP) bool {
+	if options == nil || ip == nil {
+		return false
+	}
+	for _, proxy := range options.TrustedProxies {
+		if network, err := parseNetwork(proxy); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
 }
 
 type HealthCheck struct {
@@ -29,17 +210,325 @@ type HealthCheck struct {
 	Report bool `yaml:"report,flow"`
 }
 
+// Cache contains the expiration of the failures kept for recovery and the interval
+// on which expired failures are evicted. Zero values disable expiration and the sweep.
+// The auto recover interval is the interval on which the failures whose duration has passed are recovered,
+// if their scheduled recovery was missed or failed. Zero disables the scan.
+// If a persistence path is set the running failures are saved in that file, and with fail closed
+// an injection is rolled back and rejected when the running failures can not be saved
+type Cache struct {
+	Expiration          time.Duration `yaml:"expiration,omitempty"`
+	SweepInterval       time.Duration `yaml:"sweep_interval,omitempty"`
+	AutoRecoverInterval time.Duration `yaml:"auto_recover_interval,omitempty"`
+	PersistencePath     string        `yaml:"persistence_path,omitempty"`
+	FailClosed          bool          `yaml:"fail_closed,omitempty"`
+}
+
+// Messages contains the go text/template of the message of successful responses from the bots.
+// The template can use the fields .Target, .Status and .Message
+type Messages struct {
+	SuccessTemplate string `yaml:"success_template,omitempty"`
+}
+
+// Recover contains the policy for the failures in the cache whose recovery failed.
+// With requeue the recovery is retried once after the retry interval.
+// The webhook concurrency bounds the concurrent recoveries of one alertmanager webhook call.
+// With treat unreachable as recovered the failures of targets that can not be reached are dropped from the cache
+type Recover struct {
+	OnFailure                   RecoverFailurePolicy `yaml:"on_failure,omitempty"`
+	RetryInterval               time.Duration        `yaml:"retry_interval,omitempty"`
+	WebhookConcurrency          int                  `yaml:"webhook_concurrency,omitempty"`
+	VerifyHealth                bool                 `yaml:"verify_health,omitempty"`
+	CallbackURL                 string               `yaml:"callback_url,omitempty"`
+	TreatUnreachableAsRecovered bool                 `yaml:"treat_unreachable_as_recovered,omitempty"`
+	SerialPerTarget             bool                 `yaml:"serial_per_target,omitempty"`
+	// TypePriority is the order in which a recover-all recovers the failure types. The failures of a type are
+	// recovered after all the failures of the types before it, and the types that are not listed are recovered last
+	TypePriority []FailureType `yaml:"type_priority,omitempty"`
+	// EchoGroupKey returns the groupKey of an Alertmanager webhook in its response, for the correlation with
+	// the notification logs of Alertmanager
+	EchoGroupKey bool `yaml:"echo_group_key,omitempty"`
+}
+
+type RecoverFailurePolicy string
+
+const (
+	Keep    RecoverFailurePolicy = "keep"
+	Drop    RecoverFailurePolicy = "drop"
+	Requeue RecoverFailurePolicy = "requeue"
+)
+
+// Auth contains the bearer tokens that are accepted by the api. If there are no tokens the api is not authenticated.
+// The name of a token identifies the principal of the requests made with it
+type Auth struct {
+	Tokens []*APIToken `yaml:"tokens,omitempty"`
+}
+
+type APIToken struct {
+	Name  string             `yaml:"name"`
+	Token chaoslogger.Secret `yaml:"token"`
+}
+
+// Policy restricts the actions that can be performed for a job. If allowed actions are set
+// only those actions are permitted, and forbidden actions are never permitted
+type Policy struct {
+	Job              string   `yaml:"job"`
+	AllowedActions   []string `yaml:"allowed_actions,omitempty"`
+	ForbiddenActions []string `yaml:"forbidden_actions,omitempty"`
+}
+
+// Allows returns true if the policy permits the action
+func (policy *Policy) Allows(action string) bool {
+	for _, forbidden := range policy.ForbiddenActions {
+		if strings.EqualFold(forbidden, action) {
+			return false
+		}
+	}
+
+	if len(policy.AllowedActions) == 0 {
+		return true
+	}
+	for _, allowed := range policy.AllowedActions {
+		if strings.EqualFold(allowed, action) {
+			return true
+		}
+	}
+	return false
+}
+
+// MaintenanceWindow is either a fixed time range from start to end,
+// or a cron schedule that opens a window lasting for the specified duration.
+// The allowed jobs can still be injected while the window is open
+type MaintenanceWindow struct {
+	Start       time.Time     `yaml:"start,omitempty"`
+	End         time.Time     `yaml:"end,omitempty"`
+	Schedule    string        `yaml:"schedule,omitempty"`
+	Duration    time.Duration `yaml:"duration,omitempty"`
+	AllowedJobs []string      `yaml:"allowed_jobs,omitempty"`
+	schedule    cron.Schedule
+}
+
+// JobsFromConfig is a job of the config. If the bot port is set, the bots of the targets are called on that port
+// instead of the port of the target, for targets that serve different failure types on different ports
 type JobsFromConfig struct {
 	JobName       string      `yaml:"job_name"`
 	FailureType   FailureType `yaml:"type"`
 	ComponentName string      `yaml:"component_name,omitempty"`
 	Targets       []string    `yaml:"targets,omitempty"`
+	TargetGroups  []string    `yaml:"target_groups,omitempty"`
+	BotPort       string      `yaml:"bot_port,omitempty"`
+}
+
+// Addresses returns the addresses of the bots of the targets of the job
+func (cj *JobsFromConfig) Addresses() []string {
+	addresses := make([]string, 0, len(cj.Targets))
+	for _, target := range cj.Targets {
+		addresses = append(addresses, BotAddress(target, cj.BotPort))
+	}
+	return addresses
+}
+
+// BotAddress returns the address of the bot of the target, with the port replaced by the bot port if it is set
+func BotAddress(target string, botPort string) string {
+	if botPort == "" {
+		return target
+	}
+	host, _, err := net.SplitHostPort(target)
+	if err != nil {
+		host = target
+	}
+	return net.JoinHostPort(host, botPort)
 }
 
 type Bots struct {
-	CACert     string `yaml:"ca_cert,omitempty"`
-	PublicCert string `yaml:"public_cert,omitempty"`
-	PeerToken  string `yaml:"peer_token"`
+	CACert        string             `yaml:"ca_cert,omitempty"`
+	PublicCert    string             `yaml:"public_cert,omitempty"`
+	PeerToken     chaoslogger.Secret `yaml:"peer_token"`
+	PeerTokenFile string             `yaml:"peer_token_file,omitempty"`
+	LBPolicy      string             `yaml:"lb_policy,omitempty"`
+	MinTLSVersion string             `yaml:"min_tls_version,omitempty"`
+	CipherSuites  []string           `yaml:"cipher_suites,omitempty"`
+	Backoff       *Backoff           `yaml:"backoff,omitempty"`
+	// DialTimeout is the time the connection to a bot is waited for, 5 seconds if it is not set
+	DialTimeout time.Duration `yaml:"dial_timeout,omitempty"`
+	// KeepaliveTime is the time after which an idle connection to a bot is pinged. Not pinged if it is not set
+	KeepaliveTime time.Duration `yaml:"keepalive_time,omitempty"`
+	// KeepaliveTimeout is the time a ping is waited for, before the connection is closed
+	KeepaliveTimeout time.Duration `yaml:"keepalive_timeout,omitempty"`
+	// Retry is the retry policy of the calls to the bots that are unavailable. Not retried if it is not set
+	Retry *Retry `yaml:"retry,omitempty"`
+	// MaxConcurrentPerTarget is the number of the calls of bulk actions and recoveries in flight to a bot. Not limited if it is not set
+	MaxConcurrentPerTarget int `yaml:"max_concurrent_per_target,omitempty"`
+}
+
+// Backoff contains after how many consecutive failures of a bot the injections on its target are stopped,
+// for the initial duration that doubles with every further failure up to the max
+type Backoff struct {
+	Failures int           `yaml:"failures,omitempty"`
+	Initial  time.Duration `yaml:"initial,omitempty"`
+	Max      time.Duration `yaml:"max,omitempty"`
+}
+
+// Retry contains how many times a call to a bot that is unavailable is attempted, and the delay before the
+// first retry that doubles with every further retry
+type Retry struct {
+	MaxAttempts int           `yaml:"max_attempts,omitempty"`
+	BaseDelay   time.Duration `yaml:"base_delay,omitempty"`
+}
+
+// TLSMinVersion returns the minimum TLS version of the connections with the bots, which is TLS 1.2 if it is not set
+func (bots *Bots) TLSMinVersion() (uint16, error) {
+	if bots == nil {
+		return tls.VersionTLS12, nil
+	}
+
+	switch bots.MinTLSVersion {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("bots min_tls_version {%s} should be one of 1.2 or 1.3", bots.MinTLSVersion)
+	}
+}
+
+// tls12CipherSuites are the ids of the secure TLS 1.2 cipher suites that go implements, by their names.
+// The chacha20 suites are also accepted with the _SHA256 suffix that newer versions of go name them with
+var tls12CipherSuites = map[string]uint16{
+	"TLS_RSA_WITH_AES_128_CBC_SHA":                  tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_RSA_WITH_AES_256_CBC_SHA":                  tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_RSA_WITH_AES_128_GCM_SHA256":               tls.TLS_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_RSA_WITH_AES_256_GCM_SHA384":               tls.TLS_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA":          tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	"TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA":            tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256":       tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384":       tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256":         tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384":         tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305":          tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256":   tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305":        tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+	"TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256": tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+}
+
+// tlsVersions are the names of the TLS versions that go implements, by their ids
+var tlsVersions = map[uint16]string{
+	tls.VersionTLS10: "TLS 1.0",
+	tls.VersionTLS11: "TLS 1.1",
+	tls.VersionTLS12: "TLS 1.2",
+	tls.VersionTLS13: "TLS 1.3",
+}
+
+// TLSVersionName returns the name of the TLS version, or its hex id if it is not known
+func TLSVersionName(version uint16) string {
+	if name, ok := tlsVersions[version]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%04X", version)
+}
+
+// TLSCipherSuiteName returns the name of the TLS 1.2 cipher suite, without the _SHA256 suffix of the chacha20 suites,
+// or its hex id if it is not known
+func TLSCipherSuiteName(id uint16) string {
+	name := ""
+	for suite, suiteID := range tls12CipherSuites {
+		if suiteID == id && (name == "" || len(suite) < len(name)) {
+			name = suite
+		}
+	}
+	if name == "" {
+		return fmt.Sprintf("0x%04X", id)
+	}
+	return name
+}
+
+// TLSCipherSuites returns the ids of the cipher suites of the connections with the bots, or nil for the defaults of go.
+// The cipher suites of TLS 1.3 are not configurable, so only the names of TLS 1.2 cipher suites are accepted
+func (bots *Bots) TLSCipherSuites() ([]uint16, error) {
+	if bots == nil || len(bots.CipherSuites) == 0 {
+		return nil, nil
+	}
+
+	ids := make([]uint16, 0, len(bots.CipherSuites))
+	for _, name := range bots.CipherSuites {
+		id, ok := tls12CipherSuites[name]
+		if !ok {
+			return nil, fmt.Errorf("bots cipher_suites {%s} is not a supported TLS 1.2 cipher suite", name)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// loadPeerToken sets the peer token from the contents of the peer_token_file, if it is set
+func (bots *Bots) loadPeerToken() error {
+	if bots == nil || bots.PeerTokenFile == "" {
+		return nil
+	}
+
+	if bots.PeerToken != "" {
+		return errors.New("Only one of peer_token and peer_token_file should be set")
+	}
+
+	token, err := ioutil.ReadFile(bots.PeerTokenFile)
+	if err != nil {
+		return errors.Wrap(err, "could not read peer token file")
+	}
+	bots.PeerToken = chaoslogger.Secret(strings.TrimSpace(string(token)))
+
+	return nil
+}
+
+// checkCertificates reads the certificates of the bots once, so that a certificate that can not be used
+// fails the startup, instead of the dial of every target
+func (bots *Bots) checkCertificates() error {
+	if bots == nil {
+		return nil
+	}
+
+	for _, certificate := range []struct{ name, path string }{{"ca_cert", bots.CACert}, {"public_cert", bots.PublicCert}} {
+		if certificate.path == "" {
+			continue
+		}
+
+		pemCert, err := ioutil.ReadFile(certificate.path)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("could not read bots %s", certificate.name))
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(pemCert) {
+			return fmt.Errorf("bots %s {%s} does not contain a pem certificate", certificate.name, certificate.path)
+		}
+	}
+
+	return nil
+}
+
+// Overrides are the values of the command line flags that take precedence over the values of the config file.
+// Empty values do not override anything
+type Overrides struct {
+	Port      string
+	AuthToken string
+}
+
+// Override applies the overrides to the config. An auth token replaces the tokens of the config file
+func (config *Config) Override(overrides *Overrides) {
+	if overrides == nil {
+		return
+	}
+
+	if overrides.Port != "" {
+		if config.APIOptions == nil {
+			config.APIOptions = &RestAPIOptions{Scheme: "http"}
+		}
+		config.APIOptions.Port = overrides.Port
+	}
+
+	if overrides.AuthToken != "" {
+		config.Auth = &Auth{Tokens: []*APIToken{{Name: "command line", Token: chaoslogger.Secret(overrides.AuthToken)}}}
+	}
 }
 
 type FailureType string
@@ -52,11 +541,22 @@ const (
 	Network FailureType = "Network"
 )
 
+// GetConfig reads the config from the file, after validating it against the default schema
 func GetConfig(file string) (*Config, error) {
-	return unmarshalConfFromFile(file)
+	return GetConfigWithSchema(file, "")
+}
+
+// GetConfigWithSchema reads the config from the file, after validating it against the json schema
+// of the schema file. If the schema file is empty the default schema is used
+func GetConfigWithSchema(file string, schemaFile string) (*Config, error) {
+	schema, err := LoadSchema(schemaFile)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalConfFromFile(file, schema)
 }
 
-func unmarshalConfFromFile(file string) (*Config, error) {
+func unmarshalConfFromFile(file string, schema *Schema) (*Config, error) {
 	DefaultRestAPI := &RestAPIOptions{
 		Port:   "8080",
 		Scheme: "http",
@@ -67,6 +567,7 @@ func unmarshalConfFromFile(file string) (*Config, error) {
 			Active: false,
 			Report: false,
 		},
+		Cache: &Cache{},
 	}
 
 	config := DefaultConfig
@@ -78,12 +579,32 @@ func unmarshalConfFromFile(file string) (*Config, error) {
 			return nil, err
 		}
 
+		if err = schema.ValidateYAML(yamlFile); err != nil {
+			return nil, err
+		}
+
 		if err = yaml.Unmarshal(yamlFile, &config); err != nil {
 			err = errors.Wrap(err, "could not unmarshal yml")
 			return nil, err
 		}
 	}
 
+	if err := config.Bots.loadPeerToken(); err != nil {
+		return nil, err
+	}
+
+	if err := config.Bots.checkCertificates(); err != nil {
+		return nil, err
+	}
+
+	if err := config.normalizeTargets(); err != nil {
+		return nil, err
+	}
+
+	if err := config.expandTargetGroups(); err != nil {
+		return nil, err
+	}
+
 	if err := config.validate(); err != nil {
 		return nil, err
 	}
@@ -91,42 +612,422 @@ func unmarshalConfFromFile(file string) (*Config, error) {
 	return &config, nil
 }
 
+// expandTargetGroups adds the targets of the target groups referenced by every job to the targets of the job
+// NormalizeTarget strips the scheme of a target, e.g. http://127.0.0.1:8081 becomes 127.0.0.1:8081,
+// since the bots are dialed with grpc on host:port. Targets with a path are rejected
+func NormalizeTarget(target string) (string, error) {
+	normalized := target
+	if i := strings.Index(normalized, "://"); i >= 0 {
+		normalized = normalized[i+len("://"):]
+	}
+	normalized = strings.TrimSuffix(normalized, "/")
+
+	if normalized == "" || strings.Contains(normalized, "/") {
+		return "", fmt.Errorf("target {%s} should be of the form host:port", target)
+	}
+	return normalized, nil
+}
+
+// normalizeTargets normalizes the targets of the jobs, of the target groups and of the target labels
+func (config *Config) normalizeTargets() error {
+	for _, job := range config.JobsFromConfig {
+		if err := normalizeAll(job.Targets); err != nil {
+			return err
+		}
+	}
+
+	for _, group := range config.TargetGroups {
+		if err := normalizeAll(group); err != nil {
+			return err
+		}
+	}
+
+	targetLabels := make(map[string]Labels, len(config.TargetLabels))
+	for target, labels := range config.TargetLabels {
+		normalized, err := NormalizeTarget(target)
+		if err != nil {
+			return err
+		}
+		targetLabels[normalized] = labels
+	}
+	if config.TargetLabels != nil {
+		config.TargetLabels = targetLabels
+	}
+
+	return nil
+}
+
+func normalizeAll(targets []string) error {
+	for i, target := range targets {
+		normalized, err := NormalizeTarget(target)
+		if err != nil {
+			return err
+		}
+		targets[i] = normalized
+	}
+	return nil
+}
+
+func (config *Config) expandTargetGroups() error {
+	for _, job := range config.JobsFromConfig {
+		for _, groupName := range job.TargetGroups {
+			group, ok := config.TargetGroups[groupName]
+			if !ok {
+				return fmt.Errorf("job {%s} references unknown target group {%s}", job.JobName, groupName)
+			}
+			job.Targets = appendUnique(job.Targets, group...)
+		}
+	}
+
+	return nil
+}
+
+func appendUnique(targets []string, additional ...string) []string {
+	for _, target := range additional {
+		if !contains(targets, target) {
+			targets = append(targets, target)
+		}
+	}
+
+	return targets
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
 func (config *Config) validate() error {
+	if config.APIOptions != nil {
+		if err := config.APIOptions.validate(); err != nil {
+			return err
+		}
+	}
+
+	if config.RandomSelection != nil && (config.RandomSelection.HealthBias < -1 || config.RandomSelection.HealthBias > 1) {
+		return fmt.Errorf("random_selection health_bias {%g} should be between -1 and 1", config.RandomSelection.HealthBias)
+	}
+
+	if config.Metrics != nil {
+		switch config.Metrics.TargetLabelMode() {
+		case TargetLabelKeep, TargetLabelDrop, TargetLabelBucket:
+		default:
+			return fmt.Errorf("metrics target_label {%s} should be one of keep, drop or bucket", config.Metrics.TargetLabel)
+		}
+
+		if config.Metrics.TargetBuckets < 0 {
+			return fmt.Errorf("metrics target_buckets {%d} should not be negative", config.Metrics.TargetBuckets)
+		}
+	}
+
+	if config.Limits != nil && config.Limits.MaxNameLength < 0 {
+		return fmt.Errorf("limits max_name_length {%d} should not be negative", config.Limits.MaxNameLength)
+	}
+
+	if config.Limits != nil && config.Limits.MinHealthyTargets < 0 {
+		return fmt.Errorf("limits min_healthy_targets {%d} should not be negative", config.Limits.MinHealthyTargets)
+	}
+
+	if config.Limits != nil && config.Limits.MinHealthyTargets > 0 && (config.HealthCheck == nil || !config.HealthCheck.Active) {
+		return errors.New("limits min_healthy_targets requires the health check to be active")
+	}
+
 	for _, jobFromConfig := range config.JobsFromConfig {
-		err := validate(jobFromConfig)
+		err := validate(jobFromConfig, config.Limits.NameLength())
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, window := range config.MaintenanceWindows {
+		err := window.validate()
 		if err != nil {
 			return err
 		}
 	}
+
+	if config.Cache != nil && config.Cache.FailClosed && config.Cache.PersistencePath == "" {
+		return errors.New("The cache can only fail closed when a persistence_path is set")
+	}
+
+	if config.Bots != nil {
+		switch config.Bots.LBPolicy {
+		case "", "pick_first", "round_robin":
+		default:
+			return fmt.Errorf("bots lb_policy {%s} should be one of pick_first or round_robin", config.Bots.LBPolicy)
+		}
+
+		if _, err := config.Bots.TLSMinVersion(); err != nil {
+			return err
+		}
+
+		if _, err := config.Bots.TLSCipherSuites(); err != nil {
+			return err
+		}
+
+		if backoff := config.Bots.Backoff; backoff != nil && (backoff.Failures < 0 || backoff.Initial < 0 || backoff.Max < 0) {
+			return errors.New("bots backoff failures, initial and max should not be negative")
+		}
+
+		if config.Bots.DialTimeout < 0 || config.Bots.KeepaliveTime < 0 || config.Bots.KeepaliveTimeout < 0 {
+			return errors.New("bots dial_timeout, keepalive_time and keepalive_timeout should not be negative")
+		}
+
+		if retry := config.Bots.Retry; retry != nil && (retry.MaxAttempts < 0 || retry.BaseDelay < 0) {
+			return errors.New("bots retry max_attempts and base_delay should not be negative")
+		}
+
+		if config.Bots.MaxConcurrentPerTarget < 0 {
+			return fmt.Errorf("bots max_concurrent_per_target {%d} should not be negative", config.Bots.MaxConcurrentPerTarget)
+		}
+	}
+
+	if config.Auth != nil {
+		for _, apiToken := range config.Auth.Tokens {
+			if apiToken.Name == "" || apiToken.Token == "" {
+				return errors.New("Every api token should contain a name and a token")
+			}
+		}
+	}
+
+	for _, policy := range config.Policies {
+		if policy.Job == "" {
+			return errors.New("Every policy should contain a job")
+		}
+	}
+
+	if config.Recover != nil {
+		switch config.Recover.OnFailure {
+		case "", Keep, Drop, Requeue:
+		default:
+			return fmt.Errorf("recover on_failure {%s} should be one of keep, drop or requeue", config.Recover.OnFailure)
+		}
+
+		if config.Recover.WebhookConcurrency < 0 {
+			return fmt.Errorf("recover webhook_concurrency {%d} should not be negative", config.Recover.WebhookConcurrency)
+		}
+
+		if config.Recover.CallbackURL != "" {
+			if callbackURL, err := url.Parse(config.Recover.CallbackURL); err != nil || callbackURL.Scheme == "" || callbackURL.Host == "" {
+				return fmt.Errorf("recover callback_url {%s} should be an absolute url", chaoslogger.URL(config.Recover.CallbackURL))
+			}
+		}
+
+		prioritized := make(map[FailureType]bool)
+		for _, failureType := range config.Recover.TypePriority {
+			switch failureType {
+			case Docker, Service, CPU, Network:
+			default:
+				return fmt.Errorf("recover type_priority {%s} should be one of Docker, Service, CPU or Network", failureType)
+			}
+			if prioritized[failureType] {
+				return fmt.Errorf("recover type_priority {%s} should not be repeated", failureType)
+			}
+			prioritized[failureType] = true
+		}
+	}
+
+	if config.Grafana != nil {
+		if grafanaURL, err := url.Parse(config.Grafana.URL); err != nil || grafanaURL.Scheme == "" || grafanaURL.Host == "" {
+			return fmt.Errorf("grafana url {%s} should be an absolute url", chaoslogger.URL(config.Grafana.URL))
+		}
+	}
+
+	if config.Defaults != nil {
+		for failureType, duration := range config.Defaults.DurationByType {
+			switch failureType {
+			case Docker, Service, CPU, Network:
+			default:
+				return fmt.Errorf("defaults duration_by_type {%s} should be one of Docker, Service, CPU or Network", failureType)
+			}
+			if duration < 0 {
+				return fmt.Errorf("defaults duration_by_type of {%s} should not be negative", failureType)
+			}
+		}
+	}
+
+	for alias, canonical := range config.ActionAliases {
+		switch strings.ToLower(alias) {
+		case "start", "recover", "kill":
+			return fmt.Errorf("action_aliases {%s} should not be the name of an action", alias)
+		}
+		switch canonical {
+		case "start", "recover", "kill":
+		default:
+			return fmt.Errorf("action_aliases {%s} should be an alias of start, recover or kill", alias)
+		}
+	}
+
+	if config.Messages != nil && config.Messages.SuccessTemplate != "" {
+		if _, err := template.New("success").Parse(config.Messages.SuccessTemplate); err != nil {
+			return errors.Wrap(err, "could not parse success_template")
+		}
+	}
 	return nil
 }
 
-func validate(job *JobsFromConfig) error {
+func validate(job *JobsFromConfig, maxNameLength int) error {
 	if job.JobName == "" || job.FailureType == "" {
 		return errors.New("Every job should contain a job_name and type")
 	}
 
-	if job.FailureType == Docker || job.FailureType == Service {
+	switch job.FailureType {
+	case Docker, Service:
 		if job.ComponentName == "" {
 			return fmt.Errorf("failure type {%s} should have component_name", job.FailureType)
 		}
-	} else if job.FailureType == CPU || job.FailureType == Server || job.FailureType == Network {
+	case CPU, Server, Network:
 		if job.ComponentName != "" {
 			return fmt.Errorf("job {%s} should not have component_name", job.FailureType)
 		}
+	default:
+		return fmt.Errorf("unknown failure type {%s}", job.FailureType)
 	}
 
 	if strings.Contains(job.JobName, ",") {
 		return errors.New("The job name and the component name should not contain the unique operator \",\"")
 	}
 
+	for _, name := range []string{job.JobName, job.ComponentName} {
+		if len(name) > maxNameLength {
+			return fmt.Errorf("The name of length {%d} exceeds the max_name_length {%d}", len(name), maxNameLength)
+		}
+		if name != "" && !namePattern.MatchString(name) {
+			return fmt.Errorf("The name {%q} should contain only letters, digits, spaces and the characters _.:@/-", name)
+		}
+	}
+
+	if job.BotPort != "" {
+		if port, err := strconv.Atoi(job.BotPort); err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("job {%s} bot_port {%s} should be a port number", job.JobName, job.BotPort)
+		}
+	}
+
+	return nil
+}
+
+func (options *RestAPIOptions) validate() error {
+	if options.MaxConnections < 0 {
+		return fmt.Errorf("api_options max_connections {%d} should not be negative", options.MaxConnections)
+	}
+	for _, proxy := range options.TrustedProxies {
+		if _, err := parseNetwork(proxy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseNetwork parses a cidr, or an ip as the network that contains only that ip
+func parseNetwork(value string) (*net.IPNet, error) {
+	if _, network, err := net.ParseCIDR(value); err == nil {
+		return network, nil
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, fmt.Errorf("trusted proxy {%s} should be an ip or a cidr", value)
+	}
+	bits := 8 * net.IPv6len
+	if ip.To4() != nil {
+		ip = ip.To4()
+		bits = 8 * net.IPv4len
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+}
+
+func (window *MaintenanceWindow) validate() error {
+	if window.Schedule != "" {
+		if window.Duration <= 0 {
+			return fmt.Errorf("maintenance window with schedule {%s} should have a positive duration", window.Schedule)
+		}
+
+		schedule, err := cron.ParseStandard(window.Schedule)
+		if err != nil {
+			return errors.Wrap(err, fmt.Sprintf("could not parse maintenance window schedule {%s}", window.Schedule))
+		}
+		window.schedule = schedule
+
+		return nil
+	}
+
+	if window.Start.IsZero() || window.End.IsZero() || !window.Start.Before(window.End) {
+		return errors.New("Every maintenance window should contain either a schedule and duration or a start before the end")
+	}
+
 	return nil
 }
 
+// Contains returns true if the time provided falls inside the maintenance window
+func (window *MaintenanceWindow) Contains(t time.Time) bool {
+	if window.Schedule != "" {
+		schedule := window.schedule
+		if schedule == nil {
+			var err error
+			if schedule, err = cron.ParseStandard(window.Schedule); err != nil {
+				return false
+			}
+		}
+		return !schedule.Next(t.Add(-window.Duration)).After(t)
+	}
+
+	return !t.Before(window.Start) && t.Before(window.End)
+}
+
+// Allows returns true if the job can be injected while the maintenance window is open
+func (window *MaintenanceWindow) Allows(job string) bool {
+	for _, allowed := range window.AllowedJobs {
+		if allowed == job {
+			return true
+		}
+	}
+	return false
+}
+
 type Job struct {
-	ComponentName string
-	FailureType   FailureType
-	Target        []string
+	ComponentName   string
+	FailureType     FailureType
+	Target          []string
+	BotPort         string
+	DefaultDuration time.Duration
+	Policies        []*Policy
+	TargetLabels    map[string]Labels
+}
+
+// RecoverAfter returns the duration after which a failure of the job is recovered automatically, which is the
+// duration of the request in seconds, or the default duration of the job if the request omits it
+func (job *Job) RecoverAfter(durationSec int) time.Duration {
+	if durationSec > 0 {
+		return time.Duration(durationSec) * time.Second
+	}
+	if job == nil {
+		return 0
+	}
+	return job.DefaultDuration
+}
+
+// Address returns the address of the bot that the failures of the job on the target are injected by
+func (job *Job) Address(target string) string {
+	return BotAddress(target, job.BotPort)
+}
+
+// RequiresConfirmation returns true if injections on the target have to be confirmed, since it is a production target
+func (job *Job) RequiresConfirmation(target string) bool {
+	return job.TargetLabels[target].IsProduction()
+}
+
+// Allows returns true if all the policies of the job permit the action
+func (job *Job) Allows(action string) bool {
+	for _, policy := range job.Policies {
+		if !policy.Allows(action) {
+			return false
+		}
+	}
+	return true
 }
 
 func (config *Config) GetJobMap(loggers chaoslogger.Loggers) map[string]*Job {
@@ -136,6 +1037,22 @@ func (config *Config) GetJobMap(loggers chaoslogger.Loggers) map[string]*Job {
 		configJobs.addToJobsMap(jobs, loggers)
 	}
 
+	for _, policy := range config.Policies {
+		job, ok := jobs[policy.Job]
+		if !ok {
+			_ = level.Warn(loggers.OutLogger).Log("msg", fmt.Sprintf("The policy for job %s does not match any job", policy.Job))
+			continue
+		}
+		job.Policies = append(job.Policies, policy)
+	}
+
+	for _, job := range jobs {
+		job.TargetLabels = config.TargetLabels
+		if config.Defaults != nil {
+			job.DefaultDuration = config.Defaults.DurationByType[job.FailureType]
+		}
+	}
+
 	showRegisteredJobs(jobs, loggers)
 
 	return jobs
@@ -149,6 +1066,7 @@ func (cj *JobsFromConfig) addToJobsMap(jobs map[string]*Job, loggers chaoslogger
 			ComponentName: cj.ComponentName,
 			FailureType:   cj.FailureType,
 			Target:        cj.Targets,
+			BotPort:       cj.BotPort,
 		}
 	}
 }
@@ -1,9 +1,13 @@
 package config
 
 import (
+	"crypto/tls"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
 	"github.com/stretchr/testify/assert"
@@ -31,7 +35,10 @@ func TestShouldUnmarshalSimpleConfig(t *testing.T) {
 	assert.Equal(t, "cpu injection", config.JobsFromConfig[3].JobName, "the correct fourth job name from the file")
 	assert.Equal(t, "server injection", config.JobsFromConfig[4].JobName, "the correct fifth job name from the file")
 	assert.Equal(t, "network injection", config.JobsFromConfig[5].JobName, "the correct sixth job name from the file")
-	assert.Equal(t, "1234", config.Bots.PeerToken, "the peer token for the communication with the bots")
+	assert.Equal(t, "1234", config.Bots.PeerToken.Value(), "the peer token for the communication with the bots")
+	assert.Equal(t, 2, len(config.MaintenanceWindows))
+	assert.Equal(t, time.Date(2021, 4, 11, 6, 0, 0, 0, time.UTC), config.MaintenanceWindows[0].End)
+	assert.Equal(t, 8*time.Hour, config.MaintenanceWindows[1].Duration)
 }
 
 func TestShouldUnmarshalConfigWIthMissingDefaultValues(t *testing.T) {
@@ -60,7 +67,7 @@ func TestShouldErrorWhenCanNotFindConfigFile(t *testing.T) {
 func TestShouldErrorWhenCanNotUnmarshalFile(t *testing.T) {
 	_, err := GetConfig("test/unmarshalable_config.yml")
 	if err != nil {
-		assert.Equal(t, "could not unmarshal yml: yaml: unmarshal errors:\n  line 2: cannot unmarshal !!map into []*config.JobsFromConfig", err.Error())
+		assert.Equal(t, "config does not match schema: jobs should be of type {array}", err.Error())
 	} else {
 		t.Errorf("There should be an error because the file can not be unmarshaled")
 	}
@@ -140,3 +147,657 @@ func getLoggers() chaoslogger.Loggers {
 		ErrLogger: chaoslogger.New(allowLevel, os.Stderr),
 	}
 }
+
+func TestMaintenanceWindowContainsTime(t *testing.T) {
+	now := time.Date(2021, 4, 10, 12, 30, 0, 0, time.UTC)
+
+	dataItems := []struct {
+		message  string
+		window   *MaintenanceWindow
+		expected bool
+	}{
+		{
+			message:  "Should contain time inside a fixed time range",
+			window:   &MaintenanceWindow{Start: now.Add(-time.Hour), End: now.Add(time.Hour)},
+			expected: true,
+		},
+		{
+			message:  "Should not contain time after a fixed time range",
+			window:   &MaintenanceWindow{Start: now.Add(-2 * time.Hour), End: now.Add(-time.Hour)},
+			expected: false,
+		},
+		{
+			message:  "Should contain time inside a window opened by the schedule",
+			window:   &MaintenanceWindow{Schedule: "0 12 * * *", Duration: time.Hour},
+			expected: true,
+		},
+		{
+			message:  "Should not contain time after a window opened by the schedule has closed",
+			window:   &MaintenanceWindow{Schedule: "0 12 * * *", Duration: 10 * time.Minute},
+			expected: false,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			if err := dataItem.window.validate(); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, dataItem.expected, dataItem.window.Contains(now))
+		})
+	}
+}
+
+func TestShouldErrorForInvalidMaintenanceWindow(t *testing.T) {
+	dataItems := []struct {
+		message  string
+		window   *MaintenanceWindow
+		expected string
+	}{
+		{
+			message:  "Should error when schedule has no duration",
+			window:   &MaintenanceWindow{Schedule: "0 12 * * *"},
+			expected: "maintenance window with schedule {0 12 * * *} should have a positive duration",
+		},
+		{
+			message:  "Should error when schedule can not be parsed",
+			window:   &MaintenanceWindow{Schedule: "not a schedule", Duration: time.Hour},
+			expected: "could not parse maintenance window schedule {not a schedule}: expected exactly 5 fields, found 3: [not a schedule]",
+		},
+		{
+			message:  "Should error when start is after end",
+			window:   &MaintenanceWindow{Start: time.Now(), End: time.Now().Add(-time.Hour)},
+			expected: "Every maintenance window should contain either a schedule and duration or a start before the end",
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			config := &Config{MaintenanceWindows: []*MaintenanceWindow{dataItem.window}}
+			err := config.validate()
+			if err == nil {
+				t.Fatal("There should be an error because the maintenance window is invalid")
+			}
+			assert.Equal(t, dataItem.expected, err.Error())
+		})
+	}
+}
+
+func TestShouldErrorWhenCacheFailsClosedWithoutPersistence(t *testing.T) {
+	config := &Config{Cache: &Cache{FailClosed: true}}
+
+	err := config.validate()
+	if err == nil {
+		t.Fatal("There should be an error because fail closed requires a persistence path")
+	}
+	assert.Equal(t, "The cache can only fail closed when a persistence_path is set", err.Error())
+}
+
+func TestShouldErrorForInvalidSuccessTemplate(t *testing.T) {
+	config := &Config{Messages: &Messages{SuccessTemplate: "{{.Target"}}
+
+	err := config.validate()
+
+	assert.NotNil(t, err)
+}
+
+func TestShouldErrorForUnknownRecoverFailurePolicy(t *testing.T) {
+	config := &Config{Recover: &Recover{OnFailure: "retry"}}
+
+	err := config.validate()
+	if err == nil {
+		t.Fatal("There should be an error because the recover failure policy is unknown")
+	}
+	assert.Equal(t, "recover on_failure {retry} should be one of keep, drop or requeue", err.Error())
+}
+
+func TestShouldErrorForInvalidTrustedProxy(t *testing.T) {
+	config := &Config{APIOptions: &RestAPIOptions{TrustedProxies: []string{"10.0.0.0/8", "proxy.local"}}}
+
+	err := config.validate()
+	if err == nil {
+		t.Fatal("There should be an error because the trusted proxy is not an ip or a cidr")
+	}
+	assert.Equal(t, "trusted proxy {proxy.local} should be an ip or a cidr", err.Error())
+}
+
+func TestOverridesTakePrecedenceOverTheConfigFile(t *testing.T) {
+	config := &Config{
+		APIOptions: &RestAPIOptions{Port: "8090", Scheme: "https"},
+		Auth:       &Auth{Tokens: []*APIToken{{Name: "ci", Token: "file-token"}}},
+	}
+
+	config.Override(&Overrides{Port: "9090", AuthToken: "flag-token"})
+
+	assert.Equal(t, &RestAPIOptions{Port: "9090", Scheme: "https"}, config.APIOptions)
+	assert.Equal(t, []*APIToken{{Name: "command line", Token: "flag-token"}}, config.Auth.Tokens)
+}
+
+func TestEmptyOverridesKeepTheConfigFileValues(t *testing.T) {
+	config := &Config{
+		APIOptions: &RestAPIOptions{Port: "8090", Scheme: "https"},
+		Auth:       &Auth{Tokens: []*APIToken{{Name: "ci", Token: "file-token"}}},
+	}
+
+	config.Override(&Overrides{})
+
+	assert.Equal(t, "8090", config.APIOptions.Port)
+	assert.Equal(t, []*APIToken{{Name: "ci", Token: "file-token"}}, config.Auth.Tokens)
+}
+
+func TestShouldErrorForRelativeRecoverCallbackURL(t *testing.T) {
+	config := &Config{Recover: &Recover{CallbackURL: "/chaos/recovered"}}
+
+	err := config.validate()
+	if err == nil {
+		t.Fatal("There should be an error because the callback url is not absolute")
+	}
+	assert.Equal(t, "recover callback_url {/chaos/recovered} should be an absolute url", err.Error())
+}
+
+func TestShouldErrorForUnknownFailureType(t *testing.T) {
+	config := &Config{JobsFromConfig: []*JobsFromConfig{{JobName: "memory job", FailureType: "Memory", Targets: []string{"127.0.0.1"}}}}
+
+	err := config.validate()
+	if err == nil {
+		t.Fatal("There should be an error because the failure type is unknown")
+	}
+	assert.Equal(t, "unknown failure type {Memory}", err.Error())
+}
+
+func TestShouldErrorForNegativeMaxConnections(t *testing.T) {
+	config := &Config{APIOptions: &RestAPIOptions{MaxConnections: -1}}
+
+	err := config.validate()
+	if err == nil {
+		t.Fatal("There should be an error because the max connections are negative")
+	}
+	assert.Equal(t, "api_options max_connections {-1} should not be negative", err.Error())
+}
+
+func TestShouldErrorForInvalidBotPort(t *testing.T) {
+	config := &Config{JobsFromConfig: []*JobsFromConfig{{JobName: "cpu job", FailureType: CPU, BotPort: "http"}}}
+
+	err := config.validate()
+	if err == nil {
+		t.Fatal("There should be an error because the bot port is not a port number")
+	}
+	assert.Equal(t, "job {cpu job} bot_port {http} should be a port number", err.Error())
+}
+
+func TestShouldResolveTheBotAddressOfTheJob(t *testing.T) {
+	assert.Equal(t, "host1:8081", (&Job{}).Address("host1:8081"))
+	assert.Equal(t, "host1:8082", (&Job{BotPort: "8082"}).Address("host1:8081"))
+	assert.Equal(t, "host1:8082", (&Job{BotPort: "8082"}).Address("host1"))
+}
+
+func TestShouldApplyTheDefaultDurationOfTheTypeToJobs(t *testing.T) {
+	config := &Config{
+		JobsFromConfig: []*JobsFromConfig{
+			{JobName: "cpu job", FailureType: CPU, Targets: []string{"127.0.0.1"}},
+			{JobName: "server job", FailureType: Server, Targets: []string{"127.0.0.1"}},
+		},
+		Defaults: &Defaults{DurationByType: map[FailureType]time.Duration{CPU: time.Minute}},
+	}
+
+	jobs := config.GetJobMap(loggers)
+
+	assert.Equal(t, time.Minute, jobs["cpu job"].RecoverAfter(0))
+	assert.Equal(t, 30*time.Second, jobs["cpu job"].RecoverAfter(30), "the duration of the request should take precedence")
+	assert.Equal(t, time.Duration(0), jobs["server job"].RecoverAfter(0))
+}
+
+func TestShouldErrorForDefaultDurationOfServerFailures(t *testing.T) {
+	config := &Config{Defaults: &Defaults{DurationByType: map[FailureType]time.Duration{Server: time.Minute}}}
+
+	err := config.validate()
+	if err == nil {
+		t.Fatal("There should be an error because server failures can not be recovered")
+	}
+	assert.Equal(t, "defaults duration_by_type {Server} should be one of Docker, Service, CPU or Network", err.Error())
+}
+
+func TestShouldAttachPoliciesToJobs(t *testing.T) {
+	config := &Config{
+		JobsFromConfig: []*JobsFromConfig{
+			{JobName: "prod-db", FailureType: Service, ComponentName: "postgres", Targets: []string{"127.0.0.1"}},
+			{JobName: "staging-db", FailureType: Service, ComponentName: "postgres", Targets: []string{"127.0.0.2"}},
+		},
+		Policies: []*Policy{{Job: "prod-db", ForbiddenActions: []string{"kill"}}},
+	}
+
+	jobMap := config.GetJobMap(loggers)
+
+	assert.False(t, jobMap["prod-db"].Allows("kill"))
+	assert.True(t, jobMap["prod-db"].Allows("recover"))
+	assert.True(t, jobMap["staging-db"].Allows("kill"))
+}
+
+func TestShouldErrorForAPITokenWithoutName(t *testing.T) {
+	config := &Config{Auth: &Auth{Tokens: []*APIToken{{Token: "secret"}}}}
+
+	err := config.validate()
+	if err == nil {
+		t.Fatal("There should be an error because the api token has no name")
+	}
+	assert.Equal(t, "Every api token should contain a name and a token", err.Error())
+}
+
+func TestShouldReadPeerTokenFromFile(t *testing.T) {
+	config, err := GetConfig("test/peer_token_file_config.yml")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	assert.Equal(t, "abcd-1234", config.Bots.PeerToken.Value(), "the peer token should be read from the file without the trailing newline")
+}
+
+func TestShouldErrorWhenCanNotFindPeerTokenFile(t *testing.T) {
+	_, err := GetConfig("test/missing_peer_token_file_config.yml")
+	if err == nil {
+		t.Fatal("There should be an error because the peer token file does not exist")
+	}
+	assert.Equal(t, "could not read peer token file: open test/non_existent_peer_token: no such file or directory", err.Error())
+}
+
+func TestShouldReadTheCertificatesOfTheBotsAtStartup(t *testing.T) {
+	config, err := GetConfig("test/ca_cert_config.yml")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	assert.Equal(t, "test/certs/ca-cert.pem", config.Bots.CACert)
+}
+
+func TestShouldErrorForUnusableCertificatesOfTheBots(t *testing.T) {
+	dataItems := []struct {
+		message       string
+		file          string
+		expectedError string
+	}{
+		{
+			message:       "Should error for a public cert that can not be read",
+			file:          "test/missing_public_cert_config.yml",
+			expectedError: "could not read bots public_cert: open test/non_existent_cert.pem: no such file or directory",
+		},
+		{
+			message:       "Should error for a public cert that is not a pem certificate",
+			file:          "test/not_pem_public_cert_config.yml",
+			expectedError: "bots public_cert {test/peer_token} does not contain a pem certificate",
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			_, err := GetConfig(dataItem.file)
+
+			if assert.NotNil(t, err) {
+				assert.Equal(t, dataItem.expectedError, err.Error())
+			}
+		})
+	}
+}
+
+func TestShouldResolveTargetGroupsOfJobs(t *testing.T) {
+	config, err := GetConfig("test/target_groups_config.yml")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	jobMap := config.GetJobMap(loggers)
+
+	assert.Equal(t, []string{"127.0.0.1:8081", "127.0.0.2:8081"}, jobMap["nginx docker"].Target)
+	assert.Equal(t, []string{"127.0.0.3:8081", "127.0.0.1:8081", "127.0.0.2:8081"}, jobMap["web cpu"].Target,
+		"the targets of the group should be added once to the targets of the job")
+}
+
+func TestShouldErrorForUnknownTargetGroup(t *testing.T) {
+	_, err := GetConfig("test/unknown_target_group_config.yml")
+	if err == nil {
+		t.Fatal("There should be an error because the target group does not exist")
+	}
+	assert.Equal(t, "job {nginx docker} references unknown target group {db}", err.Error())
+}
+
+func TestShouldRequireConfirmationForProductionTargets(t *testing.T) {
+	config := &Config{
+		JobsFromConfig: []*JobsFromConfig{
+			{JobName: "web", FailureType: CPU, Targets: []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}},
+		},
+		TargetLabels: map[string]Labels{
+			"127.0.0.1": {"env": "prod"},
+			"127.0.0.2": {"env": "staging"},
+		},
+	}
+
+	jobMap := config.GetJobMap(loggers)
+
+	assert.True(t, jobMap["web"].RequiresConfirmation("127.0.0.1"))
+	assert.False(t, jobMap["web"].RequiresConfirmation("127.0.0.2"))
+	assert.False(t, jobMap["web"].RequiresConfirmation("127.0.0.3"))
+}
+
+func TestShouldErrorForRelativeGrafanaURL(t *testing.T) {
+	config := &Config{Grafana: &Grafana{URL: "grafana:3000/api"}}
+
+	err := config.validate()
+	if err == nil {
+		t.Fatal("There should be an error because the grafana url is not absolute")
+	}
+	assert.Equal(t, "grafana url {grafana:3000/api} should be an absolute url", err.Error())
+}
+
+func TestShouldErrorForHealthBiasOutOfRange(t *testing.T) {
+	config := &Config{RandomSelection: &RandomSelection{HealthBias: 1.5}}
+
+	err := config.validate()
+	if err == nil {
+		t.Fatal("There should be an error because the health bias is greater than 1")
+	}
+	assert.Equal(t, "random_selection health_bias {1.5} should be between -1 and 1", err.Error())
+}
+
+func TestShouldErrorForUnknownMetricsTargetLabel(t *testing.T) {
+	config := &Config{Metrics: &Metrics{TargetLabel: "hash"}}
+
+	err := config.validate()
+	if err == nil {
+		t.Fatal("There should be an error because the target label is unknown")
+	}
+	assert.Equal(t, "metrics target_label {hash} should be one of keep, drop or bucket", err.Error())
+}
+
+func TestShouldErrorForNegativeWebhookConcurrency(t *testing.T) {
+	config := &Config{Recover: &Recover{WebhookConcurrency: -1}}
+
+	err := config.validate()
+	if err == nil {
+		t.Fatal("There should be an error because the webhook concurrency is negative")
+	}
+	assert.Equal(t, "recover webhook_concurrency {-1} should not be negative", err.Error())
+}
+
+func TestShouldErrorForInvalidRecoverTypePriority(t *testing.T) {
+	dataItems := []struct {
+		message       string
+		typePriority  []FailureType
+		expectedError string
+	}{
+		{
+			message:       "Should error for a type that is not recovered",
+			typePriority:  []FailureType{Network, Server},
+			expectedError: "recover type_priority {Server} should be one of Docker, Service, CPU or Network",
+		},
+		{
+			message:       "Should error for a repeated type",
+			typePriority:  []FailureType{Network, Docker, Network},
+			expectedError: "recover type_priority {Network} should not be repeated",
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			config := &Config{Recover: &Recover{TypePriority: dataItem.typePriority}}
+
+			err := config.validate()
+			if err == nil {
+				t.Fatal("There should be an error because the type priority is invalid")
+			}
+			assert.Equal(t, dataItem.expectedError, err.Error())
+		})
+	}
+}
+
+func TestShouldErrorForUnknownLoadBalancingPolicy(t *testing.T) {
+	config := &Config{Bots: &Bots{LBPolicy: "least_request"}}
+
+	err := config.validate()
+	if err == nil {
+		t.Fatal("There should be an error because the load balancing policy is unknown")
+	}
+	assert.Equal(t, "bots lb_policy {least_request} should be one of pick_first or round_robin", err.Error())
+}
+
+func TestShouldErrorForInvalidBotsOptions(t *testing.T) {
+	dataItems := []struct {
+		message       string
+		bots          *Bots
+		expectedError string
+	}{
+		{
+			message:       "Should error for an unknown minimum TLS version",
+			bots:          &Bots{MinTLSVersion: "1.1"},
+			expectedError: "bots min_tls_version {1.1} should be one of 1.2 or 1.3",
+		},
+		{
+			message:       "Should error for an unknown cipher suite",
+			bots:          &Bots{CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_UNKNOWN"}},
+			expectedError: "bots cipher_suites {TLS_UNKNOWN} is not a supported TLS 1.2 cipher suite",
+		},
+		{
+			message:       "Should error for a negative backoff",
+			bots:          &Bots{Backoff: &Backoff{Initial: -time.Second}},
+			expectedError: "bots backoff failures, initial and max should not be negative",
+		},
+		{
+			message:       "Should error for a negative dial timeout",
+			bots:          &Bots{DialTimeout: -time.Second},
+			expectedError: "bots dial_timeout, keepalive_time and keepalive_timeout should not be negative",
+		},
+		{
+			message:       "Should error for a negative retry base delay",
+			bots:          &Bots{Retry: &Retry{MaxAttempts: 3, BaseDelay: -time.Second}},
+			expectedError: "bots retry max_attempts and base_delay should not be negative",
+		},
+		{
+			message:       "Should error for a negative max concurrent calls per target",
+			bots:          &Bots{MaxConcurrentPerTarget: -1},
+			expectedError: "bots max_concurrent_per_target {-1} should not be negative",
+		},
+		{
+			message:       "Should error for a TLS 1.3 cipher suite, since they are not configurable",
+			bots:          &Bots{CipherSuites: []string{"TLS_AES_128_GCM_SHA256"}},
+			expectedError: "bots cipher_suites {TLS_AES_128_GCM_SHA256} is not a supported TLS 1.2 cipher suite",
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			config := &Config{Bots: dataItem.bots}
+
+			err := config.validate()
+
+			if assert.NotNil(t, err) {
+				assert.Equal(t, dataItem.expectedError, err.Error())
+			}
+		})
+	}
+}
+
+func TestTLSOptionsOfBots(t *testing.T) {
+	bots := &Bots{MinTLSVersion: "1.3", CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384"}}
+
+	minVersion, err := bots.TLSMinVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipherSuites, err := bots.TLSCipherSuites()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, uint16(tls.VersionTLS13), minVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384}, cipherSuites)
+
+	var withoutBots *Bots
+	minVersion, _ = withoutBots.TLSMinVersion()
+	assert.Equal(t, uint16(tls.VersionTLS12), minVersion, "the minimum version should default to TLS 1.2")
+}
+
+func TestShouldErrorForInvalidJobNames(t *testing.T) {
+	dataItems := []struct {
+		message       string
+		job           *JobsFromConfig
+		limits        *Limits
+		expectedError string
+	}{
+		{
+			message:       "Should error for a job name longer than the default max length",
+			job:           &JobsFromConfig{JobName: strings.Repeat("a", 129), FailureType: CPU},
+			expectedError: "The name of length {129} exceeds the max_name_length {128}",
+		},
+		{
+			message:       "Should error for a component name longer than the configured max length",
+			job:           &JobsFromConfig{JobName: "docker job", FailureType: Docker, ComponentName: "zookeeper_1"},
+			limits:        &Limits{MaxNameLength: 10},
+			expectedError: "The name of length {11} exceeds the max_name_length {10}",
+		},
+		{
+			message:       "Should error for a job name with a new line",
+			job:           &JobsFromConfig{JobName: "cpu job\nlevel=error", FailureType: CPU},
+			expectedError: `The name {"cpu job\nlevel=error"} should contain only letters, digits, spaces and the characters _.:@/-`,
+		},
+		{
+			message:       "Should error for a component name with a quote",
+			job:           &JobsFromConfig{JobName: "service job", FailureType: Service, ComponentName: `zoo"keeper`},
+			expectedError: `The name {"zoo\"keeper"} should contain only letters, digits, spaces and the characters _.:@/-`,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			config := &Config{JobsFromConfig: []*JobsFromConfig{dataItem.job}, Limits: dataItem.limits}
+
+			err := config.validate()
+
+			if assert.NotNil(t, err) {
+				assert.Equal(t, dataItem.expectedError, err.Error())
+			}
+		})
+	}
+}
+
+func TestShouldAcceptJobNamesWithinTheLimits(t *testing.T) {
+	config := &Config{
+		JobsFromConfig: []*JobsFromConfig{
+			{JobName: "zookeeper docker", FailureType: Docker, ComponentName: "my_zoo-1.2"},
+			{JobName: "zookeeper service", FailureType: Service, ComponentName: "zookeeper@instance.service"},
+		},
+		Limits: &Limits{MaxNameLength: 26},
+	}
+
+	assert.NoError(t, config.validate())
+}
+
+func TestShouldErrorForMinHealthyTargetsWithoutHealthCheck(t *testing.T) {
+	config := &Config{Limits: &Limits{MinHealthyTargets: 2}, HealthCheck: &HealthCheck{Active: false}}
+
+	err := config.validate()
+	if err == nil {
+		t.Fatal("There should be an error because the healthy targets can not be known without the health check")
+	}
+	assert.Equal(t, "limits min_healthy_targets requires the health check to be active", err.Error())
+
+	config.HealthCheck.Active = true
+	assert.NoError(t, config.validate())
+}
+
+func TestShouldErrorForInvalidActionAliases(t *testing.T) {
+	dataItems := []struct {
+		message       string
+		aliases       ActionAliases
+		expectedError string
+	}{
+		{
+			message:       "Should error for an alias of an unknown action",
+			aliases:       ActionAliases{"enable": "begin"},
+			expectedError: "action_aliases {enable} should be an alias of start, recover or kill",
+		},
+		{
+			message:       "Should error for an alias that is the name of an action",
+			aliases:       ActionAliases{"Recover": "start"},
+			expectedError: "action_aliases {Recover} should not be the name of an action",
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			config := &Config{ActionAliases: dataItem.aliases}
+
+			err := config.validate()
+
+			if assert.NotNil(t, err) {
+				assert.Equal(t, dataItem.expectedError, err.Error())
+			}
+		})
+	}
+}
+
+func TestActionAliasesResolveToTheCanonicalAction(t *testing.T) {
+	aliases := ActionAliases{"enable": "start", "disable": "recover"}
+
+	assert.Equal(t, "start", aliases.Resolve("Enable"))
+	assert.Equal(t, "recover", aliases.Resolve("disable"))
+	assert.Equal(t, "start", aliases.Resolve("start"), "the canonical names should resolve to themselves")
+
+	var withoutAliases ActionAliases
+	assert.Equal(t, "kill", withoutAliases.Resolve("kill"))
+}
+
+func TestShouldErrorWhenConfigDoesNotMatchDefaultSchema(t *testing.T) {
+	_, err := GetConfig("test/schema_violating_config.yml")
+	if err == nil {
+		t.Fatal("There should be an error because the job has an unknown field")
+	}
+	assert.Equal(t, "config does not match schema: jobs[0].component is not a known field", err.Error())
+}
+
+func TestShouldValidateConfigAgainstSuppliedSchema(t *testing.T) {
+	config, err := GetConfigWithSchema("test/simple_config.yml", "test/bots_required_schema.json")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	assert.Equal(t, "1234", config.Bots.PeerToken.Value())
+
+	_, err = GetConfigWithSchema("test/missing_defaults_config.yml", "test/bots_required_schema.json")
+	if err == nil {
+		t.Fatal("There should be an error because the config has no bots")
+	}
+	assert.Equal(t, "config does not match schema: bots is required", err.Error())
+}
+
+func TestExampleConfigsMatchDefaultSchema(t *testing.T) {
+	schema, err := LoadSchema("")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, file := range []string{"example/example_simple_config.yml", "example/example_tls_config.yml"} {
+		raw, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		assert.NoError(t, schema.ValidateYAML(raw), file)
+	}
+}
+
+func TestShouldStripTheSchemeOfTargets(t *testing.T) {
+	config, err := GetConfig("test/scheme_targets_config.yml")
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	jobMap := config.GetJobMap(loggers)
+
+	assert.Equal(t, []string{"127.0.0.1:8081", "127.0.0.2:8081"}, jobMap["web cpu"].Target,
+		"the targets should be added once after the scheme is stripped")
+	assert.True(t, jobMap["web cpu"].RequiresConfirmation("127.0.0.1:8081"), "the labels should apply to the target without the scheme")
+}
+
+func TestShouldErrorForTargetWithPath(t *testing.T) {
+	_, err := GetConfig("test/target_with_path_config.yml")
+	if err == nil {
+		t.Fatal("There should be an error because the target has a path")
+	}
+	assert.Equal(t, "target {http://127.0.0.1:8081/bot} should be of the form host:port", err.Error())
+}
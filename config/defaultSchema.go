@@ -0,0 +1,237 @@
+package config
+
+// defaultSchema is the json schema that the config is validated against if no other schema is supplied.
+// It rejects unknown fields, values of the wrong type and unknown failure types, and leaves the
+// remaining checks to the validation of the unmarshaled config
+const defaultSchema = `{
+  "type": "object",
+  "additionalProperties": false,
+  "properties": {
+    "api_options": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "port": {"type": ["string", "integer"]},
+        "scheme": {"type": "string"},
+        "handler_timeout": {"type": ["string", "integer"]},
+        "read_only_port": {"type": ["string", "integer"]},
+        "idempotency_ttl": {"type": ["string", "integer"]},
+        "trusted_proxies": {"type": "array", "items": {"type": "string"}},
+        "max_connections": {"type": "integer", "minimum": 0},
+        "debug_errors": {"type": "boolean"},
+        "debug_endpoints": {"type": "boolean"}
+      }
+    },
+    "target_groups": {
+      "type": "object",
+      "additionalProperties": {"type": "array", "items": {"type": "string"}}
+    },
+    "jobs": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "properties": {
+          "job_name": {"type": "string"},
+          "type": {"type": "string", "enum": ["Docker", "Service", "CPU", "Server", "Network"]},
+          "component_name": {"type": "string"},
+          "targets": {"type": "array", "items": {"type": "string"}},
+          "target_groups": {"type": "array", "items": {"type": "string"}},
+          "bot_port": {"type": ["string", "integer"]}
+        }
+      }
+    },
+    "defaults": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "duration_by_type": {
+          "type": "object",
+          "additionalProperties": {"type": ["string", "integer"]}
+        }
+      }
+    },
+    "grafana": {
+      "type": "object",
+      "additionalProperties": false,
+      "required": ["url"],
+      "properties": {
+        "url": {"type": "string"},
+        "token": {"type": "string"},
+        "tags": {"type": "array", "items": {"type": "string"}}
+      }
+    },
+    "random_selection": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "health_bias": {"type": "number"}
+      }
+    },
+    "docker": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "allow_random": {"type": "boolean"}
+      }
+    },
+    "metrics": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "target_label": {"type": "string"},
+        "target_buckets": {"type": "integer"}
+      }
+    },
+    "limits": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "max_name_length": {"type": "integer"},
+        "min_healthy_targets": {"type": "integer"}
+      }
+    },
+    "action_aliases": {
+      "type": "object",
+      "additionalProperties": {"type": "string", "enum": ["start", "recover", "kill"]}
+    },
+    "global_labels": {
+      "type": "object",
+      "additionalProperties": {"type": "string"}
+    },
+    "target_labels": {
+      "type": "object",
+      "additionalProperties": {
+        "type": "object",
+        "additionalProperties": {"type": "string"}
+      }
+    },
+    "bots": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "ca_cert": {"type": "string"},
+        "public_cert": {"type": "string"},
+        "peer_token": {"type": ["string", "integer"]},
+        "peer_token_file": {"type": "string"},
+        "lb_policy": {"type": "string"},
+        "min_tls_version": {"type": "string"},
+        "cipher_suites": {"type": "array", "items": {"type": "string"}},
+        "backoff": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "failures": {"type": "integer"},
+            "initial": {"type": ["string", "integer"]},
+            "max": {"type": ["string", "integer"]}
+          }
+        },
+        "dial_timeout": {"type": ["string", "integer"]},
+        "keepalive_time": {"type": ["string", "integer"]},
+        "keepalive_timeout": {"type": ["string", "integer"]},
+        "retry": {
+          "type": "object",
+          "additionalProperties": false,
+          "properties": {
+            "max_attempts": {"type": "integer"},
+            "base_delay": {"type": ["string", "integer"]}
+          }
+        },
+        "max_concurrent_per_target": {"type": "integer"}
+      }
+    },
+    "health_check": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "active": {"type": "boolean"},
+        "report": {"type": "boolean"}
+      }
+    },
+    "cache": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "expiration": {"type": ["string", "integer"]},
+        "sweep_interval": {"type": ["string", "integer"]},
+        "auto_recover_interval": {"type": ["string", "integer"]},
+        "persistence_path": {"type": "string"},
+        "fail_closed": {"type": "boolean"}
+      }
+    },
+    "policies": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "properties": {
+          "job": {"type": "string"},
+          "allowed_actions": {"type": "array", "items": {"type": "string"}},
+          "forbidden_actions": {"type": "array", "items": {"type": "string"}}
+        }
+      }
+    },
+    "auth": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "tokens": {
+          "type": "array",
+          "items": {
+            "type": "object",
+            "additionalProperties": false,
+            "properties": {
+              "name": {"type": "string"},
+              "token": {"type": "string"}
+            }
+          }
+        }
+      }
+    },
+    "recover": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "on_failure": {"type": "string"},
+        "retry_interval": {"type": ["string", "integer"]},
+        "webhook_concurrency": {"type": "integer"},
+        "verify_health": {"type": "boolean"},
+        "callback_url": {"type": "string"},
+        "treat_unreachable_as_recovered": {"type": "boolean"},
+        "serial_per_target": {"type": "boolean"},
+        "type_priority": {"type": "array", "items": {"type": "string"}},
+        "echo_group_key": {"type": "boolean"}
+      }
+    },
+    "network_limits": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "max_limit": {"type": "integer", "minimum": 0},
+        "max_gap": {"type": "integer", "minimum": 0},
+        "max_jitter": {"type": "integer", "minimum": 0}
+      }
+    },
+    "messages": {
+      "type": "object",
+      "additionalProperties": false,
+      "properties": {
+        "success_template": {"type": "string"}
+      }
+    },
+    "maintenance_windows": {
+      "type": "array",
+      "items": {
+        "type": "object",
+        "additionalProperties": false,
+        "properties": {
+          "start": {"type": "string"},
+          "end": {"type": "string"},
+          "schedule": {"type": "string"},
+          "duration": {"type": ["string", "integer"]},
+          "allowed_jobs": {"type": "array", "items": {"type": "string"}}
+        }
+      }
+    }
+  }
+}`
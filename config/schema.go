@@ -0,0 +1,235 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Schema is the subset of json schema that is supported for the validation of the config:
+// type, properties, additionalProperties, required, items, enum and minimum
+type Schema struct {
+	Type                 schemaTypes        `json:"type,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *additional        `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+}
+
+// schemaTypes is the type of a schema, which is either a single type or a list of types
+type schemaTypes []string
+
+func (types *schemaTypes) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*types = schemaTypes{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(b, &multiple); err != nil {
+		return errors.New("type should be a string or an array of strings")
+	}
+	*types = multiple
+	return nil
+}
+
+// additional is the additionalProperties of a schema, which is either a boolean or the schema of the additional properties
+type additional struct {
+	allowed bool
+	schema  *Schema
+}
+
+func (a *additional) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &a.allowed); err == nil {
+		return nil
+	}
+	a.allowed = true
+	return json.Unmarshal(b, &a.schema)
+}
+
+// LoadSchema reads a json schema from the file, or returns the default schema if the file is empty
+func LoadSchema(file string) (*Schema, error) {
+	b := []byte(defaultSchema)
+	if file != "" {
+		var err error
+		if b, err = ioutil.ReadFile(file); err != nil {
+			return nil, errors.Wrap(err, "could not read schema")
+		}
+	}
+
+	schema := &Schema{}
+	if err := json.Unmarshal(b, schema); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal schema")
+	}
+	return schema, nil
+}
+
+// ValidateYAML validates the raw yaml against the schema. Null values are treated as missing,
+// since yaml keys without a value are used to leave a section empty
+func (schema *Schema) ValidateYAML(raw []byte) error {
+	var document interface{}
+	if err := yaml.Unmarshal(raw, &document); err != nil {
+		return errors.Wrap(err, "could not unmarshal yml")
+	}
+
+	if err := schema.validate("", normalize(document)); err != nil {
+		return errors.Wrap(err, "config does not match schema")
+	}
+	return nil
+}
+
+// normalize converts the maps decoded from yaml to maps with string keys, as they are decoded from json
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			m[fmt.Sprintf("%v", key)] = normalize(val)
+		}
+		return m
+	case []interface{}:
+		for i, val := range v {
+			v[i] = normalize(val)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+func (schema *Schema) validate(path string, value interface{}) error {
+	if schema == nil || value == nil {
+		return nil
+	}
+
+	if len(schema.Type) > 0 && !schema.Type.matches(value) {
+		return errors.New(fmt.Sprintf("%s should be of type {%s}", pathOrRoot(path), strings.Join(schema.Type, " or ")))
+	}
+
+	if len(schema.Enum) > 0 && !inEnum(schema.Enum, value) {
+		return errors.New(fmt.Sprintf("%s with value {%v} should be one of %v", pathOrRoot(path), value, schema.Enum))
+	}
+
+	if schema.Minimum != nil {
+		if number, ok := toNumber(value); ok && number < *schema.Minimum {
+			return errors.New(fmt.Sprintf("%s with value {%v} should not be less than %v", pathOrRoot(path), value, *schema.Minimum))
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return schema.validateObject(path, v)
+	case []interface{}:
+		for i, item := range v {
+			if err := schema.Items.validate(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (schema *Schema) validateObject(path string, object map[string]interface{}) error {
+	for _, required := range schema.Required {
+		if object[required] == nil {
+			return errors.New(fmt.Sprintf("%s is required", join(path, required)))
+		}
+	}
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		propertySchema, ok := schema.Properties[key]
+		if !ok && schema.AdditionalProperties != nil {
+			if !schema.AdditionalProperties.allowed {
+				return errors.New(fmt.Sprintf("%s is not a known field", join(path, key)))
+			}
+			propertySchema = schema.AdditionalProperties.schema
+		}
+		if err := propertySchema.validate(join(path, key), object[key]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (types schemaTypes) matches(value interface{}) bool {
+	for _, t := range types {
+		switch t {
+		case "object":
+			if _, ok := value.(map[string]interface{}); ok {
+				return true
+			}
+		case "array":
+			if _, ok := value.([]interface{}); ok {
+				return true
+			}
+		case "string":
+			if _, ok := value.(string); ok {
+				return true
+			}
+		case "boolean":
+			if _, ok := value.(bool); ok {
+				return true
+			}
+		case "integer":
+			switch value.(type) {
+			case int, int64, uint64:
+				return true
+			}
+		case "number":
+			if _, ok := toNumber(value); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func toNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprintf("%v", e) == fmt.Sprintf("%v", value) {
+			return true
+		}
+	}
+	return false
+}
+
+func join(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "config"
+	}
+	return path
+}
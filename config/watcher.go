@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/go-kit/kit/log/level"
+)
+
+// Watcher reloads the config from its file, e.g. on SIGHUP, so that jobs and targets can be added or removed
+// without restarting the master. A config that does not validate is not applied, and the running config is kept
+type Watcher struct {
+	file       string
+	schemaFile string
+	overrides  *Overrides
+	mu         sync.RWMutex
+	config     *Config
+	loggers    chaoslogger.Loggers
+}
+
+// JobsDiff contains the names of the jobs that were added, removed or changed by a reload
+type JobsDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// NewWatcher returns the watcher of the config file, with the config that is running. The overrides are
+// applied to every reloaded config, as they are to the running one
+func NewWatcher(file string, schemaFile string, overrides *Overrides, config *Config, loggers chaoslogger.Loggers) *Watcher {
+	return &Watcher{
+		file:       file,
+		schemaFile: schemaFile,
+		overrides:  overrides,
+		config:     config,
+		loggers:    loggers,
+	}
+}
+
+// Config returns the running config
+func (w *Watcher) Config() *Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.config
+}
+
+// Reload reads and validates the config file again, and returns the new config with the jobs that changed.
+// The running config is replaced only if the new config is valid
+func (w *Watcher) Reload() (*Config, *JobsDiff, error) {
+	config, err := GetConfigWithSchema(w.file, w.schemaFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	config.Override(w.overrides)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	diff := DiffJobs(w.config.JobsFromConfig, config.JobsFromConfig)
+	w.config = config
+
+	return config, diff, nil
+}
+
+// Watch reloads the config on every signal, and applies every valid config, until the signals are closed.
+// A config that can not be reloaded is logged and not applied
+func (w *Watcher) Watch(signals <-chan os.Signal, apply func(config *Config)) {
+	for range signals {
+		config, diff, err := w.Reload()
+		if err != nil {
+			_ = level.Error(w.loggers.ErrLogger).Log("msg", "Could not reload the config, the running config is kept", "file", w.file, "err", err)
+			continue
+		}
+
+		_ = level.Info(w.loggers.OutLogger).Log("msg", "reloaded the config", "file", w.file,
+			"added", fmt.Sprint(diff.Added), "removed", fmt.Sprint(diff.Removed), "changed", fmt.Sprint(diff.Changed))
+		apply(config)
+	}
+}
+
+// DiffJobs returns the names of the jobs that are only in the new jobs, only in the old jobs,
+// or in both with a different definition
+func DiffJobs(oldJobs []*JobsFromConfig, newJobs []*JobsFromConfig) *JobsDiff {
+	oldByName := make(map[string]*JobsFromConfig, len(oldJobs))
+	for _, job := range oldJobs {
+		oldByName[job.JobName] = job
+	}
+
+	diff := &JobsDiff{}
+	newByName := make(map[string]*JobsFromConfig, len(newJobs))
+	for _, job := range newJobs {
+		newByName[job.JobName] = job
+		oldJob, ok := oldByName[job.JobName]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, job.JobName)
+		case !reflect.DeepEqual(oldJob, job):
+			diff.Changed = append(diff.Changed, job.JobName)
+		}
+	}
+
+	for _, job := range oldJobs {
+		if _, ok := newByName[job.JobName]; !ok {
+			diff.Removed = append(diff.Removed, job.JobName)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const watchedConfig = `
+api_options:
+  port: "8090"
+
+jobs:
+  - job_name: web cpu
+    type: CPU
+    targets: ['127.0.0.1:8081']
+  - job_name: db cpu
+    type: CPU
+    targets: ['127.0.0.2:8081']
+`
+
+func TestWatcherReloadsTheJobsOfTheConfig(t *testing.T) {
+	dataItems := []struct {
+		message        string
+		reloadedConfig string
+		expectedDiff   *JobsDiff
+	}{
+		{
+			message: "Should add, remove and change the jobs of the reloaded config",
+			reloadedConfig: `
+jobs:
+  - job_name: web cpu
+    type: CPU
+    targets: ['127.0.0.1:8081', '127.0.0.3:8081']
+  - job_name: cache cpu
+    type: CPU
+    targets: ['127.0.0.4:8081']
+`,
+			expectedDiff: &JobsDiff{Added: []string{"cache cpu"}, Removed: []string{"db cpu"}, Changed: []string{"web cpu"}},
+		},
+		{
+			message:        "Should not report any job if the jobs did not change",
+			reloadedConfig: watchedConfig,
+			expectedDiff:   &JobsDiff{},
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			file, cleanup := writeWatchedConfig(t, watchedConfig)
+			defer cleanup()
+			running, err := GetConfig(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			watcher := NewWatcher(file, "", &Overrides{Port: "9090"}, running, loggers)
+
+			if err = ioutil.WriteFile(file, []byte(dataItem.reloadedConfig), 0600); err != nil {
+				t.Fatal(err)
+			}
+			reloaded, diff, err := watcher.Reload()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, dataItem.expectedDiff, diff)
+			assert.Equal(t, reloaded, watcher.Config())
+			assert.Equal(t, "9090", reloaded.APIOptions.Port, "the overrides should be applied to the reloaded config")
+		})
+	}
+}
+
+func TestWatcherKeepsTheRunningConfigIfTheReloadedConfigIsInvalid(t *testing.T) {
+	file, cleanup := writeWatchedConfig(t, watchedConfig)
+	defer cleanup()
+	running, err := GetConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher := NewWatcher(file, "", &Overrides{}, running, loggers)
+
+	if err = ioutil.WriteFile(file, []byte("jobs:\n  - job_name: web cpu\n    type: unknown\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	applied := make(chan *Config, 1)
+	signals := make(chan os.Signal, 1)
+	signals <- syscall.SIGHUP
+	close(signals)
+	watcher.Watch(signals, func(config *Config) {
+		applied <- config
+	})
+
+	assert.Equal(t, 0, len(applied), "an invalid config should not be applied")
+	assert.Equal(t, running, watcher.Config())
+}
+
+func TestWatcherAppliesTheReloadedConfigOnSignal(t *testing.T) {
+	file, cleanup := writeWatchedConfig(t, watchedConfig)
+	defer cleanup()
+	running, err := GetConfig(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher := NewWatcher(file, "", &Overrides{}, running, loggers)
+
+	applied := make(chan *Config, 1)
+	signals := make(chan os.Signal, 1)
+	signals <- syscall.SIGHUP
+	close(signals)
+	watcher.Watch(signals, func(config *Config) {
+		applied <- config
+	})
+
+	assert.Equal(t, 1, len(applied))
+	assert.Equal(t, watcher.Config(), <-applied)
+}
+
+func writeWatchedConfig(t *testing.T, content string) (string, func()) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, "config.yml")
+	if err = ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file, func() { _ = os.RemoveAll(dir) }
+}
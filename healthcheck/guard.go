@@ -0,0 +1,92 @@
+package healthcheck
+
+import (
+	"fmt"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/gocache"
+)
+
+// Guard rejects the injections on several or random targets of a job that would leave fewer than the minimum
+// healthy targets. A target is healthy if its bot was serving in the latest health check, so targets that have
+// not been health-checked yet are not counted, and neither are the targets of the job that already have a failure
+// in the cache, since they are not healthy until they are recovered. A nil Guard allows every injection
+type Guard struct {
+	healthChecker *HealthChecker
+	cache         *gocache.Cache
+	minHealthy    int
+}
+
+// GuardError is returned for an injection that would leave fewer than the minimum healthy targets of the job
+type GuardError struct {
+	Job        string
+	Selected   int
+	Remaining  int
+	MinHealthy int
+}
+
+func (e *GuardError) Error() string {
+	return fmt.Sprintf("Injecting on {%d} targets of job {%s} would leave {%d} healthy targets, fewer than the minimum of {%d}",
+		e.Selected, e.Job, e.Remaining, e.MinHealthy)
+}
+
+// NewGuard returns the guard of the minimum healthy targets of the limits, or nil if the minimum is not set.
+// The targets that have a failure in the failures cache are not counted as healthy
+func NewGuard(healthChecker *HealthChecker, failures *gocache.Cache, limits *config.Limits) *Guard {
+	if healthChecker == nil || limits == nil || limits.MinHealthyTargets == 0 {
+		return nil
+	}
+	return &Guard{healthChecker: healthChecker, cache: failures, minHealthy: limits.MinHealthyTargets}
+}
+
+// Check returns a GuardError if fewer than the minimum targets of the job would be healthy after the injection
+// on the selected targets, according to the latest health checks
+func (g *Guard) Check(jobName string, job *config.Job, selected []string) error {
+	if g == nil {
+		return nil
+	}
+
+	healthy := make([]string, 0, len(job.Target))
+	for _, target := range job.Target {
+		if g.healthChecker.Serving(job.Address(target)) {
+			healthy = append(healthy, target)
+		}
+	}
+	return g.CheckHealthy(jobName, healthy, selected)
+}
+
+// CheckHealthy returns a GuardError if fewer than the minimum of the healthy targets of the job would remain
+// healthy after the injection on the selected targets. The targets of the job that already have a failure in the
+// cache are not counted as healthy
+func (g *Guard) CheckHealthy(jobName string, healthy []string, selected []string) error {
+	if g == nil {
+		return nil
+	}
+
+	isSelected := make(map[string]bool, len(selected))
+	for _, target := range selected {
+		isSelected[target] = true
+	}
+
+	remaining := 0
+	for _, target := range healthy {
+		if !isSelected[target] && !g.failed(jobName, target) {
+			remaining++
+		}
+	}
+
+	if remaining < g.minHealthy {
+		return &GuardError{Job: jobName, Selected: len(selected), Remaining: remaining, MinHealthy: g.minHealthy}
+	}
+	return nil
+}
+
+// failed returns true if the target of the job already has a failure in the cache
+func (g *Guard) failed(jobName string, target string) bool {
+	if g.cache == nil {
+		return false
+	}
+	_, ok := g.cache.Get(cache.Key{Job: jobName, Target: target})
+	return ok
+}
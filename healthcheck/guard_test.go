@@ -0,0 +1,93 @@
+package healthcheck
+
+import (
+	"testing"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuardOfTheMinHealthyTargets(t *testing.T) {
+	healthChecker := &HealthChecker{DetailsMap: map[string]*Details{
+		"127.0.0.1": {Status: v1.HealthCheckResponse_SERVING},
+		"127.0.0.2": {Status: v1.HealthCheckResponse_SERVING},
+		"127.0.0.3": {Status: v1.HealthCheckResponse_NOT_SERVING},
+		"127.0.0.4": {Status: v1.HealthCheckResponse_UNKNOWN},
+	}}
+	job := &config.Job{Target: []string{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.4"}}
+
+	dataItems := []struct {
+		message       string
+		minHealthy    int
+		selected      []string
+		expectedError string
+	}{
+		{
+			message:    "Should allow an injection that leaves the min healthy targets",
+			minHealthy: 1,
+			selected:   []string{"127.0.0.1"},
+		},
+		{
+			message:    "Should allow an injection on the unhealthy targets",
+			minHealthy: 2,
+			selected:   []string{"127.0.0.3", "127.0.0.4"},
+		},
+		{
+			message:       "Should reject an injection that leaves fewer than the min healthy targets",
+			minHealthy:    2,
+			selected:      []string{"127.0.0.1"},
+			expectedError: "Injecting on {1} targets of job {job name} would leave {1} healthy targets, fewer than the minimum of {2}",
+		},
+		{
+			message:       "Should reject an injection on all the targets",
+			minHealthy:    1,
+			selected:      job.Target,
+			expectedError: "Injecting on {4} targets of job {job name} would leave {0} healthy targets, fewer than the minimum of {1}",
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			guard := NewGuard(healthChecker, nil, &config.Limits{MinHealthyTargets: dataItem.minHealthy})
+
+			err := guard.Check("job name", job, dataItem.selected)
+
+			if dataItem.expectedError == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equal(t, dataItem.expectedError, err.Error())
+			}
+		})
+	}
+}
+
+func TestGuardDoesNotCountTheTargetsThatAlreadyHaveAFailure(t *testing.T) {
+	healthChecker := &HealthChecker{DetailsMap: map[string]*Details{
+		"127.0.0.1": {Status: v1.HealthCheckResponse_SERVING},
+		"127.0.0.2": {Status: v1.HealthCheckResponse_SERVING},
+		"127.0.0.3": {Status: v1.HealthCheckResponse_SERVING},
+	}}
+	job := &config.Job{Target: []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}}
+	failures := gocache.New(0)
+	failures.Set(cache.Key{Job: "job name", Target: "127.0.0.2"}, nil)
+	failures.Set(cache.Key{Job: "other job", Target: "127.0.0.3"}, nil)
+
+	guard := NewGuard(healthChecker, failures, &config.Limits{MinHealthyTargets: 2})
+
+	err := guard.Check("job name", job, []string{"127.0.0.1"})
+	if assert.Error(t, err) {
+		assert.Equal(t, "Injecting on {1} targets of job {job name} would leave {1} healthy targets, fewer than the minimum of {2}", err.Error())
+	}
+	assert.NoError(t, guard.Check("job name", job, []string{"127.0.0.2"}), "the injection on a target that already has a failure should not leave fewer healthy targets")
+}
+
+func TestGuardIsNilWithoutTheMinHealthyTargets(t *testing.T) {
+	healthChecker := &HealthChecker{DetailsMap: map[string]*Details{}}
+
+	guard := NewGuard(healthChecker, nil, &config.Limits{MaxNameLength: 64})
+	assert.Nil(t, guard)
+	assert.NoError(t, guard.Check("job name", &config.Job{Target: []string{"127.0.0.1"}}, []string{"127.0.0.1"}))
+}
@@ -3,23 +3,31 @@ package healthcheck
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
 
 	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
 	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
 	"github.com/robfig/cron/v3"
 )
 
+// historySize is the number of the latest health checks of every target that its score is computed from
+const historySize = 10
+
 type HealthChecker struct {
 	DetailsMap map[string]*Details
+	mu         sync.RWMutex
 	loggers    chaoslogger.Loggers
 }
 
 type Details struct {
 	Status     v1.HealthCheckResponse_ServingStatus
 	connection network.Connection
+	// history contains whether the bot was serving in every one of the latest health checks, the oldest first
+	history []bool
 }
 
 func Register(connections *network.Connections, loggers chaoslogger.Loggers) *HealthChecker {
@@ -34,34 +42,20 @@ func Register(connections *network.Connections, loggers chaoslogger.Loggers) *He
 	return healthChecker
 }
 
+// Start health-checks the targets once before it returns, so that the targets are not unknown to the guard
+// after the start, and then every minute
 func (hch *HealthChecker) Start(report bool) {
+	hch.checkAll()
+
 	c := cron.New()
 	id, err := c.AddFunc("@every 1m", func() {
-		for target, details := range hch.DetailsMap {
-			client, err := details.connection.GetHealthClient()
-			if err != nil {
-				_ = level.Error(hch.loggers.ErrLogger).Log(
-					"msg", fmt.Sprintf("Can not get healthcheck connection for target {%s}", target),
-					"err", err)
-				continue
-			}
-
-			resp, err := client.Check(context.Background(), &v1.HealthCheckRequest{})
-			if err != nil {
-				_ = level.Error(hch.loggers.ErrLogger).Log(
-					"msg", fmt.Sprintf("Failed to get valid response when health-checking target %s", target),
-					"err", err)
-				hch.DetailsMap[target].Status = v1.HealthCheckResponse_NOT_SERVING
-			} else {
-				hch.DetailsMap[target].Status = resp.Status
-			}
-		}
+		hch.checkAll()
 
 		_ = level.Debug(hch.loggers.OutLogger).Log("msg", "checking status of bots")
 
 		if report {
-			for target, details := range hch.DetailsMap {
-				_ = level.Info(hch.loggers.OutLogger).Log("msg", fmt.Sprintf("Status of bot %s is %s", target, details.Status))
+			for target, status := range hch.Statuses() {
+				_ = level.Info(hch.loggers.OutLogger).Log("msg", fmt.Sprintf("Status of bot %s is %s", target, status))
 			}
 		}
 	})
@@ -74,3 +68,151 @@ func (hch *HealthChecker) Start(report bool) {
 
 	c.Start()
 }
+
+// Reload health-checks the targets of the reloaded connections. The history of the targets that are still in the
+// connections is kept, the new targets are checked at once, and the targets that were removed are no longer checked
+func (hch *HealthChecker) Reload(connections *network.Connections) {
+	if hch == nil {
+		return
+	}
+
+	added := make(map[string]network.Connection)
+	hch.mu.Lock()
+	for target := range hch.DetailsMap {
+		if _, ok := connections.Pool[target]; !ok {
+			delete(hch.DetailsMap, target)
+		}
+	}
+	for target, connection := range connections.Pool {
+		if details, ok := hch.DetailsMap[target]; ok {
+			details.connection = connection
+			continue
+		}
+		hch.DetailsMap[target] = &Details{Status: v1.HealthCheckResponse_UNKNOWN, connection: connection}
+		added[target] = connection
+	}
+	hch.mu.Unlock()
+
+	for target, connection := range added {
+		hch.check(target, connection)
+	}
+}
+
+// checkAll health-checks all the targets concurrently, so that the targets that can not be reached
+// delay the checks by at most the dial timeout
+func (hch *HealthChecker) checkAll() {
+	var wg sync.WaitGroup
+	for target, connection := range hch.connections() {
+		wg.Add(1)
+		go func(target string, connection network.Connection) {
+			defer wg.Done()
+			hch.check(target, connection)
+		}(target, connection)
+	}
+	wg.Wait()
+}
+
+// connections returns the connections of the targets that are health-checked
+func (hch *HealthChecker) connections() map[string]network.Connection {
+	hch.mu.RLock()
+	defer hch.mu.RUnlock()
+
+	connections := make(map[string]network.Connection, len(hch.DetailsMap))
+	for target, details := range hch.DetailsMap {
+		connections[target] = details.connection
+	}
+	return connections
+}
+
+// check health-checks the bot of the target and records its status. A status that could not be determined is not recorded
+func (hch *HealthChecker) check(target string, connection network.Connection) {
+	status, err := Check(context.Background(), connection)
+	if err != nil {
+		_ = level.Error(hch.loggers.ErrLogger).Log(
+			"msg", fmt.Sprintf("Failed to health-check target {%s}", target),
+			"err", err)
+		if status == v1.HealthCheckResponse_UNKNOWN {
+			return
+		}
+	}
+	hch.mu.Lock()
+	if details, ok := hch.DetailsMap[target]; ok {
+		details.Status = status
+	}
+	hch.mu.Unlock()
+	hch.record(target, status == v1.HealthCheckResponse_SERVING)
+}
+
+// Statuses returns the status of the bot of every target in the latest health check
+func (hch *HealthChecker) Statuses() map[string]v1.HealthCheckResponse_ServingStatus {
+	hch.mu.RLock()
+	defer hch.mu.RUnlock()
+
+	statuses := make(map[string]v1.HealthCheckResponse_ServingStatus, len(hch.DetailsMap))
+	for target, details := range hch.DetailsMap {
+		statuses[target] = details.Status
+	}
+	return statuses
+}
+
+// record adds the result of a health check to the history of the target, keeping only the latest checks
+func (hch *HealthChecker) record(target string, serving bool) {
+	hch.mu.Lock()
+	defer hch.mu.Unlock()
+
+	details, ok := hch.DetailsMap[target]
+	if !ok {
+		return
+	}
+	details.history = append(details.history, serving)
+	if len(details.history) > historySize {
+		details.history = details.history[len(details.history)-historySize:]
+	}
+}
+
+// Score returns the fraction of the latest health checks of the target in which its bot was serving.
+// It returns false if the target has not been health-checked yet
+func (hch *HealthChecker) Score(target string) (float64, bool) {
+	if hch == nil {
+		return 0, false
+	}
+
+	hch.mu.RLock()
+	defer hch.mu.RUnlock()
+
+	details, ok := hch.DetailsMap[target]
+	if !ok || len(details.history) == 0 {
+		return 0, false
+	}
+	serving := 0
+	for _, ok := range details.history {
+		if ok {
+			serving++
+		}
+	}
+	return float64(serving) / float64(len(details.history)), true
+}
+
+// Serving returns true if the bot of the target was serving in the latest health check
+func (hch *HealthChecker) Serving(target string) bool {
+	hch.mu.RLock()
+	defer hch.mu.RUnlock()
+
+	details, ok := hch.DetailsMap[target]
+	return ok && details.Status == v1.HealthCheckResponse_SERVING
+}
+
+// Check returns the serving status of the bot of the connection. If the health client can not be created
+// the status is UNKNOWN, and if the bot does not respond to the health check the status is NOT_SERVING
+func Check(ctx context.Context, connection network.Connection) (v1.HealthCheckResponse_ServingStatus, error) {
+	client, err := connection.GetHealthClient()
+	if err != nil {
+		return v1.HealthCheckResponse_UNKNOWN, errors.Wrap(err, "Can not get healthcheck connection")
+	}
+
+	resp, err := client.Check(ctx, &v1.HealthCheckRequest{})
+	if err != nil {
+		return v1.HealthCheckResponse_NOT_SERVING, errors.Wrap(err, "Failed to get valid response when health-checking")
+	}
+	return resp.Status, nil
+}
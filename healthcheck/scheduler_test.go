@@ -0,0 +1,62 @@
+package healthcheck
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var loggers = createLoggers("info")
+
+func TestScoreOfTheLatestHealthChecks(t *testing.T) {
+	healthChecker := &HealthChecker{DetailsMap: map[string]*Details{"127.0.0.1": {}}}
+
+	_, ok := healthChecker.Score("127.0.0.1")
+	assert.False(t, ok, "a target that was not health-checked should not have a score")
+
+	healthChecker.record("127.0.0.1", false)
+	for i := 0; i < historySize; i++ {
+		healthChecker.record("127.0.0.1", i%2 == 0)
+	}
+
+	score, ok := healthChecker.Score("127.0.0.1")
+	assert.True(t, ok)
+	assert.Equal(t, 0.5, score, "only the latest health checks should be scored")
+
+	_, ok = healthChecker.Score("127.0.0.2")
+	assert.False(t, ok, "an unknown target should not have a score")
+}
+
+func TestStartHealthChecksTheTargetsBeforeItReturns(t *testing.T) {
+	connections := &network.Connections{Pool: map[string]network.Connection{
+		"127.0.0.1:8081": &network.MockConnection{Health: v1.HealthCheckResponse_SERVING},
+		"127.0.0.2:8081": &network.MockConnection{Health: v1.HealthCheckResponse_NOT_SERVING},
+	}}
+	healthChecker := Register(connections, loggers)
+
+	healthChecker.Start(false)
+
+	assert.True(t, healthChecker.Serving("127.0.0.1:8081"), "the targets should be checked at the start")
+	assert.False(t, healthChecker.Serving("127.0.0.2:8081"))
+	score, ok := healthChecker.Score("127.0.0.2:8081")
+	assert.True(t, ok)
+	assert.Equal(t, 0.0, score)
+}
+
+func createLoggers(debugLevel string) chaoslogger.Loggers {
+	allowLevel := &chaoslogger.AllowedLevel{}
+	if err := allowLevel.Set(debugLevel); err != nil {
+		fmt.Printf("%v", err)
+	}
+
+	return chaoslogger.Loggers{
+		OutLogger: chaoslogger.New(allowLevel, os.Stdout),
+		ErrLogger: chaoslogger.New(allowLevel, os.Stderr),
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/SotirisAlfonsos/chaos-master/config"
 	_ "github.com/SotirisAlfonsos/chaos-master/docs"
@@ -22,16 +24,21 @@ import (
 // @BasePath /chaos/api/v1
 func main() {
 	configFile := flag.String("config.file", "", "the file that contains the configuration for the chaos master")
+	schemaFile := flag.String("config.schema", "", "the json schema file that the configuration is validated against, instead of the default schema")
 	debugLevel := flag.String("debug.level", "info", "the debug level for the chaos master")
+	port := flag.String("api.port", "", "the port of the api, overrides the port of the configuration")
+	authToken := flag.String("auth.token", "", "the only bearer token accepted by the api, overrides the tokens of the configuration")
 	flag.Parse()
 
 	loggers := createLoggers(*debugLevel)
 
-	conf, err := config.GetConfig(*configFile)
+	conf, err := config.GetConfigWithSchema(*configFile, *schemaFile)
 	if err != nil {
 		_ = level.Error(loggers.ErrLogger).Log("err", err)
 		os.Exit(1)
 	}
+	overrides := &config.Overrides{Port: *port, AuthToken: *authToken}
+	conf.Override(overrides)
 
 	connections := network.GetConnectionPool(conf, loggers)
 	jobMap := conf.GetJobMap(loggers)
@@ -39,11 +46,20 @@ func main() {
 	var healthChecker *healthcheck.HealthChecker
 
 	if conf.HealthCheck.Active {
-		healthChecker := healthcheck.Register(connections, loggers)
+		healthChecker = healthcheck.Register(connections, loggers)
 		healthChecker.Start(conf.HealthCheck.Report)
 	}
-	options := api.NewAPIOptions(conf.APIOptions, jobMap, connections, loggers)
+	options := api.NewAPIOptions(conf, jobMap, connections, loggers)
 	restAPI := api.NewRestAPI(options, healthChecker)
+
+	reloads := make(chan os.Signal, 1)
+	signal.Notify(reloads, syscall.SIGHUP)
+	watcher := config.NewWatcher(*configFile, *schemaFile, overrides, conf, loggers)
+	go watcher.Watch(reloads, func(conf *config.Config) {
+		connections = connections.Reload(conf, restAPI.InUse(), loggers)
+		restAPI.Reload(conf, conf.GetJobMap(loggers), connections)
+	})
+
 	restAPI.RunAPIController()
 }
 
@@ -56,5 +72,6 @@ func createLoggers(debugLevel string) chaoslogger.Loggers {
 	return chaoslogger.Loggers{
 		OutLogger: chaoslogger.New(allowLevel, os.Stdout),
 		ErrLogger: chaoslogger.New(allowLevel, os.Stderr),
+		Level:     allowLevel,
 	}
 }
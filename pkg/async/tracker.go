@@ -0,0 +1,41 @@
+package async
+
+import (
+	"context"
+	"sync"
+)
+
+// Tracker keeps track of the asynchronous operations that should complete before the master exits
+type Tracker struct {
+	wg sync.WaitGroup
+}
+
+func NewTracker() *Tracker {
+	return &Tracker{}
+}
+
+// Go runs the operation in a new goroutine and tracks it until it returns
+func (t *Tracker) Go(operation func()) {
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		operation()
+	}()
+}
+
+// Wait blocks until all tracked operations have completed or the context is done.
+// It returns the error of the context if the operations did not complete in time
+func (t *Tracker) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
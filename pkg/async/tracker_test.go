@@ -0,0 +1,41 @@
+package async
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWaitReturnsWhenOperationsComplete(t *testing.T) {
+	tracker := NewTracker()
+	completed := make(chan struct{})
+	tracker.Go(func() {
+		time.Sleep(20 * time.Millisecond)
+		close(completed)
+	})
+
+	err := tracker.Wait(context.Background())
+
+	assert.Nil(t, err)
+	select {
+	case <-completed:
+	default:
+		t.Fatal("Wait should return only after the operation completed")
+	}
+}
+
+func TestWaitReturnsErrorWhenContextIsDone(t *testing.T) {
+	tracker := NewTracker()
+	release := make(chan struct{})
+	defer close(release)
+	tracker.Go(func() {
+		<-release
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	assert.Equal(t, context.DeadlineExceeded, tracker.Wait(ctx))
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+)
+
+// Injection contains the details of a running failure that are needed to export it, and to
+// create its recovery again from the job, e.g. the device of a network failure in the metadata.
+// Inject repeats the injection, and is nil if the injection can not be repeated, e.g. after an import
+type Injection struct {
+	StartsAt time.Time
+	Labels   map[string]string
+	Metadata map[string]string
+	Inject   func() (*v1.StatusResponse, error)
+}
+
+// Injections keeps the latest injection of every key of the cache, and the time at which it is due to be
+// recovered automatically
+type Injections struct {
+	mu         sync.RWMutex
+	injections map[Key]*Injection
+	recoverAt  map[Key]time.Time
+}
+
+func NewInjections() *Injections {
+	return &Injections{injections: make(map[Key]*Injection), recoverAt: make(map[Key]time.Time)}
+}
+
+// Record records the injection of the key. A new injection is not due to be recovered until it is scheduled.
+// A nil Injections does not record anything
+func (i *Injections) Record(key Key, injection *Injection) {
+	if i == nil {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.injections[key] = injection
+	delete(i.recoverAt, key)
+}
+
+// Get returns the latest injection of the key
+func (i *Injections) Get(key Key) (*Injection, bool) {
+	if i == nil {
+		return nil, false
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	injection, ok := i.injections[key]
+	return injection, ok
+}
+
+// AutoRecover calls recover after the duration, unless the key has been injected again in the meantime.
+// The key is due to be recovered from then on, so that it can be recovered by Expired if the call is missed.
+// Nothing is scheduled for a duration that is not positive
+func (i *Injections) AutoRecover(key Key, injection *Injection, after time.Duration, recover func()) {
+	if i == nil || after <= 0 {
+		return
+	}
+
+	i.mu.Lock()
+	if latest, ok := i.injections[key]; ok && latest == injection {
+		i.recoverAt[key] = time.Now().Add(after)
+	}
+	i.mu.Unlock()
+
+	time.AfterFunc(after, func() {
+		if latest, ok := i.Get(key); ok && latest == injection {
+			recover()
+		}
+	})
+}
+
+// RecoverAt returns the time at which the key is due to be recovered automatically, if it is scheduled
+func (i *Injections) RecoverAt(key Key) (time.Time, bool) {
+	if i == nil {
+		return time.Time{}, false
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	recoverAt, ok := i.recoverAt[key]
+	return recoverAt, ok
+}
+
+// Expired returns the keys that were due to be recovered automatically before now
+func (i *Injections) Expired(now time.Time) []Key {
+	if i == nil {
+		return nil
+	}
+
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	keys := make([]Key, 0)
+	for key, recoverAt := range i.recoverAt {
+		if recoverAt.Before(now) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Forget removes the time at which the key is due to be recovered, e.g. after it is no longer in the cache
+func (i *Injections) Forget(key Key) {
+	if i == nil {
+		return
+	}
+
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.recoverAt, key)
+}
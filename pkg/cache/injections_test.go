@@ -0,0 +1,41 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInjectionsExpiredReturnsTheKeysThatAreDue(t *testing.T) {
+	injections := NewInjections()
+	due := Key{Job: "job", Target: "127.0.0.1"}
+	notDue := Key{Job: "job", Target: "127.0.0.2"}
+	unscheduled := Key{Job: "job", Target: "127.0.0.3"}
+	for key, after := range map[Key]time.Duration{due: time.Second, notDue: time.Hour, unscheduled: 0} {
+		injection := &Injection{StartsAt: time.Now()}
+		injections.Record(key, injection)
+		injections.AutoRecover(key, injection, after, func() {})
+	}
+
+	assert.Equal(t, []Key{due}, injections.Expired(time.Now().Add(time.Minute)))
+
+	_, ok := injections.RecoverAt(unscheduled)
+	assert.False(t, ok, "a failure without a duration should not be due to be recovered")
+
+	injections.Forget(due)
+	assert.Equal(t, []Key{}, injections.Expired(time.Now().Add(time.Minute)))
+}
+
+func TestInjectionsNewInjectionIsNotDue(t *testing.T) {
+	injections := NewInjections()
+	key := Key{Job: "job", Target: "127.0.0.1"}
+	injection := &Injection{StartsAt: time.Now()}
+	injections.Record(key, injection)
+	injections.AutoRecover(key, injection, time.Second, func() {})
+
+	injections.Record(key, &Injection{StartsAt: time.Now()})
+
+	_, ok := injections.RecoverAt(key)
+	assert.False(t, ok, "the injection that replaced the scheduled one should not be due until it is scheduled")
+}
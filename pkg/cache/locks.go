@@ -0,0 +1,47 @@
+package cache
+
+import "sync"
+
+// KeyLocks serializes the operations on the same key of the cache, so that the injection
+// and the recovery of a failure can not interleave and leave the cache inconsistent
+type KeyLocks struct {
+	mu    sync.Mutex
+	locks map[Key]*keyLock
+}
+
+type keyLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func NewKeyLocks() *KeyLocks {
+	return &KeyLocks{locks: make(map[Key]*keyLock)}
+}
+
+// Lock locks the key and returns the function that unlocks it. A nil KeyLocks does not lock
+func (l *KeyLocks) Lock(key Key) func() {
+	if l == nil {
+		return func() {}
+	}
+
+	l.mu.Lock()
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &keyLock{}
+		l.locks[key] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	lock.mu.Lock()
+	return func() {
+		lock.mu.Unlock()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		lock.refs--
+		if lock.refs == 0 {
+			delete(l.locks, key)
+		}
+	}
+}
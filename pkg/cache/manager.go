@@ -1,20 +1,25 @@
 package cache
 
 import (
-	"reflect"
-
 	"github.com/SotirisAlfonsos/gocache"
 )
 
+// Key identifies a failure in the cache by its job and target
 type Key struct {
 	Job    string
 	Target string
 }
 
+// Equals returns true if the job and the target of the keys are equal
 func (k Key) Equals(key gocache.Key) bool {
-	if key == nil || reflect.ValueOf(key).Kind() == reflect.Ptr {
+	other, ok := key.(Key)
+	if !ok {
 		return false
 	}
 
-	return k.Job == key.(Key).Job && k.Target == key.(Key).Target
+	return k == other
+}
+
+func (k Key) String() string {
+	return k.Job + "," + k.Target
 }
@@ -0,0 +1,70 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyEquals(t *testing.T) {
+	dataItems := []struct {
+		message  string
+		key      Key
+		other    gocache.Key
+		expected bool
+	}{
+		{
+			message:  "Should be equal for the same job and target",
+			key:      Key{Job: "job", Target: "127.0.0.1"},
+			other:    Key{Job: "job", Target: "127.0.0.1"},
+			expected: true,
+		},
+		{
+			message:  "Should not be equal for different targets",
+			key:      Key{Job: "job", Target: "127.0.0.1"},
+			other:    Key{Job: "job", Target: "127.0.0.2"},
+			expected: false,
+		},
+		{
+			message:  "Should not be equal for different jobs",
+			key:      Key{Job: "job", Target: "127.0.0.1"},
+			other:    Key{Job: "other job", Target: "127.0.0.1"},
+			expected: false,
+		},
+		{
+			message:  "Should not be equal to a pointer to a key",
+			key:      Key{Job: "job", Target: "127.0.0.1"},
+			other:    &Key{Job: "job", Target: "127.0.0.1"},
+			expected: false,
+		},
+		{
+			message:  "Should not be equal to nil",
+			key:      Key{Job: "job", Target: "127.0.0.1"},
+			expected: false,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			assert.Equal(t, dataItem.expected, dataItem.key.Equals(dataItem.other))
+		})
+	}
+}
+
+func TestCacheWithJobAndTargetKeys(t *testing.T) {
+	c := gocache.New(0)
+	c.Set(Key{Job: "job", Target: "127.0.0.1"}, "first")
+	c.Set(Key{Job: "job", Target: "127.0.0.1"}, "second")
+	c.Set(Key{Job: "job", Target: "127.0.0.2"}, "other target")
+
+	item, ok := c.Get(Key{Job: "job", Target: "127.0.0.1"})
+
+	assert.True(t, ok)
+	assert.Equal(t, "second", item.Value)
+	assert.Equal(t, 2, c.ItemCount())
+}
+
+func TestKeyString(t *testing.T) {
+	assert.Equal(t, "job,127.0.0.1", Key{Job: "job", Target: "127.0.0.1"}.String())
+}
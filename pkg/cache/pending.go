@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"sync"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+)
+
+// Pending keeps the injections that are scheduled to be performed after a delay,
+// so that they can be canceled by a recovery before they take effect
+type Pending struct {
+	mu      sync.Mutex
+	delayed map[Key]*delayedInjection
+}
+
+type delayedInjection struct {
+	timer    *time.Timer
+	done     chan struct{}
+	canceled bool
+}
+
+func NewPending() *Pending {
+	return &Pending{
+		delayed: make(map[Key]*delayedInjection),
+	}
+}
+
+// Delay performs the injection for the key after the delay. It returns the recovery function to cache for the
+// injection, which cancels the injection if it has not been performed yet, or otherwise waits for the injection
+// to complete and calls recovery
+func (p *Pending) Delay(
+	key Key,
+	delay time.Duration,
+	inject func(),
+	recovery func() (*v1.StatusResponse, error),
+) func() (*v1.StatusResponse, error) {
+	injection := &delayedInjection{done: make(chan struct{})}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if previous, ok := p.delayed[key]; ok {
+		p.cancelLocked(key, previous)
+	}
+	p.delayed[key] = injection
+	injection.timer = time.AfterFunc(delay, func() {
+		defer close(injection.done)
+		p.remove(key, injection)
+		inject()
+	})
+
+	return func() (*v1.StatusResponse, error) {
+		if p.cancel(key, injection) {
+			return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS, Message: "The delayed injection was canceled"}, nil
+		}
+		<-injection.done
+		return recovery()
+	}
+}
+
+// Cancel cancels the delayed injection of the key. It returns true if there was
+// an injection for the key that was canceled before it was performed
+func (p *Pending) Cancel(key Key) bool {
+	if p == nil {
+		return false
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	injection, ok := p.delayed[key]
+	if !ok {
+		return false
+	}
+
+	return p.cancelLocked(key, injection)
+}
+
+func (p *Pending) cancel(key Key, injection *delayedInjection) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cancelLocked(key, injection)
+}
+
+func (p *Pending) cancelLocked(key Key, injection *delayedInjection) bool {
+	if !injection.canceled {
+		if !injection.timer.Stop() {
+			return false
+		}
+		injection.canceled = true
+	}
+	if p.delayed[key] == injection {
+		delete(p.delayed, key)
+	}
+	return true
+}
+
+func (p *Pending) remove(key Key, injection *delayedInjection) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.delayed[key] == injection {
+		delete(p.delayed, key)
+	}
+}
@@ -0,0 +1,53 @@
+package cache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPendingRecoveryCancelsInjectionWithinDelay(t *testing.T) {
+	pending := NewPending()
+	var injections, recoveries int32
+	key := Key{Job: "job", Target: "127.0.0.1"}
+
+	recovery := pending.Delay(key, 50*time.Millisecond, func() {
+		atomic.AddInt32(&injections, 1)
+	}, func() (*v1.StatusResponse, error) {
+		atomic.AddInt32(&recoveries, 1)
+		return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+	})
+
+	statusResponse, err := recovery()
+	time.Sleep(100 * time.Millisecond)
+
+	assert.Nil(t, err)
+	assert.Equal(t, v1.StatusResponse_SUCCESS, statusResponse.Status)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&injections))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&recoveries), "the bot should not be called to recover an injection that never happened")
+	assert.False(t, pending.Cancel(key))
+}
+
+func TestPendingRecoveryAfterDelayRecoversInjection(t *testing.T) {
+	pending := NewPending()
+	var injections, recoveries int32
+	key := Key{Job: "job", Target: "127.0.0.1"}
+
+	recovery := pending.Delay(key, 10*time.Millisecond, func() {
+		atomic.AddInt32(&injections, 1)
+	}, func() (*v1.StatusResponse, error) {
+		atomic.AddInt32(&recoveries, 1)
+		return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+	})
+
+	time.Sleep(50 * time.Millisecond)
+	_, err := recovery()
+
+	assert.Nil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&injections))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&recoveries))
+	assert.False(t, pending.Cancel(key), "an injection that was performed can not be canceled")
+}
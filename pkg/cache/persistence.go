@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/pkg/errors"
+)
+
+// Store is the backing store of the running failures of the cache
+type Store interface {
+	Save(keys []Key) error
+	Load() ([]Key, error)
+}
+
+// FileStore saves the running failures as a json array in a file
+type FileStore struct {
+	path string
+}
+
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// Save writes the keys in a temporary file and renames it, so that the file is never partially written
+func (f *FileStore) Save(keys []Key) error {
+	b, err := json.Marshal(keys)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// Load reads the keys of the file, or none if the file does not exist
+func (f *FileStore) Load() ([]Key, error) {
+	b, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]Key, 0)
+	if err = json.Unmarshal(b, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Persistence saves the running failures of the cache to a store after every change. The store is a snapshot
+// of the failures only, since their recoveries can not be saved, so the failures of the store are reported
+// and not recovered when the master starts. A nil Persistence means that persistence is not enabled
+type Persistence struct {
+	// mu serializes the saves, so that a snapshot of the cache is never saved over a newer one
+	mu         sync.Mutex
+	store      Store
+	failClosed bool
+}
+
+// NewPersistence returns the persistence based on the cache config, or nil if no persistence path is configured
+func NewPersistence(conf *config.Cache) *Persistence {
+	if conf == nil || conf.PersistencePath == "" {
+		return nil
+	}
+	return NewStorePersistence(NewFileStore(conf.PersistencePath), conf.FailClosed)
+}
+
+func NewStorePersistence(store Store, failClosed bool) *Persistence {
+	return &Persistence{store: store, failClosed: failClosed}
+}
+
+// Save saves the keys of all the items in the cache to the store
+func (p *Persistence) Save(c *gocache.Cache) error {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	items := c.GetAll()
+	keys := make([]Key, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, item.Key.(Key))
+	}
+
+	if err := p.store.Save(keys); err != nil {
+		return errors.Wrap(err, "Could not persist cache")
+	}
+	return nil
+}
+
+// Load returns the keys of the failures saved in the store, e.g. the failures that were running when the master stopped
+func (p *Persistence) Load() ([]Key, error) {
+	if p == nil {
+		return nil, nil
+	}
+
+	keys, err := p.store.Load()
+	if err != nil {
+		return nil, errors.Wrap(err, "Could not load persisted cache")
+	}
+	return keys, nil
+}
+
+// FailClosed returns true if an injection should be aborted when the cache can not be persisted
+func (p *Persistence) FailClosed() bool {
+	return p != nil && p.failClosed
+}
+
+// Rollback recovers the failure stored under the key and removes it from the cache. The failure is kept in
+// the cache if it could not be recovered, so that it can still be recovered later
+func Rollback(c *gocache.Cache, key Key) error {
+	item, ok := c.Get(key)
+	if !ok {
+		return errors.New(fmt.Sprintf("Could not find failure for job {%s} and target {%s} in cache", key.Job, key.Target))
+	}
+
+	recoveryFunc, ok := item.Value.(func() (*v1.StatusResponse, error))
+	if !ok || recoveryFunc == nil {
+		return errors.New(fmt.Sprintf("Could not recover failure for job {%s} and target {%s}", key.Job, key.Target))
+	}
+
+	statusResponse, err := recoveryFunc()
+	if err != nil {
+		return err
+	}
+	if statusResponse.Status != v1.StatusResponse_SUCCESS {
+		return errors.New(fmt.Sprintf("Failure response from target {%s}", key.Target))
+	}
+	c.Delete(key)
+	return nil
+}
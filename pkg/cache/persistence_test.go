@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStoreSavesKeysOfCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "failures.json")
+	c := gocache.New(0)
+	c.Set(Key{Job: "job", Target: "127.0.0.1"}, "value")
+
+	persistence := NewStorePersistence(NewFileStore(path), false)
+	if err = persistence.Save(c); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := make([]Key, 0)
+	if err = json.Unmarshal(b, &keys); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, []Key{{Job: "job", Target: "127.0.0.1"}}, keys)
+}
+
+func TestFileStoreLoadsTheSavedKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	persistence := NewStorePersistence(NewFileStore(filepath.Join(dir, "failures.json")), false)
+	keys, err := persistence.Load()
+	assert.Nil(t, err)
+	assert.Empty(t, keys, "a missing file should have no keys")
+
+	c := gocache.New(0)
+	c.Set(Key{Job: "job", Target: "127.0.0.1"}, "value")
+	if err = persistence.Save(c); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err = persistence.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, []Key{{Job: "job", Target: "127.0.0.1"}}, keys)
+}
+
+func TestDisabledPersistenceIsNoOp(t *testing.T) {
+	var persistence *Persistence
+
+	assert.Nil(t, persistence.Save(gocache.New(0)))
+	assert.False(t, persistence.FailClosed())
+}
+
+func TestRollbackRecoversAndRemovesFailure(t *testing.T) {
+	c := gocache.New(0)
+	key := Key{Job: "job", Target: "127.0.0.1"}
+	recovered := false
+	c.Set(key, func() (*v1.StatusResponse, error) {
+		recovered = true
+		return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+	})
+
+	err := Rollback(c, key)
+
+	assert.Nil(t, err)
+	assert.True(t, recovered)
+	assert.Equal(t, 0, c.ItemCount())
+}
+
+func TestRollbackReturnsErrorOfRecoveryAndKeepsFailure(t *testing.T) {
+	c := gocache.New(0)
+	key := Key{Job: "job", Target: "127.0.0.1"}
+	c.Set(key, func() (*v1.StatusResponse, error) {
+		return nil, errors.New("bot unavailable")
+	})
+
+	err := Rollback(c, key)
+
+	assert.EqualError(t, err, "bot unavailable")
+	assert.Equal(t, 1, c.ItemCount(), "the failure that could not be recovered should still be recoverable")
+}
@@ -0,0 +1,44 @@
+package cache
+
+import (
+	"time"
+
+	"github.com/SotirisAlfonsos/gocache"
+)
+
+// StartSweeper evicts the expired items of the cache on every interval, so that
+// they are removed even if the cache is never accessed. The returned function stops the sweeper
+func StartSweeper(c *gocache.Cache, interval time.Duration) func() {
+	return startTicker(interval, func(time.Time) { c.Evict() })
+}
+
+// StartAutoRecovery calls recoverExpired with the current time on every interval, so that the failures that are
+// due to be recovered are recovered even if their scheduled recovery was missed. The returned function stops it
+func StartAutoRecovery(interval time.Duration, recoverExpired func(now time.Time)) func() {
+	return startTicker(interval, recoverExpired)
+}
+
+// startTicker calls tick on every interval until the returned function is called
+func startTicker(interval time.Duration, tick func(now time.Time)) func() {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case now := <-ticker.C:
+				tick(now)
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
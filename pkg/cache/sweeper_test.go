@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSweeperRemovesExpiredItemsWithoutAccess(t *testing.T) {
+	c := gocache.New(20 * time.Millisecond)
+	c.Set(Key{Job: "job", Target: "127.0.0.1"}, "value")
+	c.Set(Key{Job: "job", Target: "127.0.0.2"}, "value")
+	assert.Equal(t, 2, c.ItemCount())
+
+	stop := StartSweeper(c, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	stop()
+
+	assert.Equal(t, 0, c.ItemCount(), "expired items should be removed by the sweeper")
+}
+
+func TestSweeperKeepsItemsWithoutExpiration(t *testing.T) {
+	c := gocache.New(0)
+	c.Set(Key{Job: "job", Target: "127.0.0.1"}, "value")
+
+	stop := StartSweeper(c, 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	stop()
+
+	assert.Equal(t, 1, c.ItemCount())
+}
+
+func TestAutoRecoveryRecoversOnEveryInterval(t *testing.T) {
+	calls := make(chan time.Time, 10)
+	stop := StartAutoRecovery(10*time.Millisecond, func(now time.Time) {
+		select {
+		case calls <- now:
+		default:
+		}
+	})
+	time.Sleep(55 * time.Millisecond)
+	stop()
+
+	assert.GreaterOrEqual(t, len(calls), 3, "the expired failures should be recovered on every interval")
+}
@@ -2,6 +2,7 @@ package chaoslogger
 
 import (
 	"io"
+	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -9,20 +10,28 @@ import (
 	"github.com/pkg/errors"
 )
 
+// Loggers contains the loggers of the master. If the allowed level of the loggers
+// is set, the level can be changed at runtime
 type Loggers struct {
 	OutLogger log.Logger
 	ErrLogger log.Logger
+	Level     *AllowedLevel
 }
 
 // AllowedLevel is a settable identifier for the minimum level a log entry
 // must be have.
 type AllowedLevel struct {
-	s string
-	o level.Option
+	mu sync.RWMutex
+	s  string
+	o  level.Option
 }
 
-// Set updates the value of the allowed level.
+// Set updates the value of the allowed level. The loggers created with
+// the allowed level use the new value for all subsequent log entries
 func (l *AllowedLevel) Set(s string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	switch s {
 	case "debug":
 		l.o = level.AllowDebug()
@@ -41,12 +50,35 @@ func (l *AllowedLevel) Set(s string) error {
 	return nil
 }
 
+// String returns the value of the allowed level
+func (l *AllowedLevel) String() string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.s
+}
+
+func (l *AllowedLevel) option() level.Option {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.o
+}
+
+// levelFilter filters the log entries with the current value of the allowed level
+type levelFilter struct {
+	next         log.Logger
+	allowedLevel *AllowedLevel
+}
+
+func (f *levelFilter) Log(keyvals ...interface{}) error {
+	return level.NewFilter(f.next, f.allowedLevel.option()).Log(keyvals...)
+}
+
 // New returns a new leveled oklog logger. Each logged line will be annotated
 // with a timestamp. The output always goes to stderr.
 func New(allowedLevel *AllowedLevel, writer io.Writer) log.Logger {
 	l := log.NewLogfmtLogger(log.NewSyncWriter(writer))
 
-	l = level.NewFilter(l, allowedLevel.o)
+	l = &levelFilter{next: l, allowedLevel: allowedLevel}
 	l = log.With(l, "ts", timestampFormat(), "caller", log.DefaultCaller)
 
 	return l
@@ -0,0 +1,59 @@
+package chaoslogger
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Redacted is logged instead of the value of a secret
+const Redacted = "***"
+
+// Secret is a value of the configuration that is never written in the log output, e.g. a token.
+// It is masked wherever it is formatted, including as a field of a struct that is logged with %v
+type Secret string
+
+// String returns the redacted value of the secret, or an empty string if the secret is not set
+func (s Secret) String() string {
+	if s == "" {
+		return ""
+	}
+	return Redacted
+}
+
+// GoString masks the secret when it is formatted with %#v
+func (s Secret) GoString() string {
+	return s.String()
+}
+
+// Value returns the unmasked value of the secret, which should only be passed to where the secret is used
+func (s Secret) Value() string {
+	return string(s)
+}
+
+// URL returns the url with the password of its user info and the values of its query masked,
+// since urls of the configuration can contain credentials. A url that can not be parsed is masked entirely
+func URL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Redacted
+	}
+
+	if u.RawQuery != "" {
+		keys := make([]string, 0)
+		for key := range u.Query() {
+			keys = append(keys, url.QueryEscape(key)+"="+Redacted)
+		}
+		sort.Strings(keys)
+		u.RawQuery = strings.Join(keys, "&")
+	}
+
+	// the masked password is added to the formatted url, since the user info escapes it
+	if _, ok := u.User.Password(); ok {
+		user := url.User(u.User.Username()).String()
+		u.User = nil
+		return strings.Replace(u.String(), "//", "//"+user+":"+Redacted+"@", 1)
+	}
+
+	return u.String()
+}
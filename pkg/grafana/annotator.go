@@ -0,0 +1,111 @@
+package grafana
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/go-kit/kit/log/level"
+)
+
+const annotateTimeout = 10 * time.Second
+
+// Annotation is posted to the annotations api of grafana at the start and at the end of every failure
+type Annotation struct {
+	Time int64    `json:"time"`
+	Tags []string `json:"tags"`
+	Text string   `json:"text"`
+}
+
+// Annotator annotates the start and the end of the failures in grafana, so that they can be correlated with the
+// dashboards. The annotations are posted asynchronously, and a failure to annotate does not fail the action.
+// A nil Annotator does not annotate anything
+type Annotator struct {
+	url     string
+	token   chaoslogger.Secret
+	tags    []string
+	async   *async.Tracker
+	client  *http.Client
+	loggers chaoslogger.Loggers
+}
+
+// NewAnnotator returns the annotator of the grafana config, or nil if grafana is not configured
+func NewAnnotator(grafana *config.Grafana, tracker *async.Tracker, loggers chaoslogger.Loggers) *Annotator {
+	if grafana == nil || grafana.URL == "" {
+		return nil
+	}
+	if tracker == nil {
+		tracker = async.NewTracker()
+	}
+
+	return &Annotator{
+		url:     strings.TrimSuffix(grafana.URL, "/") + "/api/annotations",
+		token:   grafana.Token,
+		tags:    grafana.Tags,
+		async:   tracker,
+		client:  &http.Client{Timeout: annotateTimeout},
+		loggers: loggers,
+	}
+}
+
+// Start annotates the start of the failure of the job on the target
+func (a *Annotator) Start(job string, target string) {
+	a.annotate("start", job, target)
+}
+
+// End annotates the end of the failure of the job on the target
+func (a *Annotator) End(job string, target string) {
+	a.annotate("end", job, target)
+}
+
+func (a *Annotator) annotate(phase string, job string, target string) {
+	if a == nil {
+		return
+	}
+
+	annotation := &Annotation{
+		Time: time.Now().UnixNano() / int64(time.Millisecond),
+		Tags: append([]string{"chaos", phase, "job:" + job, "target:" + target}, a.tags...),
+		Text: fmt.Sprintf("Chaos %s of job {%s} on target {%s}", phase, job, target),
+	}
+
+	a.async.Go(func() {
+		if err := a.post(annotation); err != nil {
+			_ = level.Error(a.loggers.ErrLogger).Log("msg", fmt.Sprintf("Could not annotate the %s of job {%s} on target {%s}", phase, job, target),
+				"url", chaoslogger.URL(a.url), "err", err)
+		}
+	})
+}
+
+func (a *Annotator) post(annotation *Annotation) error {
+	body, err := json.Marshal(annotation)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, a.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if a.token != "" {
+		request.Header.Set("Authorization", "Bearer "+a.token.Value())
+	}
+
+	resp, err := a.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("grafana responded with status {%d}", resp.StatusCode)
+	}
+	return nil
+}
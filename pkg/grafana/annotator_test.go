@@ -0,0 +1,77 @@
+package grafana
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/stretchr/testify/assert"
+)
+
+var loggers = chaoslogger.Loggers{
+	OutLogger: chaoslogger.New(&chaoslogger.AllowedLevel{}, os.Stdout),
+	ErrLogger: chaoslogger.New(&chaoslogger.AllowedLevel{}, os.Stderr),
+}
+
+func TestAnnotatorPostsTheStartAndTheEndOfFailures(t *testing.T) {
+	annotations := make(chan *Annotation, 2)
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/annotations", r.URL.Path)
+		assert.Equal(t, "Bearer grafana-token", r.Header.Get("Authorization"))
+		annotation := &Annotation{}
+		if err := json.NewDecoder(r.Body).Decode(annotation); err != nil {
+			t.Error(err)
+		}
+		annotations <- annotation
+	}))
+	defer grafana.Close()
+
+	tracker := async.NewTracker()
+	annotator := NewAnnotator(&config.Grafana{URL: grafana.URL + "/", Token: "grafana-token", Tags: []string{"team:sre"}}, tracker, loggers)
+
+	annotator.Start("cpu job", "127.0.0.1")
+	if err := tracker.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	annotator.End("cpu job", "127.0.0.1")
+	if err := tracker.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	start, end := <-annotations, <-annotations
+	assert.Equal(t, []string{"chaos", "start", "job:cpu job", "target:127.0.0.1", "team:sre"}, start.Tags)
+	assert.Equal(t, "Chaos start of job {cpu job} on target {127.0.0.1}", start.Text)
+	assert.Equal(t, []string{"chaos", "end", "job:cpu job", "target:127.0.0.1", "team:sre"}, end.Tags)
+	assert.True(t, end.Time >= start.Time)
+	assert.InDelta(t, time.Now().UnixNano()/int64(time.Millisecond), end.Time, float64(time.Minute/time.Millisecond))
+}
+
+func TestAnnotatorErrorsForUnsuccessfulResponse(t *testing.T) {
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer grafana.Close()
+
+	annotator := NewAnnotator(&config.Grafana{URL: grafana.URL}, nil, loggers)
+
+	err := annotator.post(&Annotation{Text: "Chaos start"})
+
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "grafana responded with status {401}", err.Error())
+	}
+}
+
+func TestAnnotatorIsNilWithoutGrafana(t *testing.T) {
+	annotator := NewAnnotator(nil, async.NewTracker(), loggers)
+
+	assert.Nil(t, annotator)
+	annotator.Start("cpu job", "127.0.0.1")
+	annotator.End("cpu job", "127.0.0.1")
+}
@@ -0,0 +1,43 @@
+package limiter
+
+import "sync"
+
+// Limiter limits the calls in flight to every target, so that a bulk action or the recovery of many failures
+// does not overwhelm a bot. A nil Limiter does not limit the calls
+type Limiter struct {
+	max     int
+	mu      sync.Mutex
+	targets map[string]chan struct{}
+}
+
+// New returns the limiter of at most max calls in flight per target, or nil if max is not positive
+func New(max int) *Limiter {
+	if max <= 0 {
+		return nil
+	}
+	return &Limiter{max: max, targets: make(map[string]chan struct{})}
+}
+
+// Acquire waits until a call to the target can be made, and returns the function that releases it
+func (l *Limiter) Acquire(target string) func() {
+	if l == nil {
+		return func() {}
+	}
+
+	slots := l.slots(target)
+	slots <- struct{}{}
+	return func() { <-slots }
+}
+
+// slots returns the buffered channel of the calls in flight to the target
+func (l *Limiter) slots(target string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	slots, ok := l.targets[target]
+	if !ok {
+		slots = make(chan struct{}, l.max)
+		l.targets[target] = slots
+	}
+	return slots
+}
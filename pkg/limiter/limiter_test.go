@@ -0,0 +1,56 @@
+package limiter
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcquireNeverExceedsTheLimitPerTarget(t *testing.T) {
+	limiter := New(2)
+	var mu sync.Mutex
+	inFlight := make(map[string]int)
+	maxInFlight := make(map[string]int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		for _, target := range []string{"127.0.0.1", "127.0.0.2"} {
+			wg.Add(1)
+			go func(target string) {
+				defer wg.Done()
+				defer limiter.Acquire(target)()
+
+				mu.Lock()
+				inFlight[target]++
+				if inFlight[target] > maxInFlight[target] {
+					maxInFlight[target] = inFlight[target]
+				}
+				mu.Unlock()
+
+				time.Sleep(5 * time.Millisecond)
+
+				mu.Lock()
+				inFlight[target]--
+				mu.Unlock()
+			}(target)
+		}
+	}
+	wg.Wait()
+
+	assert.Equal(t, map[string]int{"127.0.0.1": 2, "127.0.0.2": 2}, maxInFlight, "every target should reach but not exceed the limit")
+}
+
+func TestNilLimiterDoesNotLimit(t *testing.T) {
+	limiter := New(0)
+	assert.Nil(t, limiter)
+
+	releases := make([]func(), 0, 10)
+	for i := 0; i < 10; i++ {
+		releases = append(releases, limiter.Acquire("127.0.0.1"))
+	}
+	for _, release := range releases {
+		release()
+	}
+}
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+)
+
+// FailureTypes are the failure types that are counted, in the order they are exposed
+var FailureTypes = []config.FailureType{config.Docker, config.Service, config.CPU, config.Server, config.Network}
+
+// InFlight counts the injection requests of every failure type that have not completed yet.
+// The counters are created once, so that they can be updated concurrently without a lock.
+// A nil InFlight does not count anything
+type InFlight struct {
+	counters map[config.FailureType]*int64
+}
+
+func NewInFlight() *InFlight {
+	counters := make(map[config.FailureType]*int64, len(FailureTypes))
+	for _, failureType := range FailureTypes {
+		counters[failureType] = new(int64)
+	}
+	return &InFlight{counters: counters}
+}
+
+// Track increments the counter of the failure type. The returned function decrements it, and should be
+// called when the injection completes
+func (f *InFlight) Track(failureType config.FailureType) func() {
+	if f == nil {
+		return func() {}
+	}
+	counter, ok := f.counters[failureType]
+	if !ok {
+		return func() {}
+	}
+
+	atomic.AddInt64(counter, 1)
+	return func() {
+		atomic.AddInt64(counter, -1)
+	}
+}
+
+// Get returns the number of in-flight injections of the failure type
+func (f *InFlight) Get(failureType config.FailureType) int64 {
+	if f == nil {
+		return 0
+	}
+	counter, ok := f.counters[failureType]
+	if !ok {
+		return 0
+	}
+	return atomic.LoadInt64(counter)
+}
+
+// Snapshot returns the number of in-flight injections of every failure type
+func (f *InFlight) Snapshot() map[config.FailureType]int64 {
+	snapshot := make(map[config.FailureType]int64, len(FailureTypes))
+	for _, failureType := range FailureTypes {
+		snapshot[failureType] = f.Get(failureType)
+	}
+	return snapshot
+}
+
+// Write writes the in-flight injections as a gauge in the prometheus text format
+func (f *InFlight) Write(w io.Writer) error {
+	if _, err := fmt.Fprint(w,
+		"# HELP chaos_master_inflight_injections The number of injection requests that have not completed yet\n",
+		"# TYPE chaos_master_inflight_injections gauge\n",
+	); err != nil {
+		return err
+	}
+	for _, failureType := range FailureTypes {
+		if _, err := fmt.Fprintf(w, "chaos_master_inflight_injections{type=%q} %d\n", failureType, f.Get(failureType)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// Run with -race to also detect unsynchronized access to the counters
+func TestInFlightReturnsToZeroAfterConcurrentInjections(t *testing.T) {
+	inFlight := NewInFlight()
+
+	var started, release sync.WaitGroup
+	var wg sync.WaitGroup
+	started.Add(100)
+	release.Add(1)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			done := inFlight.Track(config.CPU)
+			started.Done()
+			release.Wait()
+			done()
+		}()
+	}
+
+	started.Wait()
+	assert.Equal(t, int64(100), inFlight.Get(config.CPU))
+	assert.Equal(t, int64(0), inFlight.Get(config.Docker))
+
+	release.Done()
+	wg.Wait()
+	assert.Equal(t, int64(0), inFlight.Get(config.CPU))
+}
+
+func TestNilInFlightDoesNotCount(t *testing.T) {
+	var inFlight *InFlight
+	inFlight.Track(config.CPU)()
+
+	assert.Equal(t, int64(0), inFlight.Get(config.CPU))
+}
+
+func TestInFlightIsWrittenAsPrometheusGauge(t *testing.T) {
+	inFlight := NewInFlight()
+	inFlight.Track(config.Network)
+
+	var b bytes.Buffer
+	if err := inFlight.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, b.String(), "# TYPE chaos_master_inflight_injections gauge\n")
+	assert.Contains(t, b.String(), "chaos_master_inflight_injections{type=\"Network\"} 1\n")
+	assert.Contains(t, b.String(), "chaos_master_inflight_injections{type=\"CPU\"} 0\n")
+}
@@ -0,0 +1,208 @@
+package metrics
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+)
+
+// Result is the outcome of a call to a bot
+type Result string
+
+const (
+	// Success is a call to which the bot responded with a success status
+	Success Result = "success"
+	// Failure is a call to which the bot responded with a failure status
+	Failure Result = "failure"
+	// Error is a call that did not get a response from the bot
+	Error Result = "error"
+)
+
+// DurationBuckets are the upper bounds in seconds of the buckets of the action durations
+var DurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// ResultOf returns the result of a call to a bot from its status response and error
+func ResultOf(statusResponse *v1.StatusResponse, err error) Result {
+	switch {
+	case err != nil:
+		return Error
+	case statusResponse == nil || statusResponse.Status != v1.StatusResponse_SUCCESS:
+		return Failure
+	}
+	return Success
+}
+
+type outcome struct {
+	failureType config.FailureType
+	action      string
+	target      string
+	result      Result
+}
+
+type action struct {
+	failureType config.FailureType
+	action      string
+}
+
+type histogram struct {
+	buckets []uint64
+	count   uint64
+	sum     float64
+}
+
+// Outcomes counts the results of the injections and recoveries on every target, and observes their durations.
+// The targets are counted as the target label of the config, kept, dropped or bucketed.
+// A nil Outcomes does not count anything
+type Outcomes struct {
+	mu          sync.Mutex
+	counters    map[outcome]uint64
+	durations   map[action]*histogram
+	targetLabel string
+	buckets     int
+}
+
+func NewOutcomes(conf *config.Metrics) *Outcomes {
+	return &Outcomes{
+		counters:    make(map[outcome]uint64),
+		durations:   make(map[action]*histogram),
+		targetLabel: conf.TargetLabelMode(),
+		buckets:     conf.Buckets(),
+	}
+}
+
+// target returns the value of the target label of the target, which is empty if the label is dropped
+func (o *Outcomes) target(target string) string {
+	switch o.targetLabel {
+	case config.TargetLabelDrop:
+		return ""
+	case config.TargetLabelBucket:
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(target))
+		return fmt.Sprintf("bucket-%d", h.Sum32()%uint32(o.buckets))
+	}
+	return target
+}
+
+// Observe counts the result of the action of the failure type on the target, and observes its duration
+func (o *Outcomes) Observe(failureType config.FailureType, actionName string, target string, result Result, duration time.Duration) {
+	if o == nil {
+		return
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.counters[outcome{failureType: failureType, action: actionName, target: o.target(target), result: result}]++
+
+	key := action{failureType: failureType, action: actionName}
+	h, ok := o.durations[key]
+	if !ok {
+		h = &histogram{buckets: make([]uint64, len(DurationBuckets))}
+		o.durations[key] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range DurationBuckets {
+		if seconds <= bound {
+			h.buckets[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+// Count returns the number of the actions of the failure type on the target with the result
+func (o *Outcomes) Count(failureType config.FailureType, actionName string, target string, result Result) uint64 {
+	if o == nil {
+		return 0
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.counters[outcome{failureType: failureType, action: actionName, target: o.target(target), result: result}]
+}
+
+// Write writes the results as a counter and the durations as a histogram in the prometheus text format
+func (o *Outcomes) Write(w io.Writer) error {
+	if o == nil {
+		return nil
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if _, err := fmt.Fprint(w,
+		"# HELP chaos_master_injections_total The number of the injections and recoveries by their result\n",
+		"# TYPE chaos_master_injections_total counter\n",
+	); err != nil {
+		return err
+	}
+	outcomes := make([]outcome, 0, len(o.counters))
+	for key := range o.counters {
+		outcomes = append(outcomes, key)
+	}
+	sort.Slice(outcomes, func(i, j int) bool {
+		return fmt.Sprint(outcomes[i]) < fmt.Sprint(outcomes[j])
+	})
+	for _, key := range outcomes {
+		labels := fmt.Sprintf("failure_type=%q,action=%q,target=%q,result=%q", key.failureType, key.action, key.target, key.result)
+		if o.targetLabel == config.TargetLabelDrop {
+			labels = fmt.Sprintf("failure_type=%q,action=%q,result=%q", key.failureType, key.action, key.result)
+		}
+		if _, err := fmt.Fprintf(w, "chaos_master_injections_total{%s} %d\n", labels, o.counters[key]); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprint(w,
+		"# HELP chaos_master_action_duration_seconds The duration of the injections and recoveries\n",
+		"# TYPE chaos_master_action_duration_seconds histogram\n",
+	); err != nil {
+		return err
+	}
+	actions := make([]action, 0, len(o.durations))
+	for key := range o.durations {
+		actions = append(actions, key)
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		return fmt.Sprint(actions[i]) < fmt.Sprint(actions[j])
+	})
+	for _, key := range actions {
+		if err := o.durations[key].write(w, fmt.Sprintf("failure_type=%q,action=%q", key.failureType, key.action)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *histogram) write(w io.Writer, labels string) error {
+	for i, bound := range DurationBuckets {
+		if _, err := fmt.Fprintf(w, "chaos_master_action_duration_seconds_bucket{%s,le=%q} %d\n",
+			labels, strconv.FormatFloat(bound, 'g', -1, 64), h.buckets[i]); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "chaos_master_action_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "chaos_master_action_duration_seconds_sum{%s} %g\n", labels, h.sum); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "chaos_master_action_duration_seconds_count{%s} %d\n", labels, h.count)
+	return err
+}
+
+// WriteOutstandingFailures writes the number of the failures that are not recovered yet as a gauge in the prometheus text format
+func WriteOutstandingFailures(w io.Writer, count int) error {
+	_, err := fmt.Fprintf(w,
+		"# HELP chaos_master_outstanding_failures The number of the failures that are not recovered yet\n"+
+			"# TYPE chaos_master_outstanding_failures gauge\n"+
+			"chaos_master_outstanding_failures %d\n", count)
+	return err
+}
@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResultOfTheCallToTheBot(t *testing.T) {
+	dataItems := []struct {
+		message        string
+		statusResponse *v1.StatusResponse
+		err            error
+		expected       Result
+	}{
+		{
+			message:        "Should be a success for a success status",
+			statusResponse: &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS},
+			expected:       Success,
+		},
+		{
+			message:        "Should be a failure for a failure status",
+			statusResponse: &v1.StatusResponse{Status: v1.StatusResponse_FAIL},
+			expected:       Failure,
+		},
+		{
+			message:  "Should be an error if the bot did not respond",
+			err:      errors.New("connection refused"),
+			expected: Error,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			assert.Equal(t, dataItem.expected, ResultOf(dataItem.statusResponse, dataItem.err))
+		})
+	}
+}
+
+func TestOutcomesAreWrittenAsPrometheusCounterAndHistogram(t *testing.T) {
+	outcomes := NewOutcomes(nil)
+	outcomes.Observe(config.Docker, "kill", "127.0.0.1", Success, 20*time.Millisecond)
+	outcomes.Observe(config.Docker, "kill", "127.0.0.1", Success, 2*time.Second)
+	outcomes.Observe(config.Docker, "kill", "127.0.0.2", Error, time.Millisecond)
+
+	var b bytes.Buffer
+	if err := outcomes.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, uint64(2), outcomes.Count(config.Docker, "kill", "127.0.0.1", Success))
+	assert.Contains(t, b.String(), "# TYPE chaos_master_injections_total counter\n")
+	assert.Contains(t, b.String(), "chaos_master_injections_total{failure_type=\"Docker\",action=\"kill\",target=\"127.0.0.1\",result=\"success\"} 2\n")
+	assert.Contains(t, b.String(), "chaos_master_injections_total{failure_type=\"Docker\",action=\"kill\",target=\"127.0.0.2\",result=\"error\"} 1\n")
+	assert.Contains(t, b.String(), "# TYPE chaos_master_action_duration_seconds histogram\n")
+	assert.Contains(t, b.String(), "chaos_master_action_duration_seconds_bucket{failure_type=\"Docker\",action=\"kill\",le=\"0.005\"} 1\n")
+	assert.Contains(t, b.String(), "chaos_master_action_duration_seconds_bucket{failure_type=\"Docker\",action=\"kill\",le=\"0.025\"} 2\n")
+	assert.Contains(t, b.String(), "chaos_master_action_duration_seconds_bucket{failure_type=\"Docker\",action=\"kill\",le=\"+Inf\"} 3\n")
+	assert.Contains(t, b.String(), "chaos_master_action_duration_seconds_count{failure_type=\"Docker\",action=\"kill\"} 3\n")
+}
+
+func TestNilOutcomesDoNotCount(t *testing.T) {
+	var outcomes *Outcomes
+	outcomes.Observe(config.CPU, "start", "127.0.0.1", Success, time.Second)
+
+	assert.Equal(t, uint64(0), outcomes.Count(config.CPU, "start", "127.0.0.1", Success))
+}
+
+func TestOutcomesWithoutTheTargetLabel(t *testing.T) {
+	outcomes := NewOutcomes(&config.Metrics{TargetLabel: config.TargetLabelDrop})
+	outcomes.Observe(config.Docker, "kill", "127.0.0.1", Success, time.Millisecond)
+	outcomes.Observe(config.Docker, "kill", "127.0.0.2", Success, time.Millisecond)
+
+	var b bytes.Buffer
+	if err := outcomes.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotContains(t, b.String(), "target=")
+	assert.Contains(t, b.String(), "chaos_master_injections_total{failure_type=\"Docker\",action=\"kill\",result=\"success\"} 2\n")
+}
+
+func TestOutcomesWithBucketedTargets(t *testing.T) {
+	outcomes := NewOutcomes(&config.Metrics{TargetLabel: config.TargetLabelBucket, TargetBuckets: 2})
+	targets := []string{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.4", "127.0.0.5"}
+	for _, target := range targets {
+		outcomes.Observe(config.CPU, "start", target, Success, time.Millisecond)
+	}
+
+	var b bytes.Buffer
+	if err := outcomes.Write(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range targets {
+		assert.NotContains(t, b.String(), target)
+	}
+	assert.Equal(t, 2, strings.Count(b.String(), "chaos_master_injections_total{"), "the targets should be counted in the 2 buckets")
+	assert.GreaterOrEqual(t, outcomes.Count(config.CPU, "start", "127.0.0.1", Success), uint64(1), "a target should be counted in its bucket")
+}
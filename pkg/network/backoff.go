@@ -0,0 +1,127 @@
+package network
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+)
+
+const (
+	defaultBackoffFailures = 3
+	defaultBackoffInitial  = 10 * time.Second
+	defaultBackoffMax      = 5 * time.Minute
+)
+
+// Backoff stops the injections on a target for a while after consecutive failures of its bot, so that a broken bot
+// is not called repeatedly. The duration doubles with every failure after the threshold, up to the maximum.
+// A nil Backoff never stops the injections
+type Backoff struct {
+	mu       sync.Mutex
+	failures int
+	initial  time.Duration
+	max      time.Duration
+	targets  map[string]*targetBackoff
+	now      func() time.Time
+}
+
+type targetBackoff struct {
+	failures int
+	until    time.Time
+}
+
+// BackoffError is returned for an injection on a target that is backed off
+type BackoffError struct {
+	Target    string
+	Failures  int
+	Remaining time.Duration
+}
+
+func (e *BackoffError) Error() string {
+	return fmt.Sprintf("Injections on target {%s} are backed off for {%s} after {%d} consecutive failures", e.Target, e.Remaining, e.Failures)
+}
+
+// RetryAfter returns the remaining duration of the backoff
+func (e *BackoffError) RetryAfter() time.Duration {
+	return e.Remaining
+}
+
+func NewBackoff(conf *config.Backoff) *Backoff {
+	backoff := &Backoff{
+		failures: defaultBackoffFailures,
+		initial:  defaultBackoffInitial,
+		max:      defaultBackoffMax,
+		targets:  make(map[string]*targetBackoff),
+		now:      time.Now,
+	}
+	if conf != nil {
+		if conf.Failures > 0 {
+			backoff.failures = conf.Failures
+		}
+		if conf.Initial > 0 {
+			backoff.initial = conf.Initial
+		}
+		if conf.Max > 0 {
+			backoff.max = conf.Max
+		}
+	}
+
+	return backoff
+}
+
+// Check returns a BackoffError if the injections on the target are backed off
+func (b *Backoff) Check(target string) error {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	state, ok := b.targets[target]
+	if !ok {
+		return nil
+	}
+
+	if remaining := state.until.Sub(b.now()); remaining > 0 {
+		return &BackoffError{Target: target, Failures: state.failures, Remaining: remaining}
+	}
+	return nil
+}
+
+// Record counts the consecutive failures of the target, and backs it off once they reach the threshold.
+// A call without an error clears the failures of the target
+func (b *Backoff) Record(target string, err error) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		delete(b.targets, target)
+		return
+	}
+
+	state, ok := b.targets[target]
+	if !ok {
+		state = &targetBackoff{}
+		b.targets[target] = state
+	}
+	state.failures++
+	if state.failures >= b.failures {
+		state.until = b.now().Add(b.duration(state.failures - b.failures))
+	}
+}
+
+// duration returns the initial duration doubled for every failure after the threshold, up to the maximum
+func (b *Backoff) duration(exceeded int) time.Duration {
+	duration := b.initial
+	for i := 0; i < exceeded && duration < b.max; i++ {
+		duration *= 2
+	}
+	if duration > b.max {
+		return b.max
+	}
+	return duration
+}
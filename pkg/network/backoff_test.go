@@ -0,0 +1,61 @@
+package network
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoffEngagesAfterConsecutiveFailures(t *testing.T) {
+	now := time.Now()
+	backoff := NewBackoff(&config.Backoff{Failures: 2, Initial: 10 * time.Second, Max: 30 * time.Second})
+	backoff.now = func() time.Time { return now }
+	failure := errors.New("connection refused")
+
+	backoff.Record("127.0.0.1", failure)
+	assert.Nil(t, backoff.Check("127.0.0.1"), "the target should not be backed off before the threshold")
+
+	backoff.Record("127.0.0.1", failure)
+	assertBackedOff(t, backoff, "127.0.0.1", 2, 10*time.Second)
+	assert.Nil(t, backoff.Check("127.0.0.2"), "the other targets should not be backed off")
+
+	backoff.Record("127.0.0.1", failure)
+	assertBackedOff(t, backoff, "127.0.0.1", 3, 20*time.Second)
+
+	backoff.Record("127.0.0.1", failure)
+	assertBackedOff(t, backoff, "127.0.0.1", 4, 30*time.Second)
+
+	now = now.Add(30 * time.Second)
+	assert.Nil(t, backoff.Check("127.0.0.1"), "the target should not be backed off after the backoff expires")
+}
+
+func TestBackoffClearsOnSuccess(t *testing.T) {
+	backoff := NewBackoff(&config.Backoff{Failures: 1})
+	backoff.Record("127.0.0.1", errors.New("connection refused"))
+	assert.NotNil(t, backoff.Check("127.0.0.1"))
+
+	backoff.Record("127.0.0.1", nil)
+	assert.Nil(t, backoff.Check("127.0.0.1"))
+
+	backoff.Record("127.0.0.1", errors.New("connection refused"))
+	assertBackedOff(t, backoff, "127.0.0.1", 1, defaultBackoffInitial)
+}
+
+func TestNilBackoffNeverEngages(t *testing.T) {
+	var backoff *Backoff
+	backoff.Record("127.0.0.1", errors.New("connection refused"))
+
+	assert.Nil(t, backoff.Check("127.0.0.1"))
+}
+
+func assertBackedOff(t *testing.T, backoff *Backoff, target string, failures int, remaining time.Duration) {
+	err := backoff.Check(target)
+	if assert.IsType(t, &BackoffError{}, err) {
+		backoffErr := err.(*BackoffError)
+		assert.Equal(t, failures, backoffErr.Failures)
+		assert.InDelta(t, remaining.Seconds(), backoffErr.RetryAfter().Seconds(), 0.1)
+	}
+}
@@ -1,12 +1,17 @@
 package network
 
 import (
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
 	"io/ioutil"
+	"sync"
+	"time"
 
 	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/limiter"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/retry"
 
 	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
 	"github.com/SotirisAlfonsos/chaos-master/config"
@@ -17,10 +22,17 @@ import (
 	"google.golang.org/grpc/connectivity"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/credentials/oauth"
+	"google.golang.org/grpc/keepalive"
 )
 
 type Connections struct {
 	Pool map[string]Connection
+	// Backoff stops the injections on the targets whose bots fail repeatedly
+	Backoff *Backoff
+	// Retry retries the calls to the bots that are unavailable
+	Retry *retry.Policy
+	// Limiter limits the calls in flight to every target for the bulk actions and the recoveries
+	Limiter *limiter.Limiter
 }
 
 type Connection interface {
@@ -40,49 +52,154 @@ type connection struct {
 }
 
 type Options struct {
-	cACert     string
-	publicCert string
-	peerToken  string
+	cACert           string
+	publicCert       string
+	peerToken        string
+	lbPolicy         string
+	minTLSVersion    uint16
+	cipherSuites     []uint16
+	globalLabels     map[string]string
+	dialTimeout      time.Duration
+	keepaliveTime    time.Duration
+	keepaliveTimeout time.Duration
 }
 
+// defaultDialTimeout is the time the connection to a bot is waited for, if the dial timeout of the bots is not set
+const defaultDialTimeout = 5 * time.Second
+
 func GetConnectionPool(config *config.Config, loggers chaoslogger.Loggers) *Connections {
 	connections := &Connections{
 		Pool: make(map[string]Connection),
 	}
+	if config.Bots != nil {
+		connections.Backoff = NewBackoff(config.Bots.Backoff)
+		connections.Retry = retry.NewPolicy(config.Bots.Retry, loggers)
+		connections.Limiter = limiter.New(config.Bots.MaxConcurrentPerTarget)
+	} else {
+		connections.Backoff = NewBackoff(nil)
+	}
+
+	connections.addForTargets(addresses(config), newOptions(config), loggers)
+
+	return connections
+}
+
+// Reload returns the connections of the targets of the reloaded config. The connections of the targets that are
+// still in the config are kept as they are, the new targets are dialed, and the connections of the targets that
+// were removed are closed. The connections of the addresses in use by running failures are kept until a later reload
+// once they are no longer in use, so that the failures can still be recovered. The connections are not modified,
+// so that they can still be read while they are replaced
+func (connections *Connections) Reload(conf *config.Config, inUse []string, loggers chaoslogger.Loggers) *Connections {
+	reloaded := &Connections{
+		Pool:    make(map[string]Connection),
+		Backoff: connections.Backoff,
+	}
+	if conf.Bots != nil {
+		reloaded.Retry = retry.NewPolicy(conf.Bots.Retry, loggers)
+		reloaded.Limiter = limiter.New(conf.Bots.MaxConcurrentPerTarget)
+	}
 
-	options := &Options{}
+	targets := make(map[string]bool)
+	for _, jobFromConfig := range conf.JobsFromConfig {
+		for _, address := range jobFromConfig.Addresses() {
+			if target, err := config.NormalizeTarget(address); err == nil {
+				targets[target] = true
+			}
+		}
+	}
+
+	for _, address := range inUse {
+		targets[address] = true
+	}
+
+	for target, connection := range connections.Pool {
+		if targets[target] {
+			reloaded.Pool[target] = connection
+		}
+	}
+
+	reloaded.addForTargets(addresses(conf), newOptions(conf), loggers)
+
+	for target, connection := range connections.Pool {
+		if _, ok := reloaded.Pool[target]; !ok {
+			closeConnection(connection, loggers)
+		}
+	}
+
+	return reloaded
+}
+
+func newOptions(config *config.Config) *Options {
+	options := &Options{globalLabels: config.GlobalLabels}
 
 	if config.Bots != nil {
-		options.peerToken = config.Bots.PeerToken
+		options.peerToken = config.Bots.PeerToken.Value()
 		options.cACert = config.Bots.CACert
 		options.publicCert = config.Bots.PublicCert
+		options.lbPolicy = config.Bots.LBPolicy
+		options.dialTimeout = config.Bots.DialTimeout
+		options.keepaliveTime = config.Bots.KeepaliveTime
+		options.keepaliveTimeout = config.Bots.KeepaliveTimeout
 	}
 
-	for _, jobFromConfig := range config.JobsFromConfig {
-		connections.addForTargets(jobFromConfig.Targets, options, loggers)
+	// the tls options are validated with the config
+	options.minTLSVersion, _ = config.Bots.TLSMinVersion()
+	options.cipherSuites, _ = config.Bots.TLSCipherSuites()
+
+	return options
+}
+
+// closeConnection closes the client connection of a connection of the pool, if it was dialed
+func closeConnection(conn Connection, loggers chaoslogger.Loggers) {
+	c, ok := conn.(*connection)
+	if !ok || c.clientConnection == nil {
+		return
 	}
 
-	return connections
+	_ = level.Info(loggers.OutLogger).Log("msg", fmt.Sprintf("Close connection to %s", c.target))
+	if err := c.clientConnection.Close(); err != nil {
+		_ = level.Error(loggers.ErrLogger).Log("msg", fmt.Sprintf("failed to close connection to target %s", c.target), "err", err)
+	}
 }
 
-func (connections *Connections) addForTargets(targets []string, options *Options, loggers chaoslogger.Loggers) {
-	for _, target := range targets {
-		connection := &connection{target: target, options: options, loggers: loggers}
-		if err := connection.addToPool(connections, target); err != nil {
-			_ = level.Error(loggers.ErrLogger).Log("msg", fmt.Sprintf("failed to add connection to target %s, to connection pool", target), "err", err)
-		}
+// addresses returns the addresses of the targets of all the jobs of the config
+func addresses(config *config.Config) []string {
+	addresses := make([]string, 0)
+	for _, jobFromConfig := range config.JobsFromConfig {
+		addresses = append(addresses, jobFromConfig.Addresses()...)
 	}
+	return addresses
 }
 
-func (connection *connection) addToPool(connections *Connections, target string) error {
-	if _, ok := connections.Pool[target]; !ok {
-		connections.Pool[target] = connection
-		err := connection.dial()
+// addForTargets adds the connections of the targets that are not in the pool, and dials them concurrently,
+// so that the targets that can not be reached delay the start by at most the dial timeout
+func (connections *Connections) addForTargets(targets []string, options *Options, loggers chaoslogger.Loggers) {
+	added := make([]*connection, 0, len(targets))
+	for _, target := range targets {
+		target, err := config.NormalizeTarget(target)
 		if err != nil {
-			return err
+			_ = level.Error(loggers.ErrLogger).Log("msg", "failed to add connection to connection pool", "err", err)
+			continue
+		}
+
+		if _, ok := connections.Pool[target]; !ok {
+			conn := &connection{target: target, options: options, loggers: loggers}
+			connections.Pool[target] = conn
+			added = append(added, conn)
 		}
 	}
-	return nil
+
+	var wg sync.WaitGroup
+	for _, conn := range added {
+		wg.Add(1)
+		go func(conn *connection) {
+			defer wg.Done()
+			if err := conn.dial(); err != nil {
+				_ = level.Error(loggers.ErrLogger).Log("msg", fmt.Sprintf("failed to add connection to target %s, to connection pool", conn.target), "err", err)
+			}
+		}(conn)
+	}
+	wg.Wait()
 }
 
 func (connection *connection) dial() error {
@@ -103,8 +220,14 @@ func (connection *connection) updateClientConnection() error {
 		return err
 	}
 
+	// the dial blocks until the connection is ready, so that a bot that can not be reached fails the request
+	// after the dial timeout instead of stalling it, and a refused connection fails it at once
+	opts = append(opts, grpc.WithBlock(), grpc.FailOnNonTempDialError(true))
+	ctx, cancel := context.WithTimeout(context.Background(), connection.options.getDialTimeout())
+	defer cancel()
+
 	_ = level.Info(connection.loggers.OutLogger).Log("msg", fmt.Sprintf("Dial %s ...", connection.target))
-	clientConn, err := grpc.Dial(connection.target, opts...)
+	clientConn, err := grpc.DialContext(ctx, connection.target, opts...)
 	if err != nil {
 		return err
 	}
@@ -115,6 +238,43 @@ func (connection *connection) updateClientConnection() error {
 }
 
 func (options *Options) getGRPCOptions() ([]grpc.DialOption, error) {
+	opts, err := options.getTransportOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	if options.lbPolicy != "" {
+		opts = append(opts, grpc.WithDefaultServiceConfig(options.serviceConfig()))
+	}
+
+	if len(options.globalLabels) > 0 {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(globalLabelsInterceptor(options.globalLabels)))
+	}
+
+	if options.keepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:    options.keepaliveTime,
+			Timeout: options.keepaliveTimeout,
+		}))
+	}
+
+	return opts, nil
+}
+
+// getDialTimeout returns the dial timeout of the options, 5 seconds if it is not set
+func (options *Options) getDialTimeout() time.Duration {
+	if options.dialTimeout <= 0 {
+		return defaultDialTimeout
+	}
+	return options.dialTimeout
+}
+
+// serviceConfig returns the grpc service config with the load balancing policy of the options
+func (options *Options) serviceConfig() string {
+	return fmt.Sprintf(`{"loadBalancingConfig": [{"%s": {}}]}`, options.lbPolicy)
+}
+
+func (options *Options) getTransportOptions() ([]grpc.DialOption, error) {
 	opts := make([]grpc.DialOption, 0)
 
 	if options.peerToken == "" && options.cACert == "" && options.publicCert == "" {
@@ -127,13 +287,13 @@ func (options *Options) getGRPCOptions() ([]grpc.DialOption, error) {
 	}
 
 	if options.cACert != "" {
-		tlsCredentials, err := loadTLSCredentials(options.cACert)
+		tlsCredentials, err := options.loadTLSCredentials(options.cACert)
 		if err != nil {
 			return nil, fmt.Errorf("cannot load ca cert: %s", err)
 		}
 		opts = append(opts, grpc.WithTransportCredentials(tlsCredentials))
 	} else if options.publicCert != "" {
-		tlsCredentials, err := loadTLSCredentials(options.publicCert)
+		tlsCredentials, err := options.loadTLSCredentials(options.publicCert)
 		if err != nil {
 			return nil, fmt.Errorf("could not load public cert: %s", err)
 		}
@@ -143,7 +303,33 @@ func (options *Options) getGRPCOptions() ([]grpc.DialOption, error) {
 	return opts, nil
 }
 
-func loadTLSCredentials(cert string) (credentials.TransportCredentials, error) {
+// certificate returns the certificate the bots are verified with, the ca cert if it is set and the public cert otherwise
+func (options *Options) certificate() string {
+	if options.cACert != "" {
+		return options.cACert
+	}
+	return options.publicCert
+}
+
+// tlsMinVersion returns the minimum TLS version of the options, TLS 1.2 if it is not set
+func (options *Options) tlsMinVersion() uint16 {
+	if options.minTLSVersion == 0 {
+		return tls.VersionTLS12
+	}
+	return options.minTLSVersion
+}
+
+func (options *Options) loadTLSCredentials(cert string) (credentials.TransportCredentials, error) {
+	tlsConfig, err := options.tlsConfig(cert)
+	if err != nil {
+		return nil, err
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// tlsConfig returns the tls config that trusts the certificate, with the minimum version and the cipher suites of the options
+func (options *Options) tlsConfig(cert string) (*tls.Config, error) {
 	// Load certificate of the CA who signed server's certificate
 	pemCert, err := ioutil.ReadFile(cert)
 	if err != nil {
@@ -155,13 +341,11 @@ func loadTLSCredentials(cert string) (credentials.TransportCredentials, error) {
 		return nil, fmt.Errorf("failed to add pem certificate to cert pool")
 	}
 
-	// Create the credentials and return it
-	tlsConfig := &tls.Config{
-		MinVersion: tls.VersionTLS12,
-		RootCAs:    certPool,
-	}
-
-	return credentials.NewTLS(tlsConfig), nil
+	return &tls.Config{
+		MinVersion:   options.tlsMinVersion(),
+		CipherSuites: options.cipherSuites,
+		RootCAs:      certPool,
+	}, nil
 }
 
 func (connection *connection) GetServiceClient() (v1.ServiceClient, error) {
@@ -1,13 +1,28 @@
 package network
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/SotirisAlfonsos/chaos-master/config"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
 )
 
 var loggers = createLoggers("info")
@@ -33,6 +48,18 @@ func TestSuccessfullySetTargetConnectionPoolForSingleJob(t *testing.T) {
 				{JobName: "job name", FailureType: "failure type", ComponentName: "component name", Targets: []string{"127.0.0.1", "127.0.0.2"}}},
 			expected: []string{"127.0.0.1", "127.0.0.2"},
 		},
+		{
+			message: "Should strip the scheme of a target",
+			jobsConfig: []*config.JobsFromConfig{
+				{JobName: "job name", FailureType: "failure type", ComponentName: "component name", Targets: []string{"http://127.0.0.1:8081", "127.0.0.2:8081"}}},
+			expected: []string{"127.0.0.1:8081", "127.0.0.2:8081"},
+		},
+		{
+			message: "Should not add a target with a path",
+			jobsConfig: []*config.JobsFromConfig{
+				{JobName: "job name", FailureType: "failure type", ComponentName: "component name", Targets: []string{"http://127.0.0.1:8081/bot"}}},
+			expected: []string{},
+		},
 		{
 			message: "Should create new connection pool with no targets if no targets where provided",
 			jobsConfig: []*config.JobsFromConfig{
@@ -84,6 +111,14 @@ func TestSuccessfullySetTargetConnectionPoolForMultipleJob(t *testing.T) {
 				{JobName: "job name", FailureType: "failure type", ComponentName: "component name", Targets: []string{"127.0.0.1"}}},
 			expected: []string{"127.0.0.1"},
 		},
+		{
+			message: "Should add a connection to the bot port of every job of the target",
+			jobsConfig: []*config.JobsFromConfig{
+				{JobName: "cpu job", FailureType: config.CPU, Targets: []string{"127.0.0.1:8081"}, BotPort: "8082"},
+				{JobName: "docker job", FailureType: config.Docker, ComponentName: "nginx", Targets: []string{"127.0.0.1:8081"}, BotPort: "8083"},
+				{JobName: "service job", FailureType: config.Service, ComponentName: "nginx", Targets: []string{"127.0.0.1:8081"}}},
+			expected: []string{"127.0.0.1:8081", "127.0.0.1:8082", "127.0.0.1:8083"},
+		},
 	}
 
 	for _, dataItem := range dataItems {
@@ -107,49 +142,60 @@ func TestSuccessfullySetTargetConnectionPoolForMultipleJob(t *testing.T) {
 }
 
 func TestSuccessfullySetTargetConnectionPoolWithTls(t *testing.T) {
-	dataItems := []TestData{
+	certFile, certificate, removeCert := selfSignedCertificate(t)
+	defer removeCert()
+
+	dataItems := []struct {
+		message     string
+		bots        *config.Bots
+		certificate *tls.Certificate
+		targets     int
+	}{
 		{
 			message: "Should create connection with no cert and no peer token",
-			jobsConfig: []*config.JobsFromConfig{
-				{JobName: "job name", FailureType: "failure type", ComponentName: "component name", Targets: []string{"127.0.0.1:8081"}}},
-			bots:     &config.Bots{},
-			expected: []string{"127.0.0.1:8081"},
+			bots:    &config.Bots{},
+			targets: 1,
 		},
 		{
-			message: "Should create connections with valid public certs and token provided",
-			jobsConfig: []*config.JobsFromConfig{
-				{JobName: "job name", FailureType: "failure type", ComponentName: "component name", Targets: []string{"127.0.0.1:8081", "127.0.0.2:8081"}}},
-			bots:     &config.Bots{PublicCert: "../../config/test/certs/server-cert.pem", PeerToken: "12345"},
-			expected: []string{"127.0.0.1:8081", "127.0.0.2:8081"},
+			message:     "Should create connections with valid public certs and token provided",
+			bots:        &config.Bots{PublicCert: certFile, PeerToken: "12345"},
+			certificate: certificate,
+			targets:     2,
 		},
 		{
-			message: "Should create connections with valid ca certs and token provided",
-			jobsConfig: []*config.JobsFromConfig{
-				{JobName: "job name", FailureType: "failure type", ComponentName: "component name", Targets: []string{"127.0.0.1:8081"}}},
-			bots:     &config.Bots{CACert: "../../config/test/certs/ca-cert.pem", PeerToken: "12345"},
-			expected: []string{"127.0.0.1:8081"},
+			message:     "Should create connections with valid ca certs and token provided",
+			bots:        &config.Bots{CACert: certFile, PeerToken: "12345"},
+			certificate: certificate,
+			targets:     1,
 		},
 	}
 
 	for _, dataItem := range dataItems {
 		t.Run(dataItem.message, func(t *testing.T) {
+			targets := make([]string, 0, dataItem.targets)
+			for i := 0; i < dataItem.targets; i++ {
+				target, stop := startBot(t, dataItem.certificate)
+				defer stop()
+				targets = append(targets, target)
+			}
 			conf := &config.Config{
-				JobsFromConfig: dataItem.jobsConfig,
-				Bots:           dataItem.bots,
+				JobsFromConfig: []*config.JobsFromConfig{
+					{JobName: "job name", FailureType: "failure type", ComponentName: "component name", Targets: targets}},
+				Bots: dataItem.bots,
 			}
 
 			connectionPool := GetConnectionPool(conf, loggers)
 
-			assert.Equal(t, len(dataItem.expected), len(connectionPool.Pool))
-			for _, target := range dataItem.expected {
+			assert.Equal(t, len(targets), len(connectionPool.Pool))
+			for _, target := range targets {
 				if _, err := connectionPool.Pool[target].GetHealthClient(); err != nil {
-					t.Errorf("Connection redial should not have error when getting the health client")
+					t.Errorf("Connection redial should not have error when getting the health client. err: %s", err)
 				}
 				if _, err := connectionPool.Pool[target].GetDockerClient(); err != nil {
-					t.Errorf("Connection redial should not have error when getting the docker client")
+					t.Errorf("Connection redial should not have error when getting the docker client. err: %s", err)
 				}
 				if _, err := connectionPool.Pool[target].GetServiceClient(); err != nil {
-					t.Errorf("Connection redial should not have error when getting the service client")
+					t.Errorf("Connection redial should not have error when getting the service client. err: %s", err)
 				}
 				assert.NotNil(t, connectionPool.Pool[target])
 			}
@@ -157,6 +203,38 @@ func TestSuccessfullySetTargetConnectionPoolWithTls(t *testing.T) {
 	}
 }
 
+func TestDialFailsAfterTheDialTimeoutForATargetThatDoesNotRespond(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		// accept the connections and never complete the handshake
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	target := listener.Addr().String()
+	conf := &config.Config{
+		JobsFromConfig: []*config.JobsFromConfig{
+			{JobName: "job name", FailureType: config.Docker, ComponentName: "container", Targets: []string{target}}},
+		Bots: &config.Bots{DialTimeout: 200 * time.Millisecond},
+	}
+
+	connectionPool := GetConnectionPool(conf, loggers)
+
+	start := time.Now()
+	_, err = connectionPool.Pool[target].GetHealthClient()
+	assert.NotNil(t, err, "the dial to a target that does not respond should fail")
+	assert.Less(t, int64(time.Since(start)), int64(time.Second), "the dial should fail after the dial timeout")
+}
+
 func TestRedialFailureWithTls(t *testing.T) {
 	dataItems := []TestData{
 		{
@@ -211,3 +289,200 @@ func createLoggers(debugLevel string) chaoslogger.Loggers {
 		ErrLogger: chaoslogger.New(allowLevel, os.Stderr),
 	}
 }
+
+func TestLoadBalancingPolicyServiceConfig(t *testing.T) {
+	withoutPolicy, err := (&Options{}).getGRPCOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	options := &Options{lbPolicy: "round_robin"}
+	withPolicy, err := options.getGRPCOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, len(withoutPolicy)+1, len(withPolicy), "the service config dial option should be set")
+	assert.Equal(t, `{"loadBalancingConfig": [{"round_robin": {}}]}`, options.serviceConfig())
+
+	clientConn, err := grpc.Dial("dns:///localhost:8081", withPolicy...)
+	if err != nil {
+		t.Fatalf("The service config should be valid: %v", err)
+	}
+	clientConn.Close()
+
+	invalidPolicy, err := (&Options{lbPolicy: "unknown"}).getGRPCOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = grpc.Dial("dns:///localhost:8081", invalidPolicy...)
+	assert.NotNil(t, err, "the dial should fail for an unknown load balancing policy, since the service config is applied")
+}
+
+func TestTLSConfigWithConfiguredMinimumVersion(t *testing.T) {
+	dataItems := []struct {
+		message            string
+		options            *Options
+		expectedMinVersion uint16
+	}{
+		{
+			message:            "Should default to TLS 1.2 as the minimum version",
+			options:            &Options{},
+			expectedMinVersion: tls.VersionTLS12,
+		},
+		{
+			message:            "Should set the configured minimum version",
+			options:            &Options{minTLSVersion: tls.VersionTLS13},
+			expectedMinVersion: tls.VersionTLS13,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			tlsConfig, err := dataItem.options.tlsConfig("../../config/test/certs/ca-cert.pem")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, dataItem.expectedMinVersion, tlsConfig.MinVersion)
+			assert.NotNil(t, tlsConfig.RootCAs)
+		})
+	}
+}
+
+func TestConnectionPoolAppliesTheTLSOptionsOfTheBots(t *testing.T) {
+	conf := &config.Config{
+		Bots: &config.Bots{MinTLSVersion: "1.3", CipherSuites: []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+		JobsFromConfig: []*config.JobsFromConfig{
+			{
+				JobName:       "job",
+				FailureType:   config.Docker,
+				ComponentName: "container",
+				Targets:       []string{"127.0.0.1:8081"},
+			},
+		},
+	}
+
+	connections := GetConnectionPool(conf, loggers)
+
+	options := connections.Pool["127.0.0.1:8081"].(*connection).options
+	tlsConfig, err := options.tlsConfig("../../config/test/certs/ca-cert.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+	assert.Equal(t, []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, tlsConfig.CipherSuites)
+}
+
+func TestReloadKeepsTheConnectionsOfTheTargetsThatAreStillInTheConfig(t *testing.T) {
+	keptTarget, stopKept := startBot(t, nil)
+	defer stopKept()
+	removedTarget, stopRemoved := startBot(t, nil)
+	defer stopRemoved()
+	addedTarget, stopAdded := startBot(t, nil)
+	defer stopAdded()
+	conf := &config.Config{
+		JobsFromConfig: []*config.JobsFromConfig{
+			{JobName: "job name", FailureType: config.CPU, Targets: []string{keptTarget, removedTarget}}},
+	}
+	connections := GetConnectionPool(conf, loggers)
+	kept := connections.Pool[keptTarget]
+	removed := connections.Pool[removedTarget].(*connection)
+
+	reloadedConf := &config.Config{
+		JobsFromConfig: []*config.JobsFromConfig{
+			{JobName: "job name", FailureType: config.CPU, Targets: []string{keptTarget, addedTarget}}},
+	}
+	reloaded := connections.Reload(reloadedConf, nil, loggers)
+
+	assert.Equal(t, 2, len(reloaded.Pool))
+	assert.True(t, kept == reloaded.Pool[keptTarget], "the connection of a kept target should not be dialed again")
+	assert.NotNil(t, reloaded.Pool[addedTarget])
+	assert.Nil(t, reloaded.Pool[removedTarget])
+	assert.Equal(t, connectivity.Shutdown, removed.clientConnection.GetState(), "the connection of a removed target should be closed")
+	assert.Equal(t, 2, len(connections.Pool), "the previous connections should not be modified")
+	assert.True(t, connections.Backoff == reloaded.Backoff)
+}
+
+func TestReloadKeepsTheConnectionsInUseOfTheTargetsThatWereRemoved(t *testing.T) {
+	inUseTarget, stopInUse := startBot(t, nil)
+	defer stopInUse()
+	removedTarget, stopRemoved := startBot(t, nil)
+	defer stopRemoved()
+	conf := &config.Config{
+		JobsFromConfig: []*config.JobsFromConfig{
+			{JobName: "job name", FailureType: config.CPU, Targets: []string{inUseTarget, removedTarget}}},
+	}
+	connections := GetConnectionPool(conf, loggers)
+	inUse := connections.Pool[inUseTarget].(*connection)
+
+	reloaded := connections.Reload(&config.Config{}, []string{inUseTarget}, loggers)
+
+	assert.True(t, inUse == reloaded.Pool[inUseTarget], "the connection of a target in use should be kept")
+	assert.NotEqual(t, connectivity.Shutdown, inUse.clientConnection.GetState(), "the connection of a target in use should not be closed")
+	assert.Nil(t, reloaded.Pool[removedTarget])
+
+	reloaded = reloaded.Reload(&config.Config{}, nil, loggers)
+
+	assert.Nil(t, reloaded.Pool[inUseTarget])
+	assert.Equal(t, connectivity.Shutdown, inUse.clientConnection.GetState(), "the connection should be closed once it is no longer in use")
+}
+
+// startBot starts a grpc server with no services on a random port, with tls if the certificate is provided,
+// and returns its address and the function that stops the server
+func startBot(t *testing.T, certificate *tls.Certificate) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := make([]grpc.ServerOption, 0)
+	if certificate != nil {
+		opts = append(opts, grpc.Creds(credentials.NewServerTLSFromCert(certificate)))
+	}
+	server := grpc.NewServer(opts...)
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	return listener.Addr().String(), server.Stop
+}
+
+// selfSignedCertificate returns the path to a self signed certificate for 127.0.0.1, the certificate
+// with its key for the server, and the function that removes the certificate file
+func selfSignedCertificate(t *testing.T) (string, *tls.Certificate, func()) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "cert")
+	if err != nil {
+		t.Fatal(err)
+	}
+	removeDir := func() { _ = os.RemoveAll(dir) }
+
+	certFile := filepath.Join(dir, "cert.pem")
+	if err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		removeDir()
+		t.Fatal(err)
+	}
+
+	return certFile, &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, removeDir
+}
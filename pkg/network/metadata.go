@@ -0,0 +1,67 @@
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// LabelMetadataPrefix is prepended to the name of every injection label sent to the bot as grpc metadata
+const LabelMetadataPrefix = "chaos-label-"
+
+// WithLabels appends the labels of an injection to the outgoing grpc metadata of the context,
+// so that the bot can tag its own logs and metrics with the same experiment labels
+func WithLabels(ctx context.Context, labels map[string]string) context.Context {
+	if len(labels) == 0 {
+		return ctx
+	}
+
+	kv := make([]string, 0, 2*len(labels))
+	for name, value := range labels {
+		kv = append(kv, LabelMetadataPrefix+strings.ToLower(name), value)
+	}
+
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// ValidateLabels checks that the name of every label is allowed in a grpc metadata key once it is lowercased,
+// since the call to the bot fails otherwise
+func ValidateLabels(labels map[string]string) error {
+	for name := range labels {
+		if !isMetadataKey(strings.ToLower(name)) {
+			return errors.New(fmt.Sprintf("The label name {%s} should contain only letters, digits, '-', '_' and '.'", name))
+		}
+	}
+	return nil
+}
+
+func isMetadataKey(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// globalLabelsInterceptor appends the global labels to the outgoing grpc metadata of every call to the bots.
+// The labels of an injection take precedence over the global labels with the same name
+func globalLabelsInterceptor(labels map[string]string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		missing := make(map[string]string, len(labels))
+		for name, value := range labels {
+			if len(md.Get(LabelMetadataPrefix+strings.ToLower(name))) == 0 {
+				missing[name] = value
+			}
+		}
+		return invoker(WithLabels(ctx, missing), method, req, reply, cc, opts...)
+	}
+}
@@ -0,0 +1,96 @@
+package network
+
+import (
+	"context"
+	"testing"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestLabelsAreSentAsOutgoingMetadata(t *testing.T) {
+	var captured metadata.MD
+	interceptor := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		captured, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+
+	conn, err := grpc.Dial("127.0.0.1:0", grpc.WithInsecure(), grpc.WithUnaryInterceptor(interceptor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx := WithLabels(context.Background(), map[string]string{"Experiment": "exp-1", "team": "sre"})
+	_, err = v1.NewCPUClient(conn).Start(ctx, &v1.CPURequest{Percentage: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, []string{"exp-1"}, captured.Get(LabelMetadataPrefix+"experiment"))
+	assert.Equal(t, []string{"sre"}, captured.Get(LabelMetadataPrefix+"team"))
+}
+
+func TestNoMetadataWithoutLabels(t *testing.T) {
+	ctx := WithLabels(context.Background(), nil)
+
+	_, ok := metadata.FromOutgoingContext(ctx)
+	assert.False(t, ok)
+}
+
+func TestGlobalLabelsAreSentOnEveryCall(t *testing.T) {
+	var captured []metadata.MD
+	capturing := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		md, _ := metadata.FromOutgoingContext(ctx)
+		captured = append(captured, md)
+		return nil
+	}
+
+	options := &Options{globalLabels: map[string]string{"Fleet": "eu-west", "team": "platform"}}
+	opts, err := options.getGRPCOptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := grpc.Dial("127.0.0.1:0", append(opts, grpc.WithChainUnaryInterceptor(capturing))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if _, err = v1.NewCPUClient(conn).Start(WithLabels(context.Background(), map[string]string{"team": "sre"}), &v1.CPURequest{Percentage: 50}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = v1.NewDockerClient(conn).Recover(context.Background(), &v1.DockerRequest{Name: "nginx"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if assert.Equal(t, 2, len(captured)) {
+		assert.Equal(t, []string{"eu-west"}, captured[0].Get(LabelMetadataPrefix+"fleet"))
+		assert.Equal(t, []string{"sre"}, captured[0].Get(LabelMetadataPrefix+"team"), "the label of the injection should take precedence")
+		assert.Equal(t, []string{"eu-west"}, captured[1].Get(LabelMetadataPrefix+"fleet"))
+		assert.Equal(t, []string{"platform"}, captured[1].Get(LabelMetadataPrefix+"team"))
+	}
+}
+
+func TestValidateLabels(t *testing.T) {
+	tests := []struct {
+		message string
+		labels  map[string]string
+		valid   bool
+	}{
+		{message: "Should accept letters, digits, '-', '_' and '.'", labels: map[string]string{"Experiment_ID-2.v1": "exp 1"}, valid: true},
+		{message: "Should accept no labels", labels: nil, valid: true},
+		{message: "Should reject a label name with a space", labels: map[string]string{"experiment id": "exp-1"}, valid: false},
+		{message: "Should reject a label name with a character that is not ascii", labels: map[string]string{"expérience": "exp-1"}, valid: false},
+		{message: "Should reject an empty label name", labels: map[string]string{"": "exp-1"}, valid: false},
+	}
+
+	for _, dataItem := range tests {
+		t.Run(dataItem.message, func(t *testing.T) {
+			err := ValidateLabels(dataItem.labels)
+			assert.Equal(t, dataItem.valid, err == nil)
+		})
+	}
+}
@@ -9,6 +9,7 @@ import (
 
 type MockConnection struct {
 	Status *v1.StatusResponse
+	Health v1.HealthCheckResponse_ServingStatus
 	Err    error
 }
 
@@ -33,7 +34,7 @@ func (connection *MockConnection) GetNetworkClient() (v1.NetworkClient, error) {
 }
 
 func (connection *MockConnection) GetHealthClient() (v1.HealthClient, error) {
-	return nil, nil
+	return GetMockHealthClient(connection.Health, connection.Err), nil
 }
 
 type MockFailedConnection struct {
@@ -143,3 +144,23 @@ func (mcc *mockNetworkClient) Start(_ context.Context, _ *v1.NetworkRequest, _ .
 func (mcc *mockNetworkClient) Recover(_ context.Context, _ *v1.NetworkRequest, _ ...grpc.CallOption) (*v1.StatusResponse, error) {
 	return mcc.Status, mcc.Error
 }
+
+type mockHealthClient struct {
+	Status v1.HealthCheckResponse_ServingStatus
+	Error  error
+}
+
+func GetMockHealthClient(status v1.HealthCheckResponse_ServingStatus, err error) v1.HealthClient {
+	return &mockHealthClient{Status: status, Error: err}
+}
+
+func (mhc *mockHealthClient) Check(_ context.Context, _ *v1.HealthCheckRequest, _ ...grpc.CallOption) (*v1.HealthCheckResponse, error) {
+	if mhc.Error != nil {
+		return nil, mhc.Error
+	}
+	return &v1.HealthCheckResponse{Status: mhc.Status}, nil
+}
+
+func (mhc *mockHealthClient) Watch(_ context.Context, _ *v1.HealthCheckRequest, _ ...grpc.CallOption) (v1.Health_WatchClient, error) {
+	return nil, mhc.Error
+}
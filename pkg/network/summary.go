@@ -0,0 +1,55 @@
+package network
+
+import (
+	"sort"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+)
+
+// DialSummary contains the options the bot of a target is dialed with, without the secrets, e.g. to debug tls issues
+type DialSummary struct {
+	Target        string   `json:"target"`
+	TLS           bool     `json:"tls"`
+	Certificate   string   `json:"certificate,omitempty"`
+	MinTLSVersion string   `json:"minTLSVersion,omitempty"`
+	CipherSuites  []string `json:"cipherSuites,omitempty"`
+	HasToken      bool     `json:"hasToken"`
+	LBPolicy      string   `json:"lbPolicy,omitempty"`
+	GlobalLabels  []string `json:"globalLabels,omitempty"`
+	State         string   `json:"state,omitempty"`
+}
+
+// Summary returns the dial summary of the connection. It returns false for connections that are not dialed by
+// the connection pool, e.g. mocks
+func Summary(conn Connection) (*DialSummary, bool) {
+	c, ok := conn.(*connection)
+	if !ok {
+		return nil, false
+	}
+
+	summary := &DialSummary{
+		Target:   c.target,
+		HasToken: c.options.peerToken != "",
+		LBPolicy: c.options.lbPolicy,
+	}
+
+	if certificate := c.options.certificate(); certificate != "" {
+		summary.TLS = true
+		summary.Certificate = certificate
+		summary.MinTLSVersion = config.TLSVersionName(c.options.tlsMinVersion())
+		for _, cipherSuite := range c.options.cipherSuites {
+			summary.CipherSuites = append(summary.CipherSuites, config.TLSCipherSuiteName(cipherSuite))
+		}
+	}
+
+	for name := range c.options.globalLabels {
+		summary.GlobalLabels = append(summary.GlobalLabels, name)
+	}
+	sort.Strings(summary.GlobalLabels)
+
+	if c.clientConnection != nil {
+		summary.State = c.clientConnection.GetState().String()
+	}
+
+	return summary, true
+}
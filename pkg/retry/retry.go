@@ -0,0 +1,90 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/go-kit/kit/log/level"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultMaxAttempts = 3
+	defaultBaseDelay   = 100 * time.Millisecond
+)
+
+// Policy retries the calls to the bots that fail because the bot is unavailable, e.g. when it briefly drops its
+// connection. The delay before every retry is the base delay, doubled after every attempt.
+// A nil Policy calls once
+type Policy struct {
+	maxAttempts int
+	baseDelay   time.Duration
+	loggers     chaoslogger.Loggers
+}
+
+// NewPolicy returns the retry policy of the config, or nil if the retries are not configured
+func NewPolicy(conf *config.Retry, loggers chaoslogger.Loggers) *Policy {
+	if conf == nil {
+		return nil
+	}
+
+	policy := &Policy{
+		maxAttempts: defaultMaxAttempts,
+		baseDelay:   defaultBaseDelay,
+		loggers:     loggers,
+	}
+	if conf.MaxAttempts > 0 {
+		policy.maxAttempts = conf.MaxAttempts
+	}
+	if conf.BaseDelay > 0 {
+		policy.baseDelay = conf.BaseDelay
+	}
+
+	return policy
+}
+
+// Do calls the bot of the target until the call succeeds, fails with an error that can not be retried,
+// the attempts are exhausted or the context is done, and returns the error of the last attempt
+func (p *Policy) Do(ctx context.Context, target string, call func(ctx context.Context) error) error {
+	err := call(ctx)
+	if p == nil {
+		return err
+	}
+
+	delay := p.baseDelay
+	attempts := 1
+	for ; attempts < p.maxAttempts && Retryable(err); attempts++ {
+		_ = level.Warn(p.loggers.OutLogger).Log("msg", fmt.Sprintf("call to the bot failed on attempt %d of %d, retrying", attempts, p.maxAttempts),
+			"target", target, "delay", delay, "err", err)
+
+		select {
+		case <-ctx.Done():
+			_ = level.Error(p.loggers.ErrLogger).Log("msg", fmt.Sprintf("stopped retrying the call to the bot after %d attempts, the request is done", attempts),
+				"target", target, "err", err)
+			return err
+		case <-time.After(delay):
+		}
+
+		err = call(ctx)
+		delay *= 2
+	}
+
+	if attempts > 1 {
+		if err != nil {
+			_ = level.Error(p.loggers.ErrLogger).Log("msg", fmt.Sprintf("call to the bot failed after %d attempts", attempts), "target", target, "err", err)
+		} else {
+			_ = level.Info(p.loggers.OutLogger).Log("msg", fmt.Sprintf("call to the bot succeeded after %d attempts", attempts), "target", target)
+		}
+	}
+
+	return err
+}
+
+// Retryable returns true for the errors of a bot that is unavailable
+func Retryable(err error) bool {
+	return err != nil && status.Code(err) == codes.Unavailable
+}
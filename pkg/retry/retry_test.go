@@ -0,0 +1,126 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var loggers = getLogger()
+
+func TestDoRetries(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "connection reset")
+	invalid := status.Error(codes.InvalidArgument, "invalid percentage")
+
+	dataItems := []struct {
+		message          string
+		errors           []error
+		expectedAttempts int
+		expectedError    error
+	}{
+		{
+			message:          "Should not retry a call that succeeds",
+			errors:           []error{nil},
+			expectedAttempts: 1,
+		},
+		{
+			message:          "Should retry a call while the bot is unavailable",
+			errors:           []error{unavailable, unavailable, nil},
+			expectedAttempts: 3,
+		},
+		{
+			message:          "Should give up after the max attempts",
+			errors:           []error{unavailable, unavailable, unavailable, nil},
+			expectedAttempts: 3,
+			expectedError:    unavailable,
+		},
+		{
+			message:          "Should fail fast for an error that can not be retried",
+			errors:           []error{invalid, nil},
+			expectedAttempts: 1,
+			expectedError:    invalid,
+		},
+		{
+			message:          "Should stop retrying on an error that can not be retried",
+			errors:           []error{unavailable, invalid, nil},
+			expectedAttempts: 2,
+			expectedError:    invalid,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			policy := NewPolicy(&config.Retry{MaxAttempts: 3, BaseDelay: time.Millisecond}, loggers)
+
+			attempts := 0
+			err := policy.Do(context.Background(), "127.0.0.1", func(ctx context.Context) error {
+				err := dataItem.errors[attempts]
+				attempts++
+				return err
+			})
+
+			assert.Equal(t, dataItem.expectedAttempts, attempts)
+			assert.Equal(t, dataItem.expectedError, err)
+		})
+	}
+}
+
+func TestDoDoublesTheDelayAfterEveryAttempt(t *testing.T) {
+	policy := NewPolicy(&config.Retry{MaxAttempts: 4, BaseDelay: 20 * time.Millisecond}, loggers)
+
+	start := time.Now()
+	_ = policy.Do(context.Background(), "127.0.0.1", func(ctx context.Context) error {
+		return status.Error(codes.Unavailable, "connection reset")
+	})
+
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(140*time.Millisecond), "the delays should be 20ms, 40ms and 80ms")
+}
+
+func TestDoStopsRetryingWhenTheContextIsDone(t *testing.T) {
+	policy := NewPolicy(&config.Retry{MaxAttempts: 5, BaseDelay: time.Hour}, loggers)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	unavailable := status.Error(codes.Unavailable, "connection reset")
+
+	attempts := 0
+	err := policy.Do(ctx, "127.0.0.1", func(ctx context.Context) error {
+		attempts++
+		return unavailable
+	})
+
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, unavailable, err)
+}
+
+func TestNilPolicyCallsOnce(t *testing.T) {
+	policy := NewPolicy(nil, loggers)
+	assert.Nil(t, policy, "the policy should be nil if the retries are not configured")
+
+	attempts := 0
+	_ = policy.Do(context.Background(), "127.0.0.1", func(ctx context.Context) error {
+		attempts++
+		return status.Error(codes.Unavailable, "connection reset")
+	})
+
+	assert.Equal(t, 1, attempts)
+}
+
+func getLogger() chaoslogger.Loggers {
+	allowLevel := &chaoslogger.AllowedLevel{}
+	if err := allowLevel.Set("debug"); err != nil {
+		fmt.Printf("%v", err)
+	}
+
+	return chaoslogger.Loggers{
+		OutLogger: chaoslogger.New(allowLevel, os.Stdout),
+		ErrLogger: chaoslogger.New(allowLevel, os.Stderr),
+	}
+}
@@ -0,0 +1,27 @@
+package target
+
+import (
+	"crypto/rand"
+	"math/big"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/pkg/errors"
+)
+
+// Random returns one of the targets of the job, every target with the same probability
+func Random(job *config.Job) (string, error) {
+	return RandomOf(job.Target)
+}
+
+// RandomOf returns one of the targets, every target with the same probability
+func RandomOf(targets []string) (string, error) {
+	if len(targets) == 0 {
+		return "", errors.New("No targets available")
+	}
+
+	num, err := rand.Int(rand.Reader, big.NewInt(int64(len(targets))))
+	if err != nil {
+		return "", err
+	}
+	return targets[num.Int64()], nil
+}
@@ -0,0 +1,26 @@
+package target
+
+import (
+	"testing"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandomReturnsATargetOfTheJob(t *testing.T) {
+	job := &config.Job{Target: []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}}
+
+	for i := 0; i < 20; i++ {
+		target, err := Random(job)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Contains(t, job.Target, target)
+	}
+}
+
+func TestRandomErrorsForAJobWithoutTargets(t *testing.T) {
+	_, err := Random(&config.Job{})
+
+	assert.EqualError(t, err, "No targets available")
+}
@@ -2,12 +2,16 @@ package api
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
 	"github.com/SotirisAlfonsos/gocache"
 
@@ -16,17 +20,48 @@ import (
 	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
 	v1 "github.com/SotirisAlfonsos/chaos-master/web/api/v1"
 	"github.com/go-kit/kit/log/level"
-	"github.com/gorilla/mux"
 )
 
 type RestAPI struct {
-	Router  *mux.Router
-	Loggers chaoslogger.Loggers
-	Port    string
+	Loggers         chaoslogger.Loggers
+	Port            string
+	ReadOnlyPort    string
+	MaxConnections  int
+	cache           *gocache.Cache
+	async           *async.Tracker
+	sweepInterval   time.Duration
+	apiRouter       *v1.APIRouter
+	healthChecker   *healthcheck.HealthChecker
+	scheme          string
+	handler         *swappableHandler
+	readOnlyHandler *swappableHandler
+	reloadMu        sync.Mutex
+	// addresses are the addresses of the bots of the failures in the cache, as of the latest reload
+	addresses map[cache.Key]string
+	// autoRecoverInterval is the interval on which the failures that are due to be recovered are recovered
+	autoRecoverInterval time.Duration
 }
 
+const shutdownTimeout = 15 * time.Second
+
 func (restAPI *RestAPI) RunAPIController() {
-	server := getServer(restAPI.Router, restAPI.Port)
+	server := getServer(restAPI.handler, restAPI.Port)
+	servers := []*http.Server{server}
+	if restAPI.ReadOnlyPort != "" {
+		servers = append(servers, getServer(restAPI.readOnlyHandler, restAPI.ReadOnlyPort))
+	}
+
+	stopSweeper := func() {}
+	if restAPI.sweepInterval > 0 {
+		_ = level.Info(restAPI.Loggers.OutLogger).Log("msg", "starting cache eviction sweep every "+restAPI.sweepInterval.String())
+		stopSweeper = cache.StartSweeper(restAPI.cache, restAPI.sweepInterval)
+	}
+
+	stopAutoRecovery := func() {}
+	if restAPI.autoRecoverInterval > 0 {
+		_ = level.Info(restAPI.Loggers.OutLogger).Log("msg", "starting automatic recovery of expired failures every "+restAPI.autoRecoverInterval.String())
+		stopAutoRecovery = cache.StartAutoRecovery(restAPI.autoRecoverInterval, restAPI.recoverExpired)
+	}
 
 	_ = level.Info(restAPI.Loggers.OutLogger).Log("msg", "starting web server on port "+restAPI.Port)
 
@@ -34,61 +69,124 @@ func (restAPI *RestAPI) RunAPIController() {
 	e := make(chan error)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
-	go func() {
-		e <- server.ListenAndServe()
-	}()
+	if restAPI.ReadOnlyPort != "" {
+		_ = level.Info(restAPI.Loggers.OutLogger).Log("msg", "starting read only web server on port "+restAPI.ReadOnlyPort)
+	}
+
+	for _, s := range servers {
+		go func(s *http.Server) {
+			e <- restAPI.listenAndServe(s)
+		}(s)
+	}
 
 	select {
 	case err := <-e:
 		_ = level.Error(restAPI.Loggers.ErrLogger).Log("msg", "server error", "err", err)
 		os.Exit(1)
 	case <-c:
-		ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
-		_ = level.Info(restAPI.Loggers.OutLogger).Log("msg", "Gracefully shutting down server")
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		restAPI.shutdown(ctx, servers)
+		stopSweeper()
+		stopAutoRecovery()
+		cancel()
+		os.Exit(0)
+	}
+}
+
+// recoverExpired recovers the expired failures with the router of the running config
+func (restAPI *RestAPI) recoverExpired(now time.Time) {
+	restAPI.reloadMu.Lock()
+	apiRouter := restAPI.apiRouter
+	restAPI.reloadMu.Unlock()
+
+	apiRouter.RecoverExpired(now)
+}
+
+// listenAndServe serves the server on its address, accepting at most MaxConnections simultaneous connections if set
+func (restAPI *RestAPI) listenAndServe(s *http.Server) error {
+	listener, err := net.Listen("tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	if restAPI.MaxConnections > 0 {
+		listener = limitListener(listener, restAPI.MaxConnections)
+	}
+	return s.Serve(listener)
+}
+
+// shutdown gracefully shuts down the servers, and then waits for the in-flight
+// asynchronous operations to complete until the context is done
+func (restAPI *RestAPI) shutdown(ctx context.Context, servers []*http.Server) {
+	_ = level.Info(restAPI.Loggers.OutLogger).Log("msg", "Gracefully shutting down server")
 
-		err := server.Shutdown(ctx)
+	for _, s := range servers {
+		err := s.Shutdown(ctx)
 		if err != nil {
 			_ = level.Error(restAPI.Loggers.ErrLogger).Log("msg", "could not gracefully shut down server", "err", err)
 		}
-		cancel()
-		os.Exit(0)
+	}
+
+	if err := restAPI.async.Wait(ctx); err != nil {
+		_ = level.Error(restAPI.Loggers.ErrLogger).Log("msg", "asynchronous operations did not complete before shutdown", "err", err)
 	}
 }
 
 type Options struct {
+	config         *config.Config
 	restAPIOptions *config.RestAPIOptions
 	jobMap         map[string]*config.Job
 	connections    *network.Connections
 	cache          *gocache.Cache
+	async          *async.Tracker
 	loggers        chaoslogger.Loggers
 }
 
 func NewAPIOptions(
-	restAPIOptions *config.RestAPIOptions,
+	conf *config.Config,
 	jobMap map[string]*config.Job,
 	connections *network.Connections,
 	loggers chaoslogger.Loggers,
 ) *Options {
 	return &Options{
-		restAPIOptions: restAPIOptions,
+		config:         conf,
+		restAPIOptions: conf.APIOptions,
 		jobMap:         jobMap,
 		connections:    connections,
-		cache:          gocache.New(0),
+		cache:          gocache.New(cacheExpiration(conf.Cache)),
+		async:          async.NewTracker(),
 		loggers:        loggers,
 	}
 }
 
 func NewRestAPI(opt *Options, healthChecker *healthcheck.HealthChecker) *RestAPI {
-	router := mux.NewRouter()
-	apiRouter := v1.NewAPIRouter(opt.jobMap, opt.connections, opt.cache, opt.loggers)
-	router = apiRouter.AddRoutes(healthChecker, router)
-	router.Schemes(opt.restAPIOptions.Scheme)
+	restAPI := &RestAPI{
+		Loggers:        opt.loggers,
+		Port:           opt.restAPIOptions.Port,
+		MaxConnections: opt.restAPIOptions.MaxConnections,
+		cache:          opt.cache,
+		async:          opt.async,
+		apiRouter:      v1.NewAPIRouter(opt.config, opt.jobMap, opt.connections, opt.cache, opt.async, opt.loggers),
+		healthChecker:  healthChecker,
+		scheme:         opt.restAPIOptions.Scheme,
+	}
+	restAPI.handler = newSwappableHandler(restAPI.routes())
+	if opt.restAPIOptions.ReadOnlyPort != "" {
+		restAPI.readOnlyHandler = newSwappableHandler(restAPI.readOnlyRoutes())
+		restAPI.ReadOnlyPort = opt.restAPIOptions.ReadOnlyPort
+	}
+	if opt.config.Cache != nil {
+		restAPI.sweepInterval = opt.config.Cache.SweepInterval
+		restAPI.autoRecoverInterval = opt.config.Cache.AutoRecoverInterval
+	}
+
+	return restAPI
+}
 
-	return &RestAPI{
-		Router:  router,
-		Loggers: opt.loggers,
-		Port:    opt.restAPIOptions.Port,
+func cacheExpiration(cacheOptions *config.Cache) time.Duration {
+	if cacheOptions == nil {
+		return 0
 	}
+	return cacheOptions.Expiration
 }
 
 func getServer(router http.Handler, port string) *http.Server {
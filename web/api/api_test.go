@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShutdownWaitsForAsyncOperations(t *testing.T) {
+	dataItems := []struct {
+		message         string
+		operationDelay  time.Duration
+		shutdownTimeout time.Duration
+		expectCompleted bool
+	}{
+		{
+			message:         "Should wait for a running asynchronous operation to complete",
+			operationDelay:  50 * time.Millisecond,
+			shutdownTimeout: time.Second,
+			expectCompleted: true,
+		},
+		{
+			message:         "Should stop waiting for a running asynchronous operation after the shutdown timeout",
+			operationDelay:  time.Second,
+			shutdownTimeout: 50 * time.Millisecond,
+			expectCompleted: false,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			restAPI := &RestAPI{Loggers: getLoggers(), async: async.NewTracker()}
+			completed := make(chan struct{})
+			restAPI.async.Go(func() {
+				time.Sleep(dataItem.operationDelay)
+				close(completed)
+			})
+
+			ctx, cancel := context.WithTimeout(context.Background(), dataItem.shutdownTimeout)
+			defer cancel()
+			start := time.Now()
+			restAPI.shutdown(ctx, []*http.Server{{}})
+
+			select {
+			case <-completed:
+				assert.True(t, dataItem.expectCompleted, "shutdown should not wait longer than the timeout")
+			default:
+				assert.False(t, dataItem.expectCompleted, "shutdown should wait for the operation")
+				assert.True(t, time.Since(start) >= dataItem.shutdownTimeout)
+			}
+		})
+	}
+}
+
+func getLoggers() chaoslogger.Loggers {
+	allowLevel := &chaoslogger.AllowedLevel{}
+	_ = allowLevel.Set("debug")
+
+	return chaoslogger.Loggers{
+		OutLogger: chaoslogger.New(allowLevel, os.Stdout),
+		ErrLogger: chaoslogger.New(allowLevel, os.Stderr),
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net"
+	"sync"
+)
+
+// limitListener returns a listener that accepts at most n simultaneous connections.
+// Accept blocks once the limit is reached, until one of the accepted connections is closed
+func limitListener(l net.Listener, n int) net.Listener {
+	return &limitedListener{
+		Listener: l,
+		sem:      make(chan struct{}, n),
+		done:     make(chan struct{}),
+	}
+}
+
+type limitedListener struct {
+	net.Listener
+	sem       chan struct{}
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// acquire reserves a connection slot, and returns false if the listener is closed while waiting
+func (l *limitedListener) acquire() bool {
+	select {
+	case <-l.done:
+		return false
+	case l.sem <- struct{}{}:
+		return true
+	}
+}
+
+func (l *limitedListener) release() {
+	<-l.sem
+}
+
+func (l *limitedListener) Accept() (net.Conn, error) {
+	acquired := l.acquire()
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		if acquired {
+			l.release()
+		}
+		return nil, err
+	}
+	return &limitedConn{Conn: conn, release: l.release}, nil
+}
+
+func (l *limitedListener) Close() error {
+	err := l.Listener.Close()
+	l.closeOnce.Do(func() { close(l.done) })
+	return err
+}
+
+// limitedConn releases its slot of the listener exactly once when it is closed
+type limitedConn struct {
+	net.Conn
+	releaseOnce sync.Once
+	release     func()
+}
+
+func (c *limitedConn) Close() error {
+	err := c.Conn.Close()
+	c.releaseOnce.Do(c.release)
+	return err
+}
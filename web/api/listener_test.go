@@ -0,0 +1,93 @@
+package api
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitListenerDoesNotAcceptConnectionsBeyondTheLimit(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := limitListener(l, 1)
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		client, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer client.Close()
+	}
+
+	first := receiveConnection(t, accepted, time.Second)
+	if !assert.NotNil(t, first, "the first connection should be accepted") {
+		return
+	}
+	assert.Nil(t, receiveConnection(t, accepted, 100*time.Millisecond), "the second connection should wait for the first to close")
+
+	assert.Nil(t, first.Close())
+	second := receiveConnection(t, accepted, time.Second)
+	if assert.NotNil(t, second, "the second connection should be accepted after the first is closed") {
+		second.Close()
+	}
+}
+
+func TestLimitListenerStopsWaitingWhenClosed(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener := limitListener(l, 1)
+
+	client, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	errs := make(chan error)
+	go func() {
+		_, err := listener.Accept()
+		errs <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	assert.Nil(t, listener.Close())
+
+	select {
+	case err = <-errs:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("accept should return after the listener is closed")
+	}
+}
+
+func receiveConnection(t *testing.T, accepted <-chan net.Conn, timeout time.Duration) net.Conn {
+	t.Helper()
+	select {
+	case conn := <-accepted:
+		return conn
+	case <-time.After(timeout):
+		return nil
+	}
+}
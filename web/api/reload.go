@@ -0,0 +1,85 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/gorilla/mux"
+)
+
+// swappableHandler serves the requests with its handler, which can be replaced while requests are served.
+// The requests that are being served complete with the handler they started with
+type swappableHandler struct {
+	mu      sync.RWMutex
+	handler http.Handler
+}
+
+func newSwappableHandler(handler http.Handler) *swappableHandler {
+	return &swappableHandler{handler: handler}
+}
+
+func (h *swappableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	handler := h.handler
+	h.mu.RUnlock()
+
+	handler.ServeHTTP(w, r)
+}
+
+func (h *swappableHandler) swap(handler http.Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handler = handler
+}
+
+// Reload serves the jobs and the connections of a reloaded config, and health-checks the targets of the connections.
+// The cache and the running failures are kept, while the ports of the api are not reloaded
+func (restAPI *RestAPI) Reload(conf *config.Config, jobMap map[string]*config.Job, connections *network.Connections) {
+	restAPI.reloadMu.Lock()
+	defer restAPI.reloadMu.Unlock()
+
+	restAPI.apiRouter = restAPI.apiRouter.Reload(conf, jobMap, connections)
+	restAPI.healthChecker.Reload(connections)
+	restAPI.handler.swap(restAPI.routes())
+	if restAPI.readOnlyHandler != nil {
+		restAPI.readOnlyHandler.swap(restAPI.readOnlyRoutes())
+	}
+}
+
+// InUse returns the addresses of the bots of the failures in the cache, whose connections can not be closed on reload
+// since the recoveries of the failures use them. A failure keeps the address of the job it was first seen with,
+// so that a failure injected before a reload that changed the address of its job is recovered on the address it was injected on
+func (restAPI *RestAPI) InUse() []string {
+	restAPI.reloadMu.Lock()
+	defer restAPI.reloadMu.Unlock()
+
+	inUse := make(map[cache.Key]string)
+	for _, item := range restAPI.cache.GetAll() {
+		key := item.Key.(cache.Key)
+		address, ok := restAPI.addresses[key]
+		if !ok {
+			address = restAPI.apiRouter.Address(key)
+		}
+		inUse[key] = address
+	}
+	restAPI.addresses = inUse
+
+	addresses := make([]string, 0, len(inUse))
+	for _, address := range inUse {
+		addresses = append(addresses, address)
+	}
+	return addresses
+}
+
+func (restAPI *RestAPI) routes() http.Handler {
+	router := restAPI.apiRouter.AddRoutes(restAPI.healthChecker, mux.NewRouter())
+	router.Schemes(restAPI.scheme)
+	return router
+}
+
+func (restAPI *RestAPI) readOnlyRoutes() http.Handler {
+	return restAPI.apiRouter.AddReadOnlyRoutes(restAPI.healthChecker, mux.NewRouter())
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/healthcheck"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	apiv1 "github.com/SotirisAlfonsos/chaos-master/web/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadServesTheJobsOfTheReloadedConfig(t *testing.T) {
+	conf := &config.Config{APIOptions: &config.RestAPIOptions{Port: "8080", Scheme: "http"}}
+	jobMap := map[string]*config.Job{
+		"web cpu": {FailureType: config.CPU, Target: []string{"127.0.0.1:8081"}},
+	}
+	connections := &network.Connections{Pool: map[string]network.Connection{
+		"127.0.0.1:8081": healthyConnection(),
+	}}
+	restAPI := NewRestAPI(NewAPIOptions(conf, jobMap, connections, getLoggers()), nil)
+	restAPI.cache.Set(cache.Key{Job: "web cpu", Target: "127.0.0.1:8081"}, "value")
+
+	server := httptest.NewServer(restAPI.handler)
+	defer server.Close()
+
+	assert.Equal(t, []string{"web cpu"}, validatedJobs(t, server.URL))
+
+	reloadedJobMap := map[string]*config.Job{
+		"db cpu": {FailureType: config.CPU, Target: []string{"127.0.0.2:8081"}},
+	}
+	reloadedConnections := &network.Connections{Pool: map[string]network.Connection{
+		"127.0.0.2:8081": healthyConnection(),
+	}}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 10; i++ {
+			validatedJobs(t, server.URL)
+		}
+	}()
+	restAPI.Reload(conf, reloadedJobMap, reloadedConnections)
+	wg.Wait()
+
+	assert.Equal(t, []string{"db cpu"}, validatedJobs(t, server.URL))
+	assert.Equal(t, 1, restAPI.cache.ItemCount(), "the cache should be kept on reload")
+}
+
+func TestReloadHealthChecksTheTargetsOfTheReloadedConnections(t *testing.T) {
+	conf := &config.Config{APIOptions: &config.RestAPIOptions{Port: "8080", Scheme: "http"}}
+	jobMap := map[string]*config.Job{
+		"web cpu": {FailureType: config.CPU, Target: []string{"127.0.0.1:8081"}},
+	}
+	connections := &network.Connections{Pool: map[string]network.Connection{
+		"127.0.0.1:8081": healthyConnection(),
+	}}
+	healthChecker := healthcheck.Register(connections, getLoggers())
+	restAPI := NewRestAPI(NewAPIOptions(conf, jobMap, connections, getLoggers()), healthChecker)
+
+	reloadedJobMap := map[string]*config.Job{
+		"db cpu": {FailureType: config.CPU, Target: []string{"127.0.0.2:8081"}},
+	}
+	reloadedConnections := &network.Connections{Pool: map[string]network.Connection{
+		"127.0.0.2:8081": healthyConnection(),
+	}}
+	restAPI.Reload(conf, reloadedJobMap, reloadedConnections)
+
+	statuses := healthChecker.Statuses()
+	assert.Equal(t, 1, len(statuses), "the removed targets should no longer be health-checked")
+	assert.Equal(t, v1.HealthCheckResponse_SERVING, statuses["127.0.0.2:8081"], "the added targets should be health-checked")
+}
+
+func TestInUseKeepsTheAddressesTheFailuresWereInjectedOn(t *testing.T) {
+	conf := &config.Config{APIOptions: &config.RestAPIOptions{Port: "8080", Scheme: "http"}}
+	jobMap := map[string]*config.Job{
+		"web cpu": {FailureType: config.CPU, Target: []string{"127.0.0.1:8081"}, BotPort: "9000"},
+	}
+	connections := &network.Connections{Pool: map[string]network.Connection{
+		"127.0.0.1:9000": healthyConnection(),
+	}}
+	restAPI := NewRestAPI(NewAPIOptions(conf, jobMap, connections, getLoggers()), nil)
+	restAPI.cache.Set(cache.Key{Job: "web cpu", Target: "127.0.0.1:8081"}, "value")
+
+	assert.Equal(t, []string{"127.0.0.1:9000"}, restAPI.InUse())
+
+	reloadedJobMap := map[string]*config.Job{
+		"web cpu": {FailureType: config.CPU, Target: []string{"127.0.0.1:8081"}, BotPort: "9001"},
+	}
+	reloadedConnections := &network.Connections{Pool: map[string]network.Connection{
+		"127.0.0.1:9001": healthyConnection(),
+	}}
+	restAPI.Reload(conf, reloadedJobMap, reloadedConnections)
+
+	assert.Equal(t, []string{"127.0.0.1:9000"}, restAPI.InUse(), "the failure should keep the address it was injected on")
+
+	restAPI.cache.DeleteAll()
+	assert.Empty(t, restAPI.InUse())
+}
+
+func healthyConnection() *network.MockConnection {
+	return &network.MockConnection{Status: new(v1.StatusResponse), Health: v1.HealthCheckResponse_SERVING}
+}
+
+func validatedJobs(t *testing.T, url string) []string {
+	resp, err := http.Get(url + "/chaos/api/v1/validate") //nolint:gosec
+	if err != nil {
+		t.Error(err)
+		return nil
+	}
+	defer resp.Body.Close()
+
+	validations := make([]*apiv1.Validation, 0)
+	if err = json.NewDecoder(resp.Body).Decode(&validations); err != nil {
+		t.Error(err)
+		return nil
+	}
+
+	jobs := make([]string, 0, len(validations))
+	for _, validation := range validations {
+		jobs = append(jobs, validation.Job)
+	}
+	return jobs
+}
@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/go-kit/kit/log/level"
+)
+
+type contextKey int
+
+const (
+	principalKey contextKey = iota
+	clientIPKey
+)
+
+// Authenticator authenticates the requests to the api with bearer tokens.
+// If there are no tokens configured every request is allowed without a principal
+type Authenticator struct {
+	tokens  []*config.APIToken
+	loggers chaoslogger.Loggers
+}
+
+func NewAuthenticator(conf *config.Auth, loggers chaoslogger.Loggers) *Authenticator {
+	authenticator := &Authenticator{loggers: loggers}
+	if conf != nil {
+		authenticator.tokens = conf.Tokens
+	}
+	return authenticator
+}
+
+// Enabled returns true if requests have to be authenticated
+func (a *Authenticator) Enabled() bool {
+	return len(a.tokens) > 0
+}
+
+// Middleware rejects requests without a known bearer token with 401, and adds
+// the name of the token to the context of the request as the principal
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !a.Enabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		name, ok := a.authenticate(r)
+		if !ok {
+			client, _ := ClientIPFromContext(r.Context())
+			_ = level.Warn(a.loggers.OutLogger).Log("msg", "reject unauthenticated request", "client", client, "url", r.URL.String())
+			response.Unauthorized(w, r, "Missing or unknown bearer token", a.loggers)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithPrincipal(r.Context(), name)))
+	})
+}
+
+func (a *Authenticator) authenticate(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, "Bearer ") {
+		return "", false
+	}
+	token := []byte(strings.TrimPrefix(header, "Bearer "))
+
+	for _, apiToken := range a.tokens {
+		if subtle.ConstantTimeCompare(token, []byte(apiToken.Token.Value())) == 1 {
+			return apiToken.Name, true
+		}
+	}
+	return "", false
+}
+
+// WithPrincipal returns a copy of the context with the name of the authenticated principal
+func WithPrincipal(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, principalKey, name)
+}
+
+// PrincipalFromContext returns the name of the authenticated principal of the request context
+func PrincipalFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(principalKey).(string)
+	return name, ok
+}
+
+// Detach returns a context with the principal of the context that is not canceled with it, for the actions
+// that are performed on behalf of the principal after the request is done, e.g. a delayed kill
+func Detach(ctx context.Context) context.Context {
+	if name, ok := PrincipalFromContext(ctx); ok {
+		return WithPrincipal(context.Background(), name)
+	}
+	return context.Background()
+}
+
+// PrincipalMetadata is the name of the metadata of an injection with the principal that injected it
+const PrincipalMetadata = "principal"
+
+// WithPrincipalMetadata returns the metadata of an injection with the authenticated principal of the context,
+// so that every running failure can be traced to the token that injected it. The metadata is not changed
+// if the request was not authenticated
+func WithPrincipalMetadata(ctx context.Context, metadata map[string]string) map[string]string {
+	name, ok := PrincipalFromContext(ctx)
+	if !ok || name == "" {
+		return metadata
+	}
+
+	withPrincipal := make(map[string]string, len(metadata)+1)
+	for key, value := range metadata {
+		withPrincipal[key] = value
+	}
+	withPrincipal[PrincipalMetadata] = name
+	return withPrincipal
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithPrincipalMetadata(t *testing.T) {
+	dataItems := []struct {
+		message  string
+		ctx      context.Context
+		metadata map[string]string
+		expected map[string]string
+	}{
+		{
+			message:  "Should add the principal to the metadata",
+			ctx:      WithPrincipal(context.Background(), "ci-pipeline"),
+			metadata: map[string]string{"device": "eth0"},
+			expected: map[string]string{"device": "eth0", PrincipalMetadata: "ci-pipeline"},
+		},
+		{
+			message:  "Should add the principal to empty metadata",
+			ctx:      WithPrincipal(context.Background(), "ci-pipeline"),
+			expected: map[string]string{PrincipalMetadata: "ci-pipeline"},
+		},
+		{
+			message:  "Should not change the metadata of a request that was not authenticated",
+			ctx:      context.Background(),
+			metadata: map[string]string{"device": "eth0"},
+			expected: map[string]string{"device": "eth0"},
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			assert.Equal(t, dataItem.expected, WithPrincipalMetadata(dataItem.ctx, dataItem.metadata))
+		})
+	}
+}
+
+func TestDetachKeepsThePrincipal(t *testing.T) {
+	ctx, cancel := context.WithCancel(WithPrincipal(context.Background(), "ci-pipeline"))
+	cancel()
+
+	detached := Detach(ctx)
+
+	assert.NoError(t, detached.Err(), "the detached context should not be canceled with the request")
+	principal, ok := PrincipalFromContext(detached)
+	assert.True(t, ok)
+	assert.Equal(t, "ci-pipeline", principal)
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+)
+
+// ClientIPMiddleware adds the ip of the client to the context of the request
+func ClientIPMiddleware(options *config.RestAPIOptions) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r.WithContext(WithClientIP(r.Context(), ClientIP(r, options))))
+		})
+	}
+}
+
+// ClientIP returns the ip of the client of the request. The X-Forwarded-For header is only used if the request
+// comes from a trusted proxy, and its addresses are read from the right, skipping the trusted proxies,
+// since the addresses on the left can be set by the client. Otherwise the remote address is used
+func ClientIP(r *http.Request, options *config.RestAPIOptions) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !options.IsTrustedProxy(net.ParseIP(remote)) {
+		return remote
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header["X-Forwarded-For"], ","), ",")
+	client := remote
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		address := strings.TrimSpace(forwarded[i])
+		ip := net.ParseIP(address)
+		if ip == nil {
+			break
+		}
+		client = address
+		if !options.IsTrustedProxy(ip) {
+			break
+		}
+	}
+	return client
+}
+
+// WithClientIP returns a copy of the context with the ip of the client of the request
+func WithClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey, ip)
+}
+
+// ClientIPFromContext returns the ip of the client of the request context
+func ClientIPFromContext(ctx context.Context) (string, bool) {
+	ip, ok := ctx.Value(clientIPKey).(string)
+	return ip, ok
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	dataItems := []struct {
+		message        string
+		trustedProxies []string
+		remoteAddr     string
+		forwardedFor   []string
+		expected       string
+	}{
+		{
+			message:      "Should use the remote address if there are no trusted proxies",
+			remoteAddr:   "10.0.0.1:51000",
+			forwardedFor: []string{"203.0.113.7"},
+			expected:     "10.0.0.1",
+		},
+		{
+			message:        "Should use the remote address if the request does not come from a trusted proxy",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "192.168.1.5:51000",
+			forwardedFor:   []string{"203.0.113.7"},
+			expected:       "192.168.1.5",
+		},
+		{
+			message:        "Should use the forwarded address if the request comes from a trusted proxy",
+			trustedProxies: []string{"10.0.0.1"},
+			remoteAddr:     "10.0.0.1:51000",
+			forwardedFor:   []string{"203.0.113.7"},
+			expected:       "203.0.113.7",
+		},
+		{
+			message:        "Should use the right most forwarded address that is not a trusted proxy",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:51000",
+			forwardedFor:   []string{"198.51.100.1, 203.0.113.7", "10.0.0.2"},
+			expected:       "203.0.113.7",
+		},
+		{
+			message:        "Should use the remote address if the trusted proxy does not forward any address",
+			trustedProxies: []string{"10.0.0.0/8"},
+			remoteAddr:     "10.0.0.1:51000",
+			expected:       "10.0.0.1",
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+			r.RemoteAddr = dataItem.remoteAddr
+			for _, forwarded := range dataItem.forwardedFor {
+				r.Header.Add("X-Forwarded-For", forwarded)
+			}
+
+			ip := ClientIP(r, &config.RestAPIOptions{TrustedProxies: dataItem.trustedProxies})
+
+			assert.Equal(t, dataItem.expected, ip)
+		})
+	}
+}
+
+func TestClientIPMiddlewareAddsClientIPToContext(t *testing.T) {
+	options := &config.RestAPIOptions{TrustedProxies: []string{"10.0.0.1"}}
+	var ip string
+	handler := ClientIPMiddleware(options)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip, _ = ClientIPFromContext(r.Context())
+	}))
+
+	r := httptest.NewRequest(http.MethodGet, "/whoami", nil)
+	r.RemoteAddr = "10.0.0.1:51000"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7")
+	handler.ServeHTTP(httptest.NewRecorder(), r)
+
+	assert.Equal(t, "203.0.113.7", ip)
+}
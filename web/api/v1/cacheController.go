@@ -0,0 +1,193 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/auth"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/go-kit/kit/log/level"
+)
+
+// CacheItem is a running failure that can be recovered by the master. The principal that injected it
+// is omitted for failures that were injected without authentication
+type CacheItem struct {
+	Job       string `json:"job"`
+	Target    string `json:"target"`
+	Principal string `json:"principal,omitempty"`
+}
+
+// CacheEntry is a running failure with the details of its injection. The start time is omitted
+// for failures whose injection time is unknown, e.g. restored failures, and the recovery time
+// for failures that are not recovered automatically
+type CacheEntry struct {
+	Job       string            `json:"job"`
+	Target    string            `json:"target"`
+	StartsAt  *time.Time        `json:"startsAt,omitempty"`
+	RecoverAt *time.Time        `json:"recoverAt,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// Silence is a running failure in the format of an Alertmanager silence, with matchers on the job and target
+// of the failure. The start time is omitted for failures whose injection time is unknown, e.g. restored failures
+type Silence struct {
+	ID        string     `json:"id"`
+	Matchers  []*Matcher `json:"matchers"`
+	StartsAt  *time.Time `json:"startsAt,omitempty"`
+	CreatedBy string     `json:"createdBy"`
+	Comment   string     `json:"comment"`
+}
+
+type Matcher struct {
+	Name    string `json:"name"`
+	Value   string `json:"value"`
+	IsRegex bool   `json:"isRegex"`
+	IsEqual bool   `json:"isEqual"`
+}
+
+// ClearResult is the number of running failures that were dropped from the cache without being recovered
+type ClearResult struct {
+	Cleared int `json:"cleared"`
+}
+
+type CacheController struct {
+	cache       *gocache.Cache
+	persistence *cache.Persistence
+	locks       *cache.KeyLocks
+	injections  *cache.Injections
+	loggers     chaoslogger.Loggers
+}
+
+// Items godoc
+// @Summary List running failures
+// @Description Get the running failures that are kept in the cache for recovery
+// @Tags Status
+// @Produce json
+// @Success 200 {array} CacheItem
+// @Router /cache [get]
+func (c *CacheController) Items(w http.ResponseWriter, r *http.Request) {
+	items := c.cache.GetAll()
+	cacheItems := make([]*CacheItem, 0, len(items))
+	for _, item := range items {
+		key := item.Key.(cache.Key)
+		cacheItem := &CacheItem{Job: key.Job, Target: key.Target}
+		if injection, ok := c.injections.Get(key); ok {
+			cacheItem.Principal = injection.Metadata[auth.PrincipalMetadata]
+		}
+		cacheItems = append(cacheItems, cacheItem)
+	}
+
+	response.JSONResponse(w, r, http.StatusOK, cacheItems, c.loggers)
+}
+
+// Item godoc
+// @Summary Get a running failure
+// @Description Get the running failure of the job on the target, if it is kept in the cache for recovery
+// @Tags Status
+// @Produce json
+// @Param job query string true "The name of the job"
+// @Param target query string true "The target of the failure"
+// @Success 200 {object} CacheEntry
+// @Failure 400 {object} response.ErrorPayload
+// @Failure 404 {object} response.ErrorPayload
+// @Router /cache/item [get]
+func (c *CacheController) Item(w http.ResponseWriter, r *http.Request) {
+	key := cache.Key{Job: r.URL.Query().Get("job"), Target: r.URL.Query().Get("target")}
+	if key.Job == "" || key.Target == "" {
+		response.BadRequest(w, r, "The query parameters job and target are required", c.loggers)
+		return
+	}
+
+	if _, ok := c.cache.Get(key); !ok {
+		response.NotFound(w, r, fmt.Sprintf("There is no running failure of job {%s} on target {%s}", key.Job, key.Target), c.loggers)
+		return
+	}
+
+	entry := &CacheEntry{Job: key.Job, Target: key.Target}
+	if injection, ok := c.injections.Get(key); ok {
+		startsAt := injection.StartsAt
+		entry.StartsAt = &startsAt
+		entry.Labels = injection.Labels
+		entry.Metadata = injection.Metadata
+	}
+	if recoverAt, ok := c.injections.RecoverAt(key); ok {
+		entry.RecoverAt = &recoverAt
+	}
+
+	response.JSONResponse(w, r, http.StatusOK, entry, c.loggers)
+}
+
+// Silences godoc
+// @Summary Export running failures as silences
+// @Description Get the running failures that are kept in the cache for recovery, in the format of Alertmanager silences
+// @Tags Status
+// @Produce json
+// @Success 200 {array} Silence
+// @Router /cache/silences [get]
+func (c *CacheController) Silences(w http.ResponseWriter, r *http.Request) {
+	items := c.cache.GetAll()
+	silences := make([]*Silence, 0, len(items))
+	for _, item := range items {
+		key := item.Key.(cache.Key)
+		silence := &Silence{
+			ID: key.Job + "," + key.Target,
+			Matchers: []*Matcher{
+				{Name: "job", Value: key.Job, IsEqual: true},
+				{Name: "target", Value: key.Target, IsEqual: true},
+			},
+			CreatedBy: "chaos-master",
+			Comment:   "Running failure of job " + key.Job + " on target " + key.Target,
+		}
+		if injection, ok := c.injections.Get(key); ok {
+			silence.StartsAt = &injection.StartsAt
+		}
+		silences = append(silences, silence)
+	}
+
+	sort.Slice(silences, func(i, j int) bool {
+		return silences[i].ID < silences[j].ID
+	})
+
+	response.JSONResponse(w, r, http.StatusOK, silences, c.loggers)
+}
+
+// Clear godoc
+// @Summary Drop all running failures
+// @Description Drop all the running failures from the cache without recovering them, e.g. when the failures are known to be recovered already. Requires the query parameter confirm=true
+// @Tags Status
+// @Produce json
+// @Param confirm query bool true "Confirm that the failures are dropped without being recovered"
+// @Success 200 {object} ClearResult
+// @Failure 400 {object} response.ErrorPayload
+// @Router /cache/clear [post]
+func (c *CacheController) Clear(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("confirm") != "true" {
+		response.BadRequest(w, r, "The running failures are dropped without being recovered. Set the query parameter confirm=true to clear the cache", c.loggers)
+		return
+	}
+
+	cleared := 0
+	for _, item := range c.cache.GetAll() {
+		key := item.Key.(cache.Key)
+		unlock := c.locks.Lock(key)
+		if _, ok := c.cache.Get(key); ok {
+			c.cache.Delete(key)
+			cleared++
+		}
+		unlock()
+	}
+
+	_ = level.Warn(c.loggers.OutLogger).Log("msg", "Cleared the cache without recovering the running failures", "cleared", cleared)
+
+	if err := c.persistence.Save(c.cache); err != nil {
+		_ = level.Error(c.loggers.ErrLogger).Log("msg", "Could not persist the cleared cache", "err", err)
+	}
+
+	response.JSONResponse(w, r, http.StatusOK, &ClearResult{Cleared: cleared}, c.loggers)
+}
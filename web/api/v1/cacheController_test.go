@@ -0,0 +1,334 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnlyRoutes(t *testing.T) {
+	c := gocache.New(0)
+	c.Set(cache.Key{Job: "cpu job", Target: "127.0.0.1"}, "recovery")
+
+	connections := &network.Connections{
+		Pool: map[string]network.Connection{
+			"127.0.0.1": &network.MockConnection{Status: &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}},
+		},
+	}
+	jobMap := map[string]*config.Job{
+		"cpu job": {FailureType: config.CPU, Target: []string{"127.0.0.1"}},
+	}
+	apiRouter := NewAPIRouter(&config.Config{}, jobMap, connections, c, async.NewTracker(), loggers)
+	server := httptest.NewServer(apiRouter.AddReadOnlyRoutes(nil, mux.NewRouter()))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/chaos/api/v1/cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	items := make([]*CacheItem, 0)
+	if err = json.NewDecoder(resp.Body).Decode(&items); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []*CacheItem{{Job: "cpu job", Target: "127.0.0.1"}}, items)
+
+	for _, path := range []string{"/chaos/api/v1/cpu?action=start", "/chaos/api/v1/recover"} {
+		body := []byte(`{"job": "cpu job", "percentage": 50, "target": "127.0.0.1"}`)
+		resp, err := http.Post(server.URL+path, "", bytes.NewReader(body)) //nolint:gosec
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
+	assert.Equal(t, 1, c.ItemCount())
+}
+
+func TestExportCacheAsSilences(t *testing.T) {
+	c := gocache.New(0)
+	c.Set(cache.Key{Job: "cpu job", Target: "127.0.0.2"}, "restored recovery")
+
+	connections := &network.Connections{
+		Pool: map[string]network.Connection{
+			"127.0.0.1": &network.MockConnection{Status: &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}},
+		},
+	}
+	jobMap := map[string]*config.Job{
+		"cpu job": {FailureType: config.CPU, Target: []string{"127.0.0.1", "127.0.0.2"}},
+	}
+	apiRouter := NewAPIRouter(&config.Config{}, jobMap, connections, c, async.NewTracker(), loggers)
+	server := httptest.NewServer(apiRouter.AddRoutes(nil, mux.NewRouter()))
+	defer server.Close()
+
+	before := time.Now()
+	body := []byte(`{"job": "cpu job", "percentage": 50, "target": "127.0.0.1"}`)
+	resp, err := http.Post(server.URL+"/chaos/api/v1/cpu?action=start", "", bytes.NewReader(body)) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	resp, err = http.Get(server.URL + "/chaos/api/v1/cache/silences")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	silences := make([]*Silence, 0)
+	if err = json.NewDecoder(resp.Body).Decode(&silences); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, c.ItemCount(), len(silences), "there should be a silence for every running failure")
+
+	assert.Equal(t, "cpu job,127.0.0.1", silences[0].ID)
+	assert.Equal(t, []*Matcher{
+		{Name: "job", Value: "cpu job", IsEqual: true},
+		{Name: "target", Value: "127.0.0.1", IsEqual: true},
+	}, silences[0].Matchers)
+	assert.Equal(t, "chaos-master", silences[0].CreatedBy)
+	if assert.NotNil(t, silences[0].StartsAt) {
+		assert.False(t, silences[0].StartsAt.Before(before.Truncate(time.Second)), "the silence should start at the injection")
+	}
+
+	assert.Equal(t, "cpu job,127.0.0.2", silences[1].ID)
+	assert.Nil(t, silences[1].StartsAt, "the start time of a restored failure is unknown")
+}
+
+func TestGetCacheItem(t *testing.T) {
+	c := gocache.New(0)
+	connections := &network.Connections{
+		Pool: map[string]network.Connection{
+			"127.0.0.1": &network.MockConnection{Status: &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}},
+		},
+	}
+	jobMap := map[string]*config.Job{
+		"cpu job": {FailureType: config.CPU, Target: []string{"127.0.0.1", "127.0.0.2"}},
+	}
+	apiRouter := NewAPIRouter(&config.Config{}, jobMap, connections, c, async.NewTracker(), loggers)
+	server := httptest.NewServer(apiRouter.AddRoutes(nil, mux.NewRouter()))
+	defer server.Close()
+
+	body := []byte(`{"job": "cpu job", "percentage": 50, "target": "127.0.0.1", "durationSec": 3600, "labels": {"experiment": "exp-1"}}`)
+	resp, err := http.Post(server.URL+"/chaos/api/v1/cpu?action=start", "", bytes.NewReader(body)) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	dataItems := []struct {
+		message  string
+		query    string
+		expected int
+	}{
+		{
+			message:  "Should get the running failure of the job on the target",
+			query:    "?job=cpu+job&target=127.0.0.1",
+			expected: http.StatusOK,
+		},
+		{
+			message:  "Should not find a failure that is not running",
+			query:    "?job=cpu+job&target=127.0.0.2",
+			expected: http.StatusNotFound,
+		},
+		{
+			message:  "Should reject a request without a target",
+			query:    "?job=cpu+job",
+			expected: http.StatusBadRequest,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			resp, err := http.Get(server.URL + "/chaos/api/v1/cache/item" + dataItem.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, dataItem.expected, resp.StatusCode)
+			if dataItem.expected != http.StatusOK {
+				return
+			}
+
+			entry := &CacheEntry{}
+			if err = json.NewDecoder(resp.Body).Decode(entry); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, "cpu job", entry.Job)
+			assert.Equal(t, "127.0.0.1", entry.Target)
+			assert.Equal(t, map[string]string{"experiment": "exp-1"}, entry.Labels)
+			assert.NotNil(t, entry.StartsAt)
+			if assert.NotNil(t, entry.RecoverAt, "the failure with a duration should be recovered automatically") {
+				assert.True(t, entry.RecoverAt.After(*entry.StartsAt))
+			}
+		})
+	}
+}
+
+func TestCacheListsThePrincipalOfTheInjection(t *testing.T) {
+	dataItems := []struct {
+		message           string
+		auth              *config.Auth
+		token             string
+		expectedPrincipal string
+	}{
+		{
+			message:           "Should record the principal that injected the failure when authentication is enabled",
+			auth:              &config.Auth{Tokens: []*config.APIToken{{Name: "ci-pipeline", Token: "secret"}}},
+			token:             "secret",
+			expectedPrincipal: "ci-pipeline",
+		},
+		{
+			message: "Should not record a principal when authentication is disabled",
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			connections := &network.Connections{
+				Pool: map[string]network.Connection{
+					"127.0.0.1": &network.MockConnection{Status: &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}},
+				},
+			}
+			jobMap := map[string]*config.Job{
+				"cpu job": {FailureType: config.CPU, Target: []string{"127.0.0.1"}},
+			}
+			apiRouter := NewAPIRouter(&config.Config{Auth: dataItem.auth}, jobMap, connections, c, async.NewTracker(), loggers)
+			server := httptest.NewServer(apiRouter.AddRoutes(nil, mux.NewRouter()))
+			defer server.Close()
+
+			body := []byte(`{"job": "cpu job", "percentage": 50, "target": "127.0.0.1"}`)
+			if status := authenticatedCall(t, "POST", server.URL+"/chaos/api/v1/cpu?action=start", dataItem.token, body, nil); status != http.StatusOK {
+				t.Fatalf("the injection failed with status %d", status)
+			}
+
+			items := make([]*CacheItem, 0)
+			authenticatedCall(t, "GET", server.URL+"/chaos/api/v1/cache", dataItem.token, nil, &items)
+			assert.Equal(t, []*CacheItem{{Job: "cpu job", Target: "127.0.0.1", Principal: dataItem.expectedPrincipal}}, items)
+
+			entry := &CacheEntry{}
+			authenticatedCall(t, "GET", server.URL+"/chaos/api/v1/cache/item?job=cpu+job&target=127.0.0.1", dataItem.token, nil, entry)
+			assert.Equal(t, dataItem.expectedPrincipal, entry.Metadata["principal"])
+		})
+	}
+}
+
+// authenticatedCall calls the url with the bearer token, if set, decodes the json response into the result, if set,
+// and returns the status code of the response
+func authenticatedCall(t *testing.T, method string, url string, token string, body []byte, result interface{}) int {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if result != nil {
+		if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode
+}
+
+func TestClearCacheRequiresConfirmation(t *testing.T) {
+	dataItems := []struct {
+		message          string
+		query            string
+		expectedStatus   int
+		expectedRemained int
+	}{
+		{
+			message:          "Should not clear the cache without confirmation",
+			query:            "",
+			expectedStatus:   http.StatusBadRequest,
+			expectedRemained: 2,
+		},
+		{
+			message:          "Should not clear the cache if the confirmation is not true",
+			query:            "?confirm=false",
+			expectedStatus:   http.StatusBadRequest,
+			expectedRemained: 2,
+		},
+		{
+			message:          "Should clear the cache with confirmation",
+			query:            "?confirm=true",
+			expectedStatus:   http.StatusOK,
+			expectedRemained: 0,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			c.Set(cache.Key{Job: "cpu job", Target: "127.0.0.1"}, "recovery")
+			c.Set(cache.Key{Job: "cpu job", Target: "127.0.0.2"}, "recovery")
+
+			apiRouter := NewAPIRouter(&config.Config{}, map[string]*config.Job{}, &network.Connections{}, c, async.NewTracker(), loggers)
+			server := httptest.NewServer(apiRouter.AddRoutes(nil, mux.NewRouter()))
+			defer server.Close()
+
+			resp, err := http.Post(server.URL+"/chaos/api/v1/cache/clear"+dataItem.query, "", nil) //nolint:gosec
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, dataItem.expectedStatus, resp.StatusCode)
+			assert.Equal(t, dataItem.expectedRemained, c.ItemCount())
+			if dataItem.expectedStatus != http.StatusOK {
+				return
+			}
+
+			result := &ClearResult{}
+			if err = json.NewDecoder(resp.Body).Decode(result); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, 2, result.Cleared)
+		})
+	}
+}
+
+func TestClearCacheIsNotServedOnTheReadOnlyRoutes(t *testing.T) {
+	c := gocache.New(0)
+	c.Set(cache.Key{Job: "cpu job", Target: "127.0.0.1"}, "recovery")
+
+	apiRouter := NewAPIRouter(&config.Config{}, map[string]*config.Job{}, &network.Connections{}, c, async.NewTracker(), loggers)
+	server := httptest.NewServer(apiRouter.AddReadOnlyRoutes(nil, mux.NewRouter()))
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/chaos/api/v1/cache/clear?confirm=true", "", nil) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, 1, c.ItemCount())
+}
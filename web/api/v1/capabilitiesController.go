@@ -0,0 +1,61 @@
+package v1
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/cpu"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/docker"
+	apiNetwork "github.com/SotirisAlfonsos/chaos-master/web/api/v1/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/server"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/service"
+)
+
+// Capability contains the actions and the request payload fields supported by a failure type
+type Capability struct {
+	Actions       []string `json:"actions"`
+	PayloadFields []string `json:"payloadFields"`
+}
+
+type Capabilities struct {
+	loggers chaoslogger.Loggers
+}
+
+func supportedCapabilities() map[config.FailureType]*Capability {
+	return map[config.FailureType]*Capability{
+		config.CPU:     {Actions: cpu.Actions(), PayloadFields: payloadFields(cpu.RequestPayload{})},
+		config.Docker:  {Actions: docker.Actions(), PayloadFields: payloadFields(docker.RequestPayload{})},
+		config.Network: {Actions: apiNetwork.Actions(), PayloadFields: payloadFields(apiNetwork.RequestPayload{})},
+		config.Server:  {Actions: server.Actions(), PayloadFields: payloadFields(server.RequestPayload{})},
+		config.Service: {Actions: service.Actions(), PayloadFields: payloadFields(service.RequestPayload{})},
+	}
+}
+
+// payloadFields returns the json names of the fields of the request payload
+func payloadFields(payload interface{}) []string {
+	payloadType := reflect.TypeOf(payload)
+	fields := make([]string, 0, payloadType.NumField())
+	for i := 0; i < payloadType.NumField(); i++ {
+		name := strings.Split(payloadType.Field(i).Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, name)
+	}
+	return fields
+}
+
+// Capabilities godoc
+// @Summary List capabilities
+// @Description Get the supported actions and request payload fields of every failure type
+// @Tags Status
+// @Produce json
+// @Success 200 {object} map[string]Capability
+// @Router /capabilities [get]
+func (c *Capabilities) Capabilities(w http.ResponseWriter, r *http.Request) {
+	response.JSONResponse(w, r, http.StatusOK, supportedCapabilities(), c.loggers)
+}
@@ -0,0 +1,32 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilities(t *testing.T) {
+	server := apiHTTPTestServer(&config.Config{})
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/chaos/api/v1/capabilities")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	capabilities := make(map[config.FailureType]*Capability)
+	if err = json.NewDecoder(resp.Body).Decode(&capabilities); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, supportedCapabilities(), capabilities)
+	assert.Equal(t, []string{"recover", "start"}, capabilities[config.CPU].Actions)
+	assert.Equal(t, []string{"job", "percentage", "target", "labels", "durationSec", "steps"}, capabilities[config.CPU].PayloadFields)
+	assert.Equal(t, []string{"kill"}, capabilities[config.Server].Actions)
+}
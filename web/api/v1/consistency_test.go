@@ -0,0 +1,188 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/limiter"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// Run with -race to also detect unsynchronized access to the cache
+func TestConcurrentInjectAndRecoverOfSameKeyLeaveConsistentCache(t *testing.T) {
+	connection := &statefulConnection{}
+	jobMap := map[string]*config.Job{
+		"service job": {FailureType: config.Service, ComponentName: "nginx", Target: []string{"127.0.0.1"}},
+	}
+	connections := &network.Connections{Pool: map[string]network.Connection{"127.0.0.1": connection}}
+	cacheManager := gocache.New(0)
+
+	apiRouter := NewAPIRouter(&config.Config{}, jobMap, connections, cacheManager, async.NewTracker(), loggers)
+	server := httptest.NewServer(apiRouter.AddRoutes(nil, mux.NewRouter()))
+	defer server.Close()
+
+	for i := 0; i < 30; i++ {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			postJSON(t, server.URL+"/chaos/api/v1/service?action=kill", `{"job": "service job", "serviceName": "nginx", "target": "127.0.0.1"}`)
+		}()
+		go func() {
+			defer wg.Done()
+			postJSON(t, server.URL+"/chaos/api/v1/recover", `{"recoverAll": true}`)
+		}()
+		wg.Wait()
+
+		assert.Equal(t, connection.isKilled(), cacheManager.ItemCount() == 1,
+			"the cache should contain the failure if and only if the service is killed")
+	}
+}
+
+// A kill that is blocked on the bot holds the lock of the key, so that the bulk kill and the recovery
+// of the same key both wait for it and then compete for the lock and the only call in flight to the bot
+func TestConcurrentBulkInjectAndRecoverOfSameKeyDoNotDeadlockWithLimitOfOne(t *testing.T) {
+	connection := &blockingConnection{}
+	jobMap := map[string]*config.Job{
+		"service job": {FailureType: config.Service, ComponentName: "nginx", Target: []string{"127.0.0.1"}},
+	}
+	connections := &network.Connections{Pool: map[string]network.Connection{"127.0.0.1": connection}, Limiter: limiter.New(1)}
+
+	apiRouter := NewAPIRouter(&config.Config{}, jobMap, connections, gocache.New(0), async.NewTracker(), loggers)
+	// the server is not closed if the test fails, since closing it waits for the requests that wait forever
+	server := httptest.NewServer(apiRouter.AddRoutes(nil, mux.NewRouter()))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 20; i++ {
+			release, entered := connection.blockNextKill()
+
+			var wg sync.WaitGroup
+			wg.Add(3)
+			go func() {
+				defer wg.Done()
+				postJSON(t, server.URL+"/chaos/api/v1/service?action=kill", `{"job": "service job", "serviceName": "nginx", "target": "127.0.0.1"}`)
+			}()
+			<-entered
+			go func() {
+				defer wg.Done()
+				postJSON(t, server.URL+"/chaos/api/v1/service?action=kill&do=all", `{"job": "service job", "serviceName": "nginx"}`)
+			}()
+			go func() {
+				defer wg.Done()
+				postJSON(t, server.URL+"/chaos/api/v1/recover", `{"recoverAll": true}`)
+			}()
+			time.Sleep(20 * time.Millisecond)
+			close(release)
+			wg.Wait()
+		}
+	}()
+
+	select {
+	case <-done:
+		server.Close()
+	case <-time.After(10 * time.Second):
+		t.Fatal("the bulk kill and the recovery of the same key should not wait for each other forever")
+	}
+}
+
+func postJSON(t *testing.T, url string, body string) {
+	resp, err := http.Post(url, "application/json", bytes.NewReader([]byte(body))) //nolint:gosec
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	resp.Body.Close()
+}
+
+// statefulConnection keeps whether the service of the bot is killed, and widens the window
+// between the call to the bot and the update of the cache with a short sleep
+type statefulConnection struct {
+	network.MockConnection
+	mu     sync.Mutex
+	killed bool
+}
+
+func (connection *statefulConnection) GetServiceClient() (v1.ServiceClient, error) {
+	return &statefulServiceClient{connection: connection}, nil
+}
+
+func (connection *statefulConnection) isKilled() bool {
+	connection.mu.Lock()
+	defer connection.mu.Unlock()
+	return connection.killed
+}
+
+func (connection *statefulConnection) setKilled(killed bool) *v1.StatusResponse {
+	connection.mu.Lock()
+	connection.killed = killed
+	connection.mu.Unlock()
+	time.Sleep(time.Millisecond)
+	return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}
+}
+
+type statefulServiceClient struct {
+	connection *statefulConnection
+}
+
+func (client *statefulServiceClient) Kill(_ context.Context, _ *v1.ServiceRequest, _ ...grpc.CallOption) (*v1.StatusResponse, error) {
+	return client.connection.setKilled(true), nil
+}
+
+func (client *statefulServiceClient) Recover(_ context.Context, _ *v1.ServiceRequest, _ ...grpc.CallOption) (*v1.StatusResponse, error) {
+	return client.connection.setKilled(false), nil
+}
+
+// blockingConnection blocks the next kill on the bot until it is released
+type blockingConnection struct {
+	network.MockConnection
+	mu      sync.Mutex
+	release chan struct{}
+	entered chan struct{}
+}
+
+func (connection *blockingConnection) GetServiceClient() (v1.ServiceClient, error) {
+	return &blockingServiceClient{connection: connection}, nil
+}
+
+func (connection *blockingConnection) blockNextKill() (chan struct{}, chan struct{}) {
+	connection.mu.Lock()
+	defer connection.mu.Unlock()
+	connection.release = make(chan struct{})
+	connection.entered = make(chan struct{}, 1)
+	return connection.release, connection.entered
+}
+
+type blockingServiceClient struct {
+	connection *blockingConnection
+}
+
+func (client *blockingServiceClient) Kill(_ context.Context, _ *v1.ServiceRequest, _ ...grpc.CallOption) (*v1.StatusResponse, error) {
+	client.connection.mu.Lock()
+	release, entered := client.connection.release, client.connection.entered
+	client.connection.release = nil
+	client.connection.mu.Unlock()
+
+	if release != nil {
+		entered <- struct{}{}
+		<-release
+	}
+	return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+}
+
+func (client *blockingServiceClient) Recover(_ context.Context, _ *v1.ServiceRequest, _ ...grpc.CallOption) (*v1.StatusResponse, error) {
+	return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/healthcheck"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/grafana"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/metrics"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/SotirisAlfonsos/gocache"
+)
+
+// Options contains the dependencies of the controllers of the failures, which the router builds once for all of them.
+// Every controller reads only the options it needs, and the options that are not set are disabled
+type Options struct {
+	Connections *network.Connections
+	Cache       *gocache.Cache
+	Persistence *cache.Persistence
+	Locks       *cache.KeyLocks
+	Injections  *cache.Injections
+	InFlight    *metrics.InFlight
+	Outcomes    *metrics.Outcomes
+	Aliases     config.ActionAliases
+	Guard       *healthcheck.Guard
+	Annotator   *grafana.Annotator
+	Messages    *response.MessageTemplate
+	Async       *async.Tracker
+	Loggers     chaoslogger.Loggers
+
+	// HealthChecker skews the random target selection of docker jobs by the health of the bots
+	HealthChecker   *healthcheck.HealthChecker
+	RandomSelection *config.RandomSelection
+	Docker          *config.DockerOptions
+	NetworkLimits   *config.NetworkLimits
+	Recover         *config.Recover
+}
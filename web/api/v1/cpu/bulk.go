@@ -0,0 +1,88 @@
+package cpu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/go-kit/kit/log/level"
+)
+
+// all is the do query parameter that performs the action on all the targets of the job
+const all = "all"
+
+// allCPU performs the action on all the targets of the job concurrently, and responds with the result of every
+// target. The cache is updated for every target on which the action succeeded
+func (c *CController) allCPU(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	requestPayload := &RequestPayload{}
+	err := json.NewDecoder(r.Body).Decode(&requestPayload)
+	if err != nil {
+		response.BadRequest(w, r, "Could not decode request body", c.loggers)
+		return
+	}
+
+	if err = network.ValidateLabels(requestPayload.Labels); err != nil {
+		response.BadRequest(w, r, err.Error(), c.loggers)
+		return
+	}
+
+	action, err := toActionEnum(r.FormValue("action"), c.aliases)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), c.loggers)
+		return
+	}
+
+	job, ok := c.jobs[requestPayload.Job]
+	if !ok {
+		response.BadRequest(w, r, fmt.Sprintf("Could not find job {%s}", requestPayload.Job), c.loggers)
+		return
+	}
+
+	if len(job.Target) == 0 {
+		response.BadRequest(w, r, fmt.Sprintf("There are no targets for job {%s}", requestPayload.Job), c.loggers)
+		return
+	}
+
+	err = checkSteps(requestPayload)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), c.loggers)
+		return
+	}
+
+	if !job.Allows(action.String()) {
+		response.Forbidden(w, r, fmt.Sprintf("The action {%s} is not allowed for job {%s}", action, requestPayload.Job), c.loggers)
+		return
+	}
+
+	if action == start && r.FormValue("confirmProduction") != "true" {
+		for _, target := range job.Target {
+			if job.RequiresConfirmation(target) {
+				response.Forbidden(w, r, fmt.Sprintf("The target {%s} is a production target. Set the query parameter confirmProduction=true to inject", target), c.loggers)
+				return
+			}
+		}
+	}
+
+	if action == start {
+		if err = c.guard.Check(requestPayload.Job, job, job.Target); err != nil {
+			response.TooManyRequests(w, r, err.Error(), c.loggers)
+			return
+		}
+	}
+
+	_ = level.Info(c.loggers.OutLogger).Log("msg", fmt.Sprintf("%s CPU injection on all the targets of the job", action), "job", requestPayload.Job, "targets", len(job.Target))
+
+	payload := response.Bulk(job.Target, func(target string) (string, error) {
+		targetPayload := *requestPayload
+		targetPayload.Target = target
+		return c.performAction(ctx, action, &targetPayload)
+	})
+
+	response.JSONResponse(w, r, payload.Status, payload, c.loggers)
+}
@@ -0,0 +1,254 @@
+package cpu
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/healthcheck"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/limiter"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestCPUStartOnAllTargets(t *testing.T) {
+	dataItems := []struct {
+		message           string
+		connectionPool    map[string]*cConnection
+		expectedStatus    int
+		expectedResults   map[string]string
+		expectedCacheSize int
+	}{
+		{
+			message:           "Should start cpu injection on all the targets of the job and add them in cache",
+			connectionPool:    map[string]*cConnection{"127.0.0.1": withSuccessCPUConnection(), "127.0.0.2": withSuccessCPUConnection()},
+			expectedStatus:    http.StatusOK,
+			expectedResults:   map[string]string{"127.0.0.1": response.SUCCESS.String(), "127.0.0.2": response.SUCCESS.String()},
+			expectedCacheSize: 2,
+		},
+		{
+			message:           "Should receive internal server error, report the successful targets and add only them in cache if the action failed on a target",
+			connectionPool:    map[string]*cConnection{"127.0.0.1": withSuccessCPUConnection(), "127.0.0.2": withFailureCPUConnection()},
+			expectedStatus:    http.StatusInternalServerError,
+			expectedResults:   map[string]string{"127.0.0.1": response.SUCCESS.String(), "127.0.0.2": response.FAILURE.String()},
+			expectedCacheSize: 1,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			jobMap := map[string]*config.Job{"job name": newCPUJob("127.0.0.1", "127.0.0.2")}
+			server, err := cpuHTTPTestServerWithCacheItems(jobMap, dataItem.connectionPool, c, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			requestBody, _ := json.Marshal(&RequestPayload{Job: "job name", Percentage: 100})
+			resp, err := http.Post(server.URL+"/cpu?do=all&action=start", "", bytes.NewReader(requestBody)) //nolint:gosec
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			payload := &response.BulkPayload{}
+			if err = json.NewDecoder(resp.Body).Decode(payload); err != nil {
+				t.Fatal(err)
+			}
+
+			results := make(map[string]string)
+			for _, result := range payload.Results {
+				results[result.Target] = result.Status
+			}
+			assert.Equal(t, dataItem.expectedStatus, resp.StatusCode)
+			assert.Equal(t, dataItem.expectedResults, results)
+			assert.Equal(t, dataItem.expectedCacheSize, c.ItemCount())
+			_, ok := c.Get(cache.Key{Job: "job name", Target: "127.0.0.1"})
+			assert.True(t, ok, "the target on which the action succeeded should be in cache")
+		})
+	}
+}
+
+func TestCPUStartOnAllTargetsOfAJobWithoutTargets(t *testing.T) {
+	c := gocache.New(0)
+	jobMap := map[string]*config.Job{"job name": newCPUJob()}
+	server, err := cpuHTTPTestServerWithCacheItems(jobMap, map[string]*cConnection{}, c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	requestBody, _ := json.Marshal(&RequestPayload{Job: "job name", Percentage: 100})
+	status, message, err := post(requestBody, server.URL+"/cpu?do=all&action=start")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "There are no targets for job {job name}", message)
+	assert.Equal(t, 0, c.ItemCount())
+}
+
+func TestCPUStartOnAllTargetsWithAnInvalidLabelName(t *testing.T) {
+	c := gocache.New(0)
+	jobMap := map[string]*config.Job{"job name": newCPUJob("127.0.0.1", "127.0.0.2")}
+	connectionPool := map[string]*cConnection{"127.0.0.1": withSuccessCPUConnection(), "127.0.0.2": withSuccessCPUConnection()}
+	server, err := cpuHTTPTestServerWithCacheItems(jobMap, connectionPool, c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	requestBody, _ := json.Marshal(&RequestPayload{Job: "job name", Percentage: 100, Labels: map[string]string{"experiment id": "exp-1"}})
+	status, message, err := post(requestBody, server.URL+"/cpu?do=all&action=start")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "The label name {experiment id} should contain only letters, digits, '-', '_' and '.'", message)
+	assert.Equal(t, 0, len(c.GetAll()), "no target should be injected with an invalid label name")
+}
+
+func TestCPUStartIsRejectedIfTooFewHealthyTargetsWouldRemain(t *testing.T) {
+	dataItems := []struct {
+		message         string
+		do              string
+		expectedMessage string
+	}{
+		{
+			message:         "Should reject the injection on all the targets of the job",
+			do:              "all",
+			expectedMessage: "Injecting on {2} targets of job {job name} would leave {0} healthy targets, fewer than the minimum of {2}",
+		},
+		{
+			message:         "Should reject the injection on a random target of the job",
+			do:              "random",
+			expectedMessage: "Injecting on {1} targets of job {job name} would leave {1} healthy targets, fewer than the minimum of {2}",
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			healthChecker := &healthcheck.HealthChecker{DetailsMap: map[string]*healthcheck.Details{
+				"127.0.0.1": {Status: v1.HealthCheckResponse_SERVING},
+				"127.0.0.2": {Status: v1.HealthCheckResponse_SERVING},
+			}}
+			cController := &CController{
+				jobs:           map[string]*config.Job{"job name": newCPUJob("127.0.0.1", "127.0.0.2")},
+				connectionPool: map[string]*cConnection{"127.0.0.1": withSuccessCPUConnection(), "127.0.0.2": withSuccessCPUConnection()},
+				cache:          c,
+				guard:          healthcheck.NewGuard(healthChecker, c, &config.Limits{MinHealthyTargets: 2}),
+				loggers:        loggers,
+			}
+			router := mux.NewRouter()
+			router.HandleFunc("/cpu", cController.CPUAction).
+				Queries("action", "{action}").
+				Methods("POST")
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			requestBody, _ := json.Marshal(&RequestPayload{Job: "job name", Percentage: 100})
+			status, message, err := post(requestBody, server.URL+"/cpu?do="+dataItem.do+"&action=start")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, http.StatusTooManyRequests, status)
+			assert.Equal(t, dataItem.expectedMessage, message)
+			assert.Equal(t, 0, c.ItemCount())
+		})
+	}
+}
+
+func TestCPUStartOnAllTargetsLimitsTheCallsInFlightPerTarget(t *testing.T) {
+	connections := map[string]*instrumentedCPUConnection{"127.0.0.1": {}, "127.0.0.2": {}}
+	cController := &CController{
+		jobs: map[string]*config.Job{
+			"job A": newCPUJob("127.0.0.1", "127.0.0.2"),
+			"job B": newCPUJob("127.0.0.1", "127.0.0.2"),
+		},
+		connectionPool: map[string]*cConnection{
+			"127.0.0.1": {connection: connections["127.0.0.1"]},
+			"127.0.0.2": {connection: connections["127.0.0.2"]},
+		},
+		cache:   gocache.New(0),
+		limiter: limiter.New(1),
+		loggers: loggers,
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/cpu", cController.CPUAction).
+		Queries("action", "{action}").
+		Methods("POST")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 6; i++ {
+		wg.Add(1)
+		go func(job string) {
+			defer wg.Done()
+			requestBody, _ := json.Marshal(&RequestPayload{Job: job, Percentage: 100})
+			status, _, err := post(requestBody, server.URL+"/cpu?do=all&action=start")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			assert.Equal(t, http.StatusOK, status)
+		}([]string{"job A", "job B"}[i%2])
+	}
+	wg.Wait()
+
+	for target, connection := range connections {
+		assert.Equal(t, 6, connection.calls, "every request should start the injection on target {%s}", target)
+		assert.Equal(t, 1, connection.maxInFlight, "the calls in flight to target {%s} should not exceed the limit", target)
+	}
+}
+
+// instrumentedCPUConnection records the calls to its cpu client, and the most calls that were in flight at once
+type instrumentedCPUConnection struct {
+	network.MockConnection
+	mu          sync.Mutex
+	calls       int
+	inFlight    int
+	maxInFlight int
+}
+
+func (connection *instrumentedCPUConnection) GetCPUClient() (v1.CPUClient, error) {
+	return connection, nil
+}
+
+func (connection *instrumentedCPUConnection) Start(_ context.Context, _ *v1.CPURequest, _ ...grpc.CallOption) (*v1.StatusResponse, error) {
+	connection.mu.Lock()
+	connection.calls++
+	connection.inFlight++
+	if connection.inFlight > connection.maxInFlight {
+		connection.maxInFlight = connection.inFlight
+	}
+	connection.mu.Unlock()
+
+	time.Sleep(5 * time.Millisecond)
+
+	connection.mu.Lock()
+	connection.inFlight--
+	connection.mu.Unlock()
+	return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+}
+
+func (connection *instrumentedCPUConnection) Recover(_ context.Context, _ *v1.CPURequest, _ ...grpc.CallOption) (*v1.StatusResponse, error) {
+	return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+}
@@ -6,12 +6,22 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
 	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/healthcheck"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/grafana"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/limiter"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/metrics"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/retry"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/target"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/auth"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/controller"
 	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
 	"github.com/SotirisAlfonsos/gocache"
 	"github.com/go-kit/kit/log/level"
@@ -22,6 +32,18 @@ type CController struct {
 	jobs           map[string]*config.Job
 	connectionPool map[string]*cConnection
 	cache          *gocache.Cache
+	persistence    *cache.Persistence
+	locks          *cache.KeyLocks
+	injections     *cache.Injections
+	inFlight       *metrics.InFlight
+	outcomes       *metrics.Outcomes
+	backoff        *network.Backoff
+	retry          *retry.Policy
+	limiter        *limiter.Limiter
+	aliases        config.ActionAliases
+	guard          *healthcheck.Guard
+	annotator      *grafana.Annotator
+	messages       *response.MessageTemplate
 	loggers        chaoslogger.Loggers
 }
 
@@ -41,8 +63,17 @@ func (a action) String() string {
 	return [...]string{"recover", "start"}[a]
 }
 
-func toActionEnum(value string) (action, error) {
-	switch value {
+// Actions returns the names of all the actions supported for cpu injections
+func Actions() []string {
+	actions := make([]string, 0, int(notImplemented))
+	for a := action(0); a < notImplemented; a++ {
+		actions = append(actions, a.String())
+	}
+	return actions
+}
+
+func toActionEnum(value string, aliases config.ActionAliases) (action, error) {
+	switch strings.ToLower(aliases.Resolve(value)) {
 	case start.String():
 		return start, nil
 	case recoverFailure.String():
@@ -51,14 +82,9 @@ func toActionEnum(value string) (action, error) {
 	return notImplemented, errors.New(fmt.Sprintf("The action {%s} is not supported", value))
 }
 
-func NewCPUController(
-	jobs map[string]*config.Job,
-	connections *network.Connections,
-	cache *gocache.Cache,
-	loggers chaoslogger.Loggers,
-) *CController {
+func NewCPUController(jobs map[string]*config.Job, options *controller.Options) *CController {
 	connPool := make(map[string]*cConnection)
-	for target, connection := range connections.Pool {
+	for target, connection := range options.Connections.Pool {
 		connPool[target] = &cConnection{
 			connection: connection,
 		}
@@ -66,69 +92,172 @@ func NewCPUController(
 	return &CController{
 		jobs:           jobs,
 		connectionPool: connPool,
-		cache:          cache,
-		loggers:        loggers,
+		cache:          options.Cache,
+		persistence:    options.Persistence,
+		locks:          options.Locks,
+		injections:     options.Injections,
+		inFlight:       options.InFlight,
+		outcomes:       options.Outcomes,
+		backoff:        options.Connections.Backoff,
+		retry:          options.Connections.Retry,
+		limiter:        options.Connections.Limiter,
+		aliases:        options.Aliases,
+		guard:          options.Guard,
+		annotator:      options.Annotator,
+		messages:       options.Messages,
+		loggers:        options.Loggers,
 	}
 }
 
 type RequestPayload struct {
-	Job        string `json:"job"`
-	Percentage int32  `json:"percentage"`
-	Target     string `json:"target"`
+	Job         string            `json:"job"`
+	Percentage  int32             `json:"percentage"`
+	Target      string            `json:"target"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	DurationSec int               `json:"durationSec,omitempty"`
+	Steps       []*Step           `json:"steps,omitempty"`
+
+	// pulse is set for the injection of a pulse, which recovers the injection itself
+	pulse bool
 }
 
+// Step is a percentage of a ramp-up schedule that is held for its duration before the next step is started
+type Step struct {
+	Percentage int32 `json:"percentage"`
+	HoldSec    int   `json:"holdSec"`
+}
+
+// newCPURequest returns the bot request for the percentage of the request, or of the last step of its schedule
 func newCPURequest(details *RequestPayload) *v1.CPURequest {
+	percentage := details.Percentage
+	if len(details.Steps) > 0 {
+		percentage = details.Steps[len(details.Steps)-1].Percentage
+	}
 	return &v1.CPURequest{
-		Percentage: details.Percentage,
+		Percentage: percentage,
 	}
 }
 
+// resolve returns the request with the defaults of its job applied, as it is performed
+func resolve(job *config.Job, request *RequestPayload) *RequestPayload {
+	resolved := *request
+	resolved.DurationSec = int(job.RecoverAfter(request.DurationSec) / time.Second)
+	resolved.Percentage = newCPURequest(request).Percentage
+	return &resolved
+}
+
 // CPUAction godoc
 // @Summary Inject CPU failures
-// @Description Perform CPU spike injection. Provide a percentage and the cpu usage will increase based on it
+// @Description Perform CPU spike injection. Provide a percentage and the cpu usage will increase based on it.
+// @Description If random is specified you do not have to provide a target
 // @Tags Failure injections
 // @Accept json
 // @Produce json
+// @Param do query string false "Specify to perform the action on a random target of the job, or on all its targets" Enums(random, all)
 // @Param action query string true "Specify to perform a start or a recover for the CPU injection" Enums(start, recover)
+// @Param confirmProduction query bool false "Confirm the injection on a target labeled env: prod"
 // @Param requestPayload body RequestPayload true "Specify the job name, percentage and target"
 // @Success 200 {object} response.Payload
-// @Failure 400 {string} http.Error
-// @Failure 500 {string} http.Error
+// @Success 200 {object} response.BulkPayload "With do=all"
+// @Failure 400 {object} response.ErrorPayload
+// @Failure 500 {object} response.ErrorPayload
 // @Router /cpu [post]
 func (c *CController) CPUAction(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithCancel(context.Background())
+	if r.FormValue("do") == all {
+		c.allCPU(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
 	requestPayload := &RequestPayload{}
 	err := json.NewDecoder(r.Body).Decode(&requestPayload)
 	if err != nil {
-		response.BadRequest(w, "Could not decode request body", c.loggers)
+		response.BadRequest(w, r, "Could not decode request body", c.loggers)
+		return
+	}
+
+	if err = network.ValidateLabels(requestPayload.Labels); err != nil {
+		response.BadRequest(w, r, err.Error(), c.loggers)
+		return
+	}
+
+	action, err := toActionEnum(r.FormValue("action"), c.aliases)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), c.loggers)
 		return
 	}
 
-	action, err := toActionEnum(r.FormValue("action"))
+	err = setTarget(c.jobs, r.FormValue("do"), requestPayload)
 	if err != nil {
-		response.BadRequest(w, err.Error(), c.loggers)
+		response.BadRequest(w, r, err.Error(), c.loggers)
 		return
 	}
+	if r.FormValue("do") == "random" {
+		_ = level.Info(c.loggers.OutLogger).Log("msg", "selected random target", "job", requestPayload.Job, "target", requestPayload.Target)
+	}
 
-	err = checkIfTargetExists(c.jobs, requestPayload)
+	err = checkSteps(requestPayload)
 	if err != nil {
-		response.BadRequest(w, err.Error(), c.loggers)
+		response.BadRequest(w, r, err.Error(), c.loggers)
+		return
+	}
+
+	if !c.jobs[requestPayload.Job].Allows(action.String()) {
+		response.Forbidden(w, r, fmt.Sprintf("The action {%s} is not allowed for job {%s}", action, requestPayload.Job), c.loggers)
+		return
+	}
+
+	if action == start && c.jobs[requestPayload.Job].RequiresConfirmation(requestPayload.Target) && r.FormValue("confirmProduction") != "true" {
+		response.Forbidden(w, r, fmt.Sprintf("The target {%s} is a production target. Set the query parameter confirmProduction=true to inject", requestPayload.Target), c.loggers)
 		return
 	}
 
+	if action == start && r.FormValue("do") == "random" {
+		if err = c.guard.Check(requestPayload.Job, c.jobs[requestPayload.Job], []string{requestPayload.Target}); err != nil {
+			response.TooManyRequests(w, r, err.Error(), c.loggers)
+			return
+		}
+	}
+
 	_ = level.Info(c.loggers.OutLogger).Log("msg", fmt.Sprintf("%s CPU injection on targets {%s}", action, requestPayload.Target))
 
 	message, err := c.performAction(ctx, action, requestPayload)
 	if err != nil {
-		response.InternalServerError(w, err.Error(), c.loggers)
+		response.BotError(w, r, err, c.loggers)
 		return
 	}
 
 	_ = level.Info(c.loggers.OutLogger).Log("msg", message)
 
-	response.OkResponse(w, message, c.loggers)
+	response.OkResponseWithRequest(w, r, message, resolve(c.jobs[requestPayload.Job], requestPayload), c.loggers)
+}
+
+// setTarget checks that the target of the request is registered for the job, or sets a random target of the job with do=random
+func setTarget(jobMap map[string]*config.Job, do string, requestPayload *RequestPayload) error {
+	switch do {
+	case "":
+		return checkIfTargetExists(jobMap, requestPayload)
+	case "random":
+		return setRandomTargetIfExists(jobMap, requestPayload)
+	}
+	return errors.New(fmt.Sprintf("Do query parameter {%s} not allowed", do))
+}
+
+func setRandomTargetIfExists(jobMap map[string]*config.Job, requestPayload *RequestPayload) error {
+	job, ok := jobMap[requestPayload.Job]
+	if !ok {
+		return errors.New(fmt.Sprintf("Could not find job {%s}", requestPayload.Job))
+	}
+
+	randomTarget, err := target.Random(job)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Could not get random target for job {%s}. err: %s", requestPayload.Job, err.Error()))
+	}
+
+	requestPayload.Target = randomTarget
+	return nil
 }
 
 func checkIfTargetExists(jobMap map[string]*config.Job, requestPayload *RequestPayload) error {
@@ -145,6 +274,16 @@ func checkIfTargetExists(jobMap map[string]*config.Job, requestPayload *RequestP
 	return nil
 }
 
+func checkSteps(requestPayload *RequestPayload) error {
+	for i, step := range requestPayload.Steps {
+		if step == nil || step.HoldSec < 0 {
+			return errors.New(fmt.Sprintf("Step {%d} of the schedule should have a hold duration that is not negative", i))
+		}
+	}
+
+	return nil
+}
+
 func checkIfTargetExistsForJob(job *config.Job, requestTarget string) bool {
 	for _, target := range job.Target {
 		if target == requestTarget {
@@ -160,37 +299,118 @@ func (c *CController) performAction(
 	action action,
 	request *RequestPayload,
 ) (string, error) {
+	address := c.jobs[request.Job].Address(request.Target)
+	if action == start {
+		if err := c.backoff.Check(address); err != nil {
+			return "", err
+		}
+		defer c.inFlight.Track(config.CPU)()
+	}
+
+	unlock := c.locks.Lock(cache.Key{Job: request.Job, Target: request.Target})
+	defer unlock()
+	defer c.limiter.Acquire(address)()
+
+	ctx = network.WithLabels(ctx, request.Labels)
 	var statusResponse *v1.StatusResponse
 	var err error
-	connection := c.connectionPool[request.Target].connection
+	connection := c.connectionPool[address].connection
 
 	cpuClient, err := connection.GetCPUClient()
 	if err != nil {
 		return "", errors.Wrap(err, fmt.Sprintf("Can not get cpu connection for target {%s}", request.Target))
 	}
 
+	callStart := time.Now()
 	switch action {
 	case start:
-		statusResponse, err = cpuClient.Start(ctx, newCPURequest(request))
+		statusResponse, err = startSchedule(ctx, c.retry, cpuClient, request)
 	case recoverFailure:
-		statusResponse, err = cpuClient.Recover(ctx, newCPURequest(request))
+		err = c.retry.Do(ctx, request.Target, func(ctx context.Context) error {
+			statusResponse, err = cpuClient.Recover(ctx, newCPURequest(request))
+			return err
+		})
+	}
+
+	if action == start {
+		c.backoff.Record(address, err)
 	}
 
+	c.outcomes.Observe(config.CPU, action.String(), request.Target, metrics.ResultOf(statusResponse, err), time.Since(callStart))
+
 	switch {
 	case err != nil:
 		return "", errors.Wrap(err, fmt.Sprintf("Error response from target {%s}", request.Target))
 	case statusResponse.Status != v1.StatusResponse_SUCCESS:
 		return "", errors.New(fmt.Sprintf("Failure response from target {%s}", request.Target))
 	default:
-		if err = c.updateCache(connection, request, action); err != nil {
+		if err = c.updateCache(ctx, connection, request, action); err != nil {
 			_ = level.Error(c.loggers.ErrLogger).Log("msg", fmt.Sprintf("Could not update cache for operation cpu injection %s", action), "err", err)
+			if action == start && c.persistence.FailClosed() {
+				return "", c.rollback(request, err)
+			}
+		}
+	}
+
+	return c.messages.Render(request.Target, statusResponse.Status, statusResponse.Message), nil
+}
+
+// startSchedule starts the cpu injection with the percentage of the request. If the request has a ramp-up schedule
+// every step is started in order after the previous step is held, and the failure is recovered if a step can not be started
+func startSchedule(ctx context.Context, retries *retry.Policy, cpuClient v1.CPUClient, request *RequestPayload) (*v1.StatusResponse, error) {
+	if len(request.Steps) == 0 {
+		return startCPU(ctx, retries, cpuClient, request.Target, newCPURequest(request))
+	}
+
+	var statusResponse *v1.StatusResponse
+	var err error
+	for i, step := range request.Steps {
+		if i > 0 {
+			if err = hold(ctx, request.Steps[i-1].HoldSec); err != nil {
+				recoverSchedule(cpuClient, request)
+				return nil, errors.Wrap(err, fmt.Sprintf("Schedule interrupted before step {%d}", i))
+			}
+		}
+
+		statusResponse, err = startCPU(ctx, retries, cpuClient, request.Target, &v1.CPURequest{Percentage: step.Percentage})
+		if err != nil || statusResponse.Status != v1.StatusResponse_SUCCESS {
+			if i > 0 {
+				recoverSchedule(cpuClient, request)
+			}
+			return statusResponse, err
 		}
 	}
 
-	return fmt.Sprintf("Response from target {%s}, {%s}, {%s}", request.Target, statusResponse.Message, statusResponse.Status), nil
+	return statusResponse, nil
+}
+
+// startCPU starts the cpu injection on the target, and retries while its bot is unavailable
+func startCPU(ctx context.Context, retries *retry.Policy, cpuClient v1.CPUClient, target string, cpuRequest *v1.CPURequest) (*v1.StatusResponse, error) {
+	var statusResponse *v1.StatusResponse
+	err := retries.Do(ctx, target, func(ctx context.Context) error {
+		var err error
+		statusResponse, err = cpuClient.Start(ctx, cpuRequest)
+		return err
+	})
+	return statusResponse, err
+}
+
+// hold waits for the seconds of a step, or until the context is done
+func hold(ctx context.Context, sec int) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(time.Duration(sec) * time.Second):
+		return nil
+	}
 }
 
-func (c *CController) updateCache(connection network.Connection, request *RequestPayload, action action) error {
+// recoverSchedule recovers the steps of a schedule that were started before one of its steps failed
+func recoverSchedule(cpuClient v1.CPUClient, request *RequestPayload) {
+	_, _ = cpuClient.Recover(network.WithLabels(context.Background(), request.Labels), newCPURequest(request))
+}
+
+func (c *CController) updateCache(ctx context.Context, connection network.Connection, request *RequestPayload, action action) error {
 	key := cache.Key{
 		Job:    request.Job,
 		Target: request.Target,
@@ -198,19 +418,76 @@ func (c *CController) updateCache(connection network.Connection, request *Reques
 
 	switch action {
 	case start:
-		recoveryFunc := func() (*v1.StatusResponse, error) {
-			cpuClient, err := connection.GetCPUClient()
-			if err != nil {
-				return nil, errors.New(fmt.Sprintf("Could not recover cpu failure for job {%s} and target {%s}", request.Job, request.Target))
-			}
-			return cpuClient.Recover(context.Background(), &v1.CPURequest{})
+		// the injection is recorded, scheduled and annotated only if it is not rolled back
+		c.cache.Set(key, Recovery(connection, request))
+		err := c.persistence.Save(c.cache)
+		if err != nil && c.persistence.FailClosed() {
+			return err
 		}
-		c.cache.Set(key, recoveryFunc)
-		return nil
+		injection := &cache.Injection{StartsAt: time.Now(), Labels: request.Labels, Metadata: auth.WithPrincipalMetadata(ctx, nil), Inject: Injection(connection, request)}
+		c.injections.Record(key, injection)
+		c.scheduleRecovery(key, injection, request)
+		c.annotator.Start(request.Job, request.Target)
+		return err
 	case recoverFailure:
 		c.cache.Delete(key)
-		return nil
+		c.annotator.End(request.Job, request.Target)
+		return c.persistence.Save(c.cache)
 	default:
 		return errors.New(fmt.Sprintf("Action %s not supported for cache operation", action))
 	}
 }
+
+// scheduleRecovery recovers the failure automatically after the duration of the request, or the default duration of the job.
+// Nothing is scheduled for the injection of a pulse, since the pulse recovers it after the same duration
+func (c *CController) scheduleRecovery(key cache.Key, injection *cache.Injection, request *RequestPayload) {
+	if request.pulse {
+		return
+	}
+
+	c.injections.AutoRecover(key, injection, c.jobs[request.Job].RecoverAfter(request.DurationSec), func() {
+		if _, ok := c.cache.Get(key); !ok {
+			return
+		}
+		_ = level.Info(c.loggers.OutLogger).Log("msg", fmt.Sprintf("automatic recovery of job item {%s} on target {%s}", key.Job, key.Target))
+		if _, err := c.performAction(context.Background(), recoverFailure, request); err != nil {
+			_ = level.Error(c.loggers.ErrLogger).Log("msg", fmt.Sprintf("automatic recovery of job item {%s} on target {%s} failed", key.Job, key.Target), "err", err)
+		}
+	})
+}
+
+// Injection returns the function that injects the failure of the request on the target of the connection again
+func Injection(connection network.Connection, request *RequestPayload) func() (*v1.StatusResponse, error) {
+	return func() (*v1.StatusResponse, error) {
+		cpuClient, err := connection.GetCPUClient()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Could not inject cpu failure for job {%s} and target {%s}", request.Job, request.Target))
+		}
+		return cpuClient.Start(network.WithLabels(context.Background(), request.Labels), newCPURequest(request))
+	}
+}
+
+// Recovery returns the function that recovers the failure of the request on the target of the connection
+func Recovery(connection network.Connection, request *RequestPayload) func() (*v1.StatusResponse, error) {
+	return func() (*v1.StatusResponse, error) {
+		cpuClient, err := connection.GetCPUClient()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Could not recover cpu failure for job {%s} and target {%s}", request.Job, request.Target))
+		}
+		return cpuClient.Recover(network.WithLabels(context.Background(), request.Labels), &v1.CPURequest{})
+	}
+}
+
+// rollback recovers an injection whose recovery could not be persisted, so that no unrecoverable failure is left behind
+func (c *CController) rollback(request *RequestPayload, cause error) error {
+	key := cache.Key{Job: request.Job, Target: request.Target}
+	if err := cache.Rollback(c.cache, key); err != nil {
+		_ = level.Error(c.loggers.ErrLogger).Log("msg", fmt.Sprintf("Could not roll back injection on target {%s}", request.Target), "err", err)
+		return errors.Wrap(cause, fmt.Sprintf("Could not persist injection on target {%s} and the rollback failed", request.Target))
+	}
+	if err := c.persistence.Save(c.cache); err != nil {
+		_ = level.Error(c.loggers.ErrLogger).Log("msg", "Could not update cache after rollback", "err", err)
+	}
+
+	return errors.Wrap(cause, fmt.Sprintf("Could not persist injection on target {%s}, the injection was rolled back", request.Target))
+}
@@ -2,25 +2,33 @@ package cpu
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/SotirisAlfonsos/gocache"
 
 	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
 	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/grafana"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
 	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 var (
@@ -223,6 +231,461 @@ func TestCPUActionWithNoGrpcConnectionToTarget(t *testing.T) {
 	}
 }
 
+func TestCPUActionErrorIncludesBotStatusCode(t *testing.T) {
+	jobMap := map[string]*config.Job{
+		"job name": newCPUJob("127.0.0.1"),
+	}
+	connectionPool := map[string]*cConnection{
+		"127.0.0.1": {connection: &network.MockConnection{Err: status.Error(codes.Unavailable, "bot is unavailable")}},
+	}
+
+	server, err := cpuHTTPTestServerWithCacheItems(jobMap, connectionPool, gocache.New(0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	requestBody, _ := json.Marshal(&RequestPayload{Job: "job name", Percentage: 100, Target: "127.0.0.1"})
+	resp, err := http.Post(server.URL+"/cpu?action=start", "", bytes.NewReader(requestBody)) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	assert.Equal(t, "Unavailable", resp.Header.Get(response.BotStatusCodeHeader))
+}
+
+func TestCPUActionSendsLabelsAsMetadata(t *testing.T) {
+	var captured metadata.MD
+	interceptor := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		captured, _ = metadata.FromOutgoingContext(ctx)
+		reply.(*v1.StatusResponse).Status = v1.StatusResponse_SUCCESS
+		return nil
+	}
+
+	conn, err := grpc.Dial("127.0.0.1:0", grpc.WithInsecure(), grpc.WithUnaryInterceptor(interceptor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	jobMap := map[string]*config.Job{"job name": newCPUJob("127.0.0.1")}
+	connectionPool := map[string]*cConnection{"127.0.0.1": {connection: &clientConnection{conn: conn}}}
+	server, err := cpuHTTPTestServerWithCacheItems(jobMap, connectionPool, gocache.New(0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	requestPayload := &RequestPayload{Job: "job name", Target: "127.0.0.1", Percentage: 50, Labels: map[string]string{"experiment": "exp-1"}}
+	status, _, err := cpuPostCall(server, requestPayload, "start")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"exp-1"}, captured.Get(network.LabelMetadataPrefix+"experiment"))
+}
+
+func TestCPUActionWithAnInvalidLabelName(t *testing.T) {
+	calls := 0
+	interceptor := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		calls++
+		reply.(*v1.StatusResponse).Status = v1.StatusResponse_SUCCESS
+		return nil
+	}
+
+	conn, err := grpc.Dial("127.0.0.1:0", grpc.WithInsecure(), grpc.WithUnaryInterceptor(interceptor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	jobMap := map[string]*config.Job{"job name": newCPUJob("127.0.0.1")}
+	connectionPool := map[string]*cConnection{"127.0.0.1": {connection: &clientConnection{conn: conn}}}
+	cacheManager := gocache.New(0)
+	server, err := cpuHTTPTestServerWithCacheItems(jobMap, connectionPool, cacheManager, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	requestPayload := &RequestPayload{Job: "job name", Target: "127.0.0.1", Percentage: 50, Labels: map[string]string{"experiment id": "exp-1"}}
+	status, body, err := cpuPostCall(server, requestPayload, "start")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "The label name {experiment id} should contain only letters, digits, '-', '_' and '.'", body)
+	assert.Equal(t, 0, calls, "the request with an invalid label name should not call the bot")
+	assert.Equal(t, 0, len(cacheManager.GetAll()))
+}
+
+func TestCPUActionCancelsBotCallWhenClientDisconnects(t *testing.T) {
+	called := make(chan struct{})
+	canceled := make(chan error, 1)
+	interceptor := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		close(called)
+		select {
+		case <-ctx.Done():
+			canceled <- ctx.Err()
+		case <-time.After(5 * time.Second):
+			canceled <- nil
+		}
+		return ctx.Err()
+	}
+
+	conn, err := grpc.Dial("127.0.0.1:0", grpc.WithInsecure(), grpc.WithUnaryInterceptor(interceptor))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	cController := &CController{
+		jobs:           map[string]*config.Job{"job name": newCPUJob("127.0.0.1")},
+		connectionPool: map[string]*cConnection{"127.0.0.1": {connection: &clientConnection{conn: conn}}},
+		cache:          gocache.New(0),
+		locks:          cache.NewKeyLocks(),
+		loggers:        loggers,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	body := []byte(`{"job": "job name", "percentage": 50, "target": "127.0.0.1"}`)
+	r := httptest.NewRequest(http.MethodPost, "/cpu?action=start", bytes.NewReader(body)).WithContext(ctx)
+	go func() {
+		<-called
+		cancel()
+	}()
+	cController.CPUAction(httptest.NewRecorder(), r)
+
+	assert.Equal(t, context.Canceled, <-canceled, "the bot call should be canceled with the request")
+	assert.Equal(t, 0, cController.cache.ItemCount())
+}
+
+func TestCPUStartIsRecoveredAfterTheDefaultDuration(t *testing.T) {
+	job := newCPUJob("127.0.0.1")
+	job.DefaultDuration = 20 * time.Millisecond
+	cacheManager := gocache.New(0)
+	cController := &CController{
+		jobs:           map[string]*config.Job{"job name": job},
+		connectionPool: map[string]*cConnection{"127.0.0.1": withSuccessCPUConnection()},
+		cache:          cacheManager,
+		locks:          cache.NewKeyLocks(),
+		injections:     cache.NewInjections(),
+		loggers:        loggers,
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/cpu", cController.CPUAction).Queries("action", "{action}").Methods("POST")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	status, _, err := cpuPostCall(server, &RequestPayload{Job: "job name", Target: "127.0.0.1", Percentage: 50}, "start")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, status)
+	// the count of GetAll is locked, unlike ItemCount, since the failure is recovered concurrently
+	assert.Equal(t, 1, len(cacheManager.GetAll()))
+	assert.Eventually(t, func() bool { return len(cacheManager.GetAll()) == 0 }, time.Second, 5*time.Millisecond,
+		"the failure should be recovered after the default duration when the request omits one")
+}
+
+func TestCPUActionAcceptsTheConfiguredActionAliases(t *testing.T) {
+	cacheManager := gocache.New(0)
+	cController := &CController{
+		jobs:           map[string]*config.Job{"job name": newCPUJob("127.0.0.1")},
+		connectionPool: map[string]*cConnection{"127.0.0.1": withSuccessCPUConnection()},
+		cache:          cacheManager,
+		locks:          cache.NewKeyLocks(),
+		aliases:        config.ActionAliases{"enable": "start", "disable": "recover"},
+		loggers:        loggers,
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/cpu", cController.CPUAction).Queries("action", "{action}").Methods("POST")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	requestPayload := &RequestPayload{Job: "job name", Target: "127.0.0.1", Percentage: 50}
+	status, _, err := cpuPostCall(server, requestPayload, "enable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 1, cacheManager.ItemCount(), "the alias enable should start the injection")
+
+	status, _, err = cpuPostCall(server, requestPayload, "disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 0, cacheManager.ItemCount(), "the alias disable should recover the injection")
+
+	status, message, err := cpuPostCall(server, requestPayload, "pause")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "The action {pause} is not supported", message)
+}
+
+func TestCPUActionAnnotatesTheStartAndTheEndInGrafana(t *testing.T) {
+	dataItems := []struct {
+		message       string
+		grafanaStatus int
+	}{
+		{
+			message:       "Should annotate the start and the end of the failure",
+			grafanaStatus: http.StatusOK,
+		},
+		{
+			message:       "Should not fail the actions if grafana can not annotate",
+			grafanaStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			annotations := make(chan *grafana.Annotation, 2)
+			grafanaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				annotation := &grafana.Annotation{}
+				if err := json.NewDecoder(r.Body).Decode(annotation); err != nil {
+					t.Error(err)
+				}
+				annotations <- annotation
+				w.WriteHeader(dataItem.grafanaStatus)
+			}))
+			defer grafanaServer.Close()
+
+			tracker := async.NewTracker()
+			cController := &CController{
+				jobs:           map[string]*config.Job{"job name": newCPUJob("127.0.0.1")},
+				connectionPool: map[string]*cConnection{"127.0.0.1": withSuccessCPUConnection()},
+				cache:          gocache.New(0),
+				locks:          cache.NewKeyLocks(),
+				annotator:      grafana.NewAnnotator(&config.Grafana{URL: grafanaServer.URL}, tracker, loggers),
+				loggers:        loggers,
+			}
+			router := mux.NewRouter()
+			router.HandleFunc("/cpu", cController.CPUAction).Queries("action", "{action}").Methods("POST")
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			requestPayload := &RequestPayload{Job: "job name", Target: "127.0.0.1", Percentage: 50}
+			for _, action := range []string{"start", "recover"} {
+				status, _, err := cpuPostCall(server, requestPayload, action)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, http.StatusOK, status)
+				if err = tracker.Wait(context.Background()); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			start, end := <-annotations, <-annotations
+			assert.Equal(t, []string{"chaos", "start", "job:job name", "target:127.0.0.1"}, start.Tags)
+			assert.Equal(t, []string{"chaos", "end", "job:job name", "target:127.0.0.1"}, end.Tags)
+		})
+	}
+}
+
+func TestCPUActionRespondsWithTheResolvedRequest(t *testing.T) {
+	dataItems := []struct {
+		message  string
+		request  *RequestPayload
+		expected int
+	}{
+		{
+			message:  "Should resolve the duration to the default duration of the job",
+			request:  &RequestPayload{Job: "job name", Target: "127.0.0.1", Percentage: 50},
+			expected: 600,
+		},
+		{
+			message:  "Should keep the duration of the request",
+			request:  &RequestPayload{Job: "job name", Target: "127.0.0.1", Percentage: 50, DurationSec: 30},
+			expected: 30,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			job := newCPUJob("127.0.0.1")
+			job.DefaultDuration = 10 * time.Minute
+			jobMap := map[string]*config.Job{"job name": job}
+			connectionPool := map[string]*cConnection{"127.0.0.1": withSuccessCPUConnection()}
+			server, err := cpuHTTPTestServerWithCacheItems(jobMap, connectionPool, gocache.New(0), nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			requestBody, _ := json.Marshal(dataItem.request)
+			resp, err := http.Post(server.URL+"/cpu?action=start", "", bytes.NewReader(requestBody)) //nolint:gosec
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			respPayload := &struct {
+				ResolvedRequest *RequestPayload `json:"resolvedRequest"`
+			}{}
+			if err = json.NewDecoder(resp.Body).Decode(respPayload); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, dataItem.expected, respPayload.ResolvedRequest.DurationSec)
+			assert.Equal(t, int32(50), respPayload.ResolvedRequest.Percentage)
+		})
+	}
+}
+
+func TestCPUStartWithRampUpSchedule(t *testing.T) {
+	dataItems := []struct {
+		message       string
+		steps         []*Step
+		failAt        int
+		expectedCalls []string
+		expected      *expectedResult
+	}{
+		{
+			message:       "Should start every step of the schedule in order and cache the recovery",
+			steps:         []*Step{{Percentage: 20}, {Percentage: 50}, {Percentage: 80}},
+			expectedCalls: []string{"start 20", "start 50", "start 80"},
+			expected:      &expectedResult{cacheSize: 1, response: okResponse("Response from target {127.0.0.1}, {}, {SUCCESS}")},
+		},
+		{
+			message:       "Should recover the started steps if a step fails",
+			steps:         []*Step{{Percentage: 20}, {Percentage: 50}, {Percentage: 80}},
+			failAt:        2,
+			expectedCalls: []string{"start 20", "start 50", "start 80", "recover 80"},
+			expected:      &expectedResult{cacheSize: 0, response: internalServerErrorResponse("Error response from target {127.0.0.1}: step failed")},
+		},
+		{
+			message:  "Should receive bad request if the hold duration of a step is negative",
+			steps:    []*Step{{Percentage: 20, HoldSec: -1}, {Percentage: 50}},
+			expected: &expectedResult{cacheSize: 0, response: badRequestResponse("Step {0} of the schedule should have a hold duration that is not negative")},
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			connection := &recordingCPUConnection{failAt: dataItem.failAt}
+			c := gocache.New(0)
+			jobMap := map[string]*config.Job{"job name": newCPUJob("127.0.0.1")}
+			server, err := cpuHTTPTestServerWithCacheItems(jobMap, map[string]*cConnection{"127.0.0.1": {connection: connection}}, c, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			status, message, err := cpuPostCall(server, &RequestPayload{Job: "job name", Target: "127.0.0.1", Steps: dataItem.steps}, "start")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, dataItem.expected.response.status, status)
+			assert.Equal(t, dataItem.expected.response.message, message)
+			assert.Equal(t, dataItem.expected.cacheSize, c.ItemCount())
+			assert.Equal(t, dataItem.expectedCalls, connection.calls)
+		})
+	}
+}
+
+func TestCPUActionWithCacheWriteFailure(t *testing.T) {
+	dataItems := []struct {
+		message           string
+		failClosed        bool
+		expectedStatus    int
+		expectedCacheSize int
+		expectedRecorded  bool
+	}{
+		{
+			message:           "Should reject and roll back injection if the cache can not be persisted and the cache fails closed",
+			failClosed:        true,
+			expectedStatus:    http.StatusInternalServerError,
+			expectedCacheSize: 0,
+			expectedRecorded:  false,
+		},
+		{
+			message:           "Should perform injection if the cache can not be persisted and the cache does not fail closed",
+			failClosed:        false,
+			expectedStatus:    http.StatusOK,
+			expectedCacheSize: 1,
+			expectedRecorded:  true,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			injections := cache.NewInjections()
+			cController := &CController{
+				jobs:           map[string]*config.Job{"job name": newCPUJob("127.0.0.1")},
+				connectionPool: map[string]*cConnection{"127.0.0.1": withSuccessCPUConnection()},
+				cache:          c,
+				persistence:    cache.NewStorePersistence(&failingStore{}, dataItem.failClosed),
+				injections:     injections,
+				loggers:        loggers,
+			}
+			router := mux.NewRouter()
+			router.HandleFunc("/cpu", cController.CPUAction).Queries("action", "{action}").Methods("POST")
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			status, _, err := cpuPostCall(server, &RequestPayload{Job: "job name", Target: "127.0.0.1", Percentage: 50}, "start")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, dataItem.expectedStatus, status)
+			assert.Equal(t, dataItem.expectedCacheSize, c.ItemCount())
+			_, recorded := injections.Get(cache.Key{Job: "job name", Target: "127.0.0.1"})
+			assert.Equal(t, dataItem.expectedRecorded, recorded, "a rolled back injection should not be recorded")
+		})
+	}
+}
+
+type failingStore struct{}
+
+func (f *failingStore) Save(_ []cache.Key) error {
+	return errors.New("disk full")
+}
+
+func (f *failingStore) Load() ([]cache.Key, error) {
+	return nil, nil
+}
+
+func TestCPUActionWithCustomSuccessMessage(t *testing.T) {
+	messages, err := response.NewMessageTemplate("{{.Status}} on {{.Target}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cController := &CController{
+		jobs:           map[string]*config.Job{"job name": newCPUJob("127.0.0.1")},
+		connectionPool: map[string]*cConnection{"127.0.0.1": withSuccessCPUConnection()},
+		cache:          gocache.New(0),
+		messages:       messages,
+		loggers:        loggers,
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/cpu", cController.CPUAction).Queries("action", "{action}").Methods("POST")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	status, message, err := cpuPostCall(server, &RequestPayload{Job: "job name", Target: "127.0.0.1", Percentage: 50}, "start")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "SUCCESS on 127.0.0.1", message)
+}
+
 func TestCPUWithInvalidAction(t *testing.T) {
 	dataItems := []TestData{
 		{
@@ -272,6 +735,43 @@ func newCPUJob(targets ...string) *config.Job {
 	}
 }
 
+type clientConnection struct {
+	network.MockConnection
+	conn *grpc.ClientConn
+}
+
+func (c *clientConnection) GetCPUClient() (v1.CPUClient, error) {
+	return v1.NewCPUClient(c.conn), nil
+}
+
+// recordingCPUConnection records the calls to its cpu client, and fails the start call with the index failAt if set
+type recordingCPUConnection struct {
+	network.MockConnection
+	failAt int
+	calls  []string
+}
+
+func (c *recordingCPUConnection) GetCPUClient() (v1.CPUClient, error) {
+	return &recordingCPUClient{connection: c}, nil
+}
+
+type recordingCPUClient struct {
+	connection *recordingCPUConnection
+}
+
+func (client *recordingCPUClient) Start(_ context.Context, in *v1.CPURequest, _ ...grpc.CallOption) (*v1.StatusResponse, error) {
+	client.connection.calls = append(client.connection.calls, fmt.Sprintf("start %d", in.Percentage))
+	if client.connection.failAt > 0 && len(client.connection.calls) > client.connection.failAt {
+		return nil, errors.New("step failed")
+	}
+	return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+}
+
+func (client *recordingCPUClient) Recover(_ context.Context, in *v1.CPURequest, _ ...grpc.CallOption) (*v1.StatusResponse, error) {
+	client.connection.calls = append(client.connection.calls, fmt.Sprintf("recover %d", in.Percentage))
+	return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+}
+
 func withSuccessCPUConnection() *cConnection {
 	connection := &network.MockConnection{Status: new(v1.StatusResponse), Err: nil}
 	return &cConnection{
@@ -325,6 +825,8 @@ func cpuHTTPTestServerWithCacheItems(
 	router.HandleFunc("/cpu", cController.CPUAction).
 		Queries("action", "{action}").
 		Methods("POST")
+	router.HandleFunc("/cpu/pulse", cController.PulseAction).
+		Methods("POST")
 
 	return httptest.NewServer(router), nil
 }
@@ -344,8 +846,12 @@ func post(requestBody []byte, url string) (int, string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		b, _ := ioutil.ReadAll(resp.Body)
-		return resp.StatusCode, string(b), nil
+		errPayload := &response.ErrorPayload{}
+		err = json.NewDecoder(resp.Body).Decode(&errPayload)
+		if err != nil {
+			return resp.StatusCode, "", err
+		}
+		return errPayload.Status, errPayload.Error, nil
 	}
 
 	respPayload := &response.Payload{}
@@ -365,14 +871,14 @@ func okResponse(message string) *responseWrapper {
 
 func badRequestResponse(message string) *responseWrapper {
 	return &responseWrapper{
-		message: message + "\n",
+		message: message,
 		status:  400,
 	}
 }
 
 func internalServerErrorResponse(message string) *responseWrapper {
 	return &responseWrapper{
-		message: message + "\n",
+		message: message,
 		status:  500,
 	}
 }
@@ -394,3 +900,117 @@ func getLoggers() chaoslogger.Loggers {
 		ErrLogger: chaoslogger.New(allowLevel, os.Stderr),
 	}
 }
+
+func TestActionsMatchActionEnum(t *testing.T) {
+	actions := Actions()
+
+	assert.Equal(t, int(notImplemented), len(actions))
+	for i, name := range actions {
+		a, err := toActionEnum(name, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, action(i), a)
+	}
+}
+
+func TestCPUStartIsBackedOffAfterConsecutiveErrors(t *testing.T) {
+	cController := &CController{
+		jobs:           map[string]*config.Job{"job name": newCPUJob("127.0.0.1")},
+		connectionPool: map[string]*cConnection{"127.0.0.1": withErrorCPUConnection("connection refused")},
+		cache:          gocache.New(0),
+		backoff:        network.NewBackoff(&config.Backoff{Failures: 2, Initial: 10 * time.Second}),
+		loggers:        loggers,
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/cpu", cController.CPUAction).Queries("action", "{action}").Methods("POST")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	requestBody, _ := json.Marshal(&RequestPayload{Job: "job name", Target: "127.0.0.1", Percentage: 50})
+	for i := 0; i < 2; i++ {
+		status, _, err := post(requestBody, server.URL+"/cpu?action=start")
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, http.StatusInternalServerError, status, "the errors before the threshold should be returned")
+	}
+
+	resp, err := http.Post(server.URL+"/cpu?action=start", "", bytes.NewReader(requestBody)) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	errPayload := &response.ErrorPayload{}
+	if err = json.NewDecoder(resp.Body).Decode(errPayload); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "10", resp.Header.Get("Retry-After"))
+	assert.Regexp(t, `^Injections on target \{127.0.0.1\} are backed off for \{.*\} after \{2\} consecutive failures$`, errPayload.Error)
+
+	status, _, err := post(requestBody, server.URL+"/cpu?action=recover")
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusInternalServerError, status, "the recover actions should not be backed off")
+}
+
+func TestCPUStartOnRandomTarget(t *testing.T) {
+	dataItems := []struct {
+		message  string
+		jobMap   map[string]*config.Job
+		do       string
+		expected *expectedResult
+	}{
+		{
+			message:  "Should start cpu injection on a random target of the job and add it in cache with the target",
+			jobMap:   map[string]*config.Job{"job name": newCPUJob("127.0.0.1", "127.0.0.2")},
+			do:       "random",
+			expected: &expectedResult{cacheSize: 1, response: okResponse("Response from target {127.0.0.\\d}, {}, {SUCCESS}")},
+		},
+		{
+			message:  "Should receive bad request and not update cache when job has no targets defined",
+			jobMap:   map[string]*config.Job{"job name": newCPUJob()},
+			do:       "random",
+			expected: &expectedResult{cacheSize: 0, response: badRequestResponse("Could not get random target for job {job name}. err: No targets available")},
+		},
+		{
+			message:  "Should receive bad request and not update cache when job name does not exist",
+			jobMap:   map[string]*config.Job{"job different name": newCPUJob("127.0.0.1")},
+			do:       "random",
+			expected: &expectedResult{cacheSize: 0, response: badRequestResponse("Could not find job {job name}")},
+		},
+		{
+			message:  "Should receive bad request and not update cache for an unknown do query parameter",
+			jobMap:   map[string]*config.Job{"job name": newCPUJob("127.0.0.1")},
+			do:       "any",
+			expected: &expectedResult{cacheSize: 0, response: badRequestResponse("Do query parameter {any} not allowed")},
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			connectionPool := map[string]*cConnection{"127.0.0.1": withSuccessCPUConnection(), "127.0.0.2": withSuccessCPUConnection()}
+			server, err := cpuHTTPTestServerWithCacheItems(dataItem.jobMap, connectionPool, c, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			requestBody, _ := json.Marshal(&RequestPayload{Job: "job name", Percentage: 100})
+			status, message, err := post(requestBody, fmt.Sprintf("%s/cpu?do=%s&action=start", server.URL, dataItem.do))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, dataItem.expected.response.status, status)
+			assert.Regexp(t, regexp.MustCompile(dataItem.expected.response.message), message)
+			assert.Equal(t, dataItem.expected.cacheSize, c.ItemCount())
+			for _, item := range c.GetAll() {
+				key := item.Key.(cache.Key)
+				assert.Contains(t, message, fmt.Sprintf("{%s}", key.Target), "the cache key should have the selected target")
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package cpu
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/go-kit/kit/log/level"
+)
+
+// PulseAction godoc
+// @Summary Inject and recover a CPU failure
+// @Description Start a CPU injection, hold it for the duration of the request or the default duration of the job, and recover it
+// @Tags Failure injections
+// @Accept json
+// @Produce json
+// @Param confirmProduction query bool false "Confirm the injection on a target labeled env: prod"
+// @Param requestPayload body RequestPayload true "Specify the job name, percentage, target and duration"
+// @Success 200 {object} response.PulsePayload
+// @Failure 400 {object} response.ErrorPayload
+// @Failure 500 {object} response.PulsePayload
+// @Router /cpu/pulse [post]
+func (c *CController) PulseAction(w http.ResponseWriter, r *http.Request) {
+	requestPayload := &RequestPayload{}
+	err := json.NewDecoder(r.Body).Decode(&requestPayload)
+	if err != nil {
+		response.BadRequest(w, r, "Could not decode request body", c.loggers)
+		return
+	}
+	requestPayload.pulse = true
+
+	if err = network.ValidateLabels(requestPayload.Labels); err != nil {
+		response.BadRequest(w, r, err.Error(), c.loggers)
+		return
+	}
+
+	err = checkIfTargetExists(c.jobs, requestPayload)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), c.loggers)
+		return
+	}
+
+	err = checkSteps(requestPayload)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), c.loggers)
+		return
+	}
+
+	job := c.jobs[requestPayload.Job]
+	for _, action := range []action{start, recoverFailure} {
+		if !job.Allows(action.String()) {
+			response.Forbidden(w, r, fmt.Sprintf("The action {%s} is not allowed for job {%s}", action, requestPayload.Job), c.loggers)
+			return
+		}
+	}
+
+	if job.RequiresConfirmation(requestPayload.Target) && r.FormValue("confirmProduction") != "true" {
+		response.Forbidden(w, r, fmt.Sprintf("The target {%s} is a production target. Set the query parameter confirmProduction=true to inject", requestPayload.Target), c.loggers)
+		return
+	}
+
+	hold := job.RecoverAfter(requestPayload.DurationSec)
+	if hold <= 0 {
+		response.BadRequest(w, r, fmt.Sprintf("The pulse for job {%s} requires a positive durationSec", requestPayload.Job), c.loggers)
+		return
+	}
+
+	_ = level.Info(c.loggers.OutLogger).Log("msg", fmt.Sprintf("pulse CPU injection on target {%s} for %s", requestPayload.Target, hold))
+
+	payload := response.Pulse(r.Context(), hold,
+		func(ctx context.Context) (string, error) {
+			return c.performAction(ctx, start, requestPayload)
+		},
+		func(ctx context.Context) (string, error) {
+			return c.performAction(ctx, recoverFailure, requestPayload)
+		},
+	)
+
+	response.JSONResponse(w, r, payload.Status, payload, c.loggers)
+}
@@ -0,0 +1,87 @@
+package cpu
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCPUPulseInjectsAndRecovers(t *testing.T) {
+	connection := &recordingCPUConnection{}
+	c := gocache.New(0)
+	jobMap := map[string]*config.Job{"job name": newCPUJob("127.0.0.1")}
+	server, err := cpuHTTPTestServerWithCacheItems(jobMap, map[string]*cConnection{"127.0.0.1": {connection: connection}}, c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	status, payload := cpuPulsePostCall(t, server.URL, &RequestPayload{Job: "job name", Target: "127.0.0.1", Percentage: 50, DurationSec: 1})
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "SUCCESS", payload.Inject.Status)
+	if assert.NotNil(t, payload.Recover) {
+		assert.Equal(t, "SUCCESS", payload.Recover.Status)
+	}
+	assert.Equal(t, []string{"start 50", "recover 50"}, connection.calls)
+	assert.Equal(t, 0, c.ItemCount(), "the cache should be clean after the pulse")
+}
+
+func TestCPUPulseDoesNotRecoverAFailedInjection(t *testing.T) {
+	c := gocache.New(0)
+	jobMap := map[string]*config.Job{"job name": newCPUJob("127.0.0.1")}
+	server, err := cpuHTTPTestServerWithCacheItems(jobMap, map[string]*cConnection{"127.0.0.1": withFailureCPUConnection()}, c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	status, payload := cpuPulsePostCall(t, server.URL, &RequestPayload{Job: "job name", Target: "127.0.0.1", Percentage: 50, DurationSec: 1})
+
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, "FAILURE", payload.Inject.Status)
+	assert.Equal(t, "Failure response from target {127.0.0.1}", payload.Inject.Error)
+	assert.Nil(t, payload.Recover)
+	assert.Equal(t, 0, c.ItemCount())
+}
+
+func TestCPUPulseWithoutDuration(t *testing.T) {
+	c := gocache.New(0)
+	jobMap := map[string]*config.Job{"job name": newCPUJob("127.0.0.1")}
+	server, err := cpuHTTPTestServerWithCacheItems(jobMap, map[string]*cConnection{"127.0.0.1": withSuccessCPUConnection()}, c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	requestBody, _ := json.Marshal(&RequestPayload{Job: "job name", Target: "127.0.0.1", Percentage: 50})
+	status, message, err := post(requestBody, server.URL+"/cpu/pulse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "The pulse for job {job name} requires a positive durationSec", message)
+	assert.Equal(t, 0, c.ItemCount())
+}
+
+func cpuPulsePostCall(t *testing.T, url string, details *RequestPayload) (int, *response.PulsePayload) {
+	requestBody, _ := json.Marshal(details)
+	resp, err := http.Post(url+"/cpu/pulse", "", bytes.NewReader(requestBody)) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	payload := &response.PulsePayload{}
+	if err = json.NewDecoder(resp.Body).Decode(payload); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, payload
+}
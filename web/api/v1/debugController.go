@@ -0,0 +1,48 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+)
+
+type DebugController struct {
+	connections *network.Connections
+	loggers     chaoslogger.Loggers
+}
+
+// Connection godoc
+// @Summary Get the dial options of a target
+// @Description Get the options the bot of the target is dialed with, without the secrets. Served only if the debug endpoints are enabled
+// @Tags Status
+// @Produce json
+// @Param target query string true "The target of the bot"
+// @Success 200 {object} network.DialSummary
+// @Failure 400 {object} response.ErrorPayload
+// @Failure 404 {object} response.ErrorPayload
+// @Router /debug/connection [get]
+func (dc *DebugController) Connection(w http.ResponseWriter, r *http.Request) {
+	target, err := config.NormalizeTarget(r.URL.Query().Get("target"))
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), dc.loggers)
+		return
+	}
+
+	connection, ok := dc.connections.Pool[target]
+	if !ok {
+		response.NotFound(w, r, fmt.Sprintf("Could not find connection to target {%s}", target), dc.loggers)
+		return
+	}
+
+	summary, ok := network.Summary(connection)
+	if !ok {
+		response.NotFound(w, r, fmt.Sprintf("The connection to target {%s} is not dialed by the master", target), dc.loggers)
+		return
+	}
+
+	response.JSONResponse(w, r, http.StatusOK, summary, dc.loggers)
+}
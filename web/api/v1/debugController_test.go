@@ -0,0 +1,132 @@
+package v1
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebugConnectionSummarizesTheDialOptionsWithoutSecrets(t *testing.T) {
+	conf := &config.Config{
+		APIOptions: &config.RestAPIOptions{DebugEndpoints: true},
+		Bots: &config.Bots{
+			CACert:        "../../../config/test/certs/ca-cert.pem",
+			PeerToken:     "peer-secret",
+			MinTLSVersion: "1.3",
+			CipherSuites:  []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"},
+			LBPolicy:      "round_robin",
+		},
+		GlobalLabels: map[string]string{"fleet": "eu-west"},
+		JobsFromConfig: []*config.JobsFromConfig{
+			{JobName: "cpu job", FailureType: config.CPU, Targets: []string{"127.0.0.1:8081"}},
+		},
+	}
+	connections := network.GetConnectionPool(conf, loggers)
+	apiRouter := NewAPIRouter(conf, conf.GetJobMap(loggers), connections, gocache.New(0), async.NewTracker(), loggers)
+	server := httptest.NewServer(apiRouter.AddRoutes(nil, mux.NewRouter()))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/chaos/api/v1/debug/connection?target=http://127.0.0.1:8081")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary := &network.DialSummary{}
+	if err = json.Unmarshal(body, summary); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "127.0.0.1:8081", summary.Target)
+	assert.True(t, summary.TLS)
+	assert.Equal(t, "../../../config/test/certs/ca-cert.pem", summary.Certificate)
+	assert.Equal(t, "TLS 1.3", summary.MinTLSVersion)
+	assert.Equal(t, []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305"}, summary.CipherSuites)
+	assert.True(t, summary.HasToken)
+	assert.Equal(t, "round_robin", summary.LBPolicy)
+	assert.Equal(t, []string{"fleet"}, summary.GlobalLabels)
+	assert.NotContains(t, string(body), "peer-secret", "the peer token should not be leaked")
+	assert.NotContains(t, string(body), "eu-west", "the values of the global labels should not be leaked")
+}
+
+func TestDebugConnectionRequests(t *testing.T) {
+	dataItems := []struct {
+		message        string
+		apiOptions     *config.RestAPIOptions
+		target         string
+		expectedStatus int
+	}{
+		{
+			message:        "Should not serve the debug endpoints unless they are enabled",
+			apiOptions:     &config.RestAPIOptions{},
+			target:         "127.0.0.1:8081",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			message:        "Should reject a target with a path",
+			apiOptions:     &config.RestAPIOptions{DebugEndpoints: true},
+			target:         "127.0.0.1:8081/bot",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			message:        "Should not find a target without a connection",
+			apiOptions:     &config.RestAPIOptions{DebugEndpoints: true},
+			target:         "127.0.0.2:8081",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			message:        "Should summarize an insecure connection",
+			apiOptions:     &config.RestAPIOptions{DebugEndpoints: true},
+			target:         "127.0.0.1:8081",
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			conf := &config.Config{
+				APIOptions: dataItem.apiOptions,
+				JobsFromConfig: []*config.JobsFromConfig{
+					{JobName: "cpu job", FailureType: config.CPU, Targets: []string{"127.0.0.1:8081"}},
+				},
+			}
+			connections := network.GetConnectionPool(conf, loggers)
+			apiRouter := NewAPIRouter(conf, conf.GetJobMap(loggers), connections, gocache.New(0), async.NewTracker(), loggers)
+			server := httptest.NewServer(apiRouter.AddRoutes(nil, mux.NewRouter()))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL + "/chaos/api/v1/debug/connection?target=" + dataItem.target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, dataItem.expectedStatus, resp.StatusCode)
+			if dataItem.expectedStatus != http.StatusOK {
+				return
+			}
+
+			summary := &network.DialSummary{}
+			if err = json.NewDecoder(resp.Body).Decode(summary); err != nil {
+				t.Fatal(err)
+			}
+			assert.False(t, summary.TLS)
+			assert.False(t, summary.HasToken)
+			assert.Empty(t, summary.MinTLSVersion)
+		})
+	}
+}
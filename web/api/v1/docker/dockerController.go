@@ -2,19 +2,26 @@ package docker
 
 import (
 	"context"
-	"crypto/rand"
 	"encoding/json"
 	"fmt"
-	"math/big"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/grafana"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/limiter"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/metrics"
 	"github.com/SotirisAlfonsos/gocache"
 
 	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
 	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/healthcheck"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/retry"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/auth"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/controller"
 	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
 	"github.com/go-kit/kit/log/level"
 	"github.com/pkg/errors"
@@ -24,6 +31,22 @@ type DController struct {
 	jobs           map[string]*config.Job
 	connectionPool map[string]*dConnection
 	cache          *gocache.Cache
+	persistence    *cache.Persistence
+	locks          *cache.KeyLocks
+	injections     *cache.Injections
+	inFlight       *metrics.InFlight
+	outcomes       *metrics.Outcomes
+	backoff        *network.Backoff
+	retry          *retry.Policy
+	limiter        *limiter.Limiter
+	aliases        config.ActionAliases
+	healthBias     float64
+	randomDisabled bool
+	healthChecker  *healthcheck.HealthChecker
+	guard          *healthcheck.Guard
+	annotator      *grafana.Annotator
+	messages       *response.MessageTemplate
+	pending        *cache.Pending
 	loggers        chaoslogger.Loggers
 }
 
@@ -43,8 +66,17 @@ func (a action) String() string {
 	return [...]string{"kill", "recover"}[a]
 }
 
-func toActionEnum(value string) (action, error) {
-	switch value {
+// Actions returns the names of all the actions supported for docker injections
+func Actions() []string {
+	actions := make([]string, 0, int(notImplemented))
+	for a := action(0); a < notImplemented; a++ {
+		actions = append(actions, a.String())
+	}
+	return actions
+}
+
+func toActionEnum(value string, aliases config.ActionAliases) (action, error) {
+	switch strings.ToLower(aliases.Resolve(value)) {
 	case recoverContainer.String():
 		return recoverContainer, nil
 	case kill.String():
@@ -53,14 +85,9 @@ func toActionEnum(value string) (action, error) {
 	return notImplemented, errors.New(fmt.Sprintf("The action {%s} is not supported", value))
 }
 
-func NewDockerController(
-	jobs map[string]*config.Job,
-	connections *network.Connections,
-	cache *gocache.Cache,
-	loggers chaoslogger.Loggers,
-) *DController {
+func NewDockerController(jobs map[string]*config.Job, options *controller.Options) *DController {
 	connPool := make(map[string]*dConnection)
-	for target, connection := range connections.Pool {
+	for target, connection := range options.Connections.Pool {
 		connPool[target] = &dConnection{
 			connection: connection,
 		}
@@ -68,15 +95,37 @@ func NewDockerController(
 	return &DController{
 		jobs:           jobs,
 		connectionPool: connPool,
-		cache:          cache,
-		loggers:        loggers,
+		cache:          options.Cache,
+		persistence:    options.Persistence,
+		locks:          options.Locks,
+		injections:     options.Injections,
+		inFlight:       options.InFlight,
+		outcomes:       options.Outcomes,
+		backoff:        options.Connections.Backoff,
+		retry:          options.Connections.Retry,
+		limiter:        options.Connections.Limiter,
+		aliases:        options.Aliases,
+		healthBias:     options.RandomSelection.Bias(),
+		randomDisabled: !options.Docker.RandomAllowed(),
+		healthChecker:  options.HealthChecker,
+		guard:          options.Guard,
+		annotator:      options.Annotator,
+		messages:       options.Messages,
+		pending:        cache.NewPending(),
+		loggers:        options.Loggers,
 	}
 }
 
 type RequestPayload struct {
-	Job       string `json:"job"`
-	Container string `json:"containerName"`
-	Target    string `json:"target"`
+	Job         string            `json:"job"`
+	Container   string            `json:"containerName"`
+	Target      string            `json:"target"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	DelaySec    int               `json:"delaySec,omitempty"`
+	DurationSec int               `json:"durationSec,omitempty"`
+
+	// pulse is set for the injection of a pulse, which recovers the injection itself
+	pulse bool
 }
 
 func newDockerRequest(details *RequestPayload) *v1.DockerRequest {
@@ -85,39 +134,63 @@ func newDockerRequest(details *RequestPayload) *v1.DockerRequest {
 	}
 }
 
+// resolve returns the request with the defaults of its job applied, as it is performed
+func resolve(job *config.Job, request *RequestPayload) *RequestPayload {
+	resolved := *request
+	resolved.DurationSec = int(job.RecoverAfter(request.DurationSec) / time.Second)
+	return &resolved
+}
+
 // CalcExample godoc
 // @Summary Inject docker failures
-// @Description Perform start or stop action on a container. If random is specified you do not have to provide a target
+// @Description Perform start or stop action on a container. If random is specified you do not have to provide a target.
+// @Description With healthy-percentage the action is performed on the value percentage of the targets of the job that are healthy
 // @Tags Failure injections
 // @Accept json
 // @Produce json
-// @Param do query string false "Specify to perform action for container on random target" Enums(random)
+// @Param do query string false "Specify to perform action for container on random target, on a percentage of the healthy targets, or on all the targets" Enums(random, healthy-percentage, all)
+// @Param value query int false "The percentage of the healthy targets, with do=healthy-percentage"
 // @Param action query string true "Specify to perform a recover or a kill on the specified container" Enums(kill, recover)
+// @Param confirmProduction query bool false "Confirm the injection on a target labeled env: prod"
 // @Param requestPayload body RequestPayload true "Specify the job name, container name and target"
 // @Success 200 {object} response.Payload
-// @Failure 400 {string} http.Error
-// @Failure 500 {string} http.Error
+// @Success 200 {object} SelectionResponsePayload "With do=healthy-percentage"
+// @Success 200 {object} response.BulkPayload "With do=all"
+// @Failure 400 {object} response.ErrorPayload
+// @Failure 500 {object} response.ErrorPayload
 // @Router /docker [post]
 func (d *DController) DockerAction(w http.ResponseWriter, r *http.Request) {
-	do := r.FormValue("do")
-	if do != "" {
+	switch do := r.FormValue("do"); do {
+	case "":
+	case healthyPercentage:
+		d.healthyPercentageDocker(w, r)
+		return
+	case all:
+		d.allDocker(w, r)
+		return
+	default:
 		d.randomDocker(w, r, do)
 		return
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
 	requestPayload := &RequestPayload{}
 	err := json.NewDecoder(r.Body).Decode(&requestPayload)
 	if err != nil {
-		response.BadRequest(w, "Could not decode request body", d.loggers)
+		response.BadRequest(w, r, "Could not decode request body", d.loggers)
+		return
+	}
+
+	if err = network.ValidateLabels(requestPayload.Labels); err != nil {
+		response.BadRequest(w, r, err.Error(), d.loggers)
 		return
 	}
 
-	action, err := toActionEnum(r.FormValue("action"))
+	action, err := toActionEnum(r.FormValue("action"), d.aliases)
 	if err != nil {
-		response.BadRequest(w, err.Error(), d.loggers)
+		response.BadRequest(w, r, err.Error(), d.loggers)
 		return
 	}
 
@@ -125,60 +198,99 @@ func (d *DController) DockerAction(w http.ResponseWriter, r *http.Request) {
 
 	err = checkIfTargetExists(d.jobs, requestPayload)
 	if err != nil {
-		response.BadRequest(w, err.Error(), d.loggers)
+		response.BadRequest(w, r, err.Error(), d.loggers)
+		return
+	}
+
+	if !d.jobs[requestPayload.Job].Allows(action.String()) {
+		response.Forbidden(w, r, fmt.Sprintf("The action {%s} is not allowed for job {%s}", action, requestPayload.Job), d.loggers)
+		return
+	}
+
+	if action == kill && d.jobs[requestPayload.Job].RequiresConfirmation(requestPayload.Target) && r.FormValue("confirmProduction") != "true" {
+		response.Forbidden(w, r, fmt.Sprintf("The target {%s} is a production target. Set the query parameter confirmProduction=true to inject", requestPayload.Target), d.loggers)
 		return
 	}
 
-	message, err := d.performAction(ctx, action, requestPayload)
+	message, err := d.performActionWithDelay(ctx, action, requestPayload)
 	if err != nil {
-		response.InternalServerError(w, err.Error(), d.loggers)
+		response.BotError(w, r, err, d.loggers)
 		return
 	}
 
 	_ = level.Info(d.loggers.OutLogger).Log("msg", message)
 
-	response.OkResponse(w, message, d.loggers)
+	response.OkResponseWithRequest(w, r, message, resolve(d.jobs[requestPayload.Job], requestPayload), d.loggers)
 }
 
 func (d *DController) randomDocker(w http.ResponseWriter, r *http.Request, do string) {
 	if do != "random" {
-		response.BadRequest(w, fmt.Sprintf("Do query parameter {%s} not allowed", do), d.loggers)
+		response.BadRequest(w, r, fmt.Sprintf("Do query parameter {%s} not allowed", do), d.loggers)
+		return
+	}
+
+	if d.randomDisabled {
+		response.Forbidden(w, r, "Injections on a random target are disabled for docker jobs", d.loggers)
 		return
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
 	requestPayload := &RequestPayload{}
 	err := json.NewDecoder(r.Body).Decode(&requestPayload)
 	if err != nil {
-		response.BadRequest(w, "Could not decode request body", d.loggers)
+		response.BadRequest(w, r, "Could not decode request body", d.loggers)
 		return
 	}
 
-	action, err := toActionEnum(r.FormValue("action"))
+	if err = network.ValidateLabels(requestPayload.Labels); err != nil {
+		response.BadRequest(w, r, err.Error(), d.loggers)
+		return
+	}
+
+	action, err := toActionEnum(r.FormValue("action"), d.aliases)
 	if err != nil {
-		response.BadRequest(w, err.Error(), d.loggers)
+		response.BadRequest(w, r, err.Error(), d.loggers)
 		return
 	}
 
 	_ = level.Info(d.loggers.OutLogger).Log("msg", fmt.Sprintf("%s any container", action))
 
-	err = setRandomTargetIfExists(d.jobs, requestPayload)
+	err = d.setRandomTargetIfExists(requestPayload)
 	if err != nil {
-		response.BadRequest(w, err.Error(), d.loggers)
+		response.BadRequest(w, r, err.Error(), d.loggers)
+		return
+	}
+
+	if !d.jobs[requestPayload.Job].Allows(action.String()) {
+		response.Forbidden(w, r, fmt.Sprintf("The action {%s} is not allowed for job {%s}", action, requestPayload.Job), d.loggers)
 		return
 	}
 
-	message, err := d.performAction(ctx, action, requestPayload)
+	if action == kill && d.jobs[requestPayload.Job].RequiresConfirmation(requestPayload.Target) && r.FormValue("confirmProduction") != "true" {
+		response.Forbidden(w, r, fmt.Sprintf("The target {%s} is a production target. Set the query parameter confirmProduction=true to inject", requestPayload.Target), d.loggers)
+		return
+	}
+
+	if action == kill {
+		if err = d.guard.Check(requestPayload.Job, d.jobs[requestPayload.Job], []string{requestPayload.Target}); err != nil {
+			response.TooManyRequests(w, r, err.Error(), d.loggers)
+			return
+		}
+	}
+
+	_ = level.Info(d.loggers.OutLogger).Log("msg", "selected random target", "job", requestPayload.Job, "target", requestPayload.Target)
+
+	message, err := d.performActionWithDelay(ctx, action, requestPayload)
 	if err != nil {
-		response.InternalServerError(w, err.Error(), d.loggers)
+		response.BotError(w, r, err, d.loggers)
 		return
 	}
 
 	_ = level.Info(d.loggers.OutLogger).Log("msg", message)
 
-	response.OkResponse(w, message, d.loggers)
+	response.OkResponseWithRequest(w, r, message, resolve(d.jobs[requestPayload.Job], requestPayload), d.loggers)
 }
 
 func checkIfTargetExists(jobMap map[string]*config.Job, requestPayload *RequestPayload) error {
@@ -205,20 +317,20 @@ func checkTargetIfExistsWithContainer(job *config.Job, requestTarget string, req
 	return false
 }
 
-func setRandomTargetIfExists(jobMap map[string]*config.Job, requestPayload *RequestPayload) error {
-	job, ok := jobMap[requestPayload.Job]
+func (d *DController) setRandomTargetIfExists(requestPayload *RequestPayload) error {
+	job, ok := d.jobs[requestPayload.Job]
 	if !ok {
 		return errors.New(fmt.Sprintf("Could not find job {%s}", requestPayload.Job))
 	}
 
-	target, err := getRandomTarget(job.Target)
-	if err != nil {
-		reformattedErr := errors.New(fmt.Sprintf("Could not get random target for job {%s}. err: %s", requestPayload.Job, err.Error()))
-		return reformattedErr
+	// the container is checked before the target is selected, so that its error does not depend on the targets
+	if err := resolveContainer(job, requestPayload); err != nil {
+		return err
 	}
 
-	if job.ComponentName != requestPayload.Container {
-		reformattedErr := errors.New(fmt.Sprintf("Could not find container name {%s}", requestPayload.Container))
+	target, err := selectWeightedTarget(job.Target, d.healthWeights(job))
+	if err != nil {
+		reformattedErr := errors.New(fmt.Sprintf("Could not get random target for job {%s}. err: %s", requestPayload.Job, err.Error()))
 		return reformattedErr
 	}
 
@@ -226,16 +338,18 @@ func setRandomTargetIfExists(jobMap map[string]*config.Job, requestPayload *Requ
 	return nil
 }
 
-func getRandomTarget(targets []string) (string, error) {
-	if len(targets) > 0 {
-		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(targets))))
-		if err != nil {
-			return "", err
-		}
+// resolveContainer sets the container of the job in the request if it is omitted. Since targets can be shared
+// by jobs with different containers, the container is always the one of the job and never of another job of the target
+func resolveContainer(job *config.Job, requestPayload *RequestPayload) error {
+	if requestPayload.Container == "" {
+		requestPayload.Container = job.ComponentName
+	}
 
-		return targets[num.Int64()], nil
+	if job.ComponentName != requestPayload.Container {
+		return errors.New(fmt.Sprintf("Could not find container name {%s}", requestPayload.Container))
 	}
-	return "", errors.New("No targets available")
+
+	return nil
 }
 
 func (d *DController) performAction(
@@ -243,34 +357,56 @@ func (d *DController) performAction(
 	action action,
 	request *RequestPayload,
 ) (string, error) {
+	address := d.jobs[request.Job].Address(request.Target)
+	if action == kill {
+		if err := d.backoff.Check(address); err != nil {
+			return "", err
+		}
+		defer d.inFlight.Track(config.Docker)()
+	}
+
+	ctx = network.WithLabels(ctx, request.Labels)
 	var statusResponse *v1.StatusResponse
 	var err error
-	connection := d.connectionPool[request.Target].connection
+	connection := d.connectionPool[address].connection
 
 	dockerClient, err := connection.GetDockerClient()
 	if err != nil {
 		return "", errors.Wrap(err, fmt.Sprintf("Can not get docker connection from target {%s}", request.Target))
 	}
 
-	switch action {
-	case recoverContainer:
-		statusResponse, err = dockerClient.Recover(ctx, newDockerRequest(request))
-	case kill:
-		statusResponse, err = dockerClient.Kill(ctx, newDockerRequest(request))
+	callStart := time.Now()
+	err = d.retry.Do(ctx, request.Target, func(ctx context.Context) error {
+		switch action {
+		case recoverContainer:
+			statusResponse, err = dockerClient.Recover(ctx, newDockerRequest(request))
+		case kill:
+			statusResponse, err = dockerClient.Kill(ctx, newDockerRequest(request))
+		}
+		return err
+	})
+
+	if action == kill {
+		d.backoff.Record(address, err)
 	}
 
+	d.outcomes.Observe(config.Docker, action.String(), request.Target, metrics.ResultOf(statusResponse, err), time.Since(callStart))
+
 	switch {
 	case err != nil:
 		return "", errors.Wrap(err, fmt.Sprintf("Error response from target {%s}", request.Target))
 	case statusResponse.Status != v1.StatusResponse_SUCCESS:
 		return "", errors.New(fmt.Sprintf("Failure response from target {%s}", request.Target))
 	default:
-		if err = d.updateCache(connection, request, action); err != nil {
+		if err = d.updateCache(ctx, connection, request, action); err != nil {
 			_ = level.Error(d.loggers.ErrLogger).Log("msg", fmt.Sprintf("Could not update cache for operation %s", action), "err", err)
+			if action == kill && d.persistence.FailClosed() {
+				return "", d.rollback(request, err)
+			}
 		}
 	}
 
-	return fmt.Sprintf("Response from target {%s}, {%s}, {%s}", request.Target, statusResponse.Message, statusResponse.Status), nil
+	return d.messages.Render(request.Target, statusResponse.Status, statusResponse.Message), nil
 }
 
 func (d *DController) handleBotResponse(
@@ -284,11 +420,11 @@ func (d *DController) handleBotResponse(
 	case statusResponse.Status != v1.StatusResponse_SUCCESS:
 		return "", errors.New(fmt.Sprintf("Failure response from target {%s}", target))
 	default:
-		return fmt.Sprintf("Response from target {%s}, {%s}, {%s}", target, statusResponse.Message, statusResponse.Status), nil
+		return d.messages.Render(target, statusResponse.Status, statusResponse.Message), nil
 	}
 }
 
-func (d *DController) updateCache(connection network.Connection, request *RequestPayload, action action) error {
+func (d *DController) updateCache(ctx context.Context, connection network.Connection, request *RequestPayload, action action) error {
 	key := cache.Key{
 		Job:    request.Job,
 		Target: request.Target,
@@ -297,18 +433,138 @@ func (d *DController) updateCache(connection network.Connection, request *Reques
 	switch action {
 	case recoverContainer:
 		d.cache.Delete(key)
-		return nil
+		d.annotator.End(request.Job, request.Target)
+		return d.persistence.Save(d.cache)
 	case kill:
-		recoveryFunc := func() (*v1.StatusResponse, error) {
-			dockerClient, err := connection.GetDockerClient()
-			if err != nil {
-				return nil, errors.New(fmt.Sprintf("Could not recover container for job {%s} and target {%s}", request.Job, request.Target))
-			}
-			return dockerClient.Recover(context.Background(), &v1.DockerRequest{Name: request.Container})
+		// the injection is recorded, scheduled and annotated only if it is not rolled back
+		d.cache.Set(key, Recovery(connection, request))
+		err := d.persistence.Save(d.cache)
+		if err != nil && d.persistence.FailClosed() {
+			return err
 		}
-		d.cache.Set(key, recoveryFunc)
-		return nil
+		injection := &cache.Injection{StartsAt: time.Now(), Labels: request.Labels, Metadata: auth.WithPrincipalMetadata(ctx, nil), Inject: Injection(connection, request)}
+		d.injections.Record(key, injection)
+		d.scheduleRecovery(key, injection, request)
+		d.annotator.Start(request.Job, request.Target)
+		return err
 	default:
 		return errors.New(fmt.Sprintf("Action %s not supported for cache operation", action))
 	}
 }
+
+// scheduleRecovery recovers the failure automatically after the duration of the request, or the default duration of the job.
+// Nothing is scheduled for the injection of a pulse, since the pulse recovers it after the same duration
+// The failure is recovered under the lock of its key, and only if it has not been injected again in the meantime
+func (d *DController) scheduleRecovery(key cache.Key, injection *cache.Injection, request *RequestPayload) {
+	if request.pulse {
+		return
+	}
+
+	d.injections.AutoRecover(key, injection, d.jobs[request.Job].RecoverAfter(request.DurationSec), func() {
+		unlock := d.locks.Lock(key)
+		defer unlock()
+
+		if latest, ok := d.injections.Get(key); !ok || latest != injection {
+			return
+		}
+		if _, ok := d.cache.Get(key); !ok {
+			return
+		}
+		defer d.limiter.Acquire(d.jobs[request.Job].Address(request.Target))()
+		_ = level.Info(d.loggers.OutLogger).Log("msg", fmt.Sprintf("automatic recovery of job item {%s} on target {%s}", key.Job, key.Target))
+		if _, err := d.performAction(context.Background(), recoverContainer, request); err != nil {
+			_ = level.Error(d.loggers.ErrLogger).Log("msg", fmt.Sprintf("automatic recovery of job item {%s} on target {%s} failed", key.Job, key.Target), "err", err)
+		}
+	})
+}
+
+// Injection returns the function that injects the failure of the request on the target of the connection again
+func Injection(connection network.Connection, request *RequestPayload) func() (*v1.StatusResponse, error) {
+	return func() (*v1.StatusResponse, error) {
+		dockerClient, err := connection.GetDockerClient()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Could not inject container for job {%s} and target {%s}", request.Job, request.Target))
+		}
+		return dockerClient.Kill(network.WithLabels(context.Background(), request.Labels), &v1.DockerRequest{Name: request.Container})
+	}
+}
+
+// Recovery returns the function that recovers the failure of the request on the target of the connection
+func Recovery(connection network.Connection, request *RequestPayload) func() (*v1.StatusResponse, error) {
+	return func() (*v1.StatusResponse, error) {
+		dockerClient, err := connection.GetDockerClient()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Could not recover container for job {%s} and target {%s}", request.Job, request.Target))
+		}
+		return dockerClient.Recover(network.WithLabels(context.Background(), request.Labels), &v1.DockerRequest{Name: request.Container})
+	}
+}
+
+// performActionWithDelay schedules a kill with a delay instead of performing it, and cancels a scheduled
+// kill on recover if it has not taken effect yet. Otherwise the action is performed immediately.
+// The delayed kill does not lock the key, since its recovery waits for it to complete while holding the lock
+func (d *DController) performActionWithDelay(ctx context.Context, action action, request *RequestPayload) (string, error) {
+	key := cache.Key{Job: request.Job, Target: request.Target}
+	unlock := d.locks.Lock(key)
+	defer unlock()
+
+	switch {
+	case action == kill && request.DelaySec > 0:
+		return d.delayKill(ctx, key, request)
+	case action == recoverContainer && d.pending.Cancel(key):
+		d.cache.Delete(key)
+		if err := d.persistence.Save(d.cache); err != nil {
+			_ = level.Error(d.loggers.ErrLogger).Log("msg", "Could not update cache after canceling delayed kill", "err", err)
+		}
+		return fmt.Sprintf("Canceled the delayed kill of container {%s} on target {%s}", request.Container, request.Target), nil
+	default:
+		defer d.limiter.Acquire(d.jobs[request.Job].Address(request.Target))()
+		return d.performAction(ctx, action, request)
+	}
+}
+
+// delayKill caches the recovery of the kill immediately and performs the kill after the delay of the request.
+// A recovery within the delay cancels the kill
+func (d *DController) delayKill(ctx context.Context, key cache.Key, request *RequestPayload) (string, error) {
+	delay := time.Duration(request.DelaySec) * time.Second
+	connection := d.connectionPool[d.jobs[request.Job].Address(request.Target)].connection
+	inject := func() {
+		message, err := d.performAction(auth.Detach(ctx), kill, request)
+		if err != nil {
+			_ = level.Error(d.loggers.ErrLogger).Log("msg", fmt.Sprintf("Delayed kill of container {%s} on target {%s} failed", request.Container, request.Target), "err", err)
+			d.cache.Delete(key)
+			if err = d.persistence.Save(d.cache); err != nil {
+				_ = level.Error(d.loggers.ErrLogger).Log("msg", "Could not update cache after failed delayed kill", "err", err)
+			}
+			return
+		}
+		_ = level.Info(d.loggers.OutLogger).Log("msg", message)
+	}
+
+	d.injections.Record(key, &cache.Injection{StartsAt: time.Now(), Labels: request.Labels, Metadata: auth.WithPrincipalMetadata(ctx, nil), Inject: Injection(connection, request)})
+	d.cache.Set(key, d.pending.Delay(key, delay, inject, Recovery(connection, request)))
+	if err := d.persistence.Save(d.cache); err != nil {
+		_ = level.Error(d.loggers.ErrLogger).Log("msg", "Could not update cache for delayed kill", "err", err)
+		if d.persistence.FailClosed() {
+			d.pending.Cancel(key)
+			d.cache.Delete(key)
+			return "", errors.Wrap(err, fmt.Sprintf("Could not persist delayed kill on target {%s}, the kill was canceled", request.Target))
+		}
+	}
+
+	return fmt.Sprintf("Kill of container {%s} on target {%s} is scheduled in %s", request.Container, request.Target, delay), nil
+}
+
+// rollback recovers an injection whose recovery could not be persisted, so that no unrecoverable failure is left behind
+func (d *DController) rollback(request *RequestPayload, cause error) error {
+	key := cache.Key{Job: request.Job, Target: request.Target}
+	if err := cache.Rollback(d.cache, key); err != nil {
+		_ = level.Error(d.loggers.ErrLogger).Log("msg", fmt.Sprintf("Could not roll back injection on target {%s}", request.Target), "err", err)
+		return errors.Wrap(cause, fmt.Sprintf("Could not persist injection on target {%s} and the rollback failed", request.Target))
+	}
+	if err := d.persistence.Save(d.cache); err != nil {
+		_ = level.Error(d.loggers.ErrLogger).Log("msg", "Could not update cache after rollback", "err", err)
+	}
+
+	return errors.Wrap(cause, fmt.Sprintf("Could not persist injection on target {%s}, the injection was rolled back", request.Target))
+}
@@ -2,15 +2,16 @@ package docker
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/SotirisAlfonsos/gocache"
 
@@ -19,9 +20,11 @@ import (
 	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/controller"
 	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
 )
 
 var (
@@ -386,6 +389,20 @@ func TestDockerActionForRandomTargetOneOfJobContainerNameTargetDoesNotExist(t *t
 				response:  badRequestResponse("Could not find container name {container name does not exist}"),
 			},
 		},
+		{
+			message: "Should receive bad request for the container name before the selection of the target when job has no targets defined",
+			jobMap: map[string]*config.Job{
+				"job name": newDockerJob("container name"),
+			},
+			connectionPool: map[string]*dConnection{
+				"127.0.0.1": withSuccessDockerConnection(),
+			},
+			requestPayload: &RequestPayload{Job: "job name", Container: "container name does not exist"},
+			expected: &expectedResult{
+				cacheSize: 0,
+				response:  badRequestResponse("Could not find container name {container name does not exist}"),
+			},
+		},
 		{
 			message: "Should receive bad request and not update cache for action when job has no targets defined",
 			jobMap: map[string]*config.Job{
@@ -504,14 +521,14 @@ func okResponse(message string) *responseWrapper {
 
 func badRequestResponse(error string) *responseWrapper {
 	return &responseWrapper{
-		message: error + "\n",
+		message: error,
 		status:  400,
 	}
 }
 
 func internalServerErrorResponse(error string) *responseWrapper {
 	return &responseWrapper{
-		message: error + "\n",
+		message: error,
 		status:  500,
 	}
 }
@@ -522,6 +539,134 @@ func functionWithSuccessResponse() func() (*v1.StatusResponse, error) {
 	}
 }
 
+func TestRandomDockerLogsSelectedTarget(t *testing.T) {
+	var out bytes.Buffer
+	allowLevel := &chaoslogger.AllowedLevel{}
+	_ = allowLevel.Set("info")
+
+	dController := &DController{
+		jobs: map[string]*config.Job{
+			"job name": newDockerJob("container name", "127.0.0.2"),
+		},
+		connectionPool: map[string]*dConnection{
+			"127.0.0.2": withSuccessDockerConnection(),
+		},
+		cache: gocache.New(0),
+		loggers: chaoslogger.Loggers{
+			OutLogger: chaoslogger.New(allowLevel, &out),
+			ErrLogger: chaoslogger.New(allowLevel, &out),
+		},
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/docker", dController.DockerAction).Queries("action", "{action}").Methods("POST")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	status, _, err := dockerPostCallNoTarget(server, &RequestPayload{Job: "job name", Container: "container name"}, "random", "kill")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Contains(t, out.String(), `msg="selected random target" job="job name" target=127.0.0.2`)
+}
+
+func TestRandomDockerInjectsTheContainerOfTheSelectedJob(t *testing.T) {
+	dataItems := []struct {
+		message        string
+		requestPayload *RequestPayload
+	}{
+		{
+			message:        "Should kill the container of the job when the container is omitted",
+			requestPayload: &RequestPayload{Job: "job b"},
+		},
+		{
+			message:        "Should kill the container of the job when the container is set",
+			requestPayload: &RequestPayload{Job: "job b", Container: "container b"},
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			connection := &recordingDockerConnection{}
+			jobMap := map[string]*config.Job{
+				"job a": newDockerJob("container a", "127.0.0.1"),
+				"job b": newDockerJob("container b", "127.0.0.1"),
+			}
+			c := gocache.New(0)
+			server, err := dockerHTTPTestServerWithCacheItems(jobMap, map[string]*dConnection{"127.0.0.1": {connection: connection}}, c, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			status, _, err := dockerPostCallNoTarget(server, dataItem.requestPayload, "random", "kill")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, http.StatusOK, status)
+			assert.Equal(t, []string{"container b"}, connection.killed)
+			_, ok := c.Get(cache.Key{Job: "job b", Target: "127.0.0.1"})
+			assert.True(t, ok, "the injection should be recorded for the selected job")
+			assert.Equal(t, 1, c.ItemCount())
+		})
+	}
+}
+
+func TestRandomDockerCanBeDisabled(t *testing.T) {
+	disallowed, allowed := false, true
+	dataItems := []struct {
+		message        string
+		dockerConf     *config.DockerOptions
+		expectedStatus int
+		expectedCache  int
+	}{
+		{
+			message:        "Should reject the random injection with 403 when random is disabled",
+			dockerConf:     &config.DockerOptions{AllowRandom: &disallowed},
+			expectedStatus: http.StatusForbidden,
+			expectedCache:  0,
+		},
+		{
+			message:        "Should inject on a random target when random is enabled",
+			dockerConf:     &config.DockerOptions{AllowRandom: &allowed},
+			expectedStatus: http.StatusOK,
+			expectedCache:  1,
+		},
+		{
+			message:        "Should inject on a random target when random is not configured",
+			expectedStatus: http.StatusOK,
+			expectedCache:  1,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			connections := &network.Connections{
+				Pool: map[string]network.Connection{
+					"127.0.0.1": withSuccessDockerConnection().connection,
+				},
+			}
+			jobMap := map[string]*config.Job{"job name": newDockerJob("container name", "127.0.0.1")}
+			dController := NewDockerController(jobMap, &controller.Options{Connections: connections, Cache: c, Docker: dataItem.dockerConf, Loggers: loggers})
+			router := mux.NewRouter()
+			router.HandleFunc("/docker", dController.DockerAction).Queries("action", "{action}").Methods("POST")
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			status, message, err := dockerPostCallNoTarget(server, &RequestPayload{Job: "job name", Container: "container name"}, "random", "kill")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, dataItem.expectedStatus, status, message)
+			assert.Equal(t, dataItem.expectedCache, c.ItemCount())
+		})
+	}
+}
+
 func assertRandomActionPerformed(t *testing.T, dataItem TestDataForRandomDocker, do string, action string) {
 	t.Run(dataItem.message, func(t *testing.T) {
 		c := gocache.New(0)
@@ -602,8 +747,12 @@ func post(requestBody []byte, url string) (int, string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		b, _ := ioutil.ReadAll(resp.Body)
-		return resp.StatusCode, string(b), nil
+		errPayload := &response.ErrorPayload{}
+		err = json.NewDecoder(resp.Body).Decode(&errPayload)
+		if err != nil {
+			return resp.StatusCode, "", err
+		}
+		return errPayload.Status, errPayload.Error, nil
 	}
 
 	respPayload := &response.Payload{}
@@ -625,17 +774,18 @@ func newDockerJob(componentName string, targets ...string) *config.Job {
 func dockerHTTPTestServerWithCacheItems(
 	jobMap map[string]*config.Job,
 	connectionPool map[string]*dConnection,
-	cache *gocache.Cache,
+	cacheManager *gocache.Cache,
 	cacheItems map[cache.Key]func() (*v1.StatusResponse, error),
 ) (*httptest.Server, error) {
 	for key, val := range cacheItems {
-		cache.Set(key, val)
+		cacheManager.Set(key, val)
 	}
 
 	dController := &DController{
 		jobs:           jobMap,
 		connectionPool: connectionPool,
-		cache:          cache,
+		cache:          cacheManager,
+		pending:        cache.NewPending(),
 		loggers:        loggers,
 	}
 
@@ -658,3 +808,70 @@ func getLoggers() chaoslogger.Loggers {
 		OutLogger: chaoslogger.New(allowLevel, os.Stdout),
 	}
 }
+
+func TestActionsMatchActionEnum(t *testing.T) {
+	actions := Actions()
+
+	assert.Equal(t, int(notImplemented), len(actions))
+	for i, name := range actions {
+		a, err := toActionEnum(name, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, action(i), a)
+	}
+}
+
+// recordingDockerConnection records the names of the containers killed through its docker client
+type recordingDockerConnection struct {
+	network.MockConnection
+	killed []string
+}
+
+func (c *recordingDockerConnection) GetDockerClient() (v1.DockerClient, error) {
+	return &recordingDockerClient{connection: c}, nil
+}
+
+type recordingDockerClient struct {
+	connection *recordingDockerConnection
+}
+
+func (client *recordingDockerClient) Recover(_ context.Context, _ *v1.DockerRequest, _ ...grpc.CallOption) (*v1.StatusResponse, error) {
+	return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+}
+
+func (client *recordingDockerClient) Kill(_ context.Context, in *v1.DockerRequest, _ ...grpc.CallOption) (*v1.StatusResponse, error) {
+	client.connection.killed = append(client.connection.killed, in.Name)
+	return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+}
+
+func TestDockerAutomaticRecoveryDoesNotRecoverAKeyInjectedAgain(t *testing.T) {
+	job := newDockerJob("nginx", "127.0.0.1")
+	job.DefaultDuration = 20 * time.Millisecond
+	connection := &recoverCountingConnection{MockConnection: network.MockConnection{Status: new(v1.StatusResponse)}}
+	cacheManager := gocache.New(0)
+	locks := cache.NewKeyLocks()
+	injections := cache.NewInjections()
+	controller := &DController{
+		jobs:           map[string]*config.Job{"job name": job},
+		connectionPool: map[string]*dConnection{"127.0.0.1": {connection: connection}},
+		cache:          cacheManager,
+		locks:          locks,
+		injections:     injections,
+		pending:        cache.NewPending(),
+		loggers:        loggers,
+	}
+
+	if _, err := controller.performActionWithDelay(context.Background(), kill, &RequestPayload{Job: "job name", Container: "nginx", Target: "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the automatic recovery is due while the key is locked by an injection of the key that is in flight
+	key := cache.Key{Job: "job name", Target: "127.0.0.1"}
+	unlock := locks.Lock(key)
+	time.Sleep(100 * time.Millisecond)
+	injections.Record(key, &cache.Injection{StartsAt: time.Now()})
+	unlock()
+	time.Sleep(50 * time.Millisecond)
+
+	assert.Equal(t, 0, connection.recoverCalls(), "the automatic recovery should not recover the injection that replaced its own")
+	assert.Equal(t, 1, len(cacheManager.GetAll()))
+}
@@ -0,0 +1,186 @@
+package docker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/healthcheck"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+const (
+	healthyPercentage  = "healthy-percentage"
+	healthCheckTimeout = 5 * time.Second
+)
+
+// SelectionResult is the result of the action on one of the selected targets
+type SelectionResult struct {
+	Target  string `json:"target"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Status  string `json:"status"`
+}
+
+// SelectionResponsePayload contains the healthy targets of the job, and the result of the action on the selected ones
+type SelectionResponsePayload struct {
+	Healthy []string           `json:"healthy"`
+	Results []*SelectionResult `json:"results"`
+	Status  int                `json:"status"`
+}
+
+// healthyPercentageDocker performs the action on the percentage of the targets of the job that are healthy.
+// The health of every target is checked when the request is received
+func (d *DController) healthyPercentageDocker(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	requestPayload := &RequestPayload{}
+	err := json.NewDecoder(r.Body).Decode(&requestPayload)
+	if err != nil {
+		response.BadRequest(w, r, "Could not decode request body", d.loggers)
+		return
+	}
+
+	if err = network.ValidateLabels(requestPayload.Labels); err != nil {
+		response.BadRequest(w, r, err.Error(), d.loggers)
+		return
+	}
+
+	action, err := toActionEnum(r.FormValue("action"), d.aliases)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), d.loggers)
+		return
+	}
+
+	percentage, err := strconv.Atoi(r.FormValue("value"))
+	if err != nil || percentage < 1 || percentage > 100 {
+		response.BadRequest(w, r, fmt.Sprintf("The value {%s} should be a percentage between 1 and 100", r.FormValue("value")), d.loggers)
+		return
+	}
+
+	job, ok := d.jobs[requestPayload.Job]
+	if !ok {
+		response.BadRequest(w, r, fmt.Sprintf("Could not find job {%s}", requestPayload.Job), d.loggers)
+		return
+	}
+
+	if err = resolveContainer(job, requestPayload); err != nil {
+		response.BadRequest(w, r, err.Error(), d.loggers)
+		return
+	}
+
+	if !job.Allows(action.String()) {
+		response.Forbidden(w, r, fmt.Sprintf("The action {%s} is not allowed for job {%s}", action, requestPayload.Job), d.loggers)
+		return
+	}
+
+	healthy := d.healthyTargets(ctx, job)
+	if len(healthy) == 0 {
+		response.BadRequest(w, r, fmt.Sprintf("There are no healthy targets for job {%s}", requestPayload.Job), d.loggers)
+		return
+	}
+
+	selected, err := selectTargets(healthy, percentage)
+	if err != nil {
+		response.InternalServerError(w, r, fmt.Sprintf("Could not select targets for job {%s}. err: %s", requestPayload.Job, err.Error()), d.loggers)
+		return
+	}
+
+	for _, target := range selected {
+		if action == kill && job.RequiresConfirmation(target) && r.FormValue("confirmProduction") != "true" {
+			response.Forbidden(w, r, fmt.Sprintf("The target {%s} is a production target. Set the query parameter confirmProduction=true to inject", target), d.loggers)
+			return
+		}
+	}
+
+	if action == kill {
+		if err = d.guard.CheckHealthy(requestPayload.Job, healthy, selected); err != nil {
+			response.TooManyRequests(w, r, err.Error(), d.loggers)
+			return
+		}
+	}
+
+	_ = level.Info(d.loggers.OutLogger).Log("msg", fmt.Sprintf("%s container on %d%% of the healthy targets", action, percentage),
+		"job", requestPayload.Job, "healthy", len(healthy), "selected", len(selected))
+
+	payload := &SelectionResponsePayload{Healthy: healthy, Results: make([]*SelectionResult, 0, len(selected)), Status: http.StatusOK}
+	for _, target := range selected {
+		targetPayload := *requestPayload
+		targetPayload.Target = target
+
+		result := &SelectionResult{Target: target, Status: response.SUCCESS.String()}
+		if result.Message, err = d.performActionWithDelay(ctx, action, &targetPayload); err != nil {
+			result.Error = err.Error()
+			result.Status = response.FAILURE.String()
+			payload.Status = http.StatusInternalServerError
+		}
+		payload.Results = append(payload.Results, result)
+	}
+
+	response.JSONResponse(w, r, payload.Status, payload, d.loggers)
+}
+
+// healthyTargets returns the targets of the job whose bot is serving, in the order of the job
+func (d *DController) healthyTargets(ctx context.Context, job *config.Job) []string {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	serving := make([]bool, len(job.Target))
+	var wg sync.WaitGroup
+	for i, target := range job.Target {
+		connection, ok := d.connectionPool[job.Address(target)]
+		if !ok {
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, target string, connection *dConnection) {
+			defer wg.Done()
+			status, err := healthcheck.Check(ctx, connection.connection)
+			if err != nil {
+				_ = level.Warn(d.loggers.OutLogger).Log("msg", "target is not healthy", "target", target, "err", err)
+			}
+			serving[i] = status == v1.HealthCheckResponse_SERVING
+		}(i, target, connection)
+	}
+	wg.Wait()
+
+	healthy := make([]string, 0, len(job.Target))
+	for i, target := range job.Target {
+		if serving[i] {
+			healthy = append(healthy, target)
+		}
+	}
+	return healthy
+}
+
+// selectTargets returns a random selection of the percentage of the targets, rounded up
+func selectTargets(targets []string, percentage int) ([]string, error) {
+	count := (len(targets)*percentage + 99) / 100
+
+	remaining := append([]string{}, targets...)
+	selected := make([]string, 0, count)
+	for len(selected) < count {
+		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(remaining))))
+		if err != nil {
+			return nil, errors.Wrap(err, "Could not select random target")
+		}
+		i := num.Int64()
+		selected = append(selected, remaining[i])
+		remaining = append(remaining[:i], remaining[i+1:]...)
+	}
+
+	return selected, nil
+}
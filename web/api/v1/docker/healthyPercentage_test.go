@@ -0,0 +1,190 @@
+package docker
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/healthcheck"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealthyPercentageSelectsOnlyHealthyTargets(t *testing.T) {
+	dataItems := []struct {
+		message          string
+		value            string
+		expectedSelected int
+	}{
+		{
+			message:          "Should kill the container on all the healthy targets",
+			value:            "100",
+			expectedSelected: 2,
+		},
+		{
+			message:          "Should kill the container on half of the healthy targets",
+			value:            "50",
+			expectedSelected: 1,
+		},
+		{
+			message:          "Should round up the number of selected targets",
+			value:            "1",
+			expectedSelected: 1,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			server, err := dockerHTTPTestServerWithCacheItems(mixedHealthJobMap(), mixedHealthConnectionPool(), c, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			status, payload := healthyPercentagePostCall(t, server.URL, dataItem.value)
+
+			assert.Equal(t, http.StatusOK, status)
+			assert.Equal(t, []string{"127.0.0.1", "127.0.0.3"}, payload.Healthy)
+			assert.Equal(t, dataItem.expectedSelected, len(payload.Results))
+			for _, result := range payload.Results {
+				assert.Contains(t, []string{"127.0.0.1", "127.0.0.3"}, result.Target, "only healthy targets should be selected")
+				assert.Equal(t, "SUCCESS", result.Status)
+			}
+			assert.Equal(t, dataItem.expectedSelected, c.ItemCount())
+		})
+	}
+}
+
+func TestHealthyPercentageBadRequests(t *testing.T) {
+	dataItems := []struct {
+		message         string
+		value           string
+		connectionPool  map[string]*dConnection
+		expectedMessage string
+	}{
+		{
+			message:         "Should receive bad request if the value is not a percentage",
+			value:           "150",
+			connectionPool:  mixedHealthConnectionPool(),
+			expectedMessage: "The value {150} should be a percentage between 1 and 100",
+		},
+		{
+			message: "Should receive bad request if no target is healthy",
+			value:   "50",
+			connectionPool: map[string]*dConnection{
+				"127.0.0.1": withHealthDockerConnection(v1.HealthCheckResponse_NOT_SERVING),
+			},
+			expectedMessage: "There are no healthy targets for job {job name}",
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			server, err := dockerHTTPTestServerWithCacheItems(mixedHealthJobMap(), dataItem.connectionPool, c, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			requestBody, _ := json.Marshal(&RequestPayload{Job: "job name", Container: "container name"})
+			status, message, err := post(requestBody, server.URL+"/docker?do=healthy-percentage&value="+dataItem.value+"&action=kill")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, http.StatusBadRequest, status)
+			assert.Equal(t, dataItem.expectedMessage, message)
+			assert.Equal(t, 0, c.ItemCount())
+		})
+	}
+}
+
+func TestHealthyPercentageIsRejectedIfTooFewHealthyTargetsWouldRemain(t *testing.T) {
+	c := gocache.New(0)
+	dController := &DController{
+		jobs:           mixedHealthJobMap(),
+		connectionPool: mixedHealthConnectionPool(),
+		cache:          c,
+		pending:        cache.NewPending(),
+		guard:          healthcheck.NewGuard(&healthcheck.HealthChecker{}, nil, &config.Limits{MinHealthyTargets: 1}),
+		loggers:        loggers,
+	}
+	router := mux.NewRouter()
+	router.HandleFunc("/docker", dController.DockerAction).
+		Queries("action", "{action}").
+		Methods("POST")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	requestBody, _ := json.Marshal(&RequestPayload{Job: "job name", Container: "container name"})
+	status, message, err := post(requestBody, server.URL+"/docker?do=healthy-percentage&value=100&action=kill")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusTooManyRequests, status)
+	assert.Equal(t, "Injecting on {2} targets of job {job name} would leave {0} healthy targets, fewer than the minimum of {1}", message)
+	assert.Equal(t, 0, c.ItemCount())
+}
+
+func TestSelectTargetsReturnsDistinctTargets(t *testing.T) {
+	targets := []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}
+
+	selected, err := selectTargets(targets, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(selected)
+	assert.Equal(t, targets, selected)
+}
+
+func mixedHealthJobMap() map[string]*config.Job {
+	return map[string]*config.Job{
+		"job name": {
+			ComponentName: "container name",
+			FailureType:   config.Docker,
+			Target:        []string{"127.0.0.1", "127.0.0.2", "127.0.0.3", "127.0.0.4"},
+		},
+	}
+}
+
+func mixedHealthConnectionPool() map[string]*dConnection {
+	unreachable := &network.MockConnection{Status: new(v1.StatusResponse), Err: errors.New("connection refused")}
+	return map[string]*dConnection{
+		"127.0.0.1": withHealthDockerConnection(v1.HealthCheckResponse_SERVING),
+		"127.0.0.2": withHealthDockerConnection(v1.HealthCheckResponse_NOT_SERVING),
+		"127.0.0.3": withHealthDockerConnection(v1.HealthCheckResponse_SERVING),
+		"127.0.0.4": {connection: unreachable},
+	}
+}
+
+func withHealthDockerConnection(health v1.HealthCheckResponse_ServingStatus) *dConnection {
+	return &dConnection{connection: &network.MockConnection{Status: new(v1.StatusResponse), Health: health}}
+}
+
+func healthyPercentagePostCall(t *testing.T, url string, value string) (int, *SelectionResponsePayload) {
+	requestBody, _ := json.Marshal(&RequestPayload{Job: "job name", Container: "container name"})
+	resp, err := http.Post(url+"/docker?do=healthy-percentage&value="+value+"&action=kill", "", bytes.NewReader(requestBody)) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	payload := &SelectionResponsePayload{}
+	if err = json.NewDecoder(resp.Body).Decode(payload); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, payload
+}
@@ -0,0 +1,77 @@
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/go-kit/kit/log/level"
+)
+
+// PulseAction godoc
+// @Summary Kill and recover a container
+// @Description Kill a container, keep it down for the duration of the request or the default duration of the job, and recover it
+// @Tags Failure injections
+// @Accept json
+// @Produce json
+// @Param confirmProduction query bool false "Confirm the injection on a target labeled env: prod"
+// @Param requestPayload body RequestPayload true "Specify the job name, container name, target and duration"
+// @Success 200 {object} response.PulsePayload
+// @Failure 400 {object} response.ErrorPayload
+// @Failure 500 {object} response.PulsePayload
+// @Router /docker/pulse [post]
+func (d *DController) PulseAction(w http.ResponseWriter, r *http.Request) {
+	requestPayload := &RequestPayload{}
+	err := json.NewDecoder(r.Body).Decode(&requestPayload)
+	if err != nil {
+		response.BadRequest(w, r, "Could not decode request body", d.loggers)
+		return
+	}
+	requestPayload.pulse = true
+
+	if err = network.ValidateLabels(requestPayload.Labels); err != nil {
+		response.BadRequest(w, r, err.Error(), d.loggers)
+		return
+	}
+
+	err = checkIfTargetExists(d.jobs, requestPayload)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), d.loggers)
+		return
+	}
+
+	job := d.jobs[requestPayload.Job]
+	for _, action := range []action{kill, recoverContainer} {
+		if !job.Allows(action.String()) {
+			response.Forbidden(w, r, fmt.Sprintf("The action {%s} is not allowed for job {%s}", action, requestPayload.Job), d.loggers)
+			return
+		}
+	}
+
+	if job.RequiresConfirmation(requestPayload.Target) && r.FormValue("confirmProduction") != "true" {
+		response.Forbidden(w, r, fmt.Sprintf("The target {%s} is a production target. Set the query parameter confirmProduction=true to inject", requestPayload.Target), d.loggers)
+		return
+	}
+
+	hold := job.RecoverAfter(requestPayload.DurationSec)
+	if hold <= 0 {
+		response.BadRequest(w, r, fmt.Sprintf("The pulse for job {%s} requires a positive durationSec", requestPayload.Job), d.loggers)
+		return
+	}
+
+	_ = level.Info(d.loggers.OutLogger).Log("msg", fmt.Sprintf("pulse container with name {%s} for %s", requestPayload.Container, hold))
+
+	payload := response.Pulse(r.Context(), hold,
+		func(ctx context.Context) (string, error) {
+			return d.performActionWithDelay(ctx, kill, requestPayload)
+		},
+		func(ctx context.Context) (string, error) {
+			return d.performActionWithDelay(ctx, recoverContainer, requestPayload)
+		},
+	)
+
+	response.JSONResponse(w, r, payload.Status, payload, d.loggers)
+}
@@ -0,0 +1,58 @@
+package docker
+
+import (
+	"crypto/rand"
+	"math/big"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/target"
+)
+
+// weightPrecision is the number of the points that the random point of a weighted selection is drawn from
+const weightPrecision = 1 << 53
+
+// healthWeights returns the weight of every target of the job in the random selection, from the health score
+// of its bot and the health bias. Targets that have not been health-checked have a weight of 1
+func (d *DController) healthWeights(job *config.Job) []float64 {
+	weights := make([]float64, len(job.Target))
+	for i, target := range job.Target {
+		weights[i] = 1
+		if score, ok := d.healthChecker.Score(job.Address(target)); ok {
+			weights[i] = healthWeight(score, d.healthBias)
+		}
+	}
+	return weights
+}
+
+// healthWeight returns 1 + bias * (2*score - 1), so that with a bias of 1 a target that was always serving is twice
+// as likely to be selected as a target without a score, and a target that was never serving is not selected
+func healthWeight(score float64, bias float64) float64 {
+	return 1 + bias*(2*score-1)
+}
+
+// selectWeightedTarget returns a random target, with a probability proportional to its weight.
+// If none of the targets has a positive weight every target has the same probability
+func selectWeightedTarget(targets []string, weights []float64) (string, error) {
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+	if total <= 0 {
+		return target.RandomOf(targets)
+	}
+
+	num, err := rand.Int(rand.Reader, big.NewInt(weightPrecision))
+	if err != nil {
+		return "", err
+	}
+	point := float64(num.Int64()) / weightPrecision * total
+
+	cumulative := 0.0
+	for i, selected := range targets {
+		cumulative += weights[i]
+		if weights[i] > 0 && point < cumulative {
+			return selected, nil
+		}
+	}
+	return target.RandomOf(targets)
+}
@@ -0,0 +1,73 @@
+package docker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandomSelectionSkewsAccordingToTheHealthBias(t *testing.T) {
+	dataItems := []struct {
+		message         string
+		bias            float64
+		minHealthyShare float64
+		maxHealthyShare float64
+	}{
+		{
+			message:         "Should select only the healthy target with a bias of 1",
+			bias:            1,
+			minHealthyShare: 1,
+			maxHealthyShare: 1,
+		},
+		{
+			message:         "Should select only the unhealthy target with a bias of -1",
+			bias:            -1,
+			minHealthyShare: 0,
+			maxHealthyShare: 0,
+		},
+		{
+			message:         "Should prefer the healthy target with a positive bias",
+			bias:            0.5,
+			minHealthyShare: 0.7,
+			maxHealthyShare: 0.8,
+		},
+		{
+			message:         "Should select both targets with the same probability without a bias",
+			bias:            0,
+			minHealthyShare: 0.45,
+			maxHealthyShare: 0.55,
+		},
+	}
+
+	const draws = 10000
+	targets := []string{"healthy", "unhealthy"}
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			weights := []float64{healthWeight(1, dataItem.bias), healthWeight(0, dataItem.bias)}
+
+			healthy := 0
+			for i := 0; i < draws; i++ {
+				target, err := selectWeightedTarget(targets, weights)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if target == "healthy" {
+					healthy++
+				}
+			}
+
+			share := float64(healthy) / draws
+			assert.True(t, share >= dataItem.minHealthyShare && share <= dataItem.maxHealthyShare,
+				"the healthy target was selected in %.3f of the draws", share)
+		})
+	}
+}
+
+func TestRandomSelectionWithoutPositiveWeightsSelectsAnyTarget(t *testing.T) {
+	target, err := selectWeightedTarget([]string{"127.0.0.1", "127.0.0.2"}, []float64{0, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Contains(t, []string{"127.0.0.1", "127.0.0.2"}, target)
+}
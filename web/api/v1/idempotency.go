@@ -0,0 +1,186 @@
+package v1
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/auth"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/go-kit/kit/log/level"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header with the key that identifies retries of the same request
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on the responses that are replayed from a previous request with the same key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	defaultIdempotencyTTL = 10 * time.Minute
+)
+
+// idempotency keeps the responses of the requests with an Idempotency-Key header for the ttl,
+// so that a retried request gets the response of the first one instead of injecting again
+type idempotency struct {
+	ttl     time.Duration
+	now     func() time.Time
+	mu      sync.Mutex
+	entries map[string]*idempotentEntry
+	loggers chaoslogger.Loggers
+}
+
+// idempotentEntry is the response of a request. The done channel is closed when the response is recorded,
+// and a nil response means that the request failed with a server error and should be executed again.
+// The body hash is the hash of the body of the request, which the retries with the same key should have
+type idempotentEntry struct {
+	done     chan struct{}
+	bodyHash string
+	response *bufferedWriter
+	expireAt time.Time
+}
+
+func newIdempotency(ttl time.Duration, loggers chaoslogger.Loggers) *idempotency {
+	if ttl <= 0 {
+		ttl = defaultIdempotencyTTL
+	}
+	return &idempotency{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]*idempotentEntry),
+		loggers: loggers,
+	}
+}
+
+// middleware replays the recorded response of a previous request with the same Idempotency-Key, method and url.
+// Concurrent requests with the same key wait for the first one to complete. Responses with a server error
+// are not recorded, so that the request is executed again when it is retried. A request with the key of
+// a previous request with a different body is rejected with 422
+func (i *idempotency) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		bodyHash, err := hashBody(r)
+		if err != nil {
+			response.BadRequest(w, r, "Could not read the request body", i.loggers)
+			return
+		}
+		scoped := scopedKey(r, key)
+
+		for {
+			entry, first := i.entry(scoped, bodyHash)
+			if first {
+				i.serve(next, w, r, scoped, entry)
+				return
+			}
+
+			if entry.bodyHash != bodyHash {
+				response.UnprocessableEntity(w, r, fmt.Sprintf("The %s {%s} was used for a request with a different body", IdempotencyKeyHeader, key), i.loggers)
+				return
+			}
+
+			<-entry.done
+			if entry.response != nil {
+				_ = level.Info(i.loggers.OutLogger).Log("msg", "replay response of idempotent request", "url", r.URL.String())
+				w.Header().Set(IdempotentReplayedHeader, "true")
+				entry.response.writeTo(w)
+				return
+			}
+		}
+	})
+}
+
+// serve executes the first request with the key and records its response. The entry is recorded even if the handler
+// panics, as a failed request, so that the requests waiting for it are executed again instead of waiting forever
+func (i *idempotency) serve(next http.Handler, w http.ResponseWriter, r *http.Request, key string, entry *idempotentEntry) {
+	bw := &bufferedWriter{header: make(http.Header)}
+	recorded := false
+	defer func() {
+		if !recorded {
+			i.record(key, entry, nil)
+		}
+	}()
+
+	next.ServeHTTP(bw, r)
+	i.record(key, entry, bw)
+	recorded = true
+	bw.writeTo(w)
+}
+
+// entry returns the entry of the key, and true if the request is the first one with the key and should be executed
+func (i *idempotency) entry(key string, bodyHash string) (*idempotentEntry, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	now := i.now()
+	for k, entry := range i.entries {
+		if isClosed(entry.done) && now.After(entry.expireAt) {
+			delete(i.entries, k)
+		}
+	}
+
+	if entry, ok := i.entries[key]; ok {
+		return entry, false
+	}
+
+	entry := &idempotentEntry{done: make(chan struct{}), bodyHash: bodyHash}
+	i.entries[key] = entry
+	return entry, true
+}
+
+// record records the response of the request of the entry, or removes the entry if the request failed with a server
+// error or did not complete, and then releases the requests that wait for it
+func (i *idempotency) record(key string, entry *idempotentEntry, bw *bufferedWriter) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if bw != nil && bw.status < http.StatusInternalServerError {
+		entry.response = bw
+		entry.expireAt = i.now().Add(i.ttl)
+	} else {
+		delete(i.entries, key)
+	}
+	close(entry.done)
+}
+
+// scopedKey scopes the idempotency key to the principal, method and url of the request,
+// so that the same key can not replay the response of a different request
+func scopedKey(r *http.Request, key string) string {
+	principal, _ := auth.PrincipalFromContext(r.Context())
+	return principal + " " + r.Method + " " + r.URL.RequestURI() + " " + key
+}
+
+// hashBody returns the hex sha256 hash of the body of the request, which is restored for the handler
+func hashBody(r *http.Request) (string, error) {
+	if r.Body == nil {
+		return "", nil
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	_ = r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(body)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+func isClosed(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
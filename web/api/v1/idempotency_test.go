@@ -0,0 +1,191 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestReplayedIdempotentRequestReturnsRecordedResponse(t *testing.T) {
+	connection := &countingConnection{status: v1.StatusResponse_SUCCESS}
+	server := countingHTTPTestServer(connection)
+	defer server.Close()
+
+	firstStatus, firstBody, firstHeader := postIdempotent(t, server.URL+"/chaos/api/v1/cpu?action=start", "key-1")
+	replayStatus, replayBody, replayHeader := postIdempotent(t, server.URL+"/chaos/api/v1/cpu?action=start", "key-1")
+
+	assert.Equal(t, 1, connection.startCalls(), "the replayed request should not call the bot again")
+	assert.Equal(t, http.StatusOK, firstStatus)
+	assert.Equal(t, firstStatus, replayStatus)
+	assert.Equal(t, firstBody, replayBody)
+	assert.Equal(t, "", firstHeader.Get(IdempotentReplayedHeader))
+	assert.Equal(t, "true", replayHeader.Get(IdempotentReplayedHeader))
+
+	postIdempotent(t, server.URL+"/chaos/api/v1/cpu?action=start", "key-2")
+	assert.Equal(t, 2, connection.startCalls(), "a request with a different key should call the bot")
+}
+
+func TestConcurrentIdempotentRequestsCallTheBotOnce(t *testing.T) {
+	connection := &countingConnection{status: v1.StatusResponse_SUCCESS}
+	server := countingHTTPTestServer(connection)
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			postIdempotent(t, server.URL+"/chaos/api/v1/cpu?action=start", "key")
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, connection.startCalls())
+}
+
+func TestIdempotentRequestWithServerErrorIsExecutedAgain(t *testing.T) {
+	connection := &countingConnection{status: v1.StatusResponse_FAIL}
+	server := countingHTTPTestServer(connection)
+	defer server.Close()
+
+	firstStatus, _, _ := postIdempotent(t, server.URL+"/chaos/api/v1/cpu?action=start", "key")
+	postIdempotent(t, server.URL+"/chaos/api/v1/cpu?action=start", "key")
+
+	assert.Equal(t, http.StatusInternalServerError, firstStatus)
+	assert.Equal(t, 2, connection.startCalls(), "a failed request should not be replayed")
+}
+
+func TestIdempotentRequestWithADifferentBodyIsRejected(t *testing.T) {
+	connection := &countingConnection{status: v1.StatusResponse_SUCCESS}
+	server := countingHTTPTestServer(connection)
+	defer server.Close()
+
+	postIdempotent(t, server.URL+"/chaos/api/v1/cpu?action=start", "key")
+	status, body, _ := postIdempotentWithBody(t, server.URL+"/chaos/api/v1/cpu?action=start", "key",
+		[]byte(`{"job": "cpu job", "percentage": 90, "target": "127.0.0.1"}`))
+
+	assert.Equal(t, http.StatusUnprocessableEntity, status)
+	assert.Equal(t, "{\"error\":\"The Idempotency-Key {key} was used for a request with a different body\",\"status\":422}\n", body)
+	assert.Equal(t, 1, connection.startCalls(), "the request with a different body should not call the bot")
+}
+
+func TestIdempotentRequestIsExecutedAgainAfterTheHandlerPanics(t *testing.T) {
+	calls := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			panic("handler failed")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+	server := httptest.NewServer(newIdempotency(0, loggers).middleware(handler))
+	defer server.Close()
+
+	if _, err := idempotentCall(server.URL, "key", nil); err == nil {
+		t.Fatal("the request whose handler panicked should fail")
+	}
+
+	done := make(chan int)
+	go func() {
+		resp, err := idempotentCall(server.URL, "key", nil)
+		if err != nil {
+			done <- 0
+			return
+		}
+		_ = resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+
+	select {
+	case status := <-done:
+		assert.Equal(t, http.StatusOK, status)
+	case <-time.After(time.Second):
+		t.Fatal("the retry of a request whose handler panicked should not wait for it")
+	}
+	assert.Equal(t, 2, calls)
+}
+
+func countingHTTPTestServer(connection network.Connection) *httptest.Server {
+	jobMap := map[string]*config.Job{
+		"cpu job": {FailureType: config.CPU, Target: []string{"127.0.0.1"}},
+	}
+	connections := &network.Connections{Pool: map[string]network.Connection{"127.0.0.1": connection}}
+
+	apiRouter := NewAPIRouter(&config.Config{}, jobMap, connections, gocache.New(0), async.NewTracker(), loggers)
+	return httptest.NewServer(apiRouter.AddRoutes(nil, mux.NewRouter()))
+}
+
+func postIdempotent(t *testing.T, url string, key string) (int, string, http.Header) {
+	return postIdempotentWithBody(t, url, key, []byte(`{"job": "cpu job", "percentage": 50, "target": "127.0.0.1"}`))
+}
+
+func postIdempotentWithBody(t *testing.T, url string, key string, body []byte) (int, string, http.Header) {
+	resp, err := idempotentCall(url, key, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(b), resp.Header
+}
+
+func idempotentCall(url string, key string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(IdempotencyKeyHeader, key)
+
+	return http.DefaultClient.Do(req)
+}
+
+// countingConnection counts the cpu injections that reach the bot
+type countingConnection struct {
+	network.MockConnection
+	status v1.StatusResponse_Status
+	mu     sync.Mutex
+	starts int
+}
+
+func (connection *countingConnection) GetCPUClient() (v1.CPUClient, error) {
+	return &countingCPUClient{connection: connection}, nil
+}
+
+func (connection *countingConnection) startCalls() int {
+	connection.mu.Lock()
+	defer connection.mu.Unlock()
+	return connection.starts
+}
+
+type countingCPUClient struct {
+	connection *countingConnection
+}
+
+func (client *countingCPUClient) Start(_ context.Context, _ *v1.CPURequest, _ ...grpc.CallOption) (*v1.StatusResponse, error) {
+	client.connection.mu.Lock()
+	defer client.connection.mu.Unlock()
+	client.connection.starts++
+	return &v1.StatusResponse{Status: client.connection.status}, nil
+}
+
+func (client *countingCPUClient) Recover(_ context.Context, _ *v1.CPURequest, _ ...grpc.CallOption) (*v1.StatusResponse, error) {
+	return &v1.StatusResponse{Status: client.connection.status}, nil
+}
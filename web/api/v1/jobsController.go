@@ -0,0 +1,60 @@
+package v1
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+)
+
+// JobDescription is a job of the config, with the targets its failures can be injected on
+type JobDescription struct {
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Component string   `json:"component,omitempty"`
+	Targets   []string `json:"targets"`
+}
+
+// JobsPayload contains the jobs of the config, sorted by name
+type JobsPayload struct {
+	Jobs  []*JobDescription `json:"jobs"`
+	Count int               `json:"count"`
+}
+
+type JobsController struct {
+	jobs    map[string]*config.Job
+	loggers chaoslogger.Loggers
+}
+
+// Jobs godoc
+// @Summary List the jobs
+// @Description List the jobs of the config with their failure type, component and targets
+// @Tags Status
+// @Produce json
+// @Param type query string false "Only the jobs of the failure type" Enums(Docker, Service, CPU, Server, Network)
+// @Success 200 {object} JobsPayload
+// @Router /jobs [get]
+func (jc *JobsController) Jobs(w http.ResponseWriter, r *http.Request) {
+	jobs := jc.jobs
+	if failureType := r.FormValue("type"); failureType != "" {
+		jobs = filterJobsOnType(jobs, config.FailureType(failureType))
+	}
+
+	payload := &JobsPayload{Jobs: make([]*JobDescription, 0, len(jobs))}
+	for name, job := range jobs {
+		payload.Jobs = append(payload.Jobs, &JobDescription{
+			Name:      name,
+			Type:      string(job.FailureType),
+			Component: job.ComponentName,
+			Targets:   append([]string{}, job.Target...),
+		})
+	}
+	sort.Slice(payload.Jobs, func(i, j int) bool {
+		return payload.Jobs[i].Name < payload.Jobs[j].Name
+	})
+	payload.Count = len(payload.Jobs)
+
+	response.JSONResponse(w, r, http.StatusOK, payload, jc.loggers)
+}
@@ -0,0 +1,82 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJobsListsTheJobsOfTheConfig(t *testing.T) {
+	jobs := map[string]*config.Job{
+		"cpu job":    {FailureType: config.CPU, Target: []string{"127.0.0.1"}},
+		"docker job": {FailureType: config.Docker, ComponentName: "nginx", Target: []string{"127.0.0.2", "127.0.0.3"}},
+	}
+
+	dataItems := []struct {
+		message  string
+		jobs     map[string]*config.Job
+		query    string
+		expected *JobsPayload
+	}{
+		{
+			message: "Should list all the jobs sorted by name",
+			jobs:    jobs,
+			expected: &JobsPayload{
+				Jobs: []*JobDescription{
+					{Name: "cpu job", Type: "CPU", Targets: []string{"127.0.0.1"}},
+					{Name: "docker job", Type: "Docker", Component: "nginx", Targets: []string{"127.0.0.2", "127.0.0.3"}},
+				},
+				Count: 2,
+			},
+		},
+		{
+			message: "Should list only the jobs of the failure type",
+			jobs:    jobs,
+			query:   "?type=Docker",
+			expected: &JobsPayload{
+				Jobs:  []*JobDescription{{Name: "docker job", Type: "Docker", Component: "nginx", Targets: []string{"127.0.0.2", "127.0.0.3"}}},
+				Count: 1,
+			},
+		},
+		{
+			message:  "Should return an empty list if no job is of the failure type",
+			jobs:     jobs,
+			query:    "?type=Network",
+			expected: &JobsPayload{Jobs: []*JobDescription{}, Count: 0},
+		},
+		{
+			message:  "Should return an empty list if there are no jobs",
+			jobs:     map[string]*config.Job{},
+			expected: &JobsPayload{Jobs: []*JobDescription{}, Count: 0},
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			jobsController := &JobsController{jobs: dataItem.jobs, loggers: loggers}
+			router := mux.NewRouter()
+			router.HandleFunc("/jobs", jobsController.Jobs).Methods("GET")
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			resp, err := http.Get(server.URL + "/jobs" + dataItem.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			payload := &JobsPayload{}
+			if err = json.NewDecoder(resp.Body).Decode(payload); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, dataItem.expected, payload)
+		})
+	}
+}
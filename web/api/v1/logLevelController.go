@@ -0,0 +1,69 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/go-kit/kit/log/level"
+)
+
+// LogLevel is the minimum level of the log entries of the master. One of debug, info, warn or error
+type LogLevel struct {
+	Level string `json:"level"`
+}
+
+type LogLevelController struct {
+	loggers chaoslogger.Loggers
+}
+
+// GetLogLevel godoc
+// @Summary Get the log level
+// @Description Get the minimum level of the log entries of the master
+// @Tags Status
+// @Produce json
+// @Success 200 {object} LogLevel
+// @Failure 500 {object} response.ErrorPayload
+// @Router /loglevel [get]
+func (l *LogLevelController) GetLogLevel(w http.ResponseWriter, r *http.Request) {
+	if l.loggers.Level == nil {
+		response.InternalServerError(w, r, "The log level can not be changed at runtime", l.loggers)
+		return
+	}
+
+	response.JSONResponse(w, r, http.StatusOK, &LogLevel{Level: l.loggers.Level.String()}, l.loggers)
+}
+
+// SetLogLevel godoc
+// @Summary Set the log level
+// @Description Change the minimum level of the log entries of the master without a restart
+// @Tags Status
+// @Accept json
+// @Produce json
+// @Param logLevel body LogLevel true "The new log level"
+// @Success 200 {object} LogLevel
+// @Failure 400 {object} response.ErrorPayload
+// @Failure 500 {object} response.ErrorPayload
+// @Router /loglevel [put]
+func (l *LogLevelController) SetLogLevel(w http.ResponseWriter, r *http.Request) {
+	if l.loggers.Level == nil {
+		response.InternalServerError(w, r, "The log level can not be changed at runtime", l.loggers)
+		return
+	}
+
+	logLevel := &LogLevel{}
+	if err := json.NewDecoder(r.Body).Decode(logLevel); err != nil {
+		response.BadRequest(w, r, "Could not decode request body", l.loggers)
+		return
+	}
+
+	if err := l.loggers.Level.Set(logLevel.Level); err != nil {
+		response.BadRequest(w, r, err.Error(), l.loggers)
+		return
+	}
+
+	_ = level.Warn(l.loggers.OutLogger).Log("msg", "log level changed", "level", logLevel.Level)
+
+	response.JSONResponse(w, r, http.StatusOK, logLevel, l.loggers)
+}
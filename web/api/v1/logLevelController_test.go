@@ -0,0 +1,65 @@
+package v1
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/go-kit/kit/log/level"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetLogLevel(t *testing.T) {
+	allowedLevel := &chaoslogger.AllowedLevel{}
+	if err := allowedLevel.Set("info"); err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	bufLoggers := chaoslogger.Loggers{
+		OutLogger: chaoslogger.New(allowedLevel, buf),
+		ErrLogger: chaoslogger.New(allowedLevel, buf),
+		Level:     allowedLevel,
+	}
+
+	apiRouter := NewAPIRouter(&config.Config{}, map[string]*config.Job{}, &network.Connections{}, gocache.New(0), async.NewTracker(), bufLoggers)
+	server := httptest.NewServer(apiRouter.AddRoutes(nil, mux.NewRouter()))
+	defer server.Close()
+
+	_ = level.Debug(bufLoggers.OutLogger).Log("msg", "suppressed before")
+	assert.NotContains(t, buf.String(), "suppressed before")
+
+	assert.Equal(t, http.StatusOK, putLogLevel(t, server.URL, `{"level": "debug"}`))
+	_ = level.Debug(bufLoggers.OutLogger).Log("msg", "emitted after debug")
+	assert.Contains(t, buf.String(), "emitted after debug")
+
+	assert.Equal(t, http.StatusOK, putLogLevel(t, server.URL, `{"level": "error"}`))
+	_ = level.Debug(bufLoggers.OutLogger).Log("msg", "suppressed after error")
+	_ = level.Info(bufLoggers.OutLogger).Log("msg", "suppressed info after error")
+	assert.NotContains(t, buf.String(), "suppressed after error")
+	assert.NotContains(t, buf.String(), "suppressed info after error")
+
+	assert.Equal(t, http.StatusBadRequest, putLogLevel(t, server.URL, `{"level": "verbose"}`))
+	assert.Equal(t, "error", allowedLevel.String(), "an unknown level should not change the log level")
+}
+
+func putLogLevel(t *testing.T, url string, body string) int {
+	req, err := http.NewRequest("PUT", url+"/chaos/api/v1/loglevel", bytes.NewReader([]byte(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode
+}
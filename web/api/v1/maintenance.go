@@ -0,0 +1,113 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/pkg/errors"
+)
+
+// maxJobBodySize is the size of the body of an injection that is read for its job while a maintenance window is open
+const maxJobBodySize = 1 << 20
+
+var errBodyTooLarge = errors.New(fmt.Sprintf("The request body is larger than {%d} bytes", maxJobBodySize))
+
+type maintenance struct {
+	windows []*config.MaintenanceWindow
+	aliases config.ActionAliases
+	now     func() time.Time
+	loggers chaoslogger.Loggers
+}
+
+func newMaintenance(windows []*config.MaintenanceWindow, aliases config.ActionAliases, loggers chaoslogger.Loggers) *maintenance {
+	return &maintenance{
+		windows: windows,
+		aliases: aliases,
+		now:     time.Now,
+		loggers: loggers,
+	}
+}
+
+// middleware rejects failure injections with 503 while a maintenance window is open, unless the job of the
+// request is allowed by every open window. Recover actions, and their aliases, are always allowed, so that
+// running failures can still be reverted
+func (m *maintenance) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.EqualFold(m.aliases.Resolve(r.URL.Query().Get("action")), "recover") {
+			if open := m.openWindows(); len(open) > 0 {
+				job, err := requestJob(w, r)
+				if err == errBodyTooLarge {
+					response.RequestEntityTooLarge(w, r, err.Error(), m.loggers)
+					return
+				}
+				if err != nil {
+					response.BadRequest(w, r, "Could not read request body", m.loggers)
+					return
+				}
+				if !allowJob(open, job) {
+					response.ServiceUnavailable(w, r, "Failure injections are disabled during the maintenance window", m.loggers)
+					return
+				}
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (m *maintenance) openWindows() []*config.MaintenanceWindow {
+	now := m.now()
+	open := make([]*config.MaintenanceWindow, 0)
+	for _, window := range m.windows {
+		if window.Contains(now) {
+			open = append(open, window)
+		}
+	}
+	return open
+}
+
+func allowJob(windows []*config.MaintenanceWindow, job string) bool {
+	if job == "" {
+		return false
+	}
+	for _, window := range windows {
+		if !window.Allows(job) {
+			return false
+		}
+	}
+	return true
+}
+
+// requestJob returns the job of the request body, which is restored for the handler.
+// It is empty if the body does not contain a job. The body is read up to maxJobBodySize,
+// and errBodyTooLarge is returned if it is larger
+func requestJob(w http.ResponseWriter, r *http.Request) (string, error) {
+	if r.Body == nil {
+		return "", nil
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxJobBodySize))
+	_ = r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		if len(body) >= maxJobBodySize {
+			return "", errBodyTooLarge
+		}
+		return "", err
+	}
+
+	payload := &struct {
+		Job string `json:"job"`
+	}{}
+	if err = json.Unmarshal(body, payload); err != nil {
+		return "", nil
+	}
+	return payload.Job, nil
+}
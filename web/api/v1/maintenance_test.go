@@ -0,0 +1,159 @@
+package v1
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	loggers = getLoggers()
+)
+
+func TestInjectionsDuringMaintenanceWindow(t *testing.T) {
+	now := time.Now()
+
+	dataItems := []struct {
+		message  string
+		windows  []*config.MaintenanceWindow
+		aliases  config.ActionAliases
+		action   string
+		expected int
+	}{
+		{
+			message:  "Should block injection when a maintenance window covers the current time",
+			windows:  []*config.MaintenanceWindow{{Start: now.Add(-time.Hour), End: now.Add(time.Hour)}},
+			action:   "start",
+			expected: http.StatusServiceUnavailable,
+		},
+		{
+			message:  "Should allow recovery when a maintenance window covers the current time",
+			windows:  []*config.MaintenanceWindow{{Start: now.Add(-time.Hour), End: now.Add(time.Hour)}},
+			action:   "recover",
+			expected: http.StatusOK,
+		},
+		{
+			message:  "Should allow recovery with an alias of recover when a maintenance window covers the current time",
+			windows:  []*config.MaintenanceWindow{{Start: now.Add(-time.Hour), End: now.Add(time.Hour)}},
+			aliases:  config.ActionAliases{"disable": "recover"},
+			action:   "disable",
+			expected: http.StatusOK,
+		},
+		{
+			message:  "Should block injection with an alias of start when a maintenance window covers the current time",
+			windows:  []*config.MaintenanceWindow{{Start: now.Add(-time.Hour), End: now.Add(time.Hour)}},
+			aliases:  config.ActionAliases{"enable": "start"},
+			action:   "enable",
+			expected: http.StatusServiceUnavailable,
+		},
+		{
+			message:  "Should allow injection when no maintenance window covers the current time",
+			windows:  []*config.MaintenanceWindow{{Start: now.Add(time.Hour), End: now.Add(2 * time.Hour)}},
+			action:   "start",
+			expected: http.StatusOK,
+		},
+		{
+			message:  "Should allow injection of an allowed job when a maintenance window covers the current time",
+			windows:  []*config.MaintenanceWindow{{Start: now.Add(-time.Hour), End: now.Add(time.Hour), AllowedJobs: []string{"cpu job"}}},
+			action:   "start",
+			expected: http.StatusOK,
+		},
+		{
+			message:  "Should block injection of a job that is not allowed when a maintenance window covers the current time",
+			windows:  []*config.MaintenanceWindow{{Start: now.Add(-time.Hour), End: now.Add(time.Hour), AllowedJobs: []string{"other job"}}},
+			action:   "start",
+			expected: http.StatusServiceUnavailable,
+		},
+		{
+			message: "Should block injection of a job that is not allowed by every maintenance window that covers the current time",
+			windows: []*config.MaintenanceWindow{
+				{Start: now.Add(-time.Hour), End: now.Add(time.Hour), AllowedJobs: []string{"cpu job"}},
+				{Start: now.Add(-time.Hour), End: now.Add(time.Hour)},
+			},
+			action:   "start",
+			expected: http.StatusServiceUnavailable,
+		},
+		{
+			message:  "Should allow injection when there are no maintenance windows",
+			action:   "start",
+			expected: http.StatusOK,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			server := apiHTTPTestServer(&config.Config{MaintenanceWindows: dataItem.windows, ActionAliases: dataItem.aliases})
+			defer server.Close()
+
+			body := []byte(`{"job": "cpu job", "percentage": 50, "target": "127.0.0.1"}`)
+			resp, err := http.Post(server.URL+"/chaos/api/v1/cpu?action="+dataItem.action, "", bytes.NewReader(body)) //nolint:gosec
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, dataItem.expected, resp.StatusCode)
+		})
+	}
+}
+
+func TestInjectionWithATooLargeBodyDuringMaintenanceWindow(t *testing.T) {
+	now := time.Now()
+	windows := []*config.MaintenanceWindow{{Start: now.Add(-time.Hour), End: now.Add(time.Hour), AllowedJobs: []string{"cpu job"}}}
+	server := apiHTTPTestServer(&config.Config{MaintenanceWindows: windows})
+	defer server.Close()
+
+	body := []byte(`{"job": "cpu job", "percentage": 50, "target": "127.0.0.1", "padding": "` + strings.Repeat("x", maxJobBodySize) + `"}`)
+	resp, err := http.Post(server.URL+"/chaos/api/v1/cpu?action=start", "", bytes.NewReader(body)) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+	assert.Equal(t, "{\"error\":\"The request body is larger than {1048576} bytes\",\"status\":413}\n", string(respBody))
+}
+
+func apiHTTPTestServer(conf *config.Config) *httptest.Server {
+	jobMap := map[string]*config.Job{
+		"cpu job": {FailureType: config.CPU, Target: []string{"127.0.0.1"}},
+	}
+	connections := &network.Connections{
+		Pool: map[string]network.Connection{
+			"127.0.0.1": &network.MockConnection{Status: &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}},
+		},
+	}
+
+	apiRouter := NewAPIRouter(conf, jobMap, connections, gocache.New(0), async.NewTracker(), loggers)
+	router := apiRouter.AddRoutes(nil, mux.NewRouter())
+
+	return httptest.NewServer(router)
+}
+
+func getLoggers() chaoslogger.Loggers {
+	allowLevel := &chaoslogger.AllowedLevel{}
+	if err := allowLevel.Set("debug"); err != nil {
+		fmt.Printf("%v", err)
+	}
+
+	return chaoslogger.Loggers{
+		OutLogger: chaoslogger.New(allowLevel, os.Stdout),
+		ErrLogger: chaoslogger.New(allowLevel, os.Stderr),
+	}
+}
@@ -0,0 +1,51 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/metrics"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/go-kit/kit/log/level"
+)
+
+type MetricsController struct {
+	inFlight *metrics.InFlight
+	outcomes *metrics.Outcomes
+	cache    *gocache.Cache
+	loggers  chaoslogger.Loggers
+}
+
+// InFlight godoc
+// @Summary Get the in-flight injections
+// @Description Get the number of injection requests of every failure type that have not completed yet
+// @Tags Status
+// @Produce json
+// @Success 200 {object} map[string]int64
+// @Router /injections/inflight [get]
+func (m *MetricsController) InFlight(w http.ResponseWriter, r *http.Request) {
+	response.JSONResponse(w, r, http.StatusOK, m.inFlight.Snapshot(), m.loggers)
+}
+
+// Metrics godoc
+// @Summary Get the metrics of the master
+// @Description Get the metrics of the master in the prometheus text format. The failures that are not recovered yet are counted when the metrics are requested
+// @Tags Status
+// @Produce plain
+// @Success 200 {string} string
+// @Router /metrics [get]
+func (m *MetricsController) Metrics(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	err := m.inFlight.Write(w)
+	if err == nil {
+		err = m.outcomes.Write(w)
+	}
+	if err == nil && m.cache != nil {
+		// GetAll reads the items under the lock of the cache, while ItemCount does not
+		err = metrics.WriteOutstandingFailures(w, len(m.cache.GetAll()))
+	}
+	if err != nil {
+		_ = level.Error(m.loggers.ErrLogger).Log("msg", "Could not write metrics", "err", err)
+	}
+}
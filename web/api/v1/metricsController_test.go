@@ -0,0 +1,65 @@
+package v1
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/stretchr/testify/assert"
+)
+
+// Run with -race to also detect unsynchronized access to the in-flight counters
+func TestInFlightInjectionsReturnToZeroAfterConcurrentInjections(t *testing.T) {
+	server := apiHTTPTestServer(&config.Config{})
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			postJSON(t, server.URL+"/chaos/api/v1/cpu?action=start", `{"job": "cpu job", "percentage": 50, "target": "127.0.0.1"}`)
+		}()
+	}
+	wg.Wait()
+
+	resp, err := http.Get(server.URL + "/chaos/api/v1/injections/inflight")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	inFlight := make(map[config.FailureType]int64)
+	if err = json.NewDecoder(resp.Body).Decode(&inFlight); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, int64(0), inFlight[config.CPU])
+	assert.Equal(t, 5, len(inFlight), "every failure type should be reported")
+}
+
+func TestMetricsCountTheOutcomesOfTheInjections(t *testing.T) {
+	server := apiHTTPTestServer(&config.Config{})
+	defer server.Close()
+
+	postJSON(t, server.URL+"/chaos/api/v1/cpu?action=start", `{"job": "cpu job", "percentage": 50, "target": "127.0.0.1"}`)
+
+	resp, err := http.Get(server.URL + "/chaos/api/v1/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "chaos_master_injections_total{failure_type=\"CPU\",action=\"start\",target=\"127.0.0.1\",result=\"success\"} 1\n")
+	assert.Contains(t, string(body), "chaos_master_action_duration_seconds_count{failure_type=\"CPU\",action=\"start\"} 1\n")
+	assert.Contains(t, string(body), "chaos_master_outstanding_failures 1\n")
+}
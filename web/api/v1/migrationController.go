@@ -0,0 +1,175 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/cpu"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/docker"
+	apiNetwork "github.com/SotirisAlfonsos/chaos-master/web/api/v1/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/service"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// ExportedFailure is a running failure with the details needed to recreate its recovery on another master
+type ExportedFailure struct {
+	Job      string             `json:"job"`
+	Target   string             `json:"target"`
+	Type     config.FailureType `json:"type"`
+	StartsAt *time.Time         `json:"startsAt,omitempty"`
+	Labels   map[string]string  `json:"labels,omitempty"`
+	Metadata map[string]string  `json:"metadata,omitempty"`
+}
+
+// ImportResult is the number of running failures that were imported in the cache
+type ImportResult struct {
+	Imported int `json:"imported"`
+}
+
+type MigrationController struct {
+	jobs        map[string]*config.Job
+	connections *network.Connections
+	cache       *gocache.Cache
+	persistence *cache.Persistence
+	locks       *cache.KeyLocks
+	injections  *cache.Injections
+	loggers     chaoslogger.Loggers
+}
+
+// Export godoc
+// @Summary Export running failures
+// @Description Get the running failures of the cache with their type and metadata, to be imported on another master
+// @Tags Status
+// @Produce json
+// @Success 200 {array} ExportedFailure
+// @Router /cache/export [get]
+func (m *MigrationController) Export(w http.ResponseWriter, r *http.Request) {
+	items := m.cache.GetAll()
+	failures := make([]*ExportedFailure, 0, len(items))
+	for _, item := range items {
+		key := item.Key.(cache.Key)
+		failure := &ExportedFailure{Job: key.Job, Target: key.Target}
+		if job, ok := m.jobs[key.Job]; ok {
+			failure.Type = job.FailureType
+		}
+		if injection, ok := m.injections.Get(key); ok {
+			startsAt := injection.StartsAt
+			failure.StartsAt = &startsAt
+			failure.Labels = injection.Labels
+			failure.Metadata = injection.Metadata
+		}
+		failures = append(failures, failure)
+	}
+
+	sort.Slice(failures, func(i, j int) bool {
+		if failures[i].Job != failures[j].Job {
+			return failures[i].Job < failures[j].Job
+		}
+		return failures[i].Target < failures[j].Target
+	})
+
+	response.JSONResponse(w, r, http.StatusOK, failures, m.loggers)
+}
+
+// Import godoc
+// @Summary Import running failures
+// @Description Add running failures exported by another master to the cache, recreating their recovery from the jobs config.
+// @Description Nothing is imported if any of the failures does not match the config
+// @Tags Recover
+// @Accept json
+// @Produce json
+// @Param failures body []ExportedFailure true "The exported failures"
+// @Success 200 {object} ImportResult
+// @Failure 400 {object} response.Payload
+// @Router /cache/import [post]
+func (m *MigrationController) Import(w http.ResponseWriter, r *http.Request) {
+	failures := make([]*ExportedFailure, 0)
+	if err := json.NewDecoder(r.Body).Decode(&failures); err != nil {
+		response.BadRequest(w, r, "Could not decode request body", m.loggers)
+		return
+	}
+
+	recoveries := make([]func() (*v1.StatusResponse, error), 0, len(failures))
+	for _, failure := range failures {
+		if err := network.ValidateLabels(failure.Labels); err != nil {
+			response.BadRequest(w, r, err.Error(), m.loggers)
+			return
+		}
+		recovery, err := m.recovery(failure)
+		if err != nil {
+			response.BadRequest(w, r, err.Error(), m.loggers)
+			return
+		}
+		recoveries = append(recoveries, recovery)
+	}
+
+	for i, failure := range failures {
+		key := cache.Key{Job: failure.Job, Target: failure.Target}
+		injection := &cache.Injection{Labels: failure.Labels, Metadata: failure.Metadata}
+		if failure.StartsAt != nil {
+			injection.StartsAt = *failure.StartsAt
+		}
+
+		unlock := m.locks.Lock(key)
+		m.cache.Set(key, recoveries[i])
+		m.injections.Record(key, injection)
+		unlock()
+	}
+
+	if err := m.persistence.Save(m.cache); err != nil {
+		_ = level.Error(m.loggers.ErrLogger).Log("msg", "Could not persist the imported failures", "err", err)
+	}
+
+	response.JSONResponse(w, r, http.StatusOK, &ImportResult{Imported: len(failures)}, m.loggers)
+}
+
+// recovery recreates the recovery function of the failure, if the job, type and target of the failure match the config
+func (m *MigrationController) recovery(failure *ExportedFailure) (func() (*v1.StatusResponse, error), error) {
+	job, ok := m.jobs[failure.Job]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Could not find job {%s}", failure.Job))
+	}
+	if failure.Type != "" && failure.Type != job.FailureType {
+		return nil, errors.New(fmt.Sprintf("The type {%s} does not match the type {%s} of job {%s}", failure.Type, job.FailureType, failure.Job))
+	}
+	if !targetOfJob(job, failure.Target) {
+		return nil, errors.New(fmt.Sprintf("Target {%s} is not registered for job {%s}", failure.Target, failure.Job))
+	}
+	connection, ok := m.connections.Pool[job.Address(failure.Target)]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("Could not find connection to target {%s}", failure.Target))
+	}
+
+	switch job.FailureType {
+	case config.Docker:
+		return docker.Recovery(connection, &docker.RequestPayload{Job: failure.Job, Container: job.ComponentName, Target: failure.Target, Labels: failure.Labels}), nil
+	case config.Service:
+		return service.Recovery(connection, &service.RequestPayload{Job: failure.Job, ServiceName: job.ComponentName, Target: failure.Target, Labels: failure.Labels}), nil
+	case config.CPU:
+		return cpu.Recovery(connection, &cpu.RequestPayload{Job: failure.Job, Target: failure.Target, Labels: failure.Labels}), nil
+	case config.Network:
+		return apiNetwork.Recovery(connection, &apiNetwork.RequestPayload{Job: failure.Job, Device: failure.Metadata["device"], Target: failure.Target, Labels: failure.Labels}), nil
+	default:
+		return nil, errors.New(fmt.Sprintf("Failures of job {%s} with type {%s} can not be recovered", failure.Job, job.FailureType))
+	}
+}
+
+func targetOfJob(job *config.Job, target string) bool {
+	for _, t := range job.Target {
+		if t == target {
+			return true
+		}
+	}
+	return false
+}
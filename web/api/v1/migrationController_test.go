@@ -0,0 +1,120 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportedFailuresCanBeRecoveredAfterImport(t *testing.T) {
+	connection := &statefulConnection{}
+	jobMap := map[string]*config.Job{
+		"service job": {FailureType: config.Service, ComponentName: "nginx", Target: []string{"127.0.0.1"}},
+	}
+	connections := &network.Connections{Pool: map[string]network.Connection{"127.0.0.1": connection}}
+
+	oldCache := gocache.New(0)
+	oldServer := httptest.NewServer(NewAPIRouter(&config.Config{}, jobMap, connections, oldCache, async.NewTracker(), loggers).AddRoutes(nil, mux.NewRouter()))
+	defer oldServer.Close()
+
+	postJSON(t, oldServer.URL+"/chaos/api/v1/service?action=kill", `{"job": "service job", "serviceName": "nginx", "target": "127.0.0.1", "labels": {"team": "search"}}`)
+	assert.True(t, connection.isKilled())
+
+	resp, err := http.Get(oldServer.URL + "/chaos/api/v1/cache/export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	failures := make([]*ExportedFailure, 0)
+	if err = json.NewDecoder(resp.Body).Decode(&failures); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	if assert.Equal(t, 1, len(failures)) {
+		assert.Equal(t, config.Service, failures[0].Type)
+		assert.Equal(t, map[string]string{"team": "search"}, failures[0].Labels)
+		assert.NotNil(t, failures[0].StartsAt)
+	}
+
+	newCache := gocache.New(0)
+	newServer := httptest.NewServer(NewAPIRouter(&config.Config{}, jobMap, connections, newCache, async.NewTracker(), loggers).AddRoutes(nil, mux.NewRouter()))
+	defer newServer.Close()
+
+	body, err := json.Marshal(failures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	importResp, err := http.Post(newServer.URL+"/chaos/api/v1/cache/import", "application/json", bytes.NewReader(body)) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	importResp.Body.Close()
+	assert.Equal(t, http.StatusOK, importResp.StatusCode)
+	assert.Equal(t, 1, newCache.ItemCount())
+
+	postJSON(t, newServer.URL+"/chaos/api/v1/recover", `{"recoverAll": true}`)
+
+	assert.False(t, connection.isKilled(), "the imported failure should be recovered")
+	assert.Equal(t, 0, newCache.ItemCount())
+}
+
+func TestImportRejectsFailuresThatDoNotMatchTheConfig(t *testing.T) {
+	jobMap := map[string]*config.Job{
+		"service job": {FailureType: config.Service, ComponentName: "nginx", Target: []string{"127.0.0.1"}},
+		"server job":  {FailureType: config.Server, Target: []string{"127.0.0.1"}},
+	}
+	connections := &network.Connections{Pool: map[string]network.Connection{"127.0.0.1": &statefulConnection{}}}
+
+	dataItems := []struct {
+		message string
+		body    string
+	}{
+		{
+			message: "Should reject a failure of an unknown job",
+			body:    `[{"job": "unknown job", "target": "127.0.0.1"}]`,
+		},
+		{
+			message: "Should reject a failure with a type different from the type of the job",
+			body:    `[{"job": "service job", "target": "127.0.0.1", "type": "Docker"}]`,
+		},
+		{
+			message: "Should reject a failure on a target that is not registered for the job",
+			body:    `[{"job": "service job", "target": "127.0.0.2"}]`,
+		},
+		{
+			message: "Should reject a failure that can not be recovered",
+			body:    `[{"job": "server job", "target": "127.0.0.1"}]`,
+		},
+		{
+			message: "Should import nothing if any of the failures is rejected",
+			body:    `[{"job": "service job", "target": "127.0.0.1"}, {"job": "unknown job", "target": "127.0.0.1"}]`,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			server := httptest.NewServer(NewAPIRouter(&config.Config{}, jobMap, connections, c, async.NewTracker(), loggers).AddRoutes(nil, mux.NewRouter()))
+			defer server.Close()
+
+			resp, err := http.Post(server.URL+"/chaos/api/v1/cache/import", "application/json", bytes.NewReader([]byte(dataItem.body))) //nolint:gosec
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			assert.Equal(t, 0, c.ItemCount())
+		})
+	}
+}
@@ -5,14 +5,24 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/SotirisAlfonsos/gocache"
 
 	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
 	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/healthcheck"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/grafana"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/limiter"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/metrics"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/retry"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/target"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/auth"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/controller"
 	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
 	"github.com/go-kit/kit/log/level"
 	"github.com/pkg/errors"
@@ -22,6 +32,19 @@ type NController struct {
 	jobs           map[string]*config.Job
 	connectionPool map[string]*nConnection
 	cache          *gocache.Cache
+	persistence    *cache.Persistence
+	locks          *cache.KeyLocks
+	injections     *cache.Injections
+	inFlight       *metrics.InFlight
+	outcomes       *metrics.Outcomes
+	backoff        *network.Backoff
+	retry          *retry.Policy
+	limiter        *limiter.Limiter
+	aliases        config.ActionAliases
+	guard          *healthcheck.Guard
+	annotator      *grafana.Annotator
+	limits         *config.NetworkLimits
+	messages       *response.MessageTemplate
 	loggers        chaoslogger.Loggers
 }
 
@@ -29,14 +52,9 @@ type nConnection struct {
 	connection network.Connection
 }
 
-func NewNetworkController(
-	jobs map[string]*config.Job,
-	connections *network.Connections,
-	cache *gocache.Cache,
-	loggers chaoslogger.Loggers,
-) *NController {
+func NewNetworkController(jobs map[string]*config.Job, options *controller.Options) *NController {
 	connPool := make(map[string]*nConnection)
-	for target, connection := range connections.Pool {
+	for target, connection := range options.Connections.Pool {
 		connPool[target] = &nConnection{
 			connection: connection,
 		}
@@ -44,8 +62,21 @@ func NewNetworkController(
 	return &NController{
 		jobs:           jobs,
 		connectionPool: connPool,
-		cache:          cache,
-		loggers:        loggers,
+		cache:          options.Cache,
+		persistence:    options.Persistence,
+		locks:          options.Locks,
+		injections:     options.Injections,
+		inFlight:       options.InFlight,
+		outcomes:       options.Outcomes,
+		backoff:        options.Connections.Backoff,
+		retry:          options.Connections.Retry,
+		limiter:        options.Connections.Limiter,
+		aliases:        options.Aliases,
+		guard:          options.Guard,
+		annotator:      options.Annotator,
+		limits:         options.NetworkLimits,
+		messages:       options.Messages,
+		loggers:        options.Loggers,
 	}
 }
 
@@ -61,8 +92,17 @@ func (a action) String() string {
 	return [...]string{"recover", "start"}[a]
 }
 
-func toActionEnum(value string) (action, error) {
-	switch value {
+// Actions returns the names of all the actions supported for network injections
+func Actions() []string {
+	actions := make([]string, 0, int(notImplemented))
+	for a := action(0); a < notImplemented; a++ {
+		actions = append(actions, a.String())
+	}
+	return actions
+}
+
+func toActionEnum(value string, aliases config.ActionAliases) (action, error) {
+	switch strings.ToLower(aliases.Resolve(value)) {
 	case start.String():
 		return start, nil
 	case recoverFailure.String():
@@ -72,22 +112,27 @@ func toActionEnum(value string) (action, error) {
 }
 
 type RequestPayload struct {
-	Job           string  `json:"job"`
-	Device        string  `json:"device"`
-	Target        string  `json:"target"`
-	Latency       uint32  `json:"latency"`
-	DelayCorr     float32 `json:"delay correlation"`
-	Limit         uint32  `json:"limit"`
-	Loss          float32 `json:"loss"`
-	LossCorr      float32 `json:"loss correlation"`
-	Gap           uint32  `json:"gap"`
-	Duplicate     float32 `json:"duplicate"`
-	DuplicateCorr float32 `json:"duplicate correlation"`
-	Jitter        uint32  `json:"jitter"`
-	ReorderProb   float32 `json:"reorder probability"`
-	ReorderCorr   float32 `json:"reorder correlation"`
-	CorruptProb   float32 `json:"corrupt probability"`
-	CorruptCorr   float32 `json:"corrupt correlation"`
+	Job           string            `json:"job"`
+	Device        string            `json:"device"`
+	Target        string            `json:"target"`
+	Latency       uint32            `json:"latency"`
+	DelayCorr     float32           `json:"delay correlation"`
+	Limit         uint32            `json:"limit"`
+	Loss          float32           `json:"loss"`
+	LossCorr      float32           `json:"loss correlation"`
+	Gap           uint32            `json:"gap"`
+	Duplicate     float32           `json:"duplicate"`
+	DuplicateCorr float32           `json:"duplicate correlation"`
+	Jitter        uint32            `json:"jitter"`
+	ReorderProb   float32           `json:"reorder probability"`
+	ReorderCorr   float32           `json:"reorder correlation"`
+	CorruptProb   float32           `json:"corrupt probability"`
+	CorruptCorr   float32           `json:"corrupt correlation"`
+	Labels        map[string]string `json:"labels,omitempty"`
+	DurationSec   int               `json:"durationSec,omitempty"`
+
+	// pulse is set for the injection of a pulse, which recovers the injection itself
+	pulse bool
 }
 
 func newNetworkRequest(details *RequestPayload) *v1.NetworkRequest {
@@ -109,53 +154,149 @@ func newNetworkRequest(details *RequestPayload) *v1.NetworkRequest {
 	}
 }
 
+// resolve returns the request with the defaults of its job applied, as it is performed
+func resolve(job *config.Job, request *RequestPayload) *RequestPayload {
+	resolved := *request
+	resolved.DurationSec = int(job.RecoverAfter(request.DurationSec) / time.Second)
+	return &resolved
+}
+
 // NetworkAction godoc
 // @Summary Inject network failures
-// @Description Start and stop network failures
+// @Description Start and stop network failures. If random is specified you do not have to provide a target
 // @Tags Failure injections
 // @Accept json
 // @Produce json
+// @Param do query string false "Specify to perform the action on a random target of the job, or on all its targets" Enums(random, all)
 // @Param action query string true "Specify to perform a start or recover for a network failure injection" Enums(start, recover)
+// @Param confirmProduction query bool false "Confirm the injection on a target labeled env: prod"
 // @Param requestPayload body RequestPayload true "Specify the job name, device name, target and netem injection arguments"
 // @Success 200 {object} response.Payload
-// @Failure 400 {string} http.Error
-// @Failure 500 {string} http.Error
+// @Success 200 {object} response.BulkPayload "With do=all"
+// @Failure 400 {object} response.ErrorPayload "Also if the limit, gap or jitter is greater than its configured maximum"
+// @Failure 500 {object} response.ErrorPayload
 // @Router /network [post]
 func (n *NController) NetworkAction(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithCancel(context.Background())
+	if r.FormValue("do") == all {
+		n.allNetwork(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
 	requestPayload := &RequestPayload{}
 	err := json.NewDecoder(r.Body).Decode(&requestPayload)
 	if err != nil {
-		response.BadRequest(w, "Could not decode request body", n.loggers)
+		response.BadRequest(w, r, "Could not decode request body", n.loggers)
+		return
+	}
+
+	if err = network.ValidateLabels(requestPayload.Labels); err != nil {
+		response.BadRequest(w, r, err.Error(), n.loggers)
 		return
 	}
 
-	action, err := toActionEnum(r.FormValue("action"))
+	action, err := toActionEnum(r.FormValue("action"), n.aliases)
 	if err != nil {
-		response.BadRequest(w, err.Error(), n.loggers)
+		response.BadRequest(w, r, err.Error(), n.loggers)
 		return
 	}
 
-	err = checkIfTargetExists(n.jobs, requestPayload)
+	err = setTarget(n.jobs, r.FormValue("do"), requestPayload)
 	if err != nil {
-		response.BadRequest(w, err.Error(), n.loggers)
+		response.BadRequest(w, r, err.Error(), n.loggers)
+		return
+	}
+	if r.FormValue("do") == "random" {
+		_ = level.Info(n.loggers.OutLogger).Log("msg", "selected random target", "job", requestPayload.Job, "target", requestPayload.Target)
+	}
+
+	if action == start {
+		if err = checkLimits(n.limits, requestPayload); err != nil {
+			response.BadRequest(w, r, err.Error(), n.loggers)
+			return
+		}
+	}
+
+	if !n.jobs[requestPayload.Job].Allows(action.String()) {
+		response.Forbidden(w, r, fmt.Sprintf("The action {%s} is not allowed for job {%s}", action, requestPayload.Job), n.loggers)
+		return
+	}
+
+	if action == start && n.jobs[requestPayload.Job].RequiresConfirmation(requestPayload.Target) && r.FormValue("confirmProduction") != "true" {
+		response.Forbidden(w, r, fmt.Sprintf("The target {%s} is a production target. Set the query parameter confirmProduction=true to inject", requestPayload.Target), n.loggers)
 		return
 	}
 
+	if action == start && r.FormValue("do") == "random" {
+		if err = n.guard.Check(requestPayload.Job, n.jobs[requestPayload.Job], []string{requestPayload.Target}); err != nil {
+			response.TooManyRequests(w, r, err.Error(), n.loggers)
+			return
+		}
+	}
+
 	_ = level.Info(n.loggers.OutLogger).Log("msg",
 		fmt.Sprintf("%s network injection for device {%s} on target {%s}", action, requestPayload.Device, requestPayload.Target))
 
 	message, err := n.performAction(ctx, action, requestPayload)
 	if err != nil {
-		response.InternalServerError(w, err.Error(), n.loggers)
+		response.BotError(w, r, err, n.loggers)
 		return
 	}
 
 	_ = level.Info(n.loggers.OutLogger).Log("msg", message)
 
-	response.OkResponse(w, message, n.loggers)
+	response.OkResponseWithRequest(w, r, message, resolve(n.jobs[requestPayload.Job], requestPayload), n.loggers)
+}
+
+// checkLimits returns an error if a netem field of the request is greater than its configured maximum
+func checkLimits(limits *config.NetworkLimits, requestPayload *RequestPayload) error {
+	if limits == nil {
+		return nil
+	}
+
+	fields := []struct {
+		name    string
+		value   uint32
+		maximum uint32
+	}{
+		{name: "limit", value: requestPayload.Limit, maximum: limits.MaxLimit},
+		{name: "gap", value: requestPayload.Gap, maximum: limits.MaxGap},
+		{name: "jitter", value: requestPayload.Jitter, maximum: limits.MaxJitter},
+	}
+	for _, field := range fields {
+		if field.maximum > 0 && field.value > field.maximum {
+			return errors.New(fmt.Sprintf("The %s {%d} is greater than the maximum {%d}", field.name, field.value, field.maximum))
+		}
+	}
+	return nil
+}
+
+// setTarget checks that the target of the request is registered for the job, or sets a random target of the job with do=random
+func setTarget(jobMap map[string]*config.Job, do string, requestPayload *RequestPayload) error {
+	switch do {
+	case "":
+		return checkIfTargetExists(jobMap, requestPayload)
+	case "random":
+		return setRandomTargetIfExists(jobMap, requestPayload)
+	}
+	return errors.New(fmt.Sprintf("Do query parameter {%s} not allowed", do))
+}
+
+func setRandomTargetIfExists(jobMap map[string]*config.Job, requestPayload *RequestPayload) error {
+	job, ok := jobMap[requestPayload.Job]
+	if !ok {
+		return errors.New(fmt.Sprintf("Could not find job {%s}", requestPayload.Job))
+	}
+
+	randomTarget, err := target.Random(job)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Could not get random target for job {%s}. err: %s", requestPayload.Job, err.Error()))
+	}
+
+	requestPayload.Target = randomTarget
+	return nil
 }
 
 func checkIfTargetExists(jobMap map[string]*config.Job, requestPayload *RequestPayload) error {
@@ -187,37 +328,63 @@ func (n *NController) performAction(
 	action action,
 	request *RequestPayload,
 ) (string, error) {
+	address := n.jobs[request.Job].Address(request.Target)
+	if action == start {
+		if err := n.backoff.Check(address); err != nil {
+			return "", err
+		}
+		defer n.inFlight.Track(config.Network)()
+	}
+
+	unlock := n.locks.Lock(cache.Key{Job: request.Job, Target: request.Target})
+	defer unlock()
+	defer n.limiter.Acquire(address)()
+
+	ctx = network.WithLabels(ctx, request.Labels)
 	var statusResponse *v1.StatusResponse
 	var err error
-	connection := n.connectionPool[request.Target].connection
+	connection := n.connectionPool[address].connection
 
 	networkClient, err := connection.GetNetworkClient()
 	if err != nil {
 		return "", errors.Wrap(err, fmt.Sprintf("Can not get network connection from target {%s}", request.Target))
 	}
 
-	switch action {
-	case start:
-		statusResponse, err = networkClient.Start(ctx, newNetworkRequest(request))
-	case recoverFailure:
-		statusResponse, err = networkClient.Recover(ctx, newNetworkRequest(request))
+	callStart := time.Now()
+	err = n.retry.Do(ctx, request.Target, func(ctx context.Context) error {
+		switch action {
+		case start:
+			statusResponse, err = networkClient.Start(ctx, newNetworkRequest(request))
+		case recoverFailure:
+			statusResponse, err = networkClient.Recover(ctx, newNetworkRequest(request))
+		}
+		return err
+	})
+
+	if action == start {
+		n.backoff.Record(address, err)
 	}
 
+	n.outcomes.Observe(config.Network, action.String(), request.Target, metrics.ResultOf(statusResponse, err), time.Since(callStart))
+
 	switch {
 	case err != nil:
 		return "", errors.Wrap(err, fmt.Sprintf("Error response from target {%s}", request.Target))
 	case statusResponse.Status != v1.StatusResponse_SUCCESS:
 		return "", errors.New(fmt.Sprintf("Failure response from target {%s}", request.Target))
 	default:
-		if err = n.updateCache(connection, request, action); err != nil {
+		if err = n.updateCache(ctx, connection, request, action); err != nil {
 			_ = level.Error(n.loggers.ErrLogger).Log("msg", fmt.Sprintf("Could not update cache for operation %s", action), "err", err)
+			if action == start && n.persistence.FailClosed() {
+				return "", n.rollback(request, err)
+			}
 		}
 	}
 
-	return fmt.Sprintf("Response from target {%s}, {%s}, {%s}", request.Target, statusResponse.Message, statusResponse.Status), nil
+	return n.messages.Render(request.Target, statusResponse.Status, statusResponse.Message), nil
 }
 
-func (n *NController) updateCache(connection network.Connection, request *RequestPayload, action action) error {
+func (n *NController) updateCache(ctx context.Context, connection network.Connection, request *RequestPayload, action action) error {
 	key := cache.Key{
 		Job:    request.Job,
 		Target: request.Target,
@@ -225,19 +392,76 @@ func (n *NController) updateCache(connection network.Connection, request *Reques
 
 	switch action {
 	case start:
-		recoveryFunc := func() (*v1.StatusResponse, error) {
-			networkClient, err := connection.GetNetworkClient()
-			if err != nil {
-				return nil, errors.New(fmt.Sprintf("Could not recover network failure for job {%s} and target {%s}", request.Job, request.Target))
-			}
-			return networkClient.Recover(context.Background(), &v1.NetworkRequest{Device: request.Device})
+		// the injection is recorded, scheduled and annotated only if it is not rolled back
+		n.cache.Set(key, Recovery(connection, request))
+		err := n.persistence.Save(n.cache)
+		if err != nil && n.persistence.FailClosed() {
+			return err
 		}
-		n.cache.Set(key, recoveryFunc)
-		return nil
+		injection := &cache.Injection{StartsAt: time.Now(), Labels: request.Labels, Metadata: auth.WithPrincipalMetadata(ctx, map[string]string{"device": request.Device}), Inject: Injection(connection, request)}
+		n.injections.Record(key, injection)
+		n.scheduleRecovery(key, injection, request)
+		n.annotator.Start(request.Job, request.Target)
+		return err
 	case recoverFailure:
 		n.cache.Delete(key)
-		return nil
+		n.annotator.End(request.Job, request.Target)
+		return n.persistence.Save(n.cache)
 	default:
 		return errors.New(fmt.Sprintf("Action %s not supported for cache operation", action))
 	}
 }
+
+// scheduleRecovery recovers the failure automatically after the duration of the request, or the default duration of the job.
+// Nothing is scheduled for the injection of a pulse, since the pulse recovers it after the same duration
+func (n *NController) scheduleRecovery(key cache.Key, injection *cache.Injection, request *RequestPayload) {
+	if request.pulse {
+		return
+	}
+
+	n.injections.AutoRecover(key, injection, n.jobs[request.Job].RecoverAfter(request.DurationSec), func() {
+		if _, ok := n.cache.Get(key); !ok {
+			return
+		}
+		_ = level.Info(n.loggers.OutLogger).Log("msg", fmt.Sprintf("automatic recovery of job item {%s} on target {%s}", key.Job, key.Target))
+		if _, err := n.performAction(context.Background(), recoverFailure, request); err != nil {
+			_ = level.Error(n.loggers.ErrLogger).Log("msg", fmt.Sprintf("automatic recovery of job item {%s} on target {%s} failed", key.Job, key.Target), "err", err)
+		}
+	})
+}
+
+// Injection returns the function that injects the failure of the request on the target of the connection again
+func Injection(connection network.Connection, request *RequestPayload) func() (*v1.StatusResponse, error) {
+	return func() (*v1.StatusResponse, error) {
+		networkClient, err := connection.GetNetworkClient()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Could not inject network failure for job {%s} and target {%s}", request.Job, request.Target))
+		}
+		return networkClient.Start(network.WithLabels(context.Background(), request.Labels), newNetworkRequest(request))
+	}
+}
+
+// Recovery returns the function that recovers the failure of the request on the target of the connection
+func Recovery(connection network.Connection, request *RequestPayload) func() (*v1.StatusResponse, error) {
+	return func() (*v1.StatusResponse, error) {
+		networkClient, err := connection.GetNetworkClient()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Could not recover network failure for job {%s} and target {%s}", request.Job, request.Target))
+		}
+		return networkClient.Recover(network.WithLabels(context.Background(), request.Labels), &v1.NetworkRequest{Device: request.Device})
+	}
+}
+
+// rollback recovers an injection whose recovery could not be persisted, so that no unrecoverable failure is left behind
+func (n *NController) rollback(request *RequestPayload, cause error) error {
+	key := cache.Key{Job: request.Job, Target: request.Target}
+	if err := cache.Rollback(n.cache, key); err != nil {
+		_ = level.Error(n.loggers.ErrLogger).Log("msg", fmt.Sprintf("Could not roll back injection on target {%s}", request.Target), "err", err)
+		return errors.Wrap(cause, fmt.Sprintf("Could not persist injection on target {%s} and the rollback failed", request.Target))
+	}
+	if err := n.persistence.Save(n.cache); err != nil {
+		_ = level.Error(n.loggers.ErrLogger).Log("msg", "Could not update cache after rollback", "err", err)
+	}
+
+	return errors.Wrap(cause, fmt.Sprintf("Could not persist injection on target {%s}, the injection was rolled back", request.Target))
+}
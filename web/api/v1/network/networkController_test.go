@@ -4,11 +4,12 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"regexp"
 	"testing"
+	"time"
 
 	"github.com/SotirisAlfonsos/gocache"
 
@@ -33,6 +34,7 @@ type TestData struct {
 	connectionPool map[string]*nConnection
 	cacheItems     map[cache.Key]func() (*v1.StatusResponse, error)
 	requestPayload *RequestPayload
+	limits         *config.NetworkLimits
 	expected       *expectedResult
 }
 
@@ -241,10 +243,91 @@ func TestNetworkWithInvalidAction(t *testing.T) {
 	}
 }
 
+func TestStartNetworkWithLimits(t *testing.T) {
+	limits := &config.NetworkLimits{MaxLimit: 1000, MaxGap: 10, MaxJitter: 100}
+	dataItems := []TestData{
+		{
+			message:        "Should receive bad request and not update cache if the limit is greater than the maximum",
+			jobMap:         map[string]*config.Job{"job name": newNetworkJob("network name", "127.0.0.1")},
+			connectionPool: map[string]*nConnection{"127.0.0.1": withSuccessNetworkConnection()},
+			requestPayload: &RequestPayload{Job: "job name", Device: "device name", Target: "127.0.0.1", Limit: 1001},
+			limits:         limits,
+			expected:       &expectedResult{cacheSize: 0, response: badRequestResponse("The limit {1001} is greater than the maximum {1000}")},
+		},
+		{
+			message:        "Should receive bad request and not update cache if the gap is greater than the maximum",
+			jobMap:         map[string]*config.Job{"job name": newNetworkJob("network name", "127.0.0.1")},
+			connectionPool: map[string]*nConnection{"127.0.0.1": withSuccessNetworkConnection()},
+			requestPayload: &RequestPayload{Job: "job name", Device: "device name", Target: "127.0.0.1", Gap: 11},
+			limits:         limits,
+			expected:       &expectedResult{cacheSize: 0, response: badRequestResponse("The gap {11} is greater than the maximum {10}")},
+		},
+		{
+			message:        "Should receive bad request and not update cache if the jitter is greater than the maximum",
+			jobMap:         map[string]*config.Job{"job name": newNetworkJob("network name", "127.0.0.1")},
+			connectionPool: map[string]*nConnection{"127.0.0.1": withSuccessNetworkConnection()},
+			requestPayload: &RequestPayload{Job: "job name", Device: "device name", Target: "127.0.0.1", Jitter: 101},
+			limits:         limits,
+			expected:       &expectedResult{cacheSize: 0, response: badRequestResponse("The jitter {101} is greater than the maximum {100}")},
+		},
+		{
+			message:        "Successfully start network injection if all the fields are within their maximums",
+			jobMap:         map[string]*config.Job{"job name": newNetworkJob("network name", "127.0.0.1")},
+			connectionPool: map[string]*nConnection{"127.0.0.1": withSuccessNetworkConnection()},
+			requestPayload: &RequestPayload{Job: "job name", Device: "device name", Target: "127.0.0.1", Limit: 1000, Gap: 10, Jitter: 100},
+			limits:         limits,
+			expected:       &expectedResult{cacheSize: 1, response: okResponse("Response from target {127.0.0.1}, {}, {SUCCESS}")},
+		},
+		{
+			message:        "Successfully start network injection with any value if there are no maximums",
+			jobMap:         map[string]*config.Job{"job name": newNetworkJob("network name", "127.0.0.1")},
+			connectionPool: map[string]*nConnection{"127.0.0.1": withSuccessNetworkConnection()},
+			requestPayload: &RequestPayload{Job: "job name", Device: "device name", Target: "127.0.0.1", Limit: 100000, Gap: 1000, Jitter: 10000},
+			expected:       &expectedResult{cacheSize: 1, response: okResponse("Response from target {127.0.0.1}, {}, {SUCCESS}")},
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		assertActionPerformed(t, dataItem, "start")
+	}
+}
+
+func TestStartNetworkRespondsWithTheResolvedRequest(t *testing.T) {
+	job := newNetworkJob("network name", "127.0.0.1")
+	job.DefaultDuration = 5 * time.Minute
+	server, err := networkHTTPTestServerWithCacheItems(
+		map[string]*config.Job{"job name": job},
+		map[string]*nConnection{"127.0.0.1": withSuccessNetworkConnection()},
+		gocache.New(0), nil, nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	requestBody, _ := json.Marshal(&RequestPayload{Job: "job name", Device: "eth0", Target: "127.0.0.1", Latency: 100})
+	resp, err := http.Post(server.URL+"/network?action=start", "", bytes.NewReader(requestBody)) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	respPayload := &struct {
+		ResolvedRequest *RequestPayload `json:"resolvedRequest"`
+	}{}
+	if err = json.NewDecoder(resp.Body).Decode(respPayload); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, &RequestPayload{Job: "job name", Device: "eth0", Target: "127.0.0.1", Latency: 100, DurationSec: 300},
+		respPayload.ResolvedRequest, "the default duration of the job should be applied to the request")
+}
+
 func assertActionPerformed(t *testing.T, dataItem TestData, action string) {
 	t.Run(dataItem.message, func(t *testing.T) {
 		c := gocache.New(0)
-		server, err := networkHTTPTestServerWithCacheItems(dataItem.jobMap, dataItem.connectionPool, c, dataItem.cacheItems)
+		server, err := networkHTTPTestServerWithCacheItems(dataItem.jobMap, dataItem.connectionPool, c, dataItem.cacheItems, dataItem.limits)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -307,6 +390,7 @@ func networkHTTPTestServerWithCacheItems(
 	connectionPool map[string]*nConnection,
 	cache *gocache.Cache,
 	cacheItems map[cache.Key]func() (*v1.StatusResponse, error),
+	limits *config.NetworkLimits,
 ) (*httptest.Server, error) {
 	for key, val := range cacheItems {
 		cache.Set(key, val)
@@ -316,6 +400,7 @@ func networkHTTPTestServerWithCacheItems(
 		jobs:           jobMap,
 		connectionPool: connectionPool,
 		cache:          cache,
+		limits:         limits,
 		loggers:        loggers,
 	}
 
@@ -342,8 +427,12 @@ func post(requestBody []byte, url string) (int, string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		b, _ := ioutil.ReadAll(resp.Body)
-		return resp.StatusCode, string(b), nil
+		errPayload := &response.ErrorPayload{}
+		err = json.NewDecoder(resp.Body).Decode(&errPayload)
+		if err != nil {
+			return resp.StatusCode, "", err
+		}
+		return errPayload.Status, errPayload.Error, nil
 	}
 
 	respPayload := &response.Payload{}
@@ -363,14 +452,14 @@ func okResponse(message string) *responseWrapper {
 
 func badRequestResponse(error string) *responseWrapper {
 	return &responseWrapper{
-		message: error + "\n",
+		message: error,
 		status:  400,
 	}
 }
 
 func internalServerErrorResponse(error string) *responseWrapper {
 	return &responseWrapper{
-		message: error + "\n",
+		message: error,
 		status:  500,
 	}
 }
@@ -392,3 +481,74 @@ func getLoggers() chaoslogger.Loggers {
 		ErrLogger: chaoslogger.New(allowLevel, os.Stderr),
 	}
 }
+
+func TestActionsMatchActionEnum(t *testing.T) {
+	actions := Actions()
+
+	assert.Equal(t, int(notImplemented), len(actions))
+	for i, name := range actions {
+		a, err := toActionEnum(name, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, action(i), a)
+	}
+}
+
+func TestNetworkStartOnRandomTarget(t *testing.T) {
+	dataItems := []struct {
+		message  string
+		jobMap   map[string]*config.Job
+		do       string
+		expected *expectedResult
+	}{
+		{
+			message:  "Should start network injection on a random target of the job and add it in cache with the target",
+			jobMap:   map[string]*config.Job{"job name": newNetworkJob("network name", "127.0.0.1", "127.0.0.2")},
+			do:       "random",
+			expected: &expectedResult{cacheSize: 1, response: okResponse("Response from target {127.0.0.\\d}, {}, {SUCCESS}")},
+		},
+		{
+			message:  "Should receive bad request and not update cache when job has no targets defined",
+			jobMap:   map[string]*config.Job{"job name": newNetworkJob("network name")},
+			do:       "random",
+			expected: &expectedResult{cacheSize: 0, response: badRequestResponse("Could not get random target for job {job name}. err: No targets available")},
+		},
+		{
+			message:  "Should receive bad request and not update cache when job name does not exist",
+			jobMap:   map[string]*config.Job{"job different name": newNetworkJob("network name", "127.0.0.1")},
+			do:       "random",
+			expected: &expectedResult{cacheSize: 0, response: badRequestResponse("Could not find job {job name}")},
+		},
+		{
+			message:  "Should receive bad request and not update cache for an unknown do query parameter",
+			jobMap:   map[string]*config.Job{"job name": newNetworkJob("network name", "127.0.0.1")},
+			do:       "any",
+			expected: &expectedResult{cacheSize: 0, response: badRequestResponse("Do query parameter {any} not allowed")},
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			connectionPool := map[string]*nConnection{"127.0.0.1": withSuccessNetworkConnection(), "127.0.0.2": withSuccessNetworkConnection()}
+			server, err := networkHTTPTestServerWithCacheItems(dataItem.jobMap, connectionPool, c, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			requestBody, _ := json.Marshal(&RequestPayload{Job: "job name", Device: "device name"})
+			status, message, err := post(requestBody, fmt.Sprintf("%s/network?do=%s&action=start", server.URL, dataItem.do))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, dataItem.expected.response.status, status)
+			assert.Regexp(t, regexp.MustCompile(dataItem.expected.response.message), message)
+			assert.Equal(t, dataItem.expected.cacheSize, c.ItemCount())
+			for _, item := range c.GetAll() {
+				key := item.Key.(cache.Key)
+				assert.Contains(t, message, fmt.Sprintf("{%s}", key.Target), "the cache key should have the selected target")
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package network
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/go-kit/kit/log/level"
+)
+
+// PulseAction godoc
+// @Summary Inject and recover a network failure
+// @Description Start a network failure, hold it for the duration of the request or the default duration of the job, and recover it
+// @Tags Failure injections
+// @Accept json
+// @Produce json
+// @Param confirmProduction query bool false "Confirm the injection on a target labeled env: prod"
+// @Param requestPayload body RequestPayload true "Specify the job name, device name, target, duration and netem injection arguments"
+// @Success 200 {object} response.PulsePayload
+// @Failure 400 {object} response.ErrorPayload "Also if the limit, gap or jitter is greater than its configured maximum"
+// @Failure 500 {object} response.PulsePayload
+// @Router /network/pulse [post]
+func (n *NController) PulseAction(w http.ResponseWriter, r *http.Request) {
+	requestPayload := &RequestPayload{}
+	err := json.NewDecoder(r.Body).Decode(&requestPayload)
+	if err != nil {
+		response.BadRequest(w, r, "Could not decode request body", n.loggers)
+		return
+	}
+	requestPayload.pulse = true
+
+	if err = network.ValidateLabels(requestPayload.Labels); err != nil {
+		response.BadRequest(w, r, err.Error(), n.loggers)
+		return
+	}
+
+	err = checkIfTargetExists(n.jobs, requestPayload)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), n.loggers)
+		return
+	}
+
+	if err = checkLimits(n.limits, requestPayload); err != nil {
+		response.BadRequest(w, r, err.Error(), n.loggers)
+		return
+	}
+
+	job := n.jobs[requestPayload.Job]
+	for _, action := range []action{start, recoverFailure} {
+		if !job.Allows(action.String()) {
+			response.Forbidden(w, r, fmt.Sprintf("The action {%s} is not allowed for job {%s}", action, requestPayload.Job), n.loggers)
+			return
+		}
+	}
+
+	if job.RequiresConfirmation(requestPayload.Target) && r.FormValue("confirmProduction") != "true" {
+		response.Forbidden(w, r, fmt.Sprintf("The target {%s} is a production target. Set the query parameter confirmProduction=true to inject", requestPayload.Target), n.loggers)
+		return
+	}
+
+	hold := job.RecoverAfter(requestPayload.DurationSec)
+	if hold <= 0 {
+		response.BadRequest(w, r, fmt.Sprintf("The pulse for job {%s} requires a positive durationSec", requestPayload.Job), n.loggers)
+		return
+	}
+
+	_ = level.Info(n.loggers.OutLogger).Log("msg",
+		fmt.Sprintf("pulse network injection for device {%s} on target {%s} for %s", requestPayload.Device, requestPayload.Target, hold))
+
+	payload := response.Pulse(r.Context(), hold,
+		func(ctx context.Context) (string, error) {
+			return n.performAction(ctx, start, requestPayload)
+		},
+		func(ctx context.Context) (string, error) {
+			return n.performAction(ctx, recoverFailure, requestPayload)
+		},
+	)
+
+	response.JSONResponse(w, r, payload.Status, payload, n.loggers)
+}
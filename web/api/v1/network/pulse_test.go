@@ -0,0 +1,123 @@
+package network
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestNetworkPulseInjectsAndRecoversOnce(t *testing.T) {
+	connection := &recoverCountingConnection{MockConnection: network.MockConnection{Status: new(v1.StatusResponse)}}
+	c := gocache.New(0)
+	injections := cache.NewInjections()
+	server := networkPulseHTTPTestServer(map[string]*config.Job{"job name": newNetworkJob("eth0", "127.0.0.1")}, connection, c, injections)
+	defer server.Close()
+
+	status, payload := networkPulsePostCall(t, server.URL, &RequestPayload{Job: "job name", Device: "eth0", Target: "127.0.0.1", DurationSec: 1})
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "SUCCESS", payload.Inject.Status)
+	if assert.NotNil(t, payload.Recover) {
+		assert.Equal(t, "SUCCESS", payload.Recover.Status)
+	}
+	_, scheduled := injections.RecoverAt(cache.Key{Job: "job name", Target: "127.0.0.1"})
+	assert.False(t, scheduled, "the injection of the pulse should not also be recovered automatically")
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, 1, connection.recoverCalls())
+	assert.Equal(t, 0, c.ItemCount(), "the cache should be clean after the pulse")
+}
+
+func TestNetworkPulseDoesNotRecoverAFailedInjection(t *testing.T) {
+	statusResponse := &v1.StatusResponse{Status: v1.StatusResponse_FAIL}
+	connection := &recoverCountingConnection{MockConnection: network.MockConnection{Status: statusResponse}}
+	c := gocache.New(0)
+	injections := cache.NewInjections()
+	server := networkPulseHTTPTestServer(map[string]*config.Job{"job name": newNetworkJob("eth0", "127.0.0.1")}, connection, c, injections)
+	defer server.Close()
+
+	status, payload := networkPulsePostCall(t, server.URL, &RequestPayload{Job: "job name", Device: "eth0", Target: "127.0.0.1", DurationSec: 1})
+
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, "FAILURE", payload.Inject.Status)
+	assert.Equal(t, "Failure response from target {127.0.0.1}", payload.Inject.Error)
+	assert.Nil(t, payload.Recover)
+	assert.Equal(t, 0, connection.recoverCalls())
+	assert.Equal(t, 0, c.ItemCount())
+}
+
+func networkPulseHTTPTestServer(jobMap map[string]*config.Job, connection network.Connection, cacheManager *gocache.Cache, injections *cache.Injections) *httptest.Server {
+	controller := &NController{
+		jobs:           jobMap,
+		connectionPool: map[string]*nConnection{"127.0.0.1": {connection: connection}},
+		cache:          cacheManager,
+		locks:          cache.NewKeyLocks(),
+		injections:     injections,
+		loggers:        loggers,
+	}
+
+	router := mux.NewRouter()
+	router.HandleFunc("/network/pulse", controller.PulseAction).
+		Methods("POST")
+
+	return httptest.NewServer(router)
+}
+
+func networkPulsePostCall(t *testing.T, url string, details *RequestPayload) (int, *response.PulsePayload) {
+	requestBody, _ := json.Marshal(details)
+	resp, err := http.Post(url+"/network/pulse", "", bytes.NewReader(requestBody)) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	payload := &response.PulsePayload{}
+	if err = json.NewDecoder(resp.Body).Decode(payload); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, payload
+}
+
+// recoverCountingConnection counts the recoveries through its network client
+type recoverCountingConnection struct {
+	network.MockConnection
+	mu       sync.Mutex
+	recovers int
+}
+
+func (connection *recoverCountingConnection) GetNetworkClient() (v1.NetworkClient, error) {
+	client, err := connection.MockConnection.GetNetworkClient()
+	return &recoverCountingNetworkClient{NetworkClient: client, connection: connection}, err
+}
+
+func (connection *recoverCountingConnection) recoverCalls() int {
+	connection.mu.Lock()
+	defer connection.mu.Unlock()
+	return connection.recovers
+}
+
+type recoverCountingNetworkClient struct {
+	v1.NetworkClient
+	connection *recoverCountingConnection
+}
+
+func (client *recoverCountingNetworkClient) Recover(ctx context.Context, in *v1.NetworkRequest, opts ...grpc.CallOption) (*v1.StatusResponse, error) {
+	client.connection.mu.Lock()
+	client.connection.recovers++
+	client.connection.mu.Unlock()
+	return client.NetworkClient.Recover(ctx, in, opts...)
+}
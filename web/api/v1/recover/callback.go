@@ -0,0 +1,74 @@
+package recover
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/go-kit/kit/log/level"
+)
+
+const callbackTimeout = 10 * time.Second
+
+// CallbackSummary is posted to the callback url after a recover-all completes
+type CallbackSummary struct {
+	Recovered int `json:"recovered"`
+	Failed    int `json:"failed"`
+}
+
+func (summary *CallbackSummary) add(result *response.RecoverResult) {
+	if result.Status == response.FAILURE.String() {
+		summary.Failed++
+		return
+	}
+	summary.Recovered++
+}
+
+func recoversAll(options []Options) bool {
+	for _, option := range options {
+		if option.RecoverAll {
+			return true
+		}
+	}
+
+	return false
+}
+
+// notify posts the summary to the callback url, if set. The callback is tracked
+// as an asynchronous operation so that the recovery is not delayed by it
+func (rController *RController) notify(summary *CallbackSummary) {
+	if rController.callbackURL == "" {
+		return
+	}
+
+	rController.async.Go(func() {
+		if err := postSummary(rController.callbackURL, summary); err != nil {
+			_ = level.Error(rController.loggers.ErrLogger).Log("msg", "Could not deliver the recover callback", "url", chaoslogger.URL(rController.callbackURL), "err", err)
+			return
+		}
+		_ = level.Info(rController.loggers.OutLogger).Log("msg", "delivered the recover callback", "url", chaoslogger.URL(rController.callbackURL))
+	})
+}
+
+func postSummary(url string, summary *CallbackSummary) error {
+	body, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: callbackTimeout}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body)) //nolint:gosec
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("callback responded with status {%d}", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package recover
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/controller"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecoverCallbackIsDeliveredAfterRecoverAll(t *testing.T) {
+	dataItems := []struct {
+		message  string
+		options  *Options
+		expected *CallbackSummary
+	}{
+		{
+			message:  "Should deliver the counts of the recovered and failed items after a recover-all",
+			options:  &Options{RecoverAll: true},
+			expected: &CallbackSummary{Recovered: 2, Failed: 1},
+		},
+		{
+			message: "Should not deliver a callback after the recovery of a job",
+			options: &Options{RecoverJob: "job"},
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			summaries := make(chan *CallbackSummary, 1)
+			callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				summary := &CallbackSummary{}
+				if err := json.NewDecoder(r.Body).Decode(summary); err != nil {
+					t.Error(err)
+				}
+				summaries <- summary
+			}))
+			defer callback.Close()
+
+			cacheManager := gocache.New(0)
+			cacheManager.Set(cache.Key{Job: "job", Target: "127.0.0.1"}, functionWithSuccessResponse())
+			cacheManager.Set(cache.Key{Job: "job", Target: "127.0.0.2"}, functionWithFailureResponse())
+			cacheManager.Set(cache.Key{Job: "other job", Target: "127.0.0.1"}, functionWithSuccessResponse())
+
+			tracker := async.NewTracker()
+			rController := NewRecoverController(nil, &controller.Options{Cache: cacheManager, Locks: cache.NewKeyLocks(), Recover: &config.Recover{CallbackURL: callback.URL}, Async: tracker, Loggers: loggers})
+			router := mux.NewRouter()
+			router.HandleFunc("/recover", rController.RecoverAction).Methods("POST")
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			if _, _, _, err := restorePostCall(server, dataItem.options); err != nil {
+				t.Fatal(err)
+			}
+
+			if dataItem.expected == nil {
+				select {
+				case summary := <-summaries:
+					t.Fatalf("unexpected callback with summary %+v", summary)
+				case <-time.After(100 * time.Millisecond):
+				}
+				return
+			}
+
+			select {
+			case summary := <-summaries:
+				assert.Equal(t, dataItem.expected, summary)
+			case <-time.After(time.Second):
+				t.Fatal("the callback should be delivered after the recovery")
+			}
+		})
+	}
+}
+
+func TestPostSummaryErrorsForUnsuccessfulResponse(t *testing.T) {
+	callback := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer callback.Close()
+
+	err := postSummary(callback.URL, &CallbackSummary{Recovered: 1})
+
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "callback responded with status {502}", err.Error())
+	}
+}
@@ -0,0 +1,139 @@
+package recover
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/go-kit/kit/log/level"
+)
+
+const defaultCycleInterval = 10 * time.Second
+
+// CycleResponsePayload contains the results of the recovery and of the injection again of every failure of a cycle
+type CycleResponsePayload struct {
+	Recovered  []*response.RecoverResult `json:"recovered"`
+	Reinjected []*response.RecoverResult `json:"reinjected"`
+	Status     int                       `json:"status"`
+}
+
+// CycleAction godoc
+// @Summary Recover and inject failures again
+// @Description Recover the failures that match the options, wait for the interval and inject the recovered failures again, to simulate flapping. Failures that were imported can not be injected again and are not recovered
+// @Tags Recover
+// @Accept json
+// @Produce json
+// @Param interval query string false "The go duration to wait between the recovery and the injection, e.g. 30s. Defaults to 10s"
+// @Param Options body Options true "Create request payload that contains the recovery details"
+// @Success 200 {object} CycleResponsePayload
+// @Failure 400 {object} response.ErrorPayload
+// @Router /recover/cycle [post]
+func (rController *RController) CycleAction(w http.ResponseWriter, r *http.Request) {
+	options, err := decodeOptions(r.Body)
+	if err != nil {
+		response.BadRequest(w, r, "Could not decode request body", rController.loggers)
+		return
+	}
+
+	interval := defaultCycleInterval
+	if value := r.URL.Query().Get("interval"); value != "" {
+		if interval, err = time.ParseDuration(value); err != nil || interval < 0 {
+			response.BadRequest(w, r, fmt.Sprintf("The interval {%s} should be a positive duration", value), rController.loggers)
+			return
+		}
+	}
+
+	payload := &CycleResponsePayload{
+		Recovered:  make([]*response.RecoverResult, 0),
+		Reinjected: make([]*response.RecoverResult, 0),
+	}
+
+	items := make([]gocache.Item, 0)
+	injections := make(map[cache.Key]*cache.Injection)
+	recoveries := make(map[cache.Key]func() (*v1.StatusResponse, error))
+	for _, item := range selectItems(rController.cache.GetAll(), options, rController.injections) {
+		key := item.Key.(cache.Key)
+		injection, ok := rController.injections.Get(key)
+		if !ok || injection.Inject == nil {
+			payload.Recovered = append(payload.Recovered, response.NewRecoverResult(key.Job, key.Target,
+				response.FailureRecoverResponse(fmt.Sprintf("The injection of job {%s} on target {%s} can not be repeated", key.Job, key.Target)), 0))
+			continue
+		}
+		items = append(items, item)
+		injections[key] = injection
+		recoveries[key], _ = item.Value.(func() (*v1.StatusResponse, error))
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	rController.recoverItems(items, &wg, nil, func(result *response.RecoverResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		payload.Recovered = append(payload.Recovered, result)
+	})
+
+	select {
+	case <-time.After(interval):
+		for _, result := range payload.Recovered {
+			if result.Status == response.SUCCESS.String() {
+				key := cache.Key{Job: result.Job, Target: result.Target}
+				payload.Reinjected = append(payload.Reinjected, rController.reinject(key, injections[key], recoveries[key]))
+			}
+		}
+	case <-r.Context().Done():
+		_ = level.Warn(rController.loggers.OutLogger).Log("msg", "the cycle was canceled before the failures were injected again")
+	}
+
+	if err = rController.persistence.Save(rController.cache); err != nil {
+		_ = level.Error(rController.loggers.ErrLogger).Log("msg", "Could not update cache after cycle", "err", err)
+	}
+
+	payload.Status = http.StatusOK
+	if containsFailures(payload.Recovered) || containsFailures(payload.Reinjected) {
+		payload.Status = http.StatusInternalServerError
+	}
+	response.JSONResponse(w, r, payload.Status, payload, rController.loggers)
+}
+
+// reinject injects the recovered failure again, and adds it back to the cache with its previous recovery
+func (rController *RController) reinject(key cache.Key, injection *cache.Injection, recovery func() (*v1.StatusResponse, error)) *response.RecoverResult {
+	unlock := rController.locks.Lock(key)
+	defer unlock()
+
+	start := time.Now()
+	result := func(message *response.RecoverMessage) *response.RecoverResult {
+		return response.NewRecoverResult(key.Job, key.Target, message, time.Since(start))
+	}
+
+	if _, ok := rController.cache.Get(key); ok {
+		return result(response.FailureRecoverResponse(fmt.Sprintf("The failure of job {%s} on target {%s} was injected during the cycle", key.Job, key.Target)))
+	}
+
+	statusResponse, err := injection.Inject()
+	_ = level.Info(rController.loggers.OutLogger).Log("msg", fmt.Sprintf("inject job item {%s} again on target {%s}", key.Job, key.Target))
+	switch {
+	case err != nil:
+		return result(response.ErrorRecoverResponse(err, fmt.Sprintf("Error response from target {%s}", key.Target)))
+	case statusResponse.Status != v1.StatusResponse_SUCCESS:
+		return result(response.FailureRecoverResponse(fmt.Sprintf("Failure response from target {%s}", key.Target)))
+	}
+
+	rController.cache.Set(key, recovery)
+	rController.annotator.Start(key.Job, key.Target)
+	rController.injections.Record(key, &cache.Injection{StartsAt: time.Now(), Labels: injection.Labels, Metadata: injection.Metadata, Inject: injection.Inject})
+	return result(response.SuccessRecoverResponse(rController.messages.Render(key.Target, statusResponse.Status, statusResponse.Message)))
+}
+
+func containsFailures(results []*response.RecoverResult) bool {
+	for _, result := range results {
+		if result.Status == response.FAILURE.String() {
+			return true
+		}
+	}
+	return false
+}
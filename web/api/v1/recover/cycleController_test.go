@@ -0,0 +1,140 @@
+package recover
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/controller"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// cycleCalls records the order in which the failures were recovered and injected again
+type cycleCalls struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (c *cycleCalls) function(call string) func() (*v1.StatusResponse, error) {
+	return func() (*v1.StatusResponse, error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.calls = append(c.calls, call)
+		return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+	}
+}
+
+func (c *cycleCalls) get() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.calls...)
+}
+
+func TestCycleRecoversAndInjectsAgainTheSameFailures(t *testing.T) {
+	calls := &cycleCalls{}
+	cacheManager := gocache.New(0)
+	injections := cache.NewInjections()
+	keys := []cache.Key{{Job: "job", Target: "127.0.0.1"}, {Job: "job", Target: "127.0.0.2"}}
+	for _, key := range keys {
+		cacheManager.Set(key, calls.function("recover "+key.Target))
+		injections.Record(key, &cache.Injection{StartsAt: time.Now(), Inject: calls.function("inject " + key.Target)})
+	}
+	otherKey := cache.Key{Job: "other job", Target: "127.0.0.1"}
+	cacheManager.Set(otherKey, calls.function("recover other job"))
+	injections.Record(otherKey, &cache.Injection{Inject: calls.function("inject other job")})
+
+	server := cycleHTTPTestServer(cacheManager, injections)
+	defer server.Close()
+
+	status, payload := cyclePostCall(t, server, "10ms", &Options{RecoverJob: "job"})
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"job,127.0.0.1,SUCCESS", "job,127.0.0.2,SUCCESS"}, sortedResults(payload.Recovered))
+	assert.Equal(t, []string{"job,127.0.0.1,SUCCESS", "job,127.0.0.2,SUCCESS"}, sortedResults(payload.Reinjected))
+
+	made := calls.get()
+	assert.Equal(t, 4, len(made))
+	assert.ElementsMatch(t, []string{"recover 127.0.0.1", "recover 127.0.0.2"}, made[:2], "the failures should be recovered before they are injected again")
+	assert.ElementsMatch(t, []string{"inject 127.0.0.1", "inject 127.0.0.2"}, made[2:])
+
+	assert.Equal(t, 3, cacheManager.ItemCount(), "the failures injected again should be back in the cache")
+	for _, key := range keys {
+		_, ok := cacheManager.Get(key)
+		assert.True(t, ok)
+	}
+}
+
+func TestCycleDoesNotRecoverFailuresThatCanNotBeInjectedAgain(t *testing.T) {
+	calls := &cycleCalls{}
+	cacheManager := gocache.New(0)
+	key := cache.Key{Job: "job", Target: "127.0.0.1"}
+	cacheManager.Set(key, calls.function("recover"))
+	injections := cache.NewInjections()
+	injections.Record(key, &cache.Injection{StartsAt: time.Now()})
+
+	server := cycleHTTPTestServer(cacheManager, injections)
+	defer server.Close()
+
+	status, payload := cyclePostCall(t, server, "0s", &Options{RecoverAll: true})
+
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.Equal(t, []string{"job,127.0.0.1,FAILURE"}, sortedResults(payload.Recovered))
+	assert.Equal(t, 0, len(payload.Reinjected))
+	assert.Equal(t, 0, len(calls.get()))
+	assert.Equal(t, 1, cacheManager.ItemCount())
+}
+
+func TestCycleRejectsInvalidInterval(t *testing.T) {
+	server := cycleHTTPTestServer(gocache.New(0), cache.NewInjections())
+	defer server.Close()
+
+	for _, interval := range []string{"ten", "-1s"} {
+		status, _ := cyclePostCall(t, server, interval, &Options{RecoverAll: true})
+		assert.Equal(t, http.StatusBadRequest, status)
+	}
+}
+
+func cycleHTTPTestServer(cacheManager *gocache.Cache, injections *cache.Injections) *httptest.Server {
+	rController := NewRecoverController(nil, &controller.Options{Cache: cacheManager, Locks: cache.NewKeyLocks(), Injections: injections, Recover: &config.Recover{}, Async: async.NewTracker(), Loggers: loggers})
+	router := mux.NewRouter()
+	router.HandleFunc("/recover/cycle", rController.CycleAction).Methods("POST")
+	return httptest.NewServer(router)
+}
+
+func cyclePostCall(t *testing.T, server *httptest.Server, interval string, options *Options) (int, *CycleResponsePayload) {
+	request, _ := json.Marshal(options)
+	resp, err := http.Post(server.URL+"/recover/cycle?interval="+interval, "application/json", bytes.NewReader(request)) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	payload := &CycleResponsePayload{}
+	if resp.StatusCode != http.StatusBadRequest {
+		if err = json.NewDecoder(resp.Body).Decode(payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return resp.StatusCode, payload
+}
+
+func sortedResults(results []*response.RecoverResult) []string {
+	sorted := make([]string, 0, len(results))
+	for _, result := range results {
+		sorted = append(sorted, result.Job+","+result.Target+","+result.Status)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
@@ -1,110 +1,474 @@
 package recover
 
 import (
+	"context"
 	"fmt"
+	"net/http"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
 
 	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/healthcheck"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/grafana"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/limiter"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/metrics"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/controller"
 	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
 	"github.com/SotirisAlfonsos/gocache"
 	"github.com/go-kit/kit/log/level"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+)
+
+const (
+	defaultRetryInterval = 30 * time.Second
+	verifyHealthTimeout  = 5 * time.Second
 )
 
 type RController struct {
-	cache   *gocache.Cache
-	loggers chaoslogger.Loggers
+	jobs          map[string]*config.Job
+	cache         *gocache.Cache
+	connections   *network.Connections
+	persistence   *cache.Persistence
+	locks         *cache.KeyLocks
+	injections    *cache.Injections
+	outcomes      *metrics.Outcomes
+	messages      *response.MessageTemplate
+	onFailure     config.RecoverFailurePolicy
+	retryInterval time.Duration
+	// webhookConcurrency bounds the concurrent recoveries of one webhook call. Zero does not bound them
+	webhookConcurrency int
+	// verifyHealth marks a recovery as successful only if the bot of the target is also healthy after it
+	verifyHealth bool
+	// treatUnreachableAsRecovered drops the items of unreachable targets from the cache and reports them as recovered
+	treatUnreachableAsRecovered bool
+	// callbackURL receives the summary of every recover-all after it completes, if set
+	callbackURL string
+	// serialPerTarget recovers the items of every target one after the other, while different targets are recovered concurrently
+	serialPerTarget bool
+	// echoGroupKey returns the groupKey of the alertmanager webhook in its response
+	echoGroupKey bool
+	// limiter limits the recoveries in flight to every target
+	limiter *limiter.Limiter
+	// typePriority is the order in which the failure types are recovered
+	typePriority []config.FailureType
+	annotator    *grafana.Annotator
+	async        *async.Tracker
+	loggers      chaoslogger.Loggers
 }
 
-func NewRecoverController(cache *gocache.Cache, loggers chaoslogger.Loggers) *RController {
-	return &RController{
-		cache:   cache,
-		loggers: loggers,
+func NewRecoverController(jobs map[string]*config.Job, options *controller.Options) *RController {
+	rController := &RController{
+		jobs:          jobs,
+		cache:         options.Cache,
+		connections:   options.Connections,
+		persistence:   options.Persistence,
+		locks:         options.Locks,
+		injections:    options.Injections,
+		outcomes:      options.Outcomes,
+		messages:      options.Messages,
+		onFailure:     config.Keep,
+		retryInterval: defaultRetryInterval,
+		annotator:     options.Annotator,
+		async:         options.Async,
+		loggers:       options.Loggers,
 	}
+	if options.Connections != nil {
+		rController.limiter = options.Connections.Limiter
+	}
+	if recoverConf := options.Recover; recoverConf != nil {
+		if recoverConf.OnFailure != "" {
+			rController.onFailure = recoverConf.OnFailure
+		}
+		if recoverConf.RetryInterval > 0 {
+			rController.retryInterval = recoverConf.RetryInterval
+		}
+		rController.webhookConcurrency = recoverConf.WebhookConcurrency
+		rController.verifyHealth = recoverConf.VerifyHealth
+		rController.treatUnreachableAsRecovered = recoverConf.TreatUnreachableAsRecovered
+		rController.callbackURL = recoverConf.CallbackURL
+		rController.serialPerTarget = recoverConf.SerialPerTarget
+		rController.typePriority = recoverConf.TypePriority
+		rController.echoGroupKey = recoverConf.EchoGroupKey
+	}
+
+	return rController
 }
 
-func (rController *RController) performActionBasedOnOptions(labels Options) []*response.RecoverMessage {
-	items := rController.cache.GetAll()
+func (rController *RController) performActionBasedOnOptions(options ...Options) []*response.RecoverResult {
+	return rController.performLimitedActionBasedOnOptions(nil, options...)
+}
+
+// performLimitedActionBasedOnOptions performs at most cap(limit) recoveries concurrently. A nil limit does not bound them
+func (rController *RController) performLimitedActionBasedOnOptions(limit chan struct{}, options ...Options) []*response.RecoverResult {
+	results := make([]*response.RecoverResult, 0)
+	rController.emitActionBasedOnOptions(func(result *response.RecoverResult) {
+		results = append(results, result)
+	}, limit, options...)
+
+	return results
+}
+
+// streamActionBasedOnOptions writes every recover message to the stream as soon as its recovery completes
+func (rController *RController) streamActionBasedOnOptions(stream *response.RecoverStream, limit chan struct{}, options ...Options) {
+	results := make(chan *response.RecoverResult)
+	go func() {
+		defer close(results)
+		rController.emitActionBasedOnOptions(func(result *response.RecoverResult) {
+			results <- result
+		}, limit, options...)
+	}()
+
+	for result := range results {
+		stream.Send(result)
+	}
+}
+
+func (rController *RController) emitActionBasedOnOptions(emit func(*response.RecoverResult), limit chan struct{}, options ...Options) {
+	items := selectItems(rController.cache.GetAll(), options, rController.injections)
 	var wg sync.WaitGroup
+	var mu sync.Mutex
+	summary := &CallbackSummary{}
 
-	switch {
-	case labels.RecoverAll:
-		return rController.recoverAll(items, &wg)
-	case labels.RecoverJob != "":
-		return rController.recoverJob(items, labels, &wg)
-	case labels.RecoverTarget != "":
-		return rController.recoverTarget(items, labels, &wg)
+	rController.recoverItems(items, &wg, limit, func(result *response.RecoverResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		summary.add(result)
+		emit(result)
+	})
+	if err := rController.persistence.Save(rController.cache); err != nil {
+		_ = level.Error(rController.loggers.ErrLogger).Log("msg", "Could not update cache after recovery", "err", err)
 	}
 
-	return make([]*response.RecoverMessage, 0)
+	if recoversAll(options) {
+		rController.notify(summary)
+	}
 }
 
-func (rController *RController) recoverAll(items []gocache.Item, wg *sync.WaitGroup) []*response.RecoverMessage {
-	messages := make([]*response.RecoverMessage, 0)
+// selectItems returns the items that match any of the options, in the order they were injected. Every item is selected
+// at most once, even if it is matched by more than one of the options, and every option selects at most its limit
+func selectItems(items []gocache.Item, options []Options, injections *cache.Injections) []gocache.Item {
+	startsAt := make(map[cache.Key]time.Time, len(items))
 	for _, item := range items {
-		wg.Add(1)
 		key := item.Key.(cache.Key)
-		val := item.Value.(func() (*v1.StatusResponse, error))
-		go func() {
-			defer wg.Done()
-			messages = append(messages, rController.action(&key, val))
-		}()
+		if injection, ok := injections.Get(key); ok {
+			startsAt[key] = injection.StartsAt
+		}
 	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return startsAt[items[i].Key.(cache.Key)].Before(startsAt[items[j].Key.(cache.Key)])
+	})
 
-	wg.Wait()
-	return messages
+	selected := make([]gocache.Item, 0)
+	counts := make([]int, len(options))
+	for _, item := range items {
+		key := item.Key.(cache.Key)
+		injection, _ := injections.Get(key)
+		for i, option := range options {
+			if option.allows(counts[i]) && option.matches(key, injection) {
+				counts[i]++
+				selected = append(selected, item)
+				break
+			}
+		}
+	}
+
+	return selected
 }
 
-func (rController *RController) recoverJob(items []gocache.Item, labels Options, wg *sync.WaitGroup) []*response.RecoverMessage {
-	messages := make([]*response.RecoverMessage, 0)
-	for _, item := range items {
-		if item.Key.(cache.Key).Job == labels.RecoverJob {
+func (rController *RController) recoverItems(items []gocache.Item, wg *sync.WaitGroup, limit chan struct{}, emit func(*response.RecoverResult)) {
+	for _, stage := range rController.stages(items) {
+		for _, queue := range rController.queues(stage) {
 			wg.Add(1)
-			key := item.Key.(cache.Key)
-			val := item.Value.(func() (*v1.StatusResponse, error))
-			go func() {
+			go func(queue []cache.Key) {
 				defer wg.Done()
-				messages = append(messages, rController.action(&key, val))
-			}()
+				for _, key := range queue {
+					rController.recoverItem(key, limit, emit)
+				}
+			}(queue)
 		}
+
+		wg.Wait()
 	}
+}
 
-	wg.Wait()
-	return messages
+// stages returns the items grouped by the priority of their failure type. The items of a stage are recovered after
+// all the items of the stages before it. The items of the types without a priority are the last stage
+func (rController *RController) stages(items []gocache.Item) [][]gocache.Item {
+	if len(rController.typePriority) == 0 {
+		return [][]gocache.Item{items}
+	}
+
+	priorities := make(map[config.FailureType]int, len(rController.typePriority))
+	for i, failureType := range rController.typePriority {
+		priorities[failureType] = i
+	}
+
+	stages := make([][]gocache.Item, len(rController.typePriority)+1)
+	for _, item := range items {
+		stage := len(rController.typePriority)
+		if job, ok := rController.jobs[item.Key.(cache.Key).Job]; ok {
+			if priority, ok := priorities[job.FailureType]; ok {
+				stage = priority
+			}
+		}
+		stages[stage] = append(stages[stage], item)
+	}
+
+	return stages
 }
 
-func (rController *RController) recoverTarget(items []gocache.Item, labels Options, wg *sync.WaitGroup) []*response.RecoverMessage {
-	messages := make([]*response.RecoverMessage, 0)
+// queues returns the keys of the items grouped in the queues that are recovered concurrently. Every key is a queue
+// of its own, unless the recoveries are serialized per target, in which case every target is a queue of its keys
+func (rController *RController) queues(items []gocache.Item) [][]cache.Key {
+	queues := make([][]cache.Key, 0, len(items))
+	targets := make(map[string]int)
 	for _, item := range items {
-		if item.Key.(cache.Key).Target == labels.RecoverTarget {
-			wg.Add(1)
-			key := item.Key.(cache.Key)
-			val := item.Value.(func() (*v1.StatusResponse, error))
-			go func() {
-				defer wg.Done()
-				messages = append(messages, rController.action(&key, val))
-			}()
+		key := item.Key.(cache.Key)
+		if !rController.serialPerTarget {
+			queues = append(queues, []cache.Key{key})
+			continue
+		}
+
+		i, ok := targets[key.Target]
+		if !ok {
+			i = len(queues)
+			targets[key.Target] = i
+			queues = append(queues, nil)
+		}
+		queues[i] = append(queues[i], key)
+	}
+
+	return queues
+}
+
+func (rController *RController) recoverItem(key cache.Key, limit chan struct{}, emit func(*response.RecoverResult)) {
+	if limit != nil {
+		limit <- struct{}{}
+		defer func() { <-limit }()
+	}
+	start := time.Now()
+	if message, ok := rController.lockedAction(&key); ok {
+		emit(response.NewRecoverResult(key.Job, key.Target, message, time.Since(start)))
+	}
+}
+
+// respond writes the result of every recovery with its job, target and duration if the query parameter
+// detailed=true is set, and only the recover messages otherwise. The group key is omitted if it is empty
+func (rController *RController) respond(w http.ResponseWriter, r *http.Request, results []*response.RecoverResult, groupKey string) {
+	if isDetailed(r) {
+		response.DetailedRecoverResponse(w, r, results, groupKey, rController.loggers)
+		return
+	}
+	response.RecoverResponse(w, r, response.RecoverMessages(results), groupKey, rController.loggers)
+}
+
+func isDetailed(r *http.Request) bool {
+	return r.URL.Query().Get("detailed") == "true"
+}
+
+// newWebhookLimit returns the limit of the concurrent recoveries of one webhook call, or nil if they are not bounded
+func (rController *RController) newWebhookLimit() chan struct{} {
+	if rController.webhookConcurrency <= 0 {
+		return nil
+	}
+	return make(chan struct{}, rController.webhookConcurrency)
+}
+
+// lockedAction recovers the item while holding the lock of its key. The item is read again from the cache
+// after the lock is acquired, since it may have been recovered or replaced by a new injection in the meantime.
+// It returns false if the item is no longer in the cache
+func (rController *RController) lockedAction(key *cache.Key) (*response.RecoverMessage, bool) {
+	unlock := rController.locks.Lock(*key)
+	defer unlock()
+
+	item, ok := rController.cache.Get(*key)
+	if !ok {
+		return nil, false
+	}
+
+	function, _ := item.Value.(func() (*v1.StatusResponse, error))
+	return rController.action(key, function), true
+}
+
+// RecoverExpired recovers the items that were due to be recovered automatically before now and are still in the cache,
+// e.g. because their scheduled recovery failed. An item whose recovery fails again is
+// recovered on the next call
+func (rController *RController) RecoverExpired(now time.Time) {
+	recovered := 0
+	for _, key := range rController.injections.Expired(now) {
+		key := key
+		if _, ok := rController.cache.Get(key); !ok {
+			rController.injections.Forget(key)
+			continue
+		}
+
+		_ = level.Info(rController.loggers.OutLogger).Log("msg", fmt.Sprintf("automatic recovery of expired job item {%s} on target {%s}", key.Job, key.Target))
+		message, ok := rController.lockedAction(&key)
+		if !ok {
+			rController.injections.Forget(key)
+			continue
 		}
+		if message.Status != response.SUCCESS.String() {
+			_ = level.Error(rController.loggers.ErrLogger).Log("msg", fmt.Sprintf("automatic recovery of expired job item {%s} on target {%s} failed", key.Job, key.Target), "err", message.Error)
+			continue
+		}
+		rController.injections.Forget(key)
+		recovered++
 	}
 
-	wg.Wait()
-	return messages
+	if recovered == 0 {
+		return
+	}
+	if err := rController.persistence.Save(rController.cache); err != nil {
+		_ = level.Error(rController.loggers.ErrLogger).Log("msg", "Could not update cache after recovery", "err", err)
+	}
 }
 
 func (rController *RController) action(key *cache.Key, function func() (*v1.StatusResponse, error)) *response.RecoverMessage {
+	if function == nil {
+		_ = level.Error(rController.loggers.ErrLogger).Log("msg", fmt.Sprintf("drop job item {%s} on target {%s} from cache without recovery function", key.Job, key.Target))
+		rController.cache.Delete(key)
+		return response.FailureRecoverResponse(fmt.Sprintf("no recovery function for {%s},{%s}", key.Job, key.Target))
+	}
+
+	release := rController.limiter.Acquire(rController.address(key))
+	callStart := time.Now()
 	statusResponse, err := function()
+	release()
+	rController.outcomes.Observe(rController.failureType(key), "recover", key.Target, metrics.ResultOf(statusResponse, err), time.Since(callStart))
 	_ = level.Info(rController.loggers.OutLogger).Log("msg", fmt.Sprintf("recover job item {%s} from cache on target {%s}", key.Job, key.Target))
 
 	switch {
+	case err != nil && rController.treatUnreachableAsRecovered && isUnreachable(err):
+		_ = level.Warn(rController.loggers.OutLogger).Log("msg", fmt.Sprintf("drop job item {%s} on target {%s} from cache, since the target is unreachable", key.Job, key.Target), "err", err)
+		rController.cache.Delete(key)
+		rController.annotator.End(key.Job, key.Target)
+		return response.SuccessRecoverResponse(fmt.Sprintf("Target {%s} is unreachable, the failure is treated as recovered", key.Target))
 	case err != nil:
-		return response.FailureRecoverResponse(errors.Wrap(err, fmt.Sprintf("Error response from target {%s}", key.Target)).Error())
+		rController.handleFailure(key, function)
+		return response.ErrorRecoverResponse(err, fmt.Sprintf("Error response from target {%s}", key.Target))
 	case statusResponse.Status != v1.StatusResponse_SUCCESS:
+		rController.handleFailure(key, function)
 		return response.FailureRecoverResponse(fmt.Sprintf("Failure response from target {%s}", key.Target))
 	}
+	if err = rController.verify(rController.address(key)); err != nil {
+		rController.handleFailure(key, function)
+		return response.ErrorRecoverResponse(err, fmt.Sprintf("Recovery verification failed for target {%s}", key.Target))
+	}
 	rController.cache.Delete(key)
-	message := fmt.Sprintf("Response from target {%s}, {%s}, {%s}", key.Target, statusResponse.Message, statusResponse.Status)
+	rController.annotator.End(key.Job, key.Target)
+	message := rController.messages.Render(key.Target, statusResponse.Status, statusResponse.Message)
 	return response.SuccessRecoverResponse(message)
 }
+
+// failureType returns the failure type of the job of the key, or an empty type if the job is not known
+func (rController *RController) failureType(key *cache.Key) config.FailureType {
+	if job, ok := rController.jobs[key.Job]; ok {
+		return job.FailureType
+	}
+	return ""
+}
+
+// address returns the address of the bot of the target of the key, by which the recoveries in flight are limited
+func (rController *RController) address(key *cache.Key) string {
+	if job, ok := rController.jobs[key.Job]; ok {
+		return job.Address(key.Target)
+	}
+	return key.Target
+}
+
+// isUnreachable returns true if the error is the grpc status of a bot that can not be reached
+func isUnreachable(err error) bool {
+	return response.StatusCode(err) == codes.Unavailable.String()
+}
+
+// verify checks that the bot on the address is serving after its recovery, if health verification is enabled
+func (rController *RController) verify(address string) error {
+	if !rController.verifyHealth {
+		return nil
+	}
+
+	connection, ok := rController.connections.Pool[address]
+	if !ok {
+		return errors.New(fmt.Sprintf("Could not find connection to target {%s}", address))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), verifyHealthTimeout)
+	defer cancel()
+
+	status, err := healthcheck.Check(ctx, connection)
+	if err != nil {
+		return err
+	}
+	if status != v1.HealthCheckResponse_SERVING {
+		return errors.New(fmt.Sprintf("Target {%s} is {%s} after recovery", address, status))
+	}
+	return nil
+}
+
+// handleFailure applies the recover failure policy to the item whose recovery failed
+func (rController *RController) handleFailure(key *cache.Key, function func() (*v1.StatusResponse, error)) {
+	switch rController.onFailure {
+	case config.Drop:
+		_ = level.Warn(rController.loggers.OutLogger).Log("msg", fmt.Sprintf("drop job item {%s} on target {%s} from cache after failed recovery", key.Job, key.Target))
+		rController.cache.Delete(key)
+	case config.Requeue:
+		_ = level.Info(rController.loggers.OutLogger).Log("msg", fmt.Sprintf("retry recovery of job item {%s} on target {%s} in %s", key.Job, key.Target, rController.retryInterval))
+		injection, _ := rController.injections.Get(*key)
+		rController.async.Go(func() {
+			time.Sleep(rController.retryInterval)
+			rController.retry(key, injection)
+		})
+	}
+}
+
+// retry recovers the item again while holding the lock of its key, like lockedAction. The item is read again from
+// the cache after the lock is acquired, and the retry is dropped if the item has been recovered in the meantime or
+// replaced by a new injection, whose recovery func is not the one that failed
+func (rController *RController) retry(key *cache.Key, injection *cache.Injection) {
+	unlock := rController.locks.Lock(*key)
+	defer unlock()
+
+	item, ok := rController.cache.Get(*key)
+	if !ok {
+		return
+	}
+	if latest, _ := rController.injections.Get(*key); latest != injection {
+		return
+	}
+	function, _ := item.Value.(func() (*v1.StatusResponse, error))
+	if function == nil {
+		return
+	}
+
+	release := rController.limiter.Acquire(rController.address(key))
+	statusResponse, err := function()
+	release()
+	switch {
+	case err != nil:
+		_ = level.Error(rController.loggers.ErrLogger).Log("msg", fmt.Sprintf("retry of recovery failed for job item {%s} on target {%s}", key.Job, key.Target), "err", err)
+		return
+	case statusResponse.Status != v1.StatusResponse_SUCCESS:
+		_ = level.Error(rController.loggers.ErrLogger).Log("msg", fmt.Sprintf("retry of recovery failed for job item {%s} on target {%s}", key.Job, key.Target))
+		return
+	}
+	if err = rController.verify(rController.address(key)); err != nil {
+		_ = level.Error(rController.loggers.ErrLogger).Log("msg", fmt.Sprintf("retry of recovery failed for job item {%s} on target {%s}", key.Job, key.Target), "err", err)
+		return
+	}
+
+	rController.cache.Delete(key)
+	rController.annotator.End(key.Job, key.Target)
+	if err = rController.persistence.Save(rController.cache); err != nil {
+		_ = level.Error(rController.loggers.ErrLogger).Log("msg", "Could not update cache after recovery", "err", err)
+	}
+	_ = level.Info(rController.loggers.OutLogger).Log("msg", fmt.Sprintf("retry of recovery succeeded for job item {%s} on target {%s}", key.Job, key.Target))
+}
@@ -0,0 +1,257 @@
+package recover
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/controller"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRecoverFailurePolicy(t *testing.T) {
+	dataItems := []struct {
+		message           string
+		policy            config.RecoverFailurePolicy
+		expectedCacheSize int
+	}{
+		{
+			message:           "Should keep the item in the cache after a failed recovery",
+			policy:            config.Keep,
+			expectedCacheSize: 1,
+		},
+		{
+			message:           "Should drop the item from the cache after a failed recovery",
+			policy:            config.Drop,
+			expectedCacheSize: 0,
+		},
+		{
+			message:           "Should remove the item from the cache after a successful retry",
+			policy:            config.Requeue,
+			expectedCacheSize: 0,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			c.Set(cache.Key{Job: "job", Target: "127.0.0.1"}, functionFailingOnce())
+			tracker := async.NewTracker()
+			recoverConf := &config.Recover{OnFailure: dataItem.policy, RetryInterval: time.Millisecond}
+			rController := NewRecoverController(nil, &controller.Options{Cache: c, Locks: cache.NewKeyLocks(), Recover: recoverConf, Async: tracker, Loggers: loggers})
+
+			messages := rController.performActionBasedOnOptions(Options{RecoverAll: true})
+			if err := tracker.Wait(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, "FAILURE", messages[0].Status)
+			assert.Equal(t, dataItem.expectedCacheSize, c.ItemCount())
+		})
+	}
+}
+
+func TestRecoverRequeueKeepsItemIfRetryFails(t *testing.T) {
+	c := gocache.New(0)
+	c.Set(cache.Key{Job: "job", Target: "127.0.0.1"}, functionWithErrorResponse())
+	tracker := async.NewTracker()
+	recoverConf := &config.Recover{OnFailure: config.Requeue, RetryInterval: time.Millisecond}
+	rController := NewRecoverController(nil, &controller.Options{Cache: c, Locks: cache.NewKeyLocks(), Recover: recoverConf, Async: tracker, Loggers: loggers})
+
+	rController.performActionBasedOnOptions(Options{RecoverAll: true})
+	if err := tracker.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 1, c.ItemCount())
+}
+
+func TestRecoverRequeueDoesNotRetryAnItemInjectedAgain(t *testing.T) {
+	key := cache.Key{Job: "job", Target: "127.0.0.1"}
+	c := gocache.New(0)
+	c.Set(key, functionFailingOnce())
+	injections := cache.NewInjections()
+	injections.Record(key, &cache.Injection{StartsAt: time.Now()})
+	locks := cache.NewKeyLocks()
+	tracker := async.NewTracker()
+	recoverConf := &config.Recover{OnFailure: config.Requeue, RetryInterval: 50 * time.Millisecond}
+	rController := NewRecoverController(nil, &controller.Options{Cache: c, Locks: locks, Injections: injections, Recover: recoverConf, Async: tracker, Loggers: loggers})
+
+	rController.performActionBasedOnOptions(Options{RecoverAll: true})
+	unlock := locks.Lock(key)
+	c.Set(key, functionWithErrorResponse())
+	injections.Record(key, &cache.Injection{StartsAt: time.Now()})
+	unlock()
+	if err := tracker.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok := c.Get(key)
+	assert.True(t, ok, "the item of the new injection should not be recovered by the retry of the old one")
+	assert.Equal(t, 1, len(c.GetAll()))
+}
+
+func TestRecoverWithHealthVerification(t *testing.T) {
+	dataItems := []struct {
+		message           string
+		health            v1.HealthCheckResponse_ServingStatus
+		expectedStatus    string
+		expectedError     string
+		expectedCacheSize int
+	}{
+		{
+			message:           "Should succeed if the target is healthy after the recovery",
+			health:            v1.HealthCheckResponse_SERVING,
+			expectedStatus:    "SUCCESS",
+			expectedCacheSize: 0,
+		},
+		{
+			message:           "Should fail and keep the item if the target is not healthy after a successful recovery",
+			health:            v1.HealthCheckResponse_NOT_SERVING,
+			expectedStatus:    "FAILURE",
+			expectedError:     "Recovery verification failed for target {127.0.0.1}: Target {127.0.0.1} is {NOT_SERVING} after recovery",
+			expectedCacheSize: 1,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			c.Set(cache.Key{Job: "job", Target: "127.0.0.1"}, functionWithSuccessResponse())
+			connections := &network.Connections{
+				Pool: map[string]network.Connection{"127.0.0.1": &network.MockConnection{Health: dataItem.health}},
+			}
+			recoverConf := &config.Recover{VerifyHealth: true}
+			rController := NewRecoverController(nil, &controller.Options{Connections: connections, Cache: c, Locks: cache.NewKeyLocks(), Recover: recoverConf, Async: async.NewTracker(), Loggers: loggers})
+
+			messages := rController.performActionBasedOnOptions(Options{RecoverAll: true})
+
+			assert.Equal(t, dataItem.expectedStatus, messages[0].Status)
+			assert.Equal(t, dataItem.expectedError, messages[0].Error)
+			assert.Equal(t, dataItem.expectedCacheSize, c.ItemCount())
+		})
+	}
+}
+
+func TestRecoverWithHealthVerificationOnTheBotPort(t *testing.T) {
+	c := gocache.New(0)
+	c.Set(cache.Key{Job: "job", Target: "127.0.0.1:8080"}, functionWithSuccessResponse())
+	jobs := map[string]*config.Job{"job": {FailureType: config.CPU, Target: []string{"127.0.0.1:8080"}, BotPort: "9000"}}
+	connections := &network.Connections{
+		Pool: map[string]network.Connection{"127.0.0.1:9000": &network.MockConnection{Health: v1.HealthCheckResponse_SERVING}},
+	}
+	recoverConf := &config.Recover{VerifyHealth: true}
+	rController := NewRecoverController(jobs, &controller.Options{Connections: connections, Cache: c, Locks: cache.NewKeyLocks(), Recover: recoverConf, Async: async.NewTracker(), Loggers: loggers})
+
+	messages := rController.performActionBasedOnOptions(Options{RecoverAll: true})
+
+	assert.Equal(t, "SUCCESS", messages[0].Status)
+	assert.Equal(t, 0, c.ItemCount())
+}
+
+func TestRecoverTreatsUnreachableTargetsAsRecovered(t *testing.T) {
+	dataItems := []struct {
+		message           string
+		treatAsRecovered  bool
+		function          func() (*v1.StatusResponse, error)
+		expectedStatus    string
+		expectedCacheSize int
+	}{
+		{
+			message:           "Should drop the item of an unreachable target and count it as recovered",
+			treatAsRecovered:  true,
+			function:          functionWithUnreachableTarget(),
+			expectedStatus:    "SUCCESS",
+			expectedCacheSize: 0,
+		},
+		{
+			message:           "Should keep the item of an unreachable target if it is not treated as recovered",
+			function:          functionWithUnreachableTarget(),
+			expectedStatus:    "FAILURE",
+			expectedCacheSize: 1,
+		},
+		{
+			message:           "Should keep the item of a reachable target whose recovery failed",
+			treatAsRecovered:  true,
+			function:          functionWithErrorResponse(),
+			expectedStatus:    "FAILURE",
+			expectedCacheSize: 1,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			c.Set(cache.Key{Job: "job", Target: "127.0.0.1"}, dataItem.function)
+			recoverConf := &config.Recover{TreatUnreachableAsRecovered: dataItem.treatAsRecovered}
+			rController := NewRecoverController(nil, &controller.Options{Cache: c, Locks: cache.NewKeyLocks(), Recover: recoverConf, Async: async.NewTracker(), Loggers: loggers})
+
+			results := rController.performActionBasedOnOptions(Options{RecoverAll: true})
+
+			if assert.Equal(t, 1, len(results)) {
+				assert.Equal(t, dataItem.expectedStatus, results[0].Status)
+			}
+			assert.Equal(t, dataItem.expectedCacheSize, c.ItemCount())
+		})
+	}
+}
+
+func TestRecoverExpiredRecoversTheItemsThatAreDue(t *testing.T) {
+	c := gocache.New(0)
+	injections := cache.NewInjections()
+	due := map[cache.Key]func() (*v1.StatusResponse, error){
+		cache.Key{Job: "job", Target: "127.0.0.1"}: functionWithSuccessResponse(),
+		cache.Key{Job: "job", Target: "127.0.0.2"}: functionFailingOnce(),
+	}
+	for key, function := range due {
+		injection := &cache.Injection{StartsAt: time.Now()}
+		c.Set(key, function)
+		injections.Record(key, injection)
+		injections.AutoRecover(key, injection, time.Second, func() {})
+	}
+	notDue := cache.Key{Job: "job", Target: "127.0.0.3"}
+	notDueInjection := &cache.Injection{StartsAt: time.Now()}
+	c.Set(notDue, functionWithSuccessResponse())
+	injections.Record(notDue, notDueInjection)
+	injections.AutoRecover(notDue, notDueInjection, time.Hour, func() {})
+
+	rController := NewRecoverController(nil, &controller.Options{Cache: c, Locks: cache.NewKeyLocks(), Injections: injections, Async: async.NewTracker(), Loggers: loggers})
+	now := time.Now().Add(time.Minute)
+
+	rController.RecoverExpired(now)
+	_, ok := c.Get(cache.Key{Job: "job", Target: "127.0.0.2"})
+	assert.True(t, ok, "the item whose recovery failed should be kept")
+	assert.Equal(t, 2, c.ItemCount())
+
+	rController.RecoverExpired(now)
+	_, ok = c.Get(notDue)
+	assert.True(t, ok, "the item that is not due should not be recovered")
+	assert.Equal(t, 1, c.ItemCount(), "the item whose recovery failed should be recovered on the next call")
+	assert.ElementsMatch(t, []cache.Key{}, injections.Expired(now), "the recovered items should no longer be due")
+}
+
+func functionWithUnreachableTarget() func() (*v1.StatusResponse, error) {
+	return func() (*v1.StatusResponse, error) {
+		return nil, status.Error(codes.Unavailable, "connection refused")
+	}
+}
+
+func functionFailingOnce() func() (*v1.StatusResponse, error) {
+	calls := 0
+	return func() (*v1.StatusResponse, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("bot unavailable")
+		}
+		return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+	}
+}
@@ -3,10 +3,13 @@ package recover
 import (
 	"errors"
 	"fmt"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
 )
 
 type RequestPayload struct {
-	Alerts []*Alert `json:"alerts"`
+	GroupKey string   `json:"groupKey,omitempty"`
+	Alerts   []*Alert `json:"alerts"`
 }
 
 type Alert struct {
@@ -14,10 +17,57 @@ type Alert struct {
 	Labels Options `json:"labels"`
 }
 
+// Options select the failures to recover. If the recover metadata is set, only the failures whose labels or
+// metadata contain all of its key/values are selected, and on its own it selects all the failures that contain them.
+// If the limit is positive at most limit of the failures are selected, the ones injected first
 type Options struct {
-	RecoverJob    string `json:"recoverJob,omitempty"`
-	RecoverTarget string `json:"recoverTarget,omitempty"`
-	RecoverAll    bool   `json:"recoverAll,omitempty"`
+	RecoverJob      string            `json:"recoverJob,omitempty"`
+	RecoverTarget   string            `json:"recoverTarget,omitempty"`
+	RecoverAll      bool              `json:"recoverAll,omitempty"`
+	RecoverMetadata map[string]string `json:"recoverMetadata,omitempty"`
+	Limit           int               `json:"limit,omitempty"`
+}
+
+func (options Options) matches(key cache.Key, injection *cache.Injection) bool {
+	if !options.matchesMetadata(injection) {
+		return false
+	}
+
+	switch {
+	case options.RecoverAll:
+		return true
+	case options.RecoverJob != "":
+		return key.Job == options.RecoverJob
+	case options.RecoverTarget != "":
+		return key.Target == options.RecoverTarget
+	}
+
+	return len(options.RecoverMetadata) > 0
+}
+
+// allows returns true if the options can select one more failure, after selecting the number of failures
+func (options Options) allows(selected int) bool {
+	return options.Limit <= 0 || selected < options.Limit
+}
+
+// matchesMetadata returns true if every key/value of the recover metadata is a label or metadata of the injection
+func (options Options) matchesMetadata(injection *cache.Injection) bool {
+	if len(options.RecoverMetadata) == 0 {
+		return true
+	}
+	if injection == nil {
+		return false
+	}
+
+	for name, value := range options.RecoverMetadata {
+		label, isLabel := injection.Labels[name]
+		metadata, isMetadata := injection.Metadata[name]
+		if !(isLabel && label == value) && !(isMetadata && metadata == value) {
+			return false
+		}
+	}
+
+	return true
 }
 
 type alertStatus int
@@ -3,7 +3,9 @@ package recover
 import (
 	"encoding/json"
 	"net/http"
+	"sort"
 
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
 	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
 )
 
@@ -12,30 +14,148 @@ import (
 // @Description Alertmanager webhook to recover from failures
 // @Tags Recover
 // @Accept json
-// @Produce json
+// @Produce json,application/x-ndjson
+// @Param detailed query bool false "Return the job, target, status, error and duration of every recovery"
 // @Param RequestPayload body RequestPayload true "Create request payload that contains the recovery details"
 // @Success 200 {object} response.RecoverResponsePayload
-// @Failure 400 {string} http.Error
+// @Success 200 {object} response.DetailedRecoverResponsePayload "With detailed=true"
+// @Failure 400 {object} response.ErrorPayload
 // @Router /recover/alertmanager [post]
 func (rController *RController) RecoverActionAlertmanagerWebHook(w http.ResponseWriter, r *http.Request) {
-	recoverMessages := make([]*response.RecoverMessage, 0)
+	results := make([]*response.RecoverResult, 0)
 
 	requestPayload := &RequestPayload{}
 	err := json.NewDecoder(r.Body).Decode(&requestPayload)
 	if err != nil {
-		response.BadRequest(w, "Could not decode request body", rController.loggers)
+		response.BadRequest(w, r, "Could not decode request body", rController.loggers)
+		return
+	}
+
+	if response.AcceptsNDJSON(r) {
+		rController.streamAlerts(w, r, requestPayload.Alerts)
 		return
 	}
 
+	limit := rController.newWebhookLimit()
 	for _, alert := range requestPayload.Alerts {
 		status, err := toStatusEnum(alert.Status)
 		if err != nil {
-			response.BadRequest(w, err.Error(), rController.loggers)
+			response.BadRequest(w, r, err.Error(), rController.loggers)
 			return
 		} else if status == firing {
-			recoverMessages = rController.performActionBasedOnOptions(alert.Labels)
+			results = append(results, rController.performLimitedActionBasedOnOptions(limit, alert.Labels)...)
+		}
+	}
+
+	groupKey := ""
+	if rController.echoGroupKey {
+		groupKey = requestPayload.GroupKey
+	}
+	rController.respond(w, r, results, groupKey)
+}
+
+// streamAlerts validates the status of all the alerts before the stream starts, since a
+// bad request can not be reported after the first recover message is sent
+func (rController *RController) streamAlerts(w http.ResponseWriter, r *http.Request, alerts []*Alert) {
+	firingLabels, err := labelsOfFiringAlerts(alerts)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), rController.loggers)
+		return
+	}
+
+	stream := response.NewRecoverStream(w, isDetailed(r), rController.loggers)
+	limit := rController.newWebhookLimit()
+	for _, labels := range firingLabels {
+		rController.streamActionBasedOnOptions(stream, limit, labels)
+	}
+}
+
+// SimulateAlertmanagerWebHook godoc
+// @Summary Simulate an alert
+// @Description Recover the failures of the options as the Alertmanager webhook would for an alert with the status, to test the webhook without an Alertmanager. Firing alerts recover the failures and resolved alerts do not
+// @Tags Recover
+// @Accept json
+// @Produce json
+// @Param status query string true "The status of the simulated alert" Enums(firing, resolved)
+// @Param detailed query bool false "Return the job, target, status, error and duration of every recovery"
+// @Param Options body Options true "Create request payload that contains the recovery details"
+// @Success 200 {object} response.RecoverResponsePayload
+// @Success 200 {object} response.DetailedRecoverResponsePayload "With detailed=true"
+// @Failure 400 {object} response.ErrorPayload
+// @Router /recover/simulate [post]
+func (rController *RController) SimulateAlertmanagerWebHook(w http.ResponseWriter, r *http.Request) {
+	status, err := toStatusEnum(r.URL.Query().Get("status"))
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), rController.loggers)
+		return
+	}
+
+	options, err := decodeOptions(r.Body)
+	if err != nil {
+		response.BadRequest(w, r, "Could not decode request body", rController.loggers)
+		return
+	}
+
+	results := make([]*response.RecoverResult, 0)
+	if status == firing {
+		results = rController.performLimitedActionBasedOnOptions(rController.newWebhookLimit(), options...)
+	}
+
+	rController.respond(w, r, results, "")
+}
+
+// MatchResponsePayload contains the keys of the cache that would be recovered by the alerts
+type MatchResponsePayload struct {
+	Keys   []cache.Key `json:"keys"`
+	Status int         `json:"status"`
+}
+
+// MatchAlertmanagerWebHook godoc
+// @Summary Match failures to alerts
+// @Description Return the failures that the Alertmanager webhook would recover for the alerts, without recovering them
+// @Tags Recover
+// @Accept json
+// @Produce json
+// @Param RequestPayload body RequestPayload true "Create request payload that contains the recovery details"
+// @Success 200 {object} MatchResponsePayload
+// @Failure 400 {object} response.ErrorPayload
+// @Router /recover/match [post]
+func (rController *RController) MatchAlertmanagerWebHook(w http.ResponseWriter, r *http.Request) {
+	requestPayload := &RequestPayload{}
+	err := json.NewDecoder(r.Body).Decode(&requestPayload)
+	if err != nil {
+		response.BadRequest(w, r, "Could not decode request body", rController.loggers)
+		return
+	}
+
+	firingLabels, err := labelsOfFiringAlerts(requestPayload.Alerts)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), rController.loggers)
+		return
+	}
+
+	keys := make([]cache.Key, 0)
+	for _, item := range selectItems(rController.cache.GetAll(), firingLabels, rController.injections) {
+		keys = append(keys, item.Key.(cache.Key))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	response.JSONResponse(w, r, http.StatusOK, &MatchResponsePayload{Keys: keys, Status: http.StatusOK}, rController.loggers)
+}
+
+// labelsOfFiringAlerts returns the recover options of the alerts that are firing, or an error if the status of any alert is not supported
+func labelsOfFiringAlerts(alerts []*Alert) ([]Options, error) {
+	firingLabels := make([]Options, 0, len(alerts))
+	for _, alert := range alerts {
+		status, err := toStatusEnum(alert.Status)
+		if err != nil {
+			return nil, err
+		} else if status == firing {
+			firingLabels = append(firingLabels, alert.Labels)
 		}
 	}
 
-	response.RecoverResponse(w, recoverMessages, rController.loggers)
+	return firingLabels, nil
 }
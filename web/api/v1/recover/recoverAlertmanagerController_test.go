@@ -5,12 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"sort"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/SotirisAlfonsos/gocache"
 
@@ -220,6 +221,246 @@ func TestRecoverRequestForNotFiringAlertsWithAlertmanagerWebhook(t *testing.T) {
 	}
 }
 
+func TestAlertmanagerWebhookBoundsConcurrentRecoveries(t *testing.T) {
+	cacheManager := gocache.New(0)
+	var inFlight, maxInFlight int32
+	countingFunction := func() (*v1.StatusResponse, error) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+		time.Sleep(5 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+	}
+
+	alerts := make([]*Alert, 0)
+	for job := 0; job < 5; job++ {
+		jobName := fmt.Sprintf("job %d", job)
+		for target := 0; target < 10; target++ {
+			cacheManager.Set(cache.Key{Job: jobName, Target: fmt.Sprintf("127.0.0.%d", target)}, countingFunction)
+		}
+		alerts = append(alerts, &Alert{Status: "firing", Labels: Options{RecoverJob: jobName}})
+	}
+
+	rController := &RController{cache: cacheManager, webhookConcurrency: 3, loggers: loggers}
+	router := mux.NewRouter()
+	router.HandleFunc("/recover/alertmanager", rController.RecoverActionAlertmanagerWebHook).Methods("POST")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	statusCode, _, _, err := restoreAlertmanagerWebhookPostCall(server, newRequestPayload(alerts))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, statusCode)
+	assert.Equal(t, 0, cacheManager.ItemCount(), "all the items of all the alerts should be recovered")
+	assert.True(t, atomic.LoadInt32(&maxInFlight) <= 3, "there should be at most 3 concurrent recoveries, got %d", maxInFlight)
+	assert.True(t, atomic.LoadInt32(&maxInFlight) > 1, "the recoveries should still run concurrently")
+}
+
+func TestAlertmanagerWebhookEchoesTheGroupKey(t *testing.T) {
+	dataItems := []struct {
+		message          string
+		echoGroupKey     bool
+		detailed         bool
+		expectedGroupKey string
+	}{
+		{
+			message:          "Should return the group key of the webhook if it is echoed",
+			echoGroupKey:     true,
+			expectedGroupKey: `{}:{alertname="ChaosRecover"}`,
+		},
+		{
+			message:          "Should return the group key of the webhook with the detailed results if it is echoed",
+			echoGroupKey:     true,
+			detailed:         true,
+			expectedGroupKey: `{}:{alertname="ChaosRecover"}`,
+		},
+		{
+			message:          "Should not return the group key of the webhook if it is not echoed",
+			expectedGroupKey: "",
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			cacheManager := gocache.New(0)
+			cacheManager.Set(cache.Key{Job: "job name", Target: "127.0.0.1"}, functionWithSuccessResponse())
+
+			rController := &RController{cache: cacheManager, echoGroupKey: dataItem.echoGroupKey, loggers: loggers}
+			router := mux.NewRouter()
+			router.HandleFunc("/recover/alertmanager", rController.RecoverActionAlertmanagerWebHook).Methods("POST")
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			requestPayload := newRequestPayload([]*Alert{{Status: "firing", Labels: Options{RecoverAll: true}}})
+			requestPayload.GroupKey = `{}:{alertname="ChaosRecover"}`
+			requestBody, _ := json.Marshal(requestPayload)
+			resp, err := http.Post(fmt.Sprintf("%s/recover/alertmanager?detailed=%t", server.URL, dataItem.detailed), "", bytes.NewReader(requestBody)) //nolint:gosec
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			payload := &struct {
+				GroupKey string `json:"groupKey"`
+			}{}
+			if err = json.NewDecoder(resp.Body).Decode(payload); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, dataItem.expectedGroupKey, payload.GroupKey)
+			assert.Equal(t, 0, cacheManager.ItemCount())
+		})
+	}
+}
+
+func TestMatchReturnsTheItemsThatAlertsWouldRecover(t *testing.T) {
+	dataItems := []struct {
+		message      string
+		alerts       []*Alert
+		expectedKeys []cache.Key
+	}{
+		{
+			message:      "Should match all the items for a firing recover all alert",
+			alerts:       []*Alert{{Status: "firing", Labels: Options{RecoverAll: true}}},
+			expectedKeys: []cache.Key{{Job: "job", Target: "127.0.0.1"}, {Job: "job", Target: "127.0.0.2"}, {Job: "other job", Target: "127.0.0.1"}},
+		},
+		{
+			message:      "Should match the items of the job for a firing recover job alert",
+			alerts:       []*Alert{{Status: "firing", Labels: Options{RecoverJob: "job"}}},
+			expectedKeys: []cache.Key{{Job: "job", Target: "127.0.0.1"}, {Job: "job", Target: "127.0.0.2"}},
+		},
+		{
+			message:      "Should match the items of the target for a firing recover target alert",
+			alerts:       []*Alert{{Status: "firing", Labels: Options{RecoverTarget: "127.0.0.1"}}},
+			expectedKeys: []cache.Key{{Job: "job", Target: "127.0.0.1"}, {Job: "other job", Target: "127.0.0.1"}},
+		},
+		{
+			message:      "Should match every item once for alerts that match the same items",
+			alerts:       []*Alert{{Status: "firing", Labels: Options{RecoverJob: "other job"}}, {Status: "firing", Labels: Options{RecoverTarget: "127.0.0.1"}}},
+			expectedKeys: []cache.Key{{Job: "job", Target: "127.0.0.1"}, {Job: "other job", Target: "127.0.0.1"}},
+		},
+		{
+			message:      "Should not match any item for a resolved alert",
+			alerts:       []*Alert{{Status: "resolved", Labels: Options{RecoverAll: true}}},
+			expectedKeys: []cache.Key{},
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			server, err := recoverHTTPTestServerWithCacheItems(c, map[cache.Key]func() (*v1.StatusResponse, error){
+				cache.Key{Job: "job", Target: "127.0.0.1"}:       functionWithSuccessResponse(),
+				cache.Key{Job: "job", Target: "127.0.0.2"}:       functionWithSuccessResponse(),
+				cache.Key{Job: "other job", Target: "127.0.0.1"}: functionWithSuccessResponse(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			requestBody, _ := json.Marshal(newRequestPayload(dataItem.alerts))
+			resp, err := http.Post(server.URL+"/recover/match", "", bytes.NewReader(requestBody)) //nolint:gosec
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			payload := &MatchResponsePayload{}
+			if err = json.NewDecoder(resp.Body).Decode(payload); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+			assert.Equal(t, dataItem.expectedKeys, payload.Keys)
+			assert.Equal(t, 3, c.ItemCount(), "no item should be recovered")
+		})
+	}
+}
+
+func TestMatchRejectsUnsupportedAlertStatus(t *testing.T) {
+	server, err := recoverHTTPTestServerWithCacheItems(gocache.New(0), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	requestBody, _ := json.Marshal(newRequestPayload([]*Alert{{Status: "pending", Labels: Options{RecoverAll: true}}}))
+	status, message, _, err := post(requestBody, server.URL+"/recover/match")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "The status {pending} is not supported", message)
+}
+
+func TestSimulateRecoversAsTheAlertmanagerWebhook(t *testing.T) {
+	dataItems := []struct {
+		message           string
+		status            string
+		expectedStatus    int
+		expectedMessage   string
+		expectedRecovered int
+		expectedCacheSize int
+	}{
+		{
+			message:           "Should recover the failures of the options for a firing alert",
+			status:            "firing",
+			expectedStatus:    http.StatusOK,
+			expectedRecovered: 2,
+			expectedCacheSize: 1,
+		},
+		{
+			message:           "Should not recover any failure for a resolved alert",
+			status:            "resolved",
+			expectedStatus:    http.StatusOK,
+			expectedCacheSize: 3,
+		},
+		{
+			message:           "Should receive bad request for an unsupported status",
+			status:            "pending",
+			expectedStatus:    http.StatusBadRequest,
+			expectedMessage:   "The status {pending} is not supported",
+			expectedCacheSize: 3,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			server, err := recoverHTTPTestServerWithCacheItems(c, map[cache.Key]func() (*v1.StatusResponse, error){
+				cache.Key{Job: "job", Target: "127.0.0.1"}:       functionWithSuccessResponse(),
+				cache.Key{Job: "job", Target: "127.0.0.2"}:       functionWithSuccessResponse(),
+				cache.Key{Job: "other job", Target: "127.0.0.1"}: functionWithSuccessResponse(),
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			requestBody, _ := json.Marshal(&Options{RecoverJob: "job"})
+			status, message, recoverMessages, err := post(requestBody, server.URL+"/recover/simulate?status="+dataItem.status)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, dataItem.expectedStatus, status)
+			assert.Equal(t, dataItem.expectedMessage, message)
+			assert.Equal(t, dataItem.expectedRecovered, len(recoverMessages))
+			assert.Equal(t, dataItem.expectedCacheSize, c.ItemCount())
+		})
+	}
+}
+
 func assertSuccessfulRecoveryWithAlertmanagerWebhook(t *testing.T, dataItem TestData) {
 	t.Run(dataItem.message, func(t *testing.T) {
 		cacheManager := gocache.New(0)
@@ -255,7 +496,7 @@ func recoverResponse(status int, statuses ...string) *responseWrapper {
 
 func badRequestResponse(message string) *responseWrapper {
 	return &responseWrapper{
-		httpErrMessage: message + "\n",
+		httpErrMessage: message,
 		status:         400,
 	}
 }
@@ -296,6 +537,14 @@ func recoverHTTPTestServerWithCacheItems(
 		Methods("POST")
 	router.HandleFunc("/recover/alertmanager", rController.RecoverActionAlertmanagerWebHook).
 		Methods("POST")
+	router.HandleFunc("/recover/job/{job:.+}", rController.RecoverJobAction).
+		Methods("POST")
+	router.HandleFunc("/recover/target/{target:.+}", rController.RecoverTargetAction).
+		Methods("POST")
+	router.HandleFunc("/recover/match", rController.MatchAlertmanagerWebHook).
+		Methods("POST")
+	router.HandleFunc("/recover/simulate", rController.SimulateAlertmanagerWebHook).
+		Methods("POST")
 
 	return httptest.NewServer(router), nil
 }
@@ -321,8 +570,12 @@ func post(requestBody []byte, url string) (int, string, []*response.RecoverMessa
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-		b, _ := ioutil.ReadAll(resp.Body)
-		return resp.StatusCode, string(b), nil, nil
+		errPayload := &response.ErrorPayload{}
+		err = json.NewDecoder(resp.Body).Decode(&errPayload)
+		if err != nil {
+			return resp.StatusCode, "", nil, err
+		}
+		return errPayload.Status, errPayload.Error, nil, nil
 	}
 
 	respPayload := &response.RecoverResponsePayload{}
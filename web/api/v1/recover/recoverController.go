@@ -1,33 +1,90 @@
 package recover
 
 import (
+	"bytes"
 	"encoding/json"
+	"io"
 	"net/http"
 
 	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/gorilla/mux"
 )
 
 // RecoverAction godoc
 // @Summary recover from failures
-// @Description Recover from failures endpoint
+// @Description Recover from failures endpoint. Accepts either a single set of options or an array of options. Failures matching more than one of the options are recovered once. With the header Accept: application/x-ndjson every recover message is streamed as a line of json as soon as its recovery completes. With detailed=true the job, target and duration of every recovery are included
 // @Tags Recover
 // @Accept json
-// @Produce json
+// @Produce json,application/x-ndjson
+// @Param detailed query bool false "Return the job, target, status, error and duration of every recovery"
 // @Param Options body Options true "Create request payload that contains the recovery details"
 // @Success 200 {object} response.RecoverResponsePayload
-// @Failure 400 {string} http.Error
+// @Success 200 {object} response.DetailedRecoverResponsePayload "With detailed=true"
+// @Failure 400 {object} response.ErrorPayload
 // @Router /recover [post]
 func (rController *RController) RecoverAction(w http.ResponseWriter, r *http.Request) {
-	recoverMessages := make([]*response.RecoverMessage, 0)
-
-	request := &Options{}
-	err := json.NewDecoder(r.Body).Decode(&request)
+	options, err := decodeOptions(r.Body)
 	if err != nil {
-		response.BadRequest(w, "Could not decode request body", rController.loggers)
+		response.BadRequest(w, r, "Could not decode request body", rController.loggers)
+		return
+	}
+
+	rController.recoverOptions(w, r, options...)
+}
+
+// RecoverJobAction godoc
+// @Summary recover the failures of a job
+// @Description Recover all the failures of the job of the path, without a request body
+// @Tags Recover
+// @Produce json,application/x-ndjson
+// @Param job path string true "The name of the job"
+// @Param detailed query bool false "Return the job, target, status, error and duration of every recovery"
+// @Success 200 {object} response.RecoverResponsePayload
+// @Success 200 {object} response.DetailedRecoverResponsePayload "With detailed=true"
+// @Router /recover/job/{job} [post]
+func (rController *RController) RecoverJobAction(w http.ResponseWriter, r *http.Request) {
+	rController.recoverOptions(w, r, Options{RecoverJob: mux.Vars(r)["job"]})
+}
+
+// RecoverTargetAction godoc
+// @Summary recover the failures of a target
+// @Description Recover all the failures of the target of the path, without a request body
+// @Tags Recover
+// @Produce json,application/x-ndjson
+// @Param target path string true "The target"
+// @Param detailed query bool false "Return the job, target, status, error and duration of every recovery"
+// @Success 200 {object} response.RecoverResponsePayload
+// @Success 200 {object} response.DetailedRecoverResponsePayload "With detailed=true"
+// @Router /recover/target/{target} [post]
+func (rController *RController) RecoverTargetAction(w http.ResponseWriter, r *http.Request) {
+	rController.recoverOptions(w, r, Options{RecoverTarget: mux.Vars(r)["target"]})
+}
+
+// recoverOptions recovers the failures of the options, and streams the recover messages if the client accepts ndjson
+func (rController *RController) recoverOptions(w http.ResponseWriter, r *http.Request, options ...Options) {
+	if response.AcceptsNDJSON(r) {
+		rController.streamActionBasedOnOptions(response.NewRecoverStream(w, isDetailed(r), rController.loggers), nil, options...)
 		return
 	}
 
-	recoverMessages = rController.performActionBasedOnOptions(*request)
+	results := rController.performActionBasedOnOptions(options...)
+
+	rController.respond(w, r, results, "")
+}
+
+func decodeOptions(body io.Reader) ([]Options, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(body).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		options := make([]Options, 0)
+		err := json.Unmarshal(raw, &options)
+		return options, err
+	}
 
-	response.RecoverResponse(w, recoverMessages, rController.loggers)
+	option := Options{}
+	err := json.Unmarshal(raw, &option)
+	return []Options{option}, err
 }
@@ -1,16 +1,29 @@
 package recover
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"net/http"
 	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/SotirisAlfonsos/gocache"
 
 	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/limiter"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/controller"
 	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type RecoverTestData struct {
@@ -130,6 +143,399 @@ func TestRecoverTargetRequestSuccess(t *testing.T) { //nolint:dupl
 	}
 }
 
+func TestRecoverRequestOnThePathOfAJobOrATarget(t *testing.T) {
+	dataItems := []struct {
+		message    string
+		path       string
+		cacheItems map[cache.Key]func() (*v1.StatusResponse, error)
+		expected   *expectedResult
+	}{
+		{
+			message: "Successfully recover all items from cache for the job of the path, while not removing other jobs",
+			path:    "/recover/job/job%20name",
+			cacheItems: map[cache.Key]func() (*v1.StatusResponse, error){
+				cache.Key{Job: "job name", Target: "127.0.0.1"}:           functionWithSuccessResponse(),
+				cache.Key{Job: "job name", Target: "127.0.0.2"}:           functionWithFailureResponse(),
+				cache.Key{Job: "job different name", Target: "127.0.0.1"}: functionWithSuccessResponse(),
+			},
+			expected: &expectedResult{cacheSize: 2, response: recoverResponse(500, "SUCCESS", "FAILURE")},
+		},
+		{
+			message: "Successfully recover all items from cache for the target of the path, while not removing other targets",
+			path:    "/recover/target/127.0.0.1",
+			cacheItems: map[cache.Key]func() (*v1.StatusResponse, error){
+				cache.Key{Job: "job name", Target: "127.0.0.1"}:           functionWithSuccessResponse(),
+				cache.Key{Job: "job different name", Target: "127.0.0.1"}: functionWithSuccessResponse(),
+				cache.Key{Job: "job different name", Target: "127.0.0.2"}: functionWithSuccessResponse(),
+			},
+			expected: &expectedResult{cacheSize: 1, response: recoverResponse(200, "SUCCESS", "SUCCESS")},
+		},
+		{
+			message: "Successfully recover the items of a job of the path that contains a slash",
+			path:    "/recover/job/team/job",
+			cacheItems: map[cache.Key]func() (*v1.StatusResponse, error){
+				cache.Key{Job: "team/job", Target: "127.0.0.1"}: functionWithSuccessResponse(),
+				cache.Key{Job: "job", Target: "127.0.0.1"}:      functionWithSuccessResponse(),
+			},
+			expected: &expectedResult{cacheSize: 1, response: recoverResponse(200, "SUCCESS")},
+		},
+		{
+			message: "Should not do anything and return ok for a job of the path not in cache",
+			path:    "/recover/job/job%20name",
+			cacheItems: map[cache.Key]func() (*v1.StatusResponse, error){
+				cache.Key{Job: "job different name", Target: "127.0.0.1"}: functionWithSuccessResponse(),
+			},
+			expected: &expectedResult{cacheSize: 1, response: recoverResponse(200)},
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			cacheManager := gocache.New(0)
+			server, err := recoverHTTPTestServerWithCacheItems(cacheManager, dataItem.cacheItems)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			status, _, recoverMessages, err := post(nil, server.URL+dataItem.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, dataItem.expected.response.status, status)
+			assert.Equal(t, getSortedStatuses(dataItem.expected.response.recoverMessages), getSortedStatuses(recoverMessages))
+			assert.Equal(t, dataItem.expected.cacheSize, cacheManager.ItemCount())
+		})
+	}
+}
+
+func TestRecoverRequestWithLimitRecoversTheOldestItemsFirst(t *testing.T) {
+	cacheManager := gocache.New(0)
+	injections := cache.NewInjections()
+	now := time.Now()
+	for i, target := range []string{"127.0.0.3", "127.0.0.1", "127.0.0.2"} {
+		key := cache.Key{Job: "job name", Target: target}
+		cacheManager.Set(key, functionWithSuccessResponse())
+		injections.Record(key, &cache.Injection{StartsAt: now.Add(time.Duration(i) * time.Minute)})
+	}
+	cacheManager.Set(cache.Key{Job: "job different name", Target: "127.0.0.1"}, functionWithSuccessResponse())
+
+	rController := &RController{cache: cacheManager, injections: injections, loggers: loggers}
+	router := mux.NewRouter()
+	router.HandleFunc("/recover", rController.RecoverAction).Methods("POST")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	calls := []struct {
+		recovered int
+		remaining []string
+	}{
+		{recovered: 2, remaining: []string{"127.0.0.2"}},
+		{recovered: 1, remaining: []string{}},
+	}
+	for _, call := range calls {
+		status, _, recoverMessages, err := restorePostCall(server, &Options{RecoverJob: "job name", Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		assert.Equal(t, 200, status)
+		assert.Equal(t, call.recovered, len(recoverMessages))
+		targets := make([]string, 0)
+		for _, item := range cacheManager.GetAll() {
+			if key := item.Key.(cache.Key); key.Job == "job name" {
+				targets = append(targets, key.Target)
+			}
+		}
+		assert.ElementsMatch(t, call.remaining, targets, "the items injected first should be recovered first")
+		assert.Equal(t, len(call.remaining)+1, cacheManager.ItemCount(), "the items of other jobs should not be recovered")
+	}
+}
+
+func TestRecoverAllReturnsAMessageForEveryItem(t *testing.T) {
+	for run := 0; run < 20; run++ {
+		cacheManager := gocache.New(0)
+		for i := 0; i < 50; i++ {
+			cacheManager.Set(cache.Key{Job: fmt.Sprintf("job %d", i%5), Target: fmt.Sprintf("127.0.0.%d", i)}, functionWithSuccessResponse())
+		}
+		rController := &RController{cache: cacheManager, loggers: loggers}
+
+		results := rController.performActionBasedOnOptions(Options{RecoverAll: true})
+
+		assert.Equal(t, 50, len(results), "there should be a recover message for every item")
+		assert.Equal(t, 0, cacheManager.ItemCount())
+	}
+}
+
+func TestRecoverAllLimitsTheRecoveriesInFlightPerTarget(t *testing.T) {
+	cacheManager := gocache.New(0)
+	var mutex sync.Mutex
+	inFlight := make(map[string]int)
+	maxInFlight := make(map[string]int)
+	instrumentedFunction := func(target string) func() (*v1.StatusResponse, error) {
+		return func() (*v1.StatusResponse, error) {
+			mutex.Lock()
+			inFlight[target]++
+			if inFlight[target] > maxInFlight[target] {
+				maxInFlight[target] = inFlight[target]
+			}
+			mutex.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mutex.Lock()
+			inFlight[target]--
+			mutex.Unlock()
+			return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+		}
+	}
+	for i := 0; i < 12; i++ {
+		target := fmt.Sprintf("127.0.0.%d", i%2)
+		cacheManager.Set(cache.Key{Job: fmt.Sprintf("job %d", i), Target: target}, instrumentedFunction(target))
+	}
+	rController := &RController{cache: cacheManager, limiter: limiter.New(2), loggers: loggers}
+
+	results := rController.performActionBasedOnOptions(Options{RecoverAll: true})
+
+	assert.Equal(t, 12, len(results), "there should be a recover message for every item")
+	assert.Equal(t, map[string]int{"127.0.0.0": 2, "127.0.0.1": 2}, maxInFlight, "the recoveries in flight to a target should not exceed the limit")
+}
+
+func TestRecoverBulkRequestRecoversUnionOfOptionsOnce(t *testing.T) {
+	cacheManager := gocache.New(0)
+	var mutex sync.Mutex
+	calls := make(map[cache.Key]int)
+	countingFunction := func(key cache.Key) func() (*v1.StatusResponse, error) {
+		return func() (*v1.StatusResponse, error) {
+			mutex.Lock()
+			defer mutex.Unlock()
+			calls[key]++
+			return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+		}
+	}
+
+	keys := []cache.Key{
+		{Job: "job A", Target: "127.0.0.1"},
+		{Job: "job A", Target: "127.0.0.2"},
+		{Job: "job B", Target: "127.0.0.2"},
+		{Job: "job C", Target: "127.0.0.3"},
+		{Job: "job D", Target: "127.0.0.4"},
+	}
+	cacheItems := make(map[cache.Key]func() (*v1.StatusResponse, error))
+	for _, key := range keys {
+		cacheItems[key] = countingFunction(key)
+	}
+
+	server, err := recoverHTTPTestServerWithCacheItems(cacheManager, cacheItems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	options := []*Options{
+		{RecoverJob: "job A"},
+		{RecoverTarget: "127.0.0.2"},
+		{RecoverJob: "job C"},
+	}
+	request, _ := json.Marshal(options)
+	statusCode, _, recoverMessages, err := post(request, server.URL+"/recover")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, statusCode)
+	assert.Equal(t, 4, len(recoverMessages))
+	assert.Equal(t, 1, cacheManager.ItemCount())
+	assert.Equal(t, map[cache.Key]int{
+		{Job: "job A", Target: "127.0.0.1"}: 1,
+		{Job: "job A", Target: "127.0.0.2"}: 1,
+		{Job: "job B", Target: "127.0.0.2"}: 1,
+		{Job: "job C", Target: "127.0.0.3"}: 1,
+	}, calls)
+}
+
+func TestRecoverRequestWithMetadataRecoversOnlyMatchingItems(t *testing.T) {
+	dataItems := []struct {
+		message           string
+		options           *Options
+		expectedRecovered []cache.Key
+	}{
+		{
+			message:           "Should recover the items whose labels contain the metadata",
+			options:           &Options{RecoverMetadata: map[string]string{"experiment": "load-test-3"}},
+			expectedRecovered: []cache.Key{{Job: "job", Target: "127.0.0.1"}, {Job: "network job", Target: "127.0.0.3"}},
+		},
+		{
+			message:           "Should recover the items whose labels and metadata contain all of the metadata",
+			options:           &Options{RecoverMetadata: map[string]string{"experiment": "load-test-3", "device": "eth0"}},
+			expectedRecovered: []cache.Key{{Job: "network job", Target: "127.0.0.3"}},
+		},
+		{
+			message:           "Should recover the items of the job that contain the metadata",
+			options:           &Options{RecoverJob: "job", RecoverMetadata: map[string]string{"experiment": "load-test-3"}},
+			expectedRecovered: []cache.Key{{Job: "job", Target: "127.0.0.1"}},
+		},
+		{
+			message: "Should not recover any item if no item contains the metadata",
+			options: &Options{RecoverMetadata: map[string]string{"experiment": "load-test-4"}},
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			cacheManager := gocache.New(0)
+			injections := cache.NewInjections()
+			items := map[cache.Key]*cache.Injection{
+				{Job: "job", Target: "127.0.0.1"}:         {Labels: map[string]string{"experiment": "load-test-3"}},
+				{Job: "job", Target: "127.0.0.2"}:         {Labels: map[string]string{"experiment": "load-test-2"}},
+				{Job: "network job", Target: "127.0.0.3"}: {Labels: map[string]string{"experiment": "load-test-3"}, Metadata: map[string]string{"device": "eth0"}},
+				{Job: "other job", Target: "127.0.0.4"}:   nil,
+			}
+			for key, injection := range items {
+				cacheManager.Set(key, functionWithSuccessResponse())
+				if injection != nil {
+					injections.Record(key, injection)
+				}
+			}
+
+			rController := NewRecoverController(nil, &controller.Options{Cache: cacheManager, Locks: cache.NewKeyLocks(), Injections: injections, Async: async.NewTracker(), Loggers: loggers})
+			router := mux.NewRouter()
+			router.HandleFunc("/recover", rController.RecoverAction).Methods("POST")
+			server := httptest.NewServer(router)
+			defer server.Close()
+
+			statusCode, _, recoverMessages, err := restorePostCall(server, dataItem.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, http.StatusOK, statusCode)
+			assert.Equal(t, len(dataItem.expectedRecovered), len(recoverMessages))
+			for _, key := range dataItem.expectedRecovered {
+				_, ok := cacheManager.Get(key)
+				assert.False(t, ok, "the item %s should be recovered", key)
+			}
+			assert.Equal(t, len(items)-len(dataItem.expectedRecovered), cacheManager.ItemCount())
+		})
+	}
+}
+
+func TestRecoverAllSerializesTheRecoveriesOfEveryTarget(t *testing.T) {
+	var mu sync.Mutex
+	inFlight, maxInFlight := make(map[string]int), make(map[string]int)
+	total, maxTotal := 0, 0
+	recovery := func(target string) func() (*v1.StatusResponse, error) {
+		return func() (*v1.StatusResponse, error) {
+			mu.Lock()
+			inFlight[target]++
+			total++
+			if inFlight[target] > maxInFlight[target] {
+				maxInFlight[target] = inFlight[target]
+			}
+			if total > maxTotal {
+				maxTotal = total
+			}
+			mu.Unlock()
+
+			time.Sleep(5 * time.Millisecond)
+
+			mu.Lock()
+			inFlight[target]--
+			total--
+			mu.Unlock()
+			return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+		}
+	}
+
+	cacheManager := gocache.New(0)
+	for target := 0; target < 4; target++ {
+		for job := 0; job < 5; job++ {
+			targetName := fmt.Sprintf("127.0.0.%d", target)
+			cacheManager.Set(cache.Key{Job: fmt.Sprintf("job %d", job), Target: targetName}, recovery(targetName))
+		}
+	}
+
+	rController := NewRecoverController(nil, &controller.Options{Cache: cacheManager, Locks: cache.NewKeyLocks(), Recover: &config.Recover{SerialPerTarget: true}, Async: async.NewTracker(), Loggers: loggers})
+
+	results := rController.performActionBasedOnOptions(Options{RecoverAll: true})
+
+	assert.Equal(t, 20, len(results))
+	assert.Equal(t, 0, cacheManager.ItemCount(), "all the items should be recovered")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, 4, len(maxInFlight))
+	for target, max := range maxInFlight {
+		assert.Equal(t, 1, max, "there should be at most one recovery in flight on target %s", target)
+	}
+	assert.True(t, maxTotal > 1, "different targets should still be recovered concurrently")
+}
+
+func TestRecoverAllRecoversTheFailureTypesInTheirPriority(t *testing.T) {
+	jobs := map[string]*config.Job{
+		"service job": {FailureType: config.Service},
+		"network job": {FailureType: config.Network},
+		"cpu job":     {FailureType: config.CPU},
+		"docker job":  {FailureType: config.Docker},
+	}
+
+	var mu sync.Mutex
+	recovered := make([]config.FailureType, 0)
+	recovery := func(failureType config.FailureType) func() (*v1.StatusResponse, error) {
+		return func() (*v1.StatusResponse, error) {
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			defer mu.Unlock()
+			recovered = append(recovered, failureType)
+			return &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}, nil
+		}
+	}
+
+	cacheManager := gocache.New(0)
+	for job, conf := range jobs {
+		for target := 0; target < 3; target++ {
+			cacheManager.Set(cache.Key{Job: job, Target: fmt.Sprintf("127.0.0.%d", target)}, recovery(conf.FailureType))
+		}
+	}
+
+	rController := NewRecoverController(jobs, &controller.Options{Cache: cacheManager, Locks: cache.NewKeyLocks(), Recover: &config.Recover{TypePriority: []config.FailureType{config.Network, config.Docker}}, Async: async.NewTracker(), Loggers: loggers})
+
+	results := rController.performActionBasedOnOptions(Options{RecoverAll: true})
+
+	assert.Equal(t, 12, len(results))
+	assert.Equal(t, 0, cacheManager.ItemCount(), "all the items should be recovered")
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []config.FailureType{config.Network, config.Network, config.Network}, recovered[:3])
+	assert.Equal(t, []config.FailureType{config.Docker, config.Docker, config.Docker}, recovered[3:6])
+	assert.ElementsMatch(t, []config.FailureType{config.Service, config.Service, config.Service, config.CPU, config.CPU, config.CPU}, recovered[6:],
+		"the types without a priority should be recovered last")
+}
+
+func TestRecoverRequestErrorIncludesBotStatusCode(t *testing.T) {
+	cacheManager := gocache.New(0)
+	cacheItems := map[cache.Key]func() (*v1.StatusResponse, error){
+		cache.Key{Job: "job", Target: "127.0.0.1"}: func() (*v1.StatusResponse, error) {
+			return nil, status.Error(codes.Unavailable, "bot is unavailable")
+		},
+	}
+	server, err := recoverHTTPTestServerWithCacheItems(cacheManager, cacheItems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	statusCode, _, recoverMessages, err := restorePostCall(server, &Options{RecoverAll: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 500, statusCode)
+	assert.Equal(t, 1, len(recoverMessages))
+	assert.Equal(t, "Unavailable", recoverMessages[0].Code)
+	assert.Equal(t, 1, cacheManager.ItemCount())
+}
+
 func assertSuccessfulRecovery(t *testing.T, dataItem RecoverTestData) {
 	t.Run(dataItem.message, func(t *testing.T) {
 		cacheManager := gocache.New(0)
@@ -157,3 +563,126 @@ func restorePostCall(server *httptest.Server, options *Options) (int, string, []
 
 	return post(request, url)
 }
+
+func TestRecoverAllRequestStreamsMessages(t *testing.T) {
+	cacheManager := gocache.New(0)
+	cacheItems := make(map[cache.Key]func() (*v1.StatusResponse, error))
+	for i := 0; i < 20; i++ {
+		cacheItems[cache.Key{Job: "job", Target: fmt.Sprintf("127.0.0.%d", i)}] = functionWithSuccessResponse()
+	}
+	cacheItems[cache.Key{Job: "job", Target: "127.0.1.1"}] = functionWithFailureResponse()
+
+	server, err := recoverHTTPTestServerWithCacheItems(cacheManager, cacheItems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	requestBody, _ := json.Marshal(&Options{RecoverAll: true})
+	req, err := http.NewRequest("POST", server.URL+"/recover", bytes.NewReader(requestBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Accept", response.NDJSON)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	assert.Equal(t, 200, resp.StatusCode)
+	assert.Equal(t, response.NDJSON, resp.Header.Get("Content-Type"))
+
+	recoverMessages := make([]*response.RecoverMessage, 0)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		recoverMessage := &response.RecoverMessage{}
+		if err := json.Unmarshal(scanner.Bytes(), recoverMessage); err != nil {
+			t.Fatal(err)
+		}
+		recoverMessages = append(recoverMessages, recoverMessage)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 21, len(recoverMessages))
+	statuses := getSortedStatuses(recoverMessages)
+	assert.Equal(t, "FAILURE", statuses[0])
+	assert.Equal(t, "SUCCESS", statuses[1])
+	assert.Equal(t, 1, cacheManager.ItemCount())
+}
+
+func TestRecoverItemWithoutRecoveryFunction(t *testing.T) {
+	cacheManager := gocache.New(0)
+	var nilFunction func() (*v1.StatusResponse, error)
+	cacheItems := map[cache.Key]func() (*v1.StatusResponse, error){
+		cache.Key{Job: "job", Target: "127.0.0.1"}: nilFunction,
+		cache.Key{Job: "job", Target: "127.0.0.2"}: functionWithSuccessResponse(),
+	}
+	server, err := recoverHTTPTestServerWithCacheItems(cacheManager, cacheItems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	cacheManager.Set(cache.Key{Job: "other job", Target: "127.0.0.1"}, nil)
+
+	statusCode, _, recoverMessages, err := restorePostCall(server, &Options{RecoverAll: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 500, statusCode)
+	assert.Equal(t, []string{"FAILURE", "FAILURE", "SUCCESS"}, getSortedStatuses(recoverMessages))
+	errorMessages := make([]string, 0)
+	for _, recoverMessage := range recoverMessages {
+		if recoverMessage.Error != "" {
+			errorMessages = append(errorMessages, recoverMessage.Error)
+		}
+	}
+	assert.ElementsMatch(t, []string{"no recovery function for {job},{127.0.0.1}", "no recovery function for {other job},{127.0.0.1}"}, errorMessages)
+	assert.Equal(t, 0, cacheManager.ItemCount(), "items without recovery function should be dropped")
+}
+
+func TestRecoverAllRequestWithDetailedResults(t *testing.T) {
+	cacheManager := gocache.New(0)
+	cacheItems := map[cache.Key]func() (*v1.StatusResponse, error){
+		cache.Key{Job: "job", Target: "127.0.0.1"}:   functionWithSuccessResponse(),
+		cache.Key{Job: "job", Target: "127.0.0.2"}:   functionWithFailureResponse(),
+		cache.Key{Job: "job_1", Target: "127.0.0.1"}: functionWithErrorResponse(),
+	}
+	server, err := recoverHTTPTestServerWithCacheItems(cacheManager, cacheItems)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	requestBody, _ := json.Marshal(&Options{RecoverAll: true})
+	resp, err := http.Post(server.URL+"/recover?detailed=true", "application/json", bytes.NewReader(requestBody)) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	payload := &response.DetailedRecoverResponsePayload{}
+	if err = json.NewDecoder(resp.Body).Decode(payload); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 500, resp.StatusCode)
+	assert.Equal(t, 500, payload.Status)
+	results := make(map[string]*response.RecoverResult, len(payload.Results))
+	for _, result := range payload.Results {
+		results[result.Job+","+result.Target] = result
+		assert.True(t, result.DurationSeconds >= 0)
+	}
+	assert.Equal(t, 3, len(results), "there should be a result for every job and target")
+
+	assert.Equal(t, "SUCCESS", results["job,127.0.0.1"].Status)
+	assert.Equal(t, "", results["job,127.0.0.1"].Error)
+	assert.Equal(t, "FAILURE", results["job,127.0.0.2"].Status)
+	assert.Equal(t, "Failure response from target {127.0.0.2}", results["job,127.0.0.2"].Error)
+	assert.Equal(t, "FAILURE", results["job_1,127.0.0.1"].Status)
+	assert.Equal(t, "Error response from target {127.0.0.1}: error", results["job_1,127.0.0.1"].Error)
+}
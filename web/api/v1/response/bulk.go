@@ -0,0 +1,52 @@
+package response
+
+import (
+	"net/http"
+	"sync"
+)
+
+// TargetResult is the outcome of the action on one of the targets of a job
+type TargetResult struct {
+	Target  string `json:"target"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Status  string `json:"status"`
+}
+
+// BulkPayload contains the outcome of the action on every target of the job. The status is 500 if the action
+// failed on any of the targets, and the results still report the targets on which it succeeded
+type BulkPayload struct {
+	Results []*TargetResult `json:"results"`
+	Status  int             `json:"status"`
+}
+
+// Bulk performs the action on all the targets concurrently, and returns the results in the order of the targets
+func Bulk(targets []string, action func(target string) (string, error)) *BulkPayload {
+	results := make([]*TargetResult, len(targets))
+
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		wg.Add(1)
+		go func(i int, target string) {
+			defer wg.Done()
+			message, err := action(target)
+			results[i] = newTargetResult(target, message, err)
+		}(i, target)
+	}
+	wg.Wait()
+
+	payload := &BulkPayload{Results: results, Status: http.StatusOK}
+	for _, result := range results {
+		if result.Status == FAILURE.String() {
+			payload.Status = http.StatusInternalServerError
+		}
+	}
+	return payload
+}
+
+func newTargetResult(target string, message string, err error) *TargetResult {
+	if err != nil {
+		return &TargetResult{Target: target, Error: err.Error(), Status: FAILURE.String()}
+	}
+	return &TargetResult{Target: target, Message: message, Status: SUCCESS.String()}
+}
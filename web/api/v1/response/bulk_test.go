@@ -0,0 +1,51 @@
+package response
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBulkReportsTheResultOfEveryTarget(t *testing.T) {
+	dataItems := []struct {
+		message        string
+		failingTarget  string
+		expectedStatus int
+	}{
+		{
+			message:        "Should respond with 200 if the action succeeded on all the targets",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			message:        "Should respond with 500 and still report the successful targets if the action failed on one of them",
+			failingTarget:  "127.0.0.2",
+			expectedStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			targets := []string{"127.0.0.1", "127.0.0.2", "127.0.0.3"}
+
+			payload := Bulk(targets, func(target string) (string, error) {
+				if target == dataItem.failingTarget {
+					return "", errors.New("connection refused")
+				}
+				return "done on " + target, nil
+			})
+
+			assert.Equal(t, dataItem.expectedStatus, payload.Status)
+			assert.Equal(t, len(targets), len(payload.Results))
+			for i, result := range payload.Results {
+				assert.Equal(t, targets[i], result.Target, "the results should be in the order of the targets")
+				if result.Target == dataItem.failingTarget {
+					assert.Equal(t, &TargetResult{Target: result.Target, Error: "connection refused", Status: FAILURE.String()}, result)
+					continue
+				}
+				assert.Equal(t, &TargetResult{Target: result.Target, Message: "done on " + result.Target, Status: SUCCESS.String()}, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package response
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+type debugErrorsKey struct{}
+
+// DebugErrors is the middleware that includes the stack traces of errors in the 500 responses of the requests.
+// It should only be used for troubleshooting, since the stack traces reveal the internals of the master
+func DebugErrors(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), debugErrorsKey{}, true)))
+	})
+}
+
+func debugErrors(r *http.Request) bool {
+	if r == nil {
+		return false
+	}
+
+	debug, _ := r.Context().Value(debugErrorsKey{}).(bool)
+	return debug
+}
+
+type stackTracer interface {
+	StackTrace() errors.StackTrace
+}
+
+// stackTrace returns the stack trace of the innermost error that has one, which is where the error originated,
+// or an empty string if the error has no stack trace
+func stackTrace(err error) string {
+	var stack errors.StackTrace
+	for err != nil {
+		if tracer, ok := err.(stackTracer); ok {
+			stack = tracer.StackTrace()
+		}
+
+		causer, ok := err.(interface{ Cause() error })
+		if !ok {
+			break
+		}
+		err = causer.Cause()
+	}
+
+	if stack == nil {
+		return ""
+	}
+	return strings.TrimSpace(fmt.Sprintf("%+v", stack))
+}
@@ -0,0 +1,65 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBotErrorIncludesStackTraceInDebugMode(t *testing.T) {
+	dataItems := []struct {
+		message       string
+		debug         bool
+		expectedStack bool
+	}{
+		{
+			message:       "Should include the stack trace of the error in debug mode",
+			debug:         true,
+			expectedStack: true,
+		},
+		{
+			message:       "Should not include the stack trace of the error by default",
+			debug:         false,
+			expectedStack: false,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			var handler http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				err := errors.Wrap(failingCall(), "Error response from target {127.0.0.1}")
+				BotError(w, r, err, getLoggers())
+			})
+			if dataItem.debug {
+				handler = DebugErrors(handler)
+			}
+			w := httptest.NewRecorder()
+
+			handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/cpu", nil))
+
+			payload := &ErrorPayload{}
+			if err := json.NewDecoder(w.Body).Decode(payload); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, http.StatusInternalServerError, payload.Status)
+			assert.Equal(t, "Error response from target {127.0.0.1}: connection refused", payload.Error)
+			if dataItem.expectedStack {
+				assert.Contains(t, payload.Stack, "response.failingCall", "the trace should start where the error originated")
+			} else {
+				assert.Empty(t, payload.Stack)
+			}
+		})
+	}
+}
+
+func TestStackTraceOfErrorWithoutStack(t *testing.T) {
+	assert.Equal(t, "", stackTrace(http.ErrHandlerTimeout))
+}
+
+func failingCall() error {
+	return errors.New("connection refused")
+}
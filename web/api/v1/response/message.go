@@ -0,0 +1,52 @@
+package response
+
+import (
+	"bytes"
+	"fmt"
+	"text/template"
+)
+
+// MessageFields are the fields available to the success message template
+type MessageFields struct {
+	Target  string
+	Status  string
+	Message string
+}
+
+// MessageTemplate renders the message of a successful response from a bot.
+// A nil MessageTemplate renders the default message
+type MessageTemplate struct {
+	tmpl *template.Template
+}
+
+// NewMessageTemplate parses the success message template. It returns nil for an empty template
+func NewMessageTemplate(text string) (*MessageTemplate, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	tmpl, err := template.New("success").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &MessageTemplate{tmpl: tmpl}, nil
+}
+
+// Render returns the success message for the response of the target.
+// If the template can not be executed the default message is returned
+func (m *MessageTemplate) Render(target string, status fmt.Stringer, message string) string {
+	fields := MessageFields{Target: target, Status: status.String(), Message: message}
+	if m == nil {
+		return defaultMessage(fields)
+	}
+
+	var b bytes.Buffer
+	if err := m.tmpl.Execute(&b, fields); err != nil {
+		return defaultMessage(fields)
+	}
+	return b.String()
+}
+
+func defaultMessage(fields MessageFields) string {
+	return fmt.Sprintf("Response from target {%s}, {%s}, {%s}", fields.Target, fields.Message, fields.Status)
+}
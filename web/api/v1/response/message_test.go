@@ -0,0 +1,50 @@
+package response
+
+import (
+	"testing"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageTemplateRender(t *testing.T) {
+	dataItems := []struct {
+		message  string
+		template string
+		expected string
+	}{
+		{
+			message:  "Should render the default message when no template is set",
+			expected: "Response from target {127.0.0.1}, {container killed}, {SUCCESS}",
+		},
+		{
+			message:  "Should render the custom template",
+			template: "{{.Status}}: {{.Target}} ({{.Message}})",
+			expected: "SUCCESS: 127.0.0.1 (container killed)",
+		},
+		{
+			message:  "Should render the default message when the template can not be executed",
+			template: "{{.Unknown}}",
+			expected: "Response from target {127.0.0.1}, {container killed}, {SUCCESS}",
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			messageTemplate, err := NewMessageTemplate(dataItem.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			message := messageTemplate.Render("127.0.0.1", v1.StatusResponse_SUCCESS, "container killed")
+
+			assert.Equal(t, dataItem.expected, message)
+		})
+	}
+}
+
+func TestShouldErrorForInvalidMessageTemplate(t *testing.T) {
+	_, err := NewMessageTemplate("{{.Target")
+
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,27 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// encode returns the json of the value, indented if the request has the query parameter pretty=true
+// so that the responses are readable with curl
+func encode(r *http.Request, v interface{}) ([]byte, error) {
+	if !pretty(r) {
+		body := new(bytes.Buffer)
+		err := json.NewEncoder(body).Encode(v)
+		return body.Bytes(), err
+	}
+
+	body, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(body, '\n'), nil
+}
+
+func pretty(r *http.Request) bool {
+	return r != nil && r.URL.Query().Get("pretty") == "true"
+}
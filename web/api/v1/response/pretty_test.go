@@ -0,0 +1,67 @@
+package response
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInjectionResponseIsIndentedWithPretty(t *testing.T) {
+	dataItems := []struct {
+		message      string
+		url          string
+		expectedBody string
+	}{
+		{
+			message:      "Should respond with compact json by default",
+			url:          "/cpu?action=start",
+			expectedBody: `{"message":"CPU injection started","resolvedRequest":{"job":"cpu job"},"status":200}` + "\n",
+		},
+		{
+			message: "Should respond with indented json with pretty=true",
+			url:     "/cpu?action=start&pretty=true",
+			expectedBody: `{
+  "message": "CPU injection started",
+  "resolvedRequest": {
+    "job": "cpu job"
+  },
+  "status": 200
+}
+`,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, dataItem.url, nil)
+			w := httptest.NewRecorder()
+
+			OkResponseWithRequest(w, r, "CPU injection started", map[string]string{"job": "cpu job"}, getLoggers())
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, dataItem.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestRecoverResponseIsIndentedWithPretty(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/recover/alertmanager?pretty=true", nil)
+	w := httptest.NewRecorder()
+
+	RecoverResponse(w, r, []*RecoverMessage{SuccessRecoverResponse("recovered")}, "", getLoggers())
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, `{
+  "recoverMessages": [
+    {
+      "message": "recovered",
+      "error": "",
+      "status": "SUCCESS"
+    }
+  ],
+  "status": 200
+}
+`, w.Body.String())
+}
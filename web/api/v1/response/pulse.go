@@ -0,0 +1,57 @@
+package response
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// PulsePhase is the outcome of the injection or of the recovery of a pulse
+type PulsePhase struct {
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Status  string `json:"status"`
+}
+
+// PulsePayload contains the outcomes of both phases of a pulse. The recovery is omitted if the injection failed
+type PulsePayload struct {
+	Inject  *PulsePhase `json:"inject"`
+	Recover *PulsePhase `json:"recover,omitempty"`
+	Status  int         `json:"status"`
+}
+
+// Pulse injects a failure, holds it for the duration and recovers it. The failure is recovered even if the
+// context is done while it is held, so that a client that disconnects does not leave the failure behind
+func Pulse(
+	ctx context.Context,
+	hold time.Duration,
+	inject func(ctx context.Context) (string, error),
+	recover func(ctx context.Context) (string, error),
+) *PulsePayload {
+	payload := &PulsePayload{Status: http.StatusOK}
+
+	payload.Inject = newPulsePhase(inject(ctx))
+	if payload.Inject.Status == FAILURE.String() {
+		payload.Status = http.StatusInternalServerError
+		return payload
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(hold):
+	}
+
+	payload.Recover = newPulsePhase(recover(context.Background()))
+	if payload.Recover.Status == FAILURE.String() {
+		payload.Status = http.StatusInternalServerError
+	}
+
+	return payload
+}
+
+func newPulsePhase(message string, err error) *PulsePhase {
+	if err != nil {
+		return &PulsePhase{Error: err.Error(), Status: FAILURE.String()}
+	}
+	return &PulsePhase{Message: message, Status: SUCCESS.String()}
+}
@@ -0,0 +1,32 @@
+package response
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPulseRecoversWhenTheContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var recovered bool
+	payload := Pulse(ctx, time.Hour,
+		func(ctx context.Context) (string, error) {
+			cancel()
+			return "injected", nil
+		},
+		func(ctx context.Context) (string, error) {
+			recovered = ctx.Err() == nil
+			return "", errors.New("recovery failed")
+		},
+	)
+
+	assert.True(t, recovered, "the recovery should not use the context of the request")
+	assert.Equal(t, &PulsePhase{Message: "injected", Status: SUCCESS.String()}, payload.Inject)
+	assert.Equal(t, &PulsePhase{Error: "recovery failed", Status: FAILURE.String()}, payload.Recover)
+	assert.Equal(t, http.StatusInternalServerError, payload.Status)
+}
@@ -1,58 +1,239 @@
 package response
 
 import (
-	"bytes"
-	"encoding/json"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+	grpcStatus "google.golang.org/grpc/status"
 )
 
+// BotStatusCodeHeader is the response header that carries the gRPC status code
+// of a failed call to a bot, so that clients can distinguish transient from permanent failures
+const BotStatusCodeHeader = "X-Bot-Status-Code"
+
 type Payload struct {
-	Message string `json:"message"`
-	Status  int    `json:"status"`
+	Message         string      `json:"message"`
+	ResolvedRequest interface{} `json:"resolvedRequest,omitempty"`
+	Status          int         `json:"status"`
+}
+
+type ErrorPayload struct {
+	Error  string `json:"error"`
+	Code   string `json:"code,omitempty"`
+	Stack  string `json:"stack,omitempty"`
+	Status int    `json:"status"`
 }
 
 // The serverError helper writes an error message and stack trace to the errorLog,
 // then sends a generic 500 Internal Server Error response to the user.
-func serverError(w http.ResponseWriter, loggers chaoslogger.Loggers, message string) {
-	statusText := http.StatusText(http.StatusInternalServerError)
-	_ = level.Error(loggers.ErrLogger).Log("msg", statusText, "err", message)
+func serverError(w http.ResponseWriter, r *http.Request, loggers chaoslogger.Loggers, payload *ErrorPayload) {
+	statusText := http.StatusText(payload.Status)
+	_ = level.Error(loggers.ErrLogger).Log("msg", statusText, "err", payload.Error)
 
-	http.Error(w, message, http.StatusInternalServerError)
+	payload.writeError(w, r, loggers)
 }
 
 // The clientError helper sends a specific status code and corresponding description
 // to the user. We'll use this later in the book to send responses like 400 "Bad
 // Request" when there's a problem with the request that the user sent.
-func clientError(w http.ResponseWriter, loggers chaoslogger.Loggers, message string, status int) {
+func clientError(w http.ResponseWriter, r *http.Request, loggers chaoslogger.Loggers, message string, status int) {
 	_ = level.Info(loggers.OutLogger).Log("msg", http.StatusText(status), "warn", message)
-	http.Error(w, message, status)
+
+	payload := &ErrorPayload{Error: message, Status: status}
+	payload.writeError(w, r, loggers)
 }
 
-func BadRequest(w http.ResponseWriter, message string, loggers chaoslogger.Loggers) {
+// writeError sends the error as json, unless the client prefers plain text in the Accept header
+func (p *ErrorPayload) writeError(w http.ResponseWriter, r *http.Request, loggers chaoslogger.Loggers) {
+	if prefersPlainText(r) {
+		http.Error(w, p.Error, p.Status)
+		return
+	}
+
+	body, err := encode(r, p)
+	if err != nil {
+		_ = level.Error(loggers.ErrLogger).Log("msg", "Error when trying to encode error response to byte array", "err", err)
+		http.Error(w, p.Error, p.Status)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	_, err = w.Write(body)
+	if err != nil {
+		_ = level.Error(loggers.ErrLogger).Log("msg", "Error when trying to write error response to byte array", "err", err)
+	}
+}
+
+// prefersPlainText returns true if text/plain has a higher quality than application/json in the Accept header,
+// or the same quality and is listed first. A media range without a quality has the quality 1
+func prefersPlainText(r *http.Request) bool {
+	if r == nil {
+		return false
+	}
+
+	plainQuality, jsonQuality := -1.0, -1.0
+	plainFirst := false
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		params := strings.Split(mediaRange, ";")
+		switch strings.TrimSpace(params[0]) {
+		case "text/plain":
+			if plainQuality < 0 {
+				plainQuality = quality(params[1:])
+				plainFirst = jsonQuality < 0
+			}
+		case "application/json":
+			if jsonQuality < 0 {
+				jsonQuality = quality(params[1:])
+			}
+		}
+	}
+
+	if plainQuality <= 0 {
+		return false
+	}
+	return plainQuality > jsonQuality || (plainQuality == jsonQuality && plainFirst)
+}
+
+// quality returns the q parameter of the parameters of a media range, 1 if it is not set or not valid
+func quality(params []string) float64 {
+	for _, param := range params {
+		name, value := param, ""
+		if i := strings.Index(param, "="); i >= 0 {
+			name, value = param[:i], param[i+1:]
+		}
+		if strings.TrimSpace(name) != "q" {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || q < 0 || q > 1 {
+			return 1
+		}
+		return q
+	}
+	return 1
+}
+
+func BadRequest(w http.ResponseWriter, r *http.Request, message string, loggers chaoslogger.Loggers) {
 	status := http.StatusBadRequest
-	clientError(w, loggers, message, status)
+	clientError(w, r, loggers, message, status)
+}
+
+func Unauthorized(w http.ResponseWriter, r *http.Request, message string, loggers chaoslogger.Loggers) {
+	status := http.StatusUnauthorized
+	w.Header().Set("WWW-Authenticate", "Bearer")
+	clientError(w, r, loggers, message, status)
+}
+
+func Forbidden(w http.ResponseWriter, r *http.Request, message string, loggers chaoslogger.Loggers) {
+	status := http.StatusForbidden
+	clientError(w, r, loggers, message, status)
+}
+
+// TooManyRequests responds with 429 for an injection that is rejected because it would affect too many targets
+func TooManyRequests(w http.ResponseWriter, r *http.Request, message string, loggers chaoslogger.Loggers) {
+	status := http.StatusTooManyRequests
+	clientError(w, r, loggers, message, status)
+}
+
+// RequestEntityTooLarge responds with 413 for a request whose body exceeds the size that is read
+func RequestEntityTooLarge(w http.ResponseWriter, r *http.Request, message string, loggers chaoslogger.Loggers) {
+	status := http.StatusRequestEntityTooLarge
+	clientError(w, r, loggers, message, status)
+}
+
+// UnprocessableEntity responds with 422 for a request that is well formed but conflicts with a previous request
+func UnprocessableEntity(w http.ResponseWriter, r *http.Request, message string, loggers chaoslogger.Loggers) {
+	status := http.StatusUnprocessableEntity
+	clientError(w, r, loggers, message, status)
+}
+
+func NotFound(w http.ResponseWriter, r *http.Request, message string, loggers chaoslogger.Loggers) {
+	status := http.StatusNotFound
+	clientError(w, r, loggers, message, status)
 }
 
-func InternalServerError(w http.ResponseWriter, message string, loggers chaoslogger.Loggers) {
-	serverError(w, loggers, message)
+func InternalServerError(w http.ResponseWriter, r *http.Request, message string, loggers chaoslogger.Loggers) {
+	serverError(w, r, loggers, &ErrorPayload{Error: message, Status: http.StatusInternalServerError})
 }
 
-func OkResponse(w http.ResponseWriter, message string, loggers chaoslogger.Loggers) {
+// retryable is implemented by the errors of calls that were not attempted, and can be retried after a duration
+type retryable interface {
+	RetryAfter() time.Duration
+}
+
+// BotError responds with 500 Internal Server Error for an error that occurred while calling a bot.
+// If the error carries a gRPC status, its code is set in the BotStatusCodeHeader.
+// If the call to the bot was not attempted and can be retried later, it responds with 503 and the Retry-After header.
+// The stack trace of the error is included if the debug errors are enabled for the request
+func BotError(w http.ResponseWriter, r *http.Request, err error, loggers chaoslogger.Loggers) {
+	if retry, ok := errors.Cause(err).(retryable); ok {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry.RetryAfter().Seconds()))))
+		ServiceUnavailable(w, r, err.Error(), loggers)
+		return
+	}
+
+	code := StatusCode(err)
+	if code != "" {
+		w.Header().Set(BotStatusCodeHeader, code)
+	}
+	payload := &ErrorPayload{Error: err.Error(), Code: code, Status: http.StatusInternalServerError}
+	if debugErrors(r) {
+		payload.Stack = stackTrace(err)
+	}
+	serverError(w, r, loggers, payload)
+}
+
+// StatusCode returns the name of the gRPC status code of the error, or an empty string
+// if the error did not originate from a gRPC call
+func StatusCode(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	if s, ok := grpcStatus.FromError(errors.Cause(err)); ok {
+		return s.Code().String()
+	}
+	return ""
+}
+
+func ServiceUnavailable(w http.ResponseWriter, r *http.Request, message string, loggers chaoslogger.Loggers) {
+	status := http.StatusServiceUnavailable
+	_ = level.Warn(loggers.OutLogger).Log("msg", http.StatusText(status), "warn", message)
+
+	payload := &ErrorPayload{Error: message, Status: status}
+	payload.writeError(w, r, loggers)
+}
+
+func OkResponse(w http.ResponseWriter, r *http.Request, message string, loggers chaoslogger.Loggers) {
 	resp := &Payload{
 		Message: message,
 		Status:  200,
 	}
-	resp.SetInWriter(w, loggers)
+	resp.SetInWriter(w, r, loggers)
 }
 
-func (p *Payload) SetInWriter(w http.ResponseWriter, loggers chaoslogger.Loggers) {
-	reqBodyBytes := new(bytes.Buffer)
-	err := json.NewEncoder(reqBodyBytes).Encode(p)
+// OkResponseWithRequest responds with the message and the request that was performed after the defaults were applied
+func OkResponseWithRequest(w http.ResponseWriter, r *http.Request, message string, resolvedRequest interface{}, loggers chaoslogger.Loggers) {
+	resp := &Payload{
+		Message:         message,
+		ResolvedRequest: resolvedRequest,
+		Status:          200,
+	}
+	resp.SetInWriter(w, r, loggers)
+}
+
+func (p *Payload) SetInWriter(w http.ResponseWriter, r *http.Request, loggers chaoslogger.Loggers) {
+	body, err := encode(r, p)
 	if err != nil {
 		_ = level.Error(loggers.ErrLogger).Log("msg", "Error when trying to encode response to byte array", "err", err)
 		w.WriteHeader(500)
@@ -60,7 +241,7 @@ func (p *Payload) SetInWriter(w http.ResponseWriter, loggers chaoslogger.Loggers
 	}
 
 	w.WriteHeader(p.Status)
-	_, err = w.Write(reqBodyBytes.Bytes())
+	_, err = w.Write(body)
 	if err != nil {
 		_ = level.Error(loggers.ErrLogger).Log("msg", "Error when trying to write response to byte array", "err", err)
 		w.WriteHeader(500)
@@ -68,7 +249,25 @@ func (p *Payload) SetInWriter(w http.ResponseWriter, loggers chaoslogger.Loggers
 	}
 }
 
+// JSONResponse writes any value as the json body of the response with the provided status, indented with pretty=true
+func JSONResponse(w http.ResponseWriter, r *http.Request, status int, v interface{}, loggers chaoslogger.Loggers) {
+	body, err := encode(r, v)
+	if err != nil {
+		_ = level.Error(loggers.ErrLogger).Log("msg", "Error when trying to encode response to byte array", "err", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, err = w.Write(body)
+	if err != nil {
+		_ = level.Error(loggers.ErrLogger).Log("msg", "Error when trying to write response to byte array", "err", err)
+	}
+}
+
 type RecoverResponsePayload struct {
+	GroupKey       string            `json:"groupKey,omitempty"`
 	RecoverMessage []*RecoverMessage `json:"recoverMessages"`
 	Status         int               `json:"status"`
 }
@@ -79,7 +278,8 @@ func (recoverR *RecoverResponsePayload) BadRequest(message string, logger log.Lo
 	_ = level.Warn(logger).Log("msg", "Bad request", "warn", message)
 }
 
-func RecoverResponse(w http.ResponseWriter, messages []*RecoverMessage, loggers chaoslogger.Loggers) {
+// RecoverResponse writes the recover messages, with the group key of the alertmanager webhook if it is not empty
+func RecoverResponse(w http.ResponseWriter, r *http.Request, messages []*RecoverMessage, groupKey string, loggers chaoslogger.Loggers) {
 	var status int
 
 	if containsFailures(messages) {
@@ -89,11 +289,71 @@ func RecoverResponse(w http.ResponseWriter, messages []*RecoverMessage, loggers
 	}
 
 	resp := &RecoverResponsePayload{
+		GroupKey:       groupKey,
 		RecoverMessage: messages,
 		Status:         status,
 	}
 
-	resp.SetInWriter(w, loggers)
+	resp.SetInWriter(w, r, loggers)
+}
+
+// RecoverResult is the detailed outcome of the recovery of the failure of a job on a target
+type RecoverResult struct {
+	Job    string `json:"job"`
+	Target string `json:"target"`
+	*RecoverMessage
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+func NewRecoverResult(job string, target string, message *RecoverMessage, duration time.Duration) *RecoverResult {
+	return &RecoverResult{
+		Job:             job,
+		Target:          target,
+		RecoverMessage:  message,
+		DurationSeconds: duration.Seconds(),
+	}
+}
+
+// RecoverMessages returns the recover messages of the results
+func RecoverMessages(results []*RecoverResult) []*RecoverMessage {
+	messages := make([]*RecoverMessage, 0, len(results))
+	for _, result := range results {
+		messages = append(messages, result.RecoverMessage)
+	}
+	return messages
+}
+
+type DetailedRecoverResponsePayload struct {
+	GroupKey string           `json:"groupKey,omitempty"`
+	Results  []*RecoverResult `json:"results"`
+	Status   int              `json:"status"`
+}
+
+// DetailedRecoverResponse writes the outcome of every recovery with its job, target and duration,
+// with the group key of the alertmanager webhook if it is not empty
+func DetailedRecoverResponse(w http.ResponseWriter, r *http.Request, results []*RecoverResult, groupKey string, loggers chaoslogger.Loggers) {
+	status := 200
+	if containsFailures(RecoverMessages(results)) {
+		status = 500
+	}
+
+	resp := &DetailedRecoverResponsePayload{
+		GroupKey: groupKey,
+		Results:  results,
+		Status:   status,
+	}
+
+	body, err := encode(r, resp)
+	if err != nil {
+		_ = level.Error(loggers.ErrLogger).Log("msg", "Error when trying to encode response to byte array", "err", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	w.WriteHeader(resp.Status)
+	if _, err := w.Write(body); err != nil {
+		_ = level.Error(loggers.ErrLogger).Log("msg", "Error when trying to write response to byte array", "err", err)
+	}
 }
 
 func containsFailures(messages []*RecoverMessage) bool {
@@ -106,9 +366,8 @@ func containsFailures(messages []*RecoverMessage) bool {
 	return false
 }
 
-func (recoverR *RecoverResponsePayload) SetInWriter(w http.ResponseWriter, loggers chaoslogger.Loggers) {
-	reqBodyBytes := new(bytes.Buffer)
-	err := json.NewEncoder(reqBodyBytes).Encode(recoverR)
+func (recoverR *RecoverResponsePayload) SetInWriter(w http.ResponseWriter, r *http.Request, loggers chaoslogger.Loggers) {
+	body, err := encode(r, recoverR)
 	if err != nil {
 		_ = level.Error(loggers.ErrLogger).Log("msg", "Error when trying to encode response to byte array", "err", err)
 		w.WriteHeader(500)
@@ -116,7 +375,7 @@ func (recoverR *RecoverResponsePayload) SetInWriter(w http.ResponseWriter, logge
 	}
 
 	w.WriteHeader(recoverR.Status)
-	_, err = w.Write(reqBodyBytes.Bytes())
+	_, err = w.Write(body)
 	if err != nil {
 		_ = level.Error(loggers.ErrLogger).Log("msg", "Error when trying to write response to byte array", "err", err)
 		w.WriteHeader(500)
@@ -127,6 +386,7 @@ func (recoverR *RecoverResponsePayload) SetInWriter(w http.ResponseWriter, logge
 type RecoverMessage struct {
 	Message string `json:"message"`
 	Error   string `json:"error"`
+	Code    string `json:"code,omitempty"`
 	Status  string `json:"status"`
 }
 
@@ -137,6 +397,16 @@ func FailureRecoverResponse(message string) *RecoverMessage {
 	}
 }
 
+// ErrorRecoverResponse is the failure response for an error returned from the bot,
+// including the gRPC status code of the error if there is one
+func ErrorRecoverResponse(err error, message string) *RecoverMessage {
+	return &RecoverMessage{
+		Error:  errors.Wrap(err, message).Error(),
+		Code:   StatusCode(err),
+		Status: FAILURE.String(),
+	}
+}
+
 func SuccessRecoverResponse(message string) *RecoverMessage {
 	return &RecoverMessage{
 		Message: message,
@@ -0,0 +1,99 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	grpcStatus "google.golang.org/grpc/status"
+)
+
+func TestErrorResponseContentNegotiation(t *testing.T) {
+	dataItems := []struct {
+		message             string
+		accept              string
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			message:             "Should respond with json when no Accept header is set",
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"bad input","status":400}` + "\n",
+		},
+		{
+			message:             "Should respond with json when json is preferred",
+			accept:              "application/json, text/plain",
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"bad input","status":400}` + "\n",
+		},
+		{
+			message:             "Should respond with plain text when plain text is listed first",
+			accept:              "text/plain, application/json",
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody:        "bad input\n",
+		},
+		{
+			message:             "Should respond with json when json has a higher quality than plain text listed first",
+			accept:              "text/plain;q=0.9, application/json",
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"bad input","status":400}` + "\n",
+		},
+		{
+			message:             "Should respond with plain text when plain text has a higher quality than json listed first",
+			accept:              "application/json;q=0.5, text/plain",
+			expectedContentType: "text/plain; charset=utf-8",
+			expectedBody:        "bad input\n",
+		},
+		{
+			message:             "Should respond with json when plain text is not acceptable",
+			accept:              "text/plain;q=0",
+			expectedContentType: "application/json",
+			expectedBody:        `{"error":"bad input","status":400}` + "\n",
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/cpu", nil)
+			if dataItem.accept != "" {
+				r.Header.Set("Accept", dataItem.accept)
+			}
+			w := httptest.NewRecorder()
+
+			BadRequest(w, r, "bad input", getLoggers())
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, dataItem.expectedContentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, dataItem.expectedBody, w.Body.String())
+		})
+	}
+}
+
+func TestBotErrorResponseIncludesCode(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/cpu", nil)
+	w := httptest.NewRecorder()
+
+	BotError(w, r, grpcStatus.Error(codes.Unavailable, "bot down"), getLoggers())
+
+	payload := &ErrorPayload{}
+	if err := json.NewDecoder(w.Body).Decode(payload); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, &ErrorPayload{Error: "rpc error: code = Unavailable desc = bot down", Code: "Unavailable", Status: http.StatusInternalServerError}, payload)
+	assert.Equal(t, "Unavailable", w.Header().Get(BotStatusCodeHeader))
+}
+
+func getLoggers() chaoslogger.Loggers {
+	allowLevel := &chaoslogger.AllowedLevel{}
+	_ = allowLevel.Set("debug")
+
+	return chaoslogger.Loggers{
+		OutLogger: chaoslogger.New(allowLevel, os.Stdout),
+		ErrLogger: chaoslogger.New(allowLevel, os.Stderr),
+	}
+}
@@ -0,0 +1,64 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/go-kit/kit/log/level"
+)
+
+// NDJSON is the media type of newline delimited json
+const NDJSON = "application/x-ndjson"
+
+// AcceptsNDJSON returns true if the client asks for newline delimited json in the Accept header
+func AcceptsNDJSON(r *http.Request) bool {
+	for _, mediaRange := range strings.Split(r.Header.Get("Accept"), ",") {
+		if strings.TrimSpace(strings.Split(mediaRange, ";")[0]) == NDJSON {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RecoverStream writes every recover message as a line of json and flushes it to the client
+// as soon as it is sent. The status of the response is always 200, since it is sent before
+// the outcome of the recoveries is known. The status of every recovery is in its message.
+// A detailed stream writes the whole result of every recovery instead of its message
+type RecoverStream struct {
+	w        http.ResponseWriter
+	encoder  *json.Encoder
+	detailed bool
+	loggers  chaoslogger.Loggers
+}
+
+func NewRecoverStream(w http.ResponseWriter, detailed bool, loggers chaoslogger.Loggers) *RecoverStream {
+	w.Header().Set("Content-Type", NDJSON)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+
+	return &RecoverStream{
+		w:        w,
+		encoder:  json.NewEncoder(w),
+		detailed: detailed,
+		loggers:  loggers,
+	}
+}
+
+func (s *RecoverStream) Send(result *RecoverResult) {
+	var line interface{} = result.RecoverMessage
+	if s.detailed {
+		line = result
+	}
+
+	if err := s.encoder.Encode(line); err != nil {
+		_ = level.Error(s.loggers.ErrLogger).Log("msg", "Error when trying to stream recover message", "err", err)
+		return
+	}
+
+	if flusher, ok := s.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
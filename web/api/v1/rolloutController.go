@@ -0,0 +1,235 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/healthcheck"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/grafana"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/metrics"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/auth"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/cpu"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/docker"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/service"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/go-kit/kit/log/level"
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultRolloutHealthTimeout = 60 * time.Second
+	defaultRolloutPollInterval  = time.Second
+)
+
+// RolloutRequest is the job whose failure is injected on its targets one at a time.
+// The percentage is only applicable to CPU jobs
+type RolloutRequest struct {
+	Job              string            `json:"job"`
+	Percentage       int32             `json:"percentage,omitempty"`
+	HealthTimeoutSec int               `json:"healthTimeoutSec,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+}
+
+// RolloutStep is the result of the injection on one of the targets of the rollout
+type RolloutStep struct {
+	Target string `json:"target"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// RolloutResponsePayload contains the steps of the rollout, which stops at the first failed step
+type RolloutResponsePayload struct {
+	Steps     []*RolloutStep `json:"steps"`
+	Completed bool           `json:"completed"`
+	Status    int            `json:"status"`
+}
+
+type RolloutController struct {
+	jobs         map[string]*config.Job
+	connections  *network.Connections
+	cache        *gocache.Cache
+	persistence  *cache.Persistence
+	locks        *cache.KeyLocks
+	injections   *cache.Injections
+	inFlight     *metrics.InFlight
+	outcomes     *metrics.Outcomes
+	annotator    *grafana.Annotator
+	pollInterval time.Duration
+	loggers      chaoslogger.Loggers
+}
+
+// Rollout godoc
+// @Summary Inject a failure on the targets of a job one at a time
+// @Description Inject the failure of the job on its targets one at a time. After every injection the rollout waits until
+// @Description the bot of the target reports healthy, or fails when the health timeout (60s by default) expires.
+// @Description The rollout stops at the first failed step. Only Docker, Service and CPU jobs can be rolled out
+// @Tags Failure injections
+// @Accept json
+// @Produce json
+// @Param confirmProduction query bool false "Confirm the injection on targets labeled env: prod"
+// @Param requestPayload body RolloutRequest true "Specify the job, and the percentage for CPU jobs"
+// @Success 200 {object} RolloutResponsePayload
+// @Failure 400 {object} response.ErrorPayload
+// @Failure 403 {object} response.ErrorPayload
+// @Failure 500 {object} RolloutResponsePayload
+// @Router /rollout [post]
+func (rc *RolloutController) Rollout(w http.ResponseWriter, r *http.Request) {
+	request := &RolloutRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		response.BadRequest(w, r, "Could not decode request body", rc.loggers)
+		return
+	}
+
+	if err := network.ValidateLabels(request.Labels); err != nil {
+		response.BadRequest(w, r, err.Error(), rc.loggers)
+		return
+	}
+
+	job, ok := rc.jobs[request.Job]
+	if !ok {
+		response.BadRequest(w, r, fmt.Sprintf("Could not find job {%s}", request.Job), rc.loggers)
+		return
+	}
+
+	action, err := injectAction(job)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), rc.loggers)
+		return
+	}
+
+	if !job.Allows(action) {
+		response.Forbidden(w, r, fmt.Sprintf("The action {%s} is not allowed for job {%s}", action, request.Job), rc.loggers)
+		return
+	}
+
+	for _, target := range job.Target {
+		if job.RequiresConfirmation(target) && r.FormValue("confirmProduction") != "true" {
+			response.Forbidden(w, r, fmt.Sprintf("The target {%s} is a production target. Set the query parameter confirmProduction=true to inject", target), rc.loggers)
+			return
+		}
+	}
+
+	healthTimeout := defaultRolloutHealthTimeout
+	if request.HealthTimeoutSec > 0 {
+		healthTimeout = time.Duration(request.HealthTimeoutSec) * time.Second
+	}
+
+	payload := &RolloutResponsePayload{Steps: make([]*RolloutStep, 0, len(job.Target)), Completed: true, Status: http.StatusOK}
+	for _, target := range job.Target {
+		step := &RolloutStep{Target: target, Status: response.SUCCESS.String()}
+		payload.Steps = append(payload.Steps, step)
+
+		if err = rc.step(r.Context(), job, request, target, healthTimeout); err != nil {
+			step.Status = response.FAILURE.String()
+			step.Error = err.Error()
+			payload.Completed = false
+			payload.Status = http.StatusInternalServerError
+			break
+		}
+	}
+
+	response.JSONResponse(w, r, payload.Status, payload, rc.loggers)
+}
+
+// step injects the failure of the job on the target, and waits until the bot of the target is healthy
+func (rc *RolloutController) step(ctx context.Context, job *config.Job, request *RolloutRequest, target string, healthTimeout time.Duration) error {
+	connection, ok := rc.connections.Pool[job.Address(target)]
+	if !ok {
+		return errors.New(fmt.Sprintf("Could not find connection to target {%s}", target))
+	}
+
+	_ = level.Info(rc.loggers.OutLogger).Log("msg", fmt.Sprintf("rollout of job {%s} on target {%s}", request.Job, target))
+	if err := rc.inject(ctx, job, request, target, connection); err != nil {
+		return err
+	}
+
+	return rc.waitUntilHealthy(ctx, target, connection, healthTimeout)
+}
+
+func (rc *RolloutController) inject(ctx context.Context, job *config.Job, request *RolloutRequest, target string, connection network.Connection) error {
+	defer rc.inFlight.Track(job.FailureType)()
+
+	key := cache.Key{Job: request.Job, Target: target}
+	unlock := rc.locks.Lock(key)
+	defer unlock()
+
+	inject, recovery := injectionOfJob(job, request, target, connection)
+	callStart := time.Now()
+	statusResponse, err := inject()
+	action, _ := injectAction(job)
+	rc.outcomes.Observe(job.FailureType, action, target, metrics.ResultOf(statusResponse, err), time.Since(callStart))
+	switch {
+	case err != nil:
+		return errors.Wrap(err, fmt.Sprintf("Error response from target {%s}", target))
+	case statusResponse.Status != v1.StatusResponse_SUCCESS:
+		return errors.New(fmt.Sprintf("Failure response from target {%s}", target))
+	}
+
+	rc.injections.Record(key, &cache.Injection{StartsAt: time.Now(), Labels: request.Labels, Metadata: auth.WithPrincipalMetadata(ctx, nil), Inject: inject})
+	rc.cache.Set(key, recovery)
+	rc.annotator.Start(request.Job, target)
+	if err = rc.persistence.Save(rc.cache); err != nil {
+		_ = level.Error(rc.loggers.ErrLogger).Log("msg", "Could not update cache after rollout step", "err", err)
+	}
+	return nil
+}
+
+// waitUntilHealthy polls the health of the bot of the target until it is serving, or the timeout expires
+func (rc *RolloutController) waitUntilHealthy(ctx context.Context, target string, connection network.Connection, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		status, err := healthcheck.Check(ctx, connection)
+		if err == nil && status == v1.HealthCheckResponse_SERVING {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.New(fmt.Sprintf("Target {%s} is {%s} after %s", target, status, timeout))
+		case <-time.After(rc.pollInterval):
+		}
+	}
+}
+
+// injectAction returns the action that injects the failure of the job, if the job can be rolled out
+func injectAction(job *config.Job) (string, error) {
+	switch job.FailureType {
+	case config.Docker, config.Service:
+		return "kill", nil
+	case config.CPU:
+		return "start", nil
+	default:
+		return "", errors.New(fmt.Sprintf("Failures with type {%s} can not be rolled out", job.FailureType))
+	}
+}
+
+// injectionOfJob returns the injection and the recovery of the failure of the job on the target
+func injectionOfJob(
+	job *config.Job,
+	request *RolloutRequest,
+	target string,
+	connection network.Connection,
+) (func() (*v1.StatusResponse, error), func() (*v1.StatusResponse, error)) {
+	switch job.FailureType {
+	case config.Docker:
+		payload := &docker.RequestPayload{Job: request.Job, Container: job.ComponentName, Target: target, Labels: request.Labels}
+		return docker.Injection(connection, payload), docker.Recovery(connection, payload)
+	case config.Service:
+		payload := &service.RequestPayload{Job: request.Job, ServiceName: job.ComponentName, Target: target, Labels: request.Labels}
+		return service.Injection(connection, payload), service.Recovery(connection, payload)
+	default:
+		payload := &cpu.RequestPayload{Job: request.Job, Percentage: request.Percentage, Target: target, Labels: request.Labels}
+		return cpu.Injection(connection, payload), cpu.Recovery(connection, payload)
+	}
+}
@@ -0,0 +1,159 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+func TestRolloutWaitsForEveryTargetToBeHealthyBeforeTheNext(t *testing.T) {
+	events := &rolloutEvents{}
+	connections := &network.Connections{Pool: map[string]network.Connection{
+		"127.0.0.1": newRolloutConnection("127.0.0.1", 2, events),
+		"127.0.0.2": newRolloutConnection("127.0.0.2", 0, events),
+	}}
+	c := gocache.New(0)
+	server := rolloutHTTPTestServer(connections, c)
+	defer server.Close()
+
+	status, payload := rolloutPostCall(t, server, `{"job": "cpu job", "percentage": 50}`)
+
+	assert.Equal(t, http.StatusOK, status)
+	assert.True(t, payload.Completed)
+	assert.Equal(t, []*RolloutStep{{Target: "127.0.0.1", Status: "SUCCESS"}, {Target: "127.0.0.2", Status: "SUCCESS"}}, payload.Steps)
+	assert.Equal(t, []string{
+		"inject 127.0.0.1",
+		"health 127.0.0.1 NOT_SERVING",
+		"health 127.0.0.1 NOT_SERVING",
+		"health 127.0.0.1 SERVING",
+		"inject 127.0.0.2",
+		"health 127.0.0.2 SERVING",
+	}, events.get(), "the second target should only be injected after the first one is healthy")
+	assert.Equal(t, 2, c.ItemCount(), "every injected target should be kept for recovery")
+}
+
+func TestRolloutStopsWhenTargetIsNotHealthyWithinTheTimeout(t *testing.T) {
+	events := &rolloutEvents{}
+	connections := &network.Connections{Pool: map[string]network.Connection{
+		"127.0.0.1": newRolloutConnection("127.0.0.1", -1, events),
+		"127.0.0.2": newRolloutConnection("127.0.0.2", 0, events),
+	}}
+	c := gocache.New(0)
+	server := rolloutHTTPTestServer(connections, c)
+	defer server.Close()
+
+	status, payload := rolloutPostCall(t, server, `{"job": "cpu job", "percentage": 50, "healthTimeoutSec": 1}`)
+
+	assert.Equal(t, http.StatusInternalServerError, status)
+	assert.False(t, payload.Completed)
+	if assert.Equal(t, 1, len(payload.Steps)) {
+		assert.Equal(t, "FAILURE", payload.Steps[0].Status)
+		assert.Equal(t, "Target {127.0.0.1} is {NOT_SERVING} after 1s", payload.Steps[0].Error)
+	}
+	assert.NotContains(t, events.get(), "inject 127.0.0.2", "the rollout should not proceed to the next target")
+	assert.Equal(t, 1, c.ItemCount(), "the injected target should be kept for recovery")
+}
+
+func rolloutHTTPTestServer(connections *network.Connections, c *gocache.Cache) *httptest.Server {
+	rController := &RolloutController{
+		jobs:         map[string]*config.Job{"cpu job": {FailureType: config.CPU, Target: []string{"127.0.0.1", "127.0.0.2"}}},
+		connections:  connections,
+		cache:        c,
+		locks:        cache.NewKeyLocks(),
+		injections:   cache.NewInjections(),
+		pollInterval: time.Millisecond,
+		loggers:      loggers,
+	}
+	return httptest.NewServer(http.HandlerFunc(rController.Rollout))
+}
+
+func rolloutPostCall(t *testing.T, server *httptest.Server, body string) (int, *RolloutResponsePayload) {
+	resp, err := http.Post(server.URL, "application/json", bytes.NewReader([]byte(body))) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	payload := &RolloutResponsePayload{}
+	if err = json.NewDecoder(resp.Body).Decode(payload); err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, payload
+}
+
+// rolloutEvents records the injections and health checks of the bots in the order they happen
+type rolloutEvents struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (e *rolloutEvents) add(event string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, event)
+}
+
+func (e *rolloutEvents) get() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string{}, e.events...)
+}
+
+// rolloutConnection is a bot that is not serving for the first unhealthy checks after an injection,
+// or never if unhealthy is negative
+type rolloutConnection struct {
+	network.MockConnection
+	target    string
+	unhealthy int
+	events    *rolloutEvents
+}
+
+func newRolloutConnection(target string, unhealthy int, events *rolloutEvents) *rolloutConnection {
+	return &rolloutConnection{
+		MockConnection: network.MockConnection{Status: &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}},
+		target:         target,
+		unhealthy:      unhealthy,
+		events:         events,
+	}
+}
+
+func (connection *rolloutConnection) GetCPUClient() (v1.CPUClient, error) {
+	connection.events.add("inject " + connection.target)
+	return connection.MockConnection.GetCPUClient()
+}
+
+func (connection *rolloutConnection) GetHealthClient() (v1.HealthClient, error) {
+	return &rolloutHealthClient{connection: connection}, nil
+}
+
+type rolloutHealthClient struct {
+	connection *rolloutConnection
+}
+
+func (client *rolloutHealthClient) Check(_ context.Context, _ *v1.HealthCheckRequest, _ ...grpc.CallOption) (*v1.HealthCheckResponse, error) {
+	status := v1.HealthCheckResponse_SERVING
+	if client.connection.unhealthy != 0 {
+		status = v1.HealthCheckResponse_NOT_SERVING
+		client.connection.unhealthy--
+	}
+	client.connection.events.add(fmt.Sprintf("health %s %s", client.connection.target, status))
+	return &v1.HealthCheckResponse{Status: status}, nil
+}
+
+func (client *rolloutHealthClient) Watch(_ context.Context, _ *v1.HealthCheckRequest, _ ...grpc.CallOption) (v1.Health_WatchClient, error) {
+	return nil, nil
+}
@@ -1,110 +1,410 @@
 package v1
 
 import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/SotirisAlfonsos/chaos-master/config"
 	"github.com/SotirisAlfonsos/chaos-master/healthcheck"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	chaosCache "github.com/SotirisAlfonsos/chaos-master/pkg/cache"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/grafana"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/metrics"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/auth"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/controller"
 	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/cpu"
 	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/docker"
 	apiNetwork "github.com/SotirisAlfonsos/chaos-master/web/api/v1/network"
 	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/recover"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
 	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/server"
 	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/service"
 	"github.com/SotirisAlfonsos/gocache"
+	"github.com/go-kit/kit/log/level"
 	"github.com/gorilla/mux"
 	httpSwagger "github.com/swaggo/http-swagger"
 )
 
 type APIRouter struct {
+	config      *config.Config
 	jobMap      map[string]*config.Job
 	connections *network.Connections
 	Cache       *gocache.Cache
+	persistence *chaosCache.Persistence
+	locks       *chaosCache.KeyLocks
+	injections  *chaosCache.Injections
+	inFlight    *metrics.InFlight
+	outcomes    *metrics.Outcomes
+	idempotency *idempotency
+	messages    *response.MessageTemplate
+	annotator   *grafana.Annotator
+	async       *async.Tracker
 	loggers     chaoslogger.Loggers
 }
 
 func NewAPIRouter(
+	conf *config.Config,
 	jobMap map[string]*config.Job,
 	connections *network.Connections,
 	cache *gocache.Cache,
+	tracker *async.Tracker,
 	loggers chaoslogger.Loggers,
 ) *APIRouter {
+	persistence := chaosCache.NewPersistence(conf.Cache)
+	reportPersistedFailures(persistence, loggers)
+
 	return &APIRouter{
+		config:      conf,
 		jobMap:      jobMap,
 		connections: connections,
 		Cache:       cache,
+		persistence: persistence,
+		locks:       chaosCache.NewKeyLocks(),
+		injections:  chaosCache.NewInjections(),
+		inFlight:    metrics.NewInFlight(),
+		outcomes:    metrics.NewOutcomes(conf.Metrics),
+		idempotency: newIdempotency(idempotencyTTL(conf.APIOptions), loggers),
+		messages:    newMessageTemplate(conf.Messages, loggers),
+		annotator:   grafana.NewAnnotator(conf.Grafana, tracker, loggers),
+		async:       tracker,
 		loggers:     loggers,
 	}
 }
 
+// reportPersistedFailures logs the failures that were saved by a previous run of the master. Their recoveries
+// are not saved, so the master can not recover them and they have to be recovered on the bots
+func reportPersistedFailures(persistence *chaosCache.Persistence, loggers chaoslogger.Loggers) {
+	keys, err := persistence.Load()
+	if err != nil {
+		_ = level.Error(loggers.ErrLogger).Log("msg", "Could not report the failures of the previous run", "err", err)
+		return
+	}
+	for _, key := range keys {
+		_ = level.Warn(loggers.OutLogger).Log("msg", fmt.Sprintf("Failure of job {%s} on target {%s} was running when the master stopped and can not be recovered by the master", key.Job, key.Target))
+	}
+}
+
+// Reload returns the router of the jobs and the connections of a reloaded config. The reloaded router shares the cache,
+// the locks and the running injections of the router, so that the failures injected before the reload can be recovered
+func (r *APIRouter) Reload(conf *config.Config, jobMap map[string]*config.Job, connections *network.Connections) *APIRouter {
+	reloaded := *r
+	reloaded.config = conf
+	reloaded.jobMap = jobMap
+	reloaded.connections = connections
+	reloaded.messages = newMessageTemplate(conf.Messages, r.loggers)
+	reloaded.annotator = grafana.NewAnnotator(conf.Grafana, r.async, r.loggers)
+	return &reloaded
+}
+
+// Address returns the address of the bot of the target of the key by the jobs of the router, or the target if its job is not known
+func (r *APIRouter) Address(key chaosCache.Key) string {
+	if job, ok := r.jobMap[key.Job]; ok {
+		return job.Address(key.Target)
+	}
+	return key.Target
+}
+
+func idempotencyTTL(apiOptions *config.RestAPIOptions) time.Duration {
+	if apiOptions == nil {
+		return 0
+	}
+	return apiOptions.IdempotencyTTL
+}
+
+func newMessageTemplate(messages *config.Messages, loggers chaoslogger.Loggers) *response.MessageTemplate {
+	if messages == nil {
+		return nil
+	}
+
+	messageTemplate, err := response.NewMessageTemplate(messages.SuccessTemplate)
+	if err != nil {
+		_ = level.Error(loggers.ErrLogger).Log("msg", "Could not parse success message template, using the default message", "err", err)
+		return nil
+	}
+	return messageTemplate
+}
+
 func (r *APIRouter) AddRoutes(healthChecker *healthcheck.HealthChecker, router *mux.Router) *mux.Router {
 	base := "/chaos/api/v1"
 
 	router = router.PathPrefix(base).Subrouter()
-	setBotRouters(router, r)
-	setRecoverRouter(router, r)
-	if healthChecker != nil {
-		setStatusRouter(healthChecker, router, r.loggers)
+	if r.config.APIOptions != nil && r.config.APIOptions.HandlerTimeout > 0 {
+		router.Use(newHandlerTimeout(r.config.APIOptions.HandlerTimeout, r.loggers).middleware)
+	}
+	if r.config.APIOptions != nil && r.config.APIOptions.DebugErrors {
+		router.Use(response.DebugErrors)
 	}
+	router.Use(auth.ClientIPMiddleware(r.config.APIOptions))
+	authenticated := router.NewRoute().Subrouter()
+	authenticated.Use(auth.NewAuthenticator(r.config.Auth, r.loggers).Middleware)
+	setBotRouters(authenticated, r, healthChecker)
+	setRecoverRouter(authenticated, r)
+	setImportRouter(authenticated, r)
+	setCacheClearRouter(authenticated, r)
+	setLogLevelRouter(authenticated, r.loggers)
+	if r.config.APIOptions != nil && r.config.APIOptions.DebugEndpoints {
+		setDebugRouter(authenticated, r)
+	}
+	setReadOnlyRouters(healthChecker, authenticated, r)
 	setSwaggerRouter(router)
+	router.NotFoundHandler = trailingSlashTolerant(router)
 
 	return router
 }
 
-func setBotRouters(router *mux.Router, r *APIRouter) {
-	serviceControllerRouter(router, r)
-	dockerControllerRouter(router, r)
-	cpuControllerRouter(router, r)
-	serverControllerRouter(router, r)
-	networkControllerRouter(router, r)
+// AddReadOnlyRoutes adds only the routes that can not inject or recover failures, to be served on the read only port
+func (r *APIRouter) AddReadOnlyRoutes(healthChecker *healthcheck.HealthChecker, router *mux.Router) *mux.Router {
+	base := "/chaos/api/v1"
+
+	router = router.PathPrefix(base).Subrouter()
+	router.Use(auth.ClientIPMiddleware(r.config.APIOptions))
+	router.Use(auth.NewAuthenticator(r.config.Auth, r.loggers).Middleware)
+	setReadOnlyRouters(healthChecker, router, r)
+	router.NotFoundHandler = trailingSlashTolerant(router)
+
+	return router
+}
+
+func setReadOnlyRouters(healthChecker *healthcheck.HealthChecker, router *mux.Router, r *APIRouter) {
+	setCapabilitiesRouter(router, r.loggers)
+	setCacheRouter(router, r)
+	setValidateRouter(router, r)
+	setJobsRouter(router, r)
+	setWhoamiRouter(router, r.loggers)
+	setMetricsRouter(router, r)
+	if healthChecker != nil {
+		setStatusRouter(healthChecker, router, r.loggers)
+	}
+}
+
+// trailingSlashTolerant retries the routing of a request that matched no route without its trailing slash.
+// mux StrictSlash is not used since it redirects, and clients follow redirects of POST requests with a GET
+func trailingSlashTolerant(router *mux.Router) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.URL.Path) > 1 && strings.HasSuffix(r.URL.Path, "/") {
+			r.URL.Path = strings.TrimRight(r.URL.Path, "/")
+			router.ServeHTTP(w, r)
+			return
+		}
+		http.NotFound(w, r)
+	})
+}
+
+func setBotRouters(router *mux.Router, r *APIRouter, healthChecker *healthcheck.HealthChecker) {
+	router = router.NewRoute().Subrouter()
+	router.Use(newMaintenance(r.config.MaintenanceWindows, r.config.ActionAliases, r.loggers).middleware)
+	router.Use(r.idempotency.middleware)
+
+	options := r.controllerOptions(healthChecker)
+	serviceControllerRouter(router, r, options)
+	dockerControllerRouter(router, r, options)
+	cpuControllerRouter(router, r, options)
+	serverControllerRouter(router, r, options)
+	networkControllerRouter(router, r, options)
+	rolloutControllerRouter(router, r)
+}
+
+// controllerOptions returns the options of the controllers of the failures, with the guard of the minimum
+// healthy targets of the health checker
+func (r *APIRouter) controllerOptions(healthChecker *healthcheck.HealthChecker) *controller.Options {
+	return &controller.Options{
+		Connections:     r.connections,
+		Cache:           r.Cache,
+		Persistence:     r.persistence,
+		Locks:           r.locks,
+		Injections:      r.injections,
+		InFlight:        r.inFlight,
+		Outcomes:        r.outcomes,
+		Aliases:         r.config.ActionAliases,
+		Guard:           healthcheck.NewGuard(healthChecker, r.Cache, r.config.Limits),
+		Annotator:       r.annotator,
+		Messages:        r.messages,
+		Async:           r.async,
+		Loggers:         r.loggers,
+		HealthChecker:   healthChecker,
+		RandomSelection: r.config.RandomSelection,
+		Docker:          r.config.Docker,
+		NetworkLimits:   r.config.NetworkLimits,
+		Recover:         r.config.Recover,
+	}
+}
+
+// RecoverExpired recovers the running failures that were due to be recovered automatically before now
+func (r *APIRouter) RecoverExpired(now time.Time) {
+	newRecoverController(r).RecoverExpired(now)
+}
+
+func newRecoverController(r *APIRouter) *recover.RController {
+	return recover.NewRecoverController(r.jobMap, r.controllerOptions(nil))
 }
 
 func setRecoverRouter(router *mux.Router, r *APIRouter) {
-	rController := recover.NewRecoverController(r.Cache, r.loggers)
+	rController := newRecoverController(r)
 	router.HandleFunc("/recover", rController.RecoverAction).
 		Methods("POST")
 	router.HandleFunc("/recover/alertmanager", rController.RecoverActionAlertmanagerWebHook).
 		Methods("POST")
+	// job names can contain slashes, e.g. team/job
+	router.HandleFunc("/recover/job/{job:.+}", rController.RecoverJobAction).
+		Methods("POST")
+	router.HandleFunc("/recover/target/{target:.+}", rController.RecoverTargetAction).
+		Methods("POST")
+	router.HandleFunc("/recover/match", rController.MatchAlertmanagerWebHook).
+		Methods("POST")
+	router.HandleFunc("/recover/simulate", rController.SimulateAlertmanagerWebHook).
+		Methods("POST")
+
+	// the cycle injects the failures again, so it is subject to the maintenance windows like the bot routes
+	cycleRouter := router.NewRoute().Subrouter()
+	cycleRouter.Use(newMaintenance(r.config.MaintenanceWindows, r.config.ActionAliases, r.loggers).middleware)
+	cycleRouter.Use(r.idempotency.middleware)
+	cycleRouter.HandleFunc("/recover/cycle", rController.CycleAction).
+		Methods("POST")
+}
+
+func setCapabilitiesRouter(router *mux.Router, loggers chaoslogger.Loggers) {
+	capabilities := &Capabilities{loggers: loggers}
+	router.HandleFunc("/capabilities", capabilities.Capabilities).Methods("GET")
+}
+
+func setCacheRouter(router *mux.Router, r *APIRouter) {
+	cacheController := newCacheController(r)
+	router.HandleFunc("/cache", cacheController.Items).Methods("GET")
+	router.HandleFunc("/cache/item", cacheController.Item).Methods("GET")
+	router.HandleFunc("/cache/silences", cacheController.Silences).Methods("GET")
+	router.HandleFunc("/cache/export", newMigrationController(r).Export).Methods("GET")
+}
+
+func setImportRouter(router *mux.Router, r *APIRouter) {
+	router.HandleFunc("/cache/import", newMigrationController(r).Import).Methods("POST")
+}
+
+func setCacheClearRouter(router *mux.Router, r *APIRouter) {
+	router.HandleFunc("/cache/clear", newCacheController(r).Clear).Methods("POST")
+}
+
+func newCacheController(r *APIRouter) *CacheController {
+	return &CacheController{
+		cache:       r.Cache,
+		persistence: r.persistence,
+		locks:       r.locks,
+		injections:  r.injections,
+		loggers:     r.loggers,
+	}
+}
+
+func newMigrationController(r *APIRouter) *MigrationController {
+	return &MigrationController{
+		jobs:        r.jobMap,
+		connections: r.connections,
+		cache:       r.Cache,
+		persistence: r.persistence,
+		locks:       r.locks,
+		injections:  r.injections,
+		loggers:     r.loggers,
+	}
+}
+
+func setValidateRouter(router *mux.Router, r *APIRouter) {
+	validateController := &ValidateController{jobs: r.jobMap, connections: r.connections, loggers: r.loggers}
+	router.HandleFunc("/validate", validateController.Validate).Methods("GET")
+}
+
+func setJobsRouter(router *mux.Router, r *APIRouter) {
+	jobsController := &JobsController{jobs: r.jobMap, loggers: r.loggers}
+	router.HandleFunc("/jobs", jobsController.Jobs).Methods("GET")
+}
+
+func setLogLevelRouter(router *mux.Router, loggers chaoslogger.Loggers) {
+	logLevelController := &LogLevelController{loggers: loggers}
+	router.HandleFunc("/loglevel", logLevelController.GetLogLevel).Methods("GET")
+	router.HandleFunc("/loglevel", logLevelController.SetLogLevel).Methods("PUT")
+}
+
+func setDebugRouter(router *mux.Router, r *APIRouter) {
+	debugController := &DebugController{connections: r.connections, loggers: r.loggers}
+	router.HandleFunc("/debug/connection", debugController.Connection).Methods("GET")
+}
+
+func setWhoamiRouter(router *mux.Router, loggers chaoslogger.Loggers) {
+	whoamiController := &WhoamiController{loggers: loggers}
+	router.HandleFunc("/whoami", whoamiController.Whoami).Methods("GET")
+}
+
+func setMetricsRouter(router *mux.Router, r *APIRouter) {
+	metricsController := &MetricsController{inFlight: r.inFlight, outcomes: r.outcomes, cache: r.Cache, loggers: r.loggers}
+	router.HandleFunc("/injections/inflight", metricsController.InFlight).Methods("GET")
+	router.HandleFunc("/metrics", metricsController.Metrics).Methods("GET")
 }
 
 func setStatusRouter(healthChecker *healthcheck.HealthChecker, router *mux.Router, loggers chaoslogger.Loggers) {
-	statusController := &Bots{StatusMap: healthChecker.DetailsMap, Loggers: loggers}
+	statusController := &Bots{HealthChecker: healthChecker, Loggers: loggers}
 	router.HandleFunc("/master/status", statusController.Status).Methods("GET")
 }
 
-func serviceControllerRouter(router *mux.Router, r *APIRouter) {
-	sController := service.NewServiceController(filterJobsOnType(r.jobMap, config.Service), r.connections, r.Cache, r.loggers)
+func serviceControllerRouter(router *mux.Router, r *APIRouter, options *controller.Options) {
+	sController := service.NewServiceController(filterJobsOnType(r.jobMap, config.Service), options)
 	router.HandleFunc("/service", sController.ServiceAction).
 		Queries("action", "{action}").
 		Methods("POST")
+	router.HandleFunc("/service/pulse", sController.PulseAction).
+		Methods("POST")
 }
 
-func dockerControllerRouter(router *mux.Router, r *APIRouter) {
-	dController := docker.NewDockerController(filterJobsOnType(r.jobMap, config.Docker), r.connections, r.Cache, r.loggers)
+func dockerControllerRouter(router *mux.Router, r *APIRouter, options *controller.Options) {
+	dController := docker.NewDockerController(filterJobsOnType(r.jobMap, config.Docker), options)
 	router.HandleFunc("/docker", dController.DockerAction).
 		Queries("action", "{action}").
 		Methods("POST")
+	router.HandleFunc("/docker/pulse", dController.PulseAction).
+		Methods("POST")
 }
 
-func cpuControllerRouter(router *mux.Router, r *APIRouter) {
-	cController := cpu.NewCPUController(filterJobsOnType(r.jobMap, config.CPU), r.connections, r.Cache, r.loggers)
+func cpuControllerRouter(router *mux.Router, r *APIRouter, options *controller.Options) {
+	cController := cpu.NewCPUController(filterJobsOnType(r.jobMap, config.CPU), options)
 	router.HandleFunc("/cpu", cController.CPUAction).
 		Queries("action", "{action}").
 		Methods("POST")
+	router.HandleFunc("/cpu/pulse", cController.PulseAction).
+		Methods("POST")
 }
 
-func serverControllerRouter(router *mux.Router, r *APIRouter) {
-	s := server.NewServerController(filterJobsOnType(r.jobMap, config.Server), r.connections, r.loggers)
+func rolloutControllerRouter(router *mux.Router, r *APIRouter) {
+	rController := &RolloutController{
+		jobs:         r.jobMap,
+		connections:  r.connections,
+		cache:        r.Cache,
+		persistence:  r.persistence,
+		locks:        r.locks,
+		injections:   r.injections,
+		inFlight:     r.inFlight,
+		outcomes:     r.outcomes,
+		annotator:    r.annotator,
+		pollInterval: defaultRolloutPollInterval,
+		loggers:      r.loggers,
+	}
+	router.HandleFunc("/rollout", rController.Rollout).
+		Methods("POST")
+}
+
+func serverControllerRouter(router *mux.Router, r *APIRouter, options *controller.Options) {
+	s := server.NewServerController(filterJobsOnType(r.jobMap, config.Server), options)
 	router.HandleFunc("/server", s.ServerAction).
 		Queries("action", "{action}").
 		Methods("POST")
 }
 
-func networkControllerRouter(router *mux.Router, r *APIRouter) {
-	n := apiNetwork.NewNetworkController(filterJobsOnType(r.jobMap, config.Network), r.connections, r.Cache, r.loggers)
+func networkControllerRouter(router *mux.Router, r *APIRouter, options *controller.Options) {
+	n := apiNetwork.NewNetworkController(filterJobsOnType(r.jobMap, config.Network), options)
 	router.HandleFunc("/network", n.NetworkAction).
 		Queries("action", "{action}").
 		Methods("POST")
+	router.HandleFunc("/network/pulse", n.PulseAction).
+		Methods("POST")
 }
 
 func filterJobsOnType(jobMap map[string]*config.Job, failureType config.FailureType) map[string]*config.Job {
@@ -0,0 +1,106 @@
+package v1
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/async"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActionsAndPathsAreNormalized(t *testing.T) {
+	dataItems := []struct {
+		message  string
+		path     string
+		expected int
+	}{
+		{
+			message:  "Should accept action with capital first letter",
+			path:     "/chaos/api/v1/cpu?action=Start",
+			expected: http.StatusOK,
+		},
+		{
+			message:  "Should accept upper case action",
+			path:     "/chaos/api/v1/cpu?action=START",
+			expected: http.StatusOK,
+		},
+		{
+			message:  "Should accept path with trailing slash",
+			path:     "/chaos/api/v1/cpu/?action=start",
+			expected: http.StatusOK,
+		},
+		{
+			message:  "Should not find path that does not exist with trailing slash",
+			path:     "/chaos/api/v1/memory/?action=start",
+			expected: http.StatusNotFound,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			server := apiHTTPTestServer(&config.Config{})
+			defer server.Close()
+
+			body := []byte(`{"job": "cpu job", "percentage": 50, "target": "127.0.0.1"}`)
+			resp, err := http.Post(server.URL+dataItem.path, "", bytes.NewReader(body)) //nolint:gosec
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, dataItem.expected, resp.StatusCode)
+		})
+	}
+}
+
+func TestInjectionsCallTheBotPortOfTheirJob(t *testing.T) {
+	cpuBot := &countingConnection{MockConnection: network.MockConnection{Status: &v1.StatusResponse{Status: v1.StatusResponse_FAIL}}, status: v1.StatusResponse_SUCCESS}
+	jobMap := map[string]*config.Job{
+		"cpu job":    {FailureType: config.CPU, Target: []string{"127.0.0.1:8081"}, BotPort: "8082"},
+		"docker job": {FailureType: config.Docker, ComponentName: "nginx", Target: []string{"127.0.0.1:8081"}, BotPort: "8083"},
+	}
+	connections := &network.Connections{Pool: map[string]network.Connection{
+		"127.0.0.1:8081": &network.MockConnection{Status: &v1.StatusResponse{Status: v1.StatusResponse_FAIL}},
+		"127.0.0.1:8082": cpuBot,
+		"127.0.0.1:8083": &network.MockConnection{Status: &v1.StatusResponse{Status: v1.StatusResponse_SUCCESS}},
+	}}
+	server := httptest.NewServer(NewAPIRouter(&config.Config{}, jobMap, connections, gocache.New(0), async.NewTracker(), loggers).AddRoutes(nil, mux.NewRouter()))
+	defer server.Close()
+
+	dataItems := []struct {
+		message string
+		path    string
+		body    string
+	}{
+		{
+			message: "Should call the cpu bot port for a cpu injection",
+			path:    "/chaos/api/v1/cpu?action=start",
+			body:    `{"job": "cpu job", "percentage": 50, "target": "127.0.0.1:8081"}`,
+		},
+		{
+			message: "Should call the docker bot port for a docker injection",
+			path:    "/chaos/api/v1/docker?action=kill",
+			body:    `{"job": "docker job", "containerName": "nginx", "target": "127.0.0.1:8081"}`,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			resp, err := http.Post(server.URL+dataItem.path, "application/json", bytes.NewReader([]byte(dataItem.body))) //nolint:gosec
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+	assert.Equal(t, 1, cpuBot.startCalls(), "the cpu injection should be sent to the cpu bot port only")
+}
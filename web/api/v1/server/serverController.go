@@ -5,19 +5,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
 	"github.com/SotirisAlfonsos/chaos-master/config"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/grafana"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/metrics"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/retry"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/controller"
 	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
 	"github.com/go-kit/kit/log/level"
 	"github.com/pkg/errors"
 )
 
 type RequestPayload struct {
-	Job    string `json:"job"`
-	Target string `json:"target"`
+	Job    string            `json:"job"`
+	Target string            `json:"target"`
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 func newServerRequest() *v1.ServerRequest {
@@ -28,6 +35,13 @@ type SController struct {
 	loggers        chaoslogger.Loggers
 	jobs           jobs
 	connectionPool map[string]*sConnection
+	inFlight       *metrics.InFlight
+	outcomes       *metrics.Outcomes
+	backoff        *network.Backoff
+	retry          *retry.Policy
+	aliases        config.ActionAliases
+	annotator      *grafana.Annotator
+	messages       *response.MessageTemplate
 }
 
 type sConnection struct {
@@ -36,13 +50,9 @@ type sConnection struct {
 
 type jobs map[string]*config.Job
 
-func NewServerController(
-	jobs map[string]*config.Job,
-	connections *network.Connections,
-	loggers chaoslogger.Loggers,
-) *SController {
+func NewServerController(jobs map[string]*config.Job, options *controller.Options) *SController {
 	connPool := make(map[string]*sConnection)
-	for target, connection := range connections.Pool {
+	for target, connection := range options.Connections.Pool {
 		connPool[target] = &sConnection{
 			connection: connection,
 		}
@@ -50,7 +60,14 @@ func NewServerController(
 	return &SController{
 		jobs:           jobs,
 		connectionPool: connPool,
-		loggers:        loggers,
+		inFlight:       options.InFlight,
+		outcomes:       options.Outcomes,
+		backoff:        options.Connections.Backoff,
+		retry:          options.Connections.Retry,
+		aliases:        options.Aliases,
+		annotator:      options.Annotator,
+		messages:       options.Messages,
+		loggers:        options.Loggers,
 	}
 }
 
@@ -61,31 +78,47 @@ func NewServerController(
 // @Accept json
 // @Produce json
 // @Param action query string true "Specify to perform a kill action on the server" Enums(kill)
+// @Param confirmProduction query bool false "Confirm the injection on a target labeled env: prod"
 // @Param requestPayload body RequestPayload true "Specify the job name and target"
 // @Success 200 {object} response.Payload
-// @Failure 400 {string} http.Error
-// @Failure 500 {string} http.Error
+// @Failure 400 {object} response.ErrorPayload
+// @Failure 500 {object} response.ErrorPayload
 // @Router /server [post]
 func (sc *SController) ServerAction(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
 	requestPayload := &RequestPayload{}
 	err := json.NewDecoder(r.Body).Decode(&requestPayload)
 	if err != nil {
-		response.BadRequest(w, "Could not decode request body", sc.loggers)
+		response.BadRequest(w, r, "Could not decode request body", sc.loggers)
 		return
 	}
 
-	action, err := toActionEnum(r.FormValue("action"))
+	if err = network.ValidateLabels(requestPayload.Labels); err != nil {
+		response.BadRequest(w, r, err.Error(), sc.loggers)
+		return
+	}
+
+	action, err := toActionEnum(r.FormValue("action"), sc.aliases)
 	if err != nil {
-		response.BadRequest(w, err.Error(), sc.loggers)
+		response.BadRequest(w, r, err.Error(), sc.loggers)
 		return
 	}
 
 	err = checkIfTargetExists(sc.jobs, requestPayload)
 	if err != nil {
-		response.BadRequest(w, err.Error(), sc.loggers)
+		response.BadRequest(w, r, err.Error(), sc.loggers)
+		return
+	}
+
+	if !sc.jobs[requestPayload.Job].Allows(action.String()) {
+		response.Forbidden(w, r, fmt.Sprintf("The action {%s} is not allowed for job {%s}", action, requestPayload.Job), sc.loggers)
+		return
+	}
+
+	if action == kill && sc.jobs[requestPayload.Job].RequiresConfirmation(requestPayload.Target) && r.FormValue("confirmProduction") != "true" {
+		response.Forbidden(w, r, fmt.Sprintf("The target {%s} is a production target. Set the query parameter confirmProduction=true to inject", requestPayload.Target), sc.loggers)
 		return
 	}
 
@@ -93,13 +126,13 @@ func (sc *SController) ServerAction(w http.ResponseWriter, r *http.Request) {
 
 	message, err := sc.performAction(ctx, action, requestPayload)
 	if err != nil {
-		response.InternalServerError(w, err.Error(), sc.loggers)
+		response.BotError(w, r, err, sc.loggers)
 		return
 	}
 
 	_ = level.Info(sc.loggers.OutLogger).Log("msg", message)
 
-	response.OkResponse(w, message, sc.loggers)
+	response.OkResponse(w, r, message, sc.loggers)
 }
 
 func (sc *SController) performAction(
@@ -107,18 +140,32 @@ func (sc *SController) performAction(
 	action action,
 	request *RequestPayload,
 ) (string, error) {
+	address := sc.jobs[request.Job].Address(request.Target)
+	if err := sc.backoff.Check(address); err != nil {
+		return "", err
+	}
+	defer sc.inFlight.Track(config.Server)()
+
+	ctx = network.WithLabels(ctx, request.Labels)
 	var statusResponse *v1.StatusResponse
 	var err error
 
-	serverClient, err := sc.connectionPool[request.Target].connection.GetServerClient()
+	serverClient, err := sc.connectionPool[address].connection.GetServerClient()
 	if err != nil {
 		return "", errors.Wrap(err, fmt.Sprintf("Can not get server connection from target {%s}", request.Target))
 	}
 
+	callStart := time.Now()
 	if action == kill {
-		statusResponse, err = serverClient.Kill(ctx, newServerRequest())
+		err = sc.retry.Do(ctx, request.Target, func(ctx context.Context) error {
+			statusResponse, err = serverClient.Kill(ctx, newServerRequest())
+			return err
+		})
+		sc.backoff.Record(address, err)
 	}
 
+	sc.outcomes.Observe(config.Server, action.String(), request.Target, metrics.ResultOf(statusResponse, err), time.Since(callStart))
+
 	switch {
 	case err != nil:
 		return "", errors.Wrap(err, fmt.Sprintf("Error response from target {%s}", request.Target))
@@ -126,7 +173,8 @@ func (sc *SController) performAction(
 		return "", errors.New(fmt.Sprintf("Failure response from target {%s}", request.Target))
 	}
 
-	return fmt.Sprintf("Response from target {%s}, {%s}, {%s}", request.Target, statusResponse.Message, statusResponse.Status), nil
+	sc.annotator.Start(request.Job, request.Target)
+	return sc.messages.Render(request.Target, statusResponse.Status, statusResponse.Message), nil
 }
 
 func checkIfTargetExists(jobMap map[string]*config.Job, requestPayload *RequestPayload) error {
@@ -164,8 +212,17 @@ func (a action) String() string {
 	return [...]string{"kill"}[a]
 }
 
-func toActionEnum(value string) (action, error) {
-	switch value {
+// Actions returns the names of all the actions supported for server injections
+func Actions() []string {
+	actions := make([]string, 0, int(notImplemented))
+	for a := action(0); a < notImplemented; a++ {
+		actions = append(actions, a.String())
+	}
+	return actions
+}
+
+func toActionEnum(value string, aliases config.ActionAliases) (action, error) {
+	switch strings.ToLower(aliases.Resolve(value)) {
 	case kill.String():
 		return kill, nil
 	}
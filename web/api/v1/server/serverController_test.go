@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
@@ -237,8 +236,12 @@ func post(requestBody []byte, url string) (int, string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		b, _ := ioutil.ReadAll(resp.Body)
-		return resp.StatusCode, string(b), nil
+		errPayload := &response.ErrorPayload{}
+		err = json.NewDecoder(resp.Body).Decode(&errPayload)
+		if err != nil {
+			return resp.StatusCode, "", err
+		}
+		return errPayload.Status, errPayload.Error, nil
 	}
 
 	respPayload := &response.Payload{}
@@ -290,14 +293,14 @@ func okResponse(message string) *responseWrapper {
 
 func badRequestResponse(error string) *responseWrapper {
 	return &responseWrapper{
-		message: error + "\n",
+		message: error,
 		status:  400,
 	}
 }
 
 func internalServerErrorResponse(error string) *responseWrapper {
 	return &responseWrapper{
-		message: error + "\n",
+		message: error,
 		status:  500,
 	}
 }
@@ -313,3 +316,14 @@ func getLoggers() chaoslogger.Loggers {
 		ErrLogger: chaoslogger.New(allowLevel, os.Stderr),
 	}
 }
+
+func TestActionsMatchActionEnum(t *testing.T) {
+	actions := Actions()
+
+	assert.Equal(t, int(notImplemented), len(actions))
+	for i, name := range actions {
+		a, err := toActionEnum(name, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, action(i), a)
+	}
+}
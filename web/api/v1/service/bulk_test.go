@@ -0,0 +1,93 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/SotirisAlfonsos/gocache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServiceKillOnAllTargets(t *testing.T) {
+	dataItems := []struct {
+		message           string
+		connectionPool    map[string]*sConnection
+		expectedStatus    int
+		expectedResults   map[string]string
+		expectedCacheSize int
+	}{
+		{
+			message:           "Should kill service on all the targets of the job and add them in cache",
+			connectionPool:    map[string]*sConnection{"127.0.0.1": withSuccessServiceConnection(), "127.0.0.2": withSuccessServiceConnection()},
+			expectedStatus:    http.StatusOK,
+			expectedResults:   map[string]string{"127.0.0.1": response.SUCCESS.String(), "127.0.0.2": response.SUCCESS.String()},
+			expectedCacheSize: 2,
+		},
+		{
+			message:           "Should receive internal server error, report the successful targets and add only them in cache if the action failed on a target",
+			connectionPool:    map[string]*sConnection{"127.0.0.1": withSuccessServiceConnection(), "127.0.0.2": withFailureServiceConnection()},
+			expectedStatus:    http.StatusInternalServerError,
+			expectedResults:   map[string]string{"127.0.0.1": response.SUCCESS.String(), "127.0.0.2": response.FAILURE.String()},
+			expectedCacheSize: 1,
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			jobMap := map[string]*config.Job{"job name": newServiceJob("service name", "127.0.0.1", "127.0.0.2")}
+			server, err := serviceHTTPTestServerWithCacheItems(jobMap, dataItem.connectionPool, c, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			requestBody, _ := json.Marshal(&RequestPayload{Job: "job name", ServiceName: "service name"})
+			resp, err := http.Post(server.URL+"/service?do=all&action=kill", "", bytes.NewReader(requestBody)) //nolint:gosec
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			payload := &response.BulkPayload{}
+			if err = json.NewDecoder(resp.Body).Decode(payload); err != nil {
+				t.Fatal(err)
+			}
+
+			results := make(map[string]string)
+			for _, result := range payload.Results {
+				results[result.Target] = result.Status
+			}
+			assert.Equal(t, dataItem.expectedStatus, resp.StatusCode)
+			assert.Equal(t, dataItem.expectedResults, results)
+			assert.Equal(t, dataItem.expectedCacheSize, c.ItemCount())
+			_, ok := c.Get(cache.Key{Job: "job name", Target: "127.0.0.1"})
+			assert.True(t, ok, "the target on which the action succeeded should be in cache")
+		})
+	}
+}
+
+func TestServiceKillOnAllTargetsOfAJobWithoutTargets(t *testing.T) {
+	c := gocache.New(0)
+	jobMap := map[string]*config.Job{"job name": newServiceJob("service name")}
+	server, err := serviceHTTPTestServerWithCacheItems(jobMap, map[string]*sConnection{}, c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	requestBody, _ := json.Marshal(&RequestPayload{Job: "job name", ServiceName: "service name"})
+	status, message, err := post(requestBody, server.URL+"/service?do=all&action=kill")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Equal(t, "There are no targets for job {job name}", message)
+	assert.Equal(t, 0, c.ItemCount())
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/go-kit/kit/log/level"
+)
+
+// PulseAction godoc
+// @Summary Kill and recover a service
+// @Description Kill a service, keep it down for the duration of the request or the default duration of the job, and recover it
+// @Tags Failure injections
+// @Accept json
+// @Produce json
+// @Param confirmProduction query bool false "Confirm the injection on a target labeled env: prod"
+// @Param requestPayload body RequestPayload true "Specify the job name, service name, target and duration"
+// @Success 200 {object} response.PulsePayload
+// @Failure 400 {object} response.ErrorPayload
+// @Failure 500 {object} response.PulsePayload
+// @Router /service/pulse [post]
+func (s *SController) PulseAction(w http.ResponseWriter, r *http.Request) {
+	requestPayload := &RequestPayload{}
+	err := json.NewDecoder(r.Body).Decode(&requestPayload)
+	if err != nil {
+		response.BadRequest(w, r, "Could not decode request body", s.loggers)
+		return
+	}
+	requestPayload.pulse = true
+
+	if err = network.ValidateLabels(requestPayload.Labels); err != nil {
+		response.BadRequest(w, r, err.Error(), s.loggers)
+		return
+	}
+
+	err = checkIfTargetExists(s.jobs, requestPayload)
+	if err != nil {
+		response.BadRequest(w, r, err.Error(), s.loggers)
+		return
+	}
+
+	job := s.jobs[requestPayload.Job]
+	for _, action := range []action{kill, recoverService} {
+		if !job.Allows(action.String()) {
+			response.Forbidden(w, r, fmt.Sprintf("The action {%s} is not allowed for job {%s}", action, requestPayload.Job), s.loggers)
+			return
+		}
+	}
+
+	if job.RequiresConfirmation(requestPayload.Target) && r.FormValue("confirmProduction") != "true" {
+		response.Forbidden(w, r, fmt.Sprintf("The target {%s} is a production target. Set the query parameter confirmProduction=true to inject", requestPayload.Target), s.loggers)
+		return
+	}
+
+	hold := job.RecoverAfter(requestPayload.DurationSec)
+	if hold <= 0 {
+		response.BadRequest(w, r, fmt.Sprintf("The pulse for job {%s} requires a positive durationSec", requestPayload.Job), s.loggers)
+		return
+	}
+
+	_ = level.Info(s.loggers.OutLogger).Log("msg", fmt.Sprintf("pulse service with name {%s} for %s", requestPayload.ServiceName, hold))
+
+	payload := response.Pulse(r.Context(), hold,
+		func(ctx context.Context) (string, error) {
+			return s.performActionWithDelay(ctx, kill, requestPayload)
+		},
+		func(ctx context.Context) (string, error) {
+			return s.performActionWithDelay(ctx, recoverService, requestPayload)
+		},
+	)
+
+	response.JSONResponse(w, r, payload.Status, payload, s.loggers)
+}
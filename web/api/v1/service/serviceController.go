@@ -5,13 +5,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/grafana"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/limiter"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/metrics"
 
 	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
 	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/healthcheck"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/cache"
 	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/retry"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/target"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/auth"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/controller"
 	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
 	"github.com/SotirisAlfonsos/gocache"
 	"github.com/go-kit/kit/log/level"
@@ -22,6 +32,19 @@ type SController struct {
 	jobs           map[string]*config.Job
 	connectionPool map[string]*sConnection
 	cache          *gocache.Cache
+	persistence    *cache.Persistence
+	locks          *cache.KeyLocks
+	injections     *cache.Injections
+	inFlight       *metrics.InFlight
+	outcomes       *metrics.Outcomes
+	backoff        *network.Backoff
+	retry          *retry.Policy
+	limiter        *limiter.Limiter
+	aliases        config.ActionAliases
+	guard          *healthcheck.Guard
+	annotator      *grafana.Annotator
+	messages       *response.MessageTemplate
+	pending        *cache.Pending
 	loggers        chaoslogger.Loggers
 }
 
@@ -29,14 +52,9 @@ type sConnection struct {
 	connection network.Connection
 }
 
-func NewServiceController(
-	jobs map[string]*config.Job,
-	connections *network.Connections,
-	cache *gocache.Cache,
-	loggers chaoslogger.Loggers,
-) *SController {
+func NewServiceController(jobs map[string]*config.Job, options *controller.Options) *SController {
 	connPool := make(map[string]*sConnection)
-	for target, connection := range connections.Pool {
+	for target, connection := range options.Connections.Pool {
 		connPool[target] = &sConnection{
 			connection: connection,
 		}
@@ -44,8 +62,21 @@ func NewServiceController(
 	return &SController{
 		jobs:           jobs,
 		connectionPool: connPool,
-		cache:          cache,
-		loggers:        loggers,
+		cache:          options.Cache,
+		persistence:    options.Persistence,
+		locks:          options.Locks,
+		injections:     options.Injections,
+		inFlight:       options.InFlight,
+		outcomes:       options.Outcomes,
+		backoff:        options.Connections.Backoff,
+		retry:          options.Connections.Retry,
+		limiter:        options.Connections.Limiter,
+		aliases:        options.Aliases,
+		guard:          options.Guard,
+		annotator:      options.Annotator,
+		messages:       options.Messages,
+		pending:        cache.NewPending(),
+		loggers:        options.Loggers,
 	}
 }
 
@@ -61,8 +92,17 @@ func (a action) String() string {
 	return [...]string{"kill", "recover"}[a]
 }
 
-func toActionEnum(value string) (action, error) {
-	switch value {
+// Actions returns the names of all the actions supported for service injections
+func Actions() []string {
+	actions := make([]string, 0, int(notImplemented))
+	for a := action(0); a < notImplemented; a++ {
+		actions = append(actions, a.String())
+	}
+	return actions
+}
+
+func toActionEnum(value string, aliases config.ActionAliases) (action, error) {
+	switch strings.ToLower(aliases.Resolve(value)) {
 	case recoverService.String():
 		return recoverService, nil
 	case kill.String():
@@ -72,9 +112,15 @@ func toActionEnum(value string) (action, error) {
 }
 
 type RequestPayload struct {
-	Job         string `json:"job"`
-	ServiceName string `json:"serviceName"`
-	Target      string `json:"target"`
+	Job         string            `json:"job"`
+	ServiceName string            `json:"serviceName"`
+	Target      string            `json:"target"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	DelaySec    int               `json:"delaySec,omitempty"`
+	DurationSec int               `json:"durationSec,omitempty"`
+
+	// pulse is set for the injection of a pulse, which recovers the injection itself
+	pulse bool
 }
 
 func newServiceRequest(details *RequestPayload) *v1.ServiceRequest {
@@ -83,52 +129,122 @@ func newServiceRequest(details *RequestPayload) *v1.ServiceRequest {
 	}
 }
 
+// resolve returns the request with the defaults of its job applied, as it is performed
+func resolve(job *config.Job, request *RequestPayload) *RequestPayload {
+	resolved := *request
+	resolved.DurationSec = int(job.RecoverAfter(request.DurationSec) / time.Second)
+	return &resolved
+}
+
 // CalcExample godoc
 // @Summary Inject service failures
-// @Description Perform start or stop action on a service
+// @Description Perform start or stop action on a service. If random is specified you do not have to provide a target
 // @Tags Failure injections
 // @Accept json
 // @Produce json
+// @Param do query string false "Specify to perform the action on a random target of the job, or on all its targets" Enums(random, all)
 // @Param action query string true "Specify to perform a recover or a kill on the specified service" Enums(kill, recover)
+// @Param confirmProduction query bool false "Confirm the injection on a target labeled env: prod"
 // @Param requestPayload body RequestPayload true "Specify the job name, service name and target"
 // @Success 200 {object} response.Payload
-// @Failure 400 {string} http.Error
-// @Failure 500 {string} http.Error
+// @Success 200 {object} response.BulkPayload "With do=all"
+// @Failure 400 {object} response.ErrorPayload
+// @Failure 500 {object} response.ErrorPayload
 // @Router /service [post]
 func (s *SController) ServiceAction(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithCancel(context.Background())
+	if r.FormValue("do") == all {
+		s.allServices(w, r)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
 	defer cancel()
 
 	requestPayload := &RequestPayload{}
 	err := json.NewDecoder(r.Body).Decode(&requestPayload)
 	if err != nil {
-		response.BadRequest(w, "Could not decode request body", s.loggers)
+		response.BadRequest(w, r, "Could not decode request body", s.loggers)
+		return
+	}
+
+	if err = network.ValidateLabels(requestPayload.Labels); err != nil {
+		response.BadRequest(w, r, err.Error(), s.loggers)
 		return
 	}
 
-	action, err := toActionEnum(r.FormValue("action"))
+	action, err := toActionEnum(r.FormValue("action"), s.aliases)
 	if err != nil {
-		response.BadRequest(w, err.Error(), s.loggers)
+		response.BadRequest(w, r, err.Error(), s.loggers)
 		return
 	}
 
-	err = checkIfTargetExists(s.jobs, requestPayload)
+	err = setTarget(s.jobs, r.FormValue("do"), requestPayload)
 	if err != nil {
-		response.BadRequest(w, err.Error(), s.loggers)
+		response.BadRequest(w, r, err.Error(), s.loggers)
+		return
+	}
+	if r.FormValue("do") == "random" {
+		_ = level.Info(s.loggers.OutLogger).Log("msg", "selected random target", "job", requestPayload.Job, "target", requestPayload.Target)
+	}
+
+	if !s.jobs[requestPayload.Job].Allows(action.String()) {
+		response.Forbidden(w, r, fmt.Sprintf("The action {%s} is not allowed for job {%s}", action, requestPayload.Job), s.loggers)
 		return
 	}
 
+	if action == kill && s.jobs[requestPayload.Job].RequiresConfirmation(requestPayload.Target) && r.FormValue("confirmProduction") != "true" {
+		response.Forbidden(w, r, fmt.Sprintf("The target {%s} is a production target. Set the query parameter confirmProduction=true to inject", requestPayload.Target), s.loggers)
+		return
+	}
+
+	if action == kill && r.FormValue("do") == "random" {
+		if err = s.guard.Check(requestPayload.Job, s.jobs[requestPayload.Job], []string{requestPayload.Target}); err != nil {
+			response.TooManyRequests(w, r, err.Error(), s.loggers)
+			return
+		}
+	}
+
 	_ = level.Info(s.loggers.OutLogger).Log("msg", fmt.Sprintf("%s service with name {%s}", action, requestPayload.ServiceName))
 
-	message, err := s.performAction(ctx, action, requestPayload)
+	message, err := s.performActionWithDelay(ctx, action, requestPayload)
 	if err != nil {
-		response.InternalServerError(w, err.Error(), s.loggers)
+		response.BotError(w, r, err, s.loggers)
 		return
 	}
 
 	_ = level.Info(s.loggers.OutLogger).Log("msg", message)
 
-	response.OkResponse(w, message, s.loggers)
+	response.OkResponseWithRequest(w, r, message, resolve(s.jobs[requestPayload.Job], requestPayload), s.loggers)
+}
+
+// setTarget checks that the target of the request is registered for the job, or sets a random target of the job with do=random
+func setTarget(jobMap map[string]*config.Job, do string, requestPayload *RequestPayload) error {
+	switch do {
+	case "":
+		return checkIfTargetExists(jobMap, requestPayload)
+	case "random":
+		return setRandomTargetIfExists(jobMap, requestPayload)
+	}
+	return errors.New(fmt.Sprintf("Do query parameter {%s} not allowed", do))
+}
+
+func setRandomTargetIfExists(jobMap map[string]*config.Job, requestPayload *RequestPayload) error {
+	job, ok := jobMap[requestPayload.Job]
+	if !ok {
+		return errors.New(fmt.Sprintf("Could not find job {%s}", requestPayload.Job))
+	}
+
+	if job.ComponentName != requestPayload.ServiceName {
+		return errors.New(fmt.Sprintf("Service {%s} is not registered for job {%s}", requestPayload.ServiceName, requestPayload.Job))
+	}
+
+	randomTarget, err := target.Random(job)
+	if err != nil {
+		return errors.New(fmt.Sprintf("Could not get random target for job {%s}. err: %s", requestPayload.Job, err.Error()))
+	}
+
+	requestPayload.Target = randomTarget
+	return nil
 }
 
 func checkIfTargetExists(jobMap map[string]*config.Job, requestPayload *RequestPayload) error {
@@ -160,37 +276,59 @@ func (s *SController) performAction(
 	action action,
 	request *RequestPayload,
 ) (string, error) {
+	address := s.jobs[request.Job].Address(request.Target)
+	if action == kill {
+		if err := s.backoff.Check(address); err != nil {
+			return "", err
+		}
+		defer s.inFlight.Track(config.Service)()
+	}
+
+	ctx = network.WithLabels(ctx, request.Labels)
 	var statusResponse *v1.StatusResponse
 	var err error
-	connection := s.connectionPool[request.Target].connection
+	connection := s.connectionPool[address].connection
 
 	serviceClient, err := connection.GetServiceClient()
 	if err != nil {
 		return "", errors.Wrap(err, fmt.Sprintf("Can not get service connection from target {%s}", request.Target))
 	}
 
-	switch action {
-	case recoverService:
-		statusResponse, err = serviceClient.Recover(ctx, newServiceRequest(request))
-	case kill:
-		statusResponse, err = serviceClient.Kill(ctx, newServiceRequest(request))
+	callStart := time.Now()
+	err = s.retry.Do(ctx, request.Target, func(ctx context.Context) error {
+		switch action {
+		case recoverService:
+			statusResponse, err = serviceClient.Recover(ctx, newServiceRequest(request))
+		case kill:
+			statusResponse, err = serviceClient.Kill(ctx, newServiceRequest(request))
+		}
+		return err
+	})
+
+	if action == kill {
+		s.backoff.Record(address, err)
 	}
 
+	s.outcomes.Observe(config.Service, action.String(), request.Target, metrics.ResultOf(statusResponse, err), time.Since(callStart))
+
 	switch {
 	case err != nil:
 		return "", errors.Wrap(err, fmt.Sprintf("Error response from target {%s}", request.Target))
 	case statusResponse.Status != v1.StatusResponse_SUCCESS:
 		return "", errors.New(fmt.Sprintf("Failure response from target {%s}", request.Target))
 	default:
-		if err = s.updateCache(connection, request, action); err != nil {
+		if err = s.updateCache(ctx, connection, request, action); err != nil {
 			_ = level.Error(s.loggers.ErrLogger).Log("msg", fmt.Sprintf("Could not update cache for operation %s", action), "err", err)
+			if action == kill && s.persistence.FailClosed() {
+				return "", s.rollback(request, err)
+			}
 		}
 	}
 
-	return fmt.Sprintf("Response from target {%s}, {%s}, {%s}", request.Target, statusResponse.Message, statusResponse.Status), nil
+	return s.messages.Render(request.Target, statusResponse.Status, statusResponse.Message), nil
 }
 
-func (s *SController) updateCache(connection network.Connection, request *RequestPayload, action action) error {
+func (s *SController) updateCache(ctx context.Context, connection network.Connection, request *RequestPayload, action action) error {
 	key := cache.Key{
 		Job:    request.Job,
 		Target: request.Target,
@@ -199,18 +337,138 @@ func (s *SController) updateCache(connection network.Connection, request *Reques
 	switch action {
 	case recoverService:
 		s.cache.Delete(key)
-		return nil
+		s.annotator.End(request.Job, request.Target)
+		return s.persistence.Save(s.cache)
 	case kill:
-		recoveryFunc := func() (*v1.StatusResponse, error) {
-			serviceClient, err := connection.GetServiceClient()
-			if err != nil {
-				return nil, errors.New(fmt.Sprintf("Could not recover service for job {%s} and target {%s}", request.Job, request.Target))
-			}
-			return serviceClient.Recover(context.Background(), &v1.ServiceRequest{Name: request.ServiceName})
+		// the injection is recorded, scheduled and annotated only if it is not rolled back
+		s.cache.Set(key, Recovery(connection, request))
+		err := s.persistence.Save(s.cache)
+		if err != nil && s.persistence.FailClosed() {
+			return err
 		}
-		s.cache.Set(key, recoveryFunc)
-		return nil
+		injection := &cache.Injection{StartsAt: time.Now(), Labels: request.Labels, Metadata: auth.WithPrincipalMetadata(ctx, nil), Inject: Injection(connection, request)}
+		s.injections.Record(key, injection)
+		s.scheduleRecovery(key, injection, request)
+		s.annotator.Start(request.Job, request.Target)
+		return err
 	default:
 		return errors.New(fmt.Sprintf("Action %s not supported for cache operation", action))
 	}
 }
+
+// scheduleRecovery recovers the failure automatically after the duration of the request, or the default duration of the job.
+// Nothing is scheduled for the injection of a pulse, since the pulse recovers it after the same duration
+// The failure is recovered under the lock of its key, and only if it has not been injected again in the meantime
+func (s *SController) scheduleRecovery(key cache.Key, injection *cache.Injection, request *RequestPayload) {
+	if request.pulse {
+		return
+	}
+
+	s.injections.AutoRecover(key, injection, s.jobs[request.Job].RecoverAfter(request.DurationSec), func() {
+		unlock := s.locks.Lock(key)
+		defer unlock()
+
+		if latest, ok := s.injections.Get(key); !ok || latest != injection {
+			return
+		}
+		if _, ok := s.cache.Get(key); !ok {
+			return
+		}
+		defer s.limiter.Acquire(s.jobs[request.Job].Address(request.Target))()
+		_ = level.Info(s.loggers.OutLogger).Log("msg", fmt.Sprintf("automatic recovery of job item {%s} on target {%s}", key.Job, key.Target))
+		if _, err := s.performAction(context.Background(), recoverService, request); err != nil {
+			_ = level.Error(s.loggers.ErrLogger).Log("msg", fmt.Sprintf("automatic recovery of job item {%s} on target {%s} failed", key.Job, key.Target), "err", err)
+		}
+	})
+}
+
+// Injection returns the function that injects the failure of the request on the target of the connection again
+func Injection(connection network.Connection, request *RequestPayload) func() (*v1.StatusResponse, error) {
+	return func() (*v1.StatusResponse, error) {
+		serviceClient, err := connection.GetServiceClient()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Could not inject service for job {%s} and target {%s}", request.Job, request.Target))
+		}
+		return serviceClient.Kill(network.WithLabels(context.Background(), request.Labels), &v1.ServiceRequest{Name: request.ServiceName})
+	}
+}
+
+// Recovery returns the function that recovers the failure of the request on the target of the connection
+func Recovery(connection network.Connection, request *RequestPayload) func() (*v1.StatusResponse, error) {
+	return func() (*v1.StatusResponse, error) {
+		serviceClient, err := connection.GetServiceClient()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("Could not recover service for job {%s} and target {%s}", request.Job, request.Target))
+		}
+		return serviceClient.Recover(network.WithLabels(context.Background(), request.Labels), &v1.ServiceRequest{Name: request.ServiceName})
+	}
+}
+
+// performActionWithDelay schedules a kill with a delay instead of performing it, and cancels a scheduled
+// kill on recover if it has not taken effect yet. Otherwise the action is performed immediately.
+// The delayed kill does not lock the key, since its recovery waits for it to complete while holding the lock
+func (s *SController) performActionWithDelay(ctx context.Context, action action, request *RequestPayload) (string, error) {
+	key := cache.Key{Job: request.Job, Target: request.Target}
+	unlock := s.locks.Lock(key)
+	defer unlock()
+
+	switch {
+	case action == kill && request.DelaySec > 0:
+		return s.delayKill(ctx, key, request)
+	case action == recoverService && s.pending.Cancel(key):
+		s.cache.Delete(key)
+		if err := s.persistence.Save(s.cache); err != nil {
+			_ = level.Error(s.loggers.ErrLogger).Log("msg", "Could not update cache after canceling delayed kill", "err", err)
+		}
+		return fmt.Sprintf("Canceled the delayed kill of service {%s} on target {%s}", request.ServiceName, request.Target), nil
+	default:
+		defer s.limiter.Acquire(s.jobs[request.Job].Address(request.Target))()
+		return s.performAction(ctx, action, request)
+	}
+}
+
+// delayKill caches the recovery of the kill immediately and performs the kill after the delay of the request.
+// A recovery within the delay cancels the kill
+func (s *SController) delayKill(ctx context.Context, key cache.Key, request *RequestPayload) (string, error) {
+	delay := time.Duration(request.DelaySec) * time.Second
+	connection := s.connectionPool[s.jobs[request.Job].Address(request.Target)].connection
+	inject := func() {
+		message, err := s.performAction(auth.Detach(ctx), kill, request)
+		if err != nil {
+			_ = level.Error(s.loggers.ErrLogger).Log("msg", fmt.Sprintf("Delayed kill of service {%s} on target {%s} failed", request.ServiceName, request.Target), "err", err)
+			s.cache.Delete(key)
+			if err = s.persistence.Save(s.cache); err != nil {
+				_ = level.Error(s.loggers.ErrLogger).Log("msg", "Could not update cache after failed delayed kill", "err", err)
+			}
+			return
+		}
+		_ = level.Info(s.loggers.OutLogger).Log("msg", message)
+	}
+
+	s.injections.Record(key, &cache.Injection{StartsAt: time.Now(), Labels: request.Labels, Metadata: auth.WithPrincipalMetadata(ctx, nil), Inject: Injection(connection, request)})
+	s.cache.Set(key, s.pending.Delay(key, delay, inject, Recovery(connection, request)))
+	if err := s.persistence.Save(s.cache); err != nil {
+		_ = level.Error(s.loggers.ErrLogger).Log("msg", "Could not update cache for delayed kill", "err", err)
+		if s.persistence.FailClosed() {
+			s.pending.Cancel(key)
+			s.cache.Delete(key)
+			return "", errors.Wrap(err, fmt.Sprintf("Could not persist delayed kill on target {%s}, the kill was canceled", request.Target))
+		}
+	}
+
+	return fmt.Sprintf("Kill of service {%s} on target {%s} is scheduled in %s", request.ServiceName, request.Target, delay), nil
+}
+
+// rollback recovers an injection whose recovery could not be persisted, so that no unrecoverable failure is left behind
+func (s *SController) rollback(request *RequestPayload, cause error) error {
+	key := cache.Key{Job: request.Job, Target: request.Target}
+	if err := cache.Rollback(s.cache, key); err != nil {
+		_ = level.Error(s.loggers.ErrLogger).Log("msg", fmt.Sprintf("Could not roll back injection on target {%s}", request.Target), "err", err)
+		return errors.Wrap(cause, fmt.Sprintf("Could not persist injection on target {%s} and the rollback failed", request.Target))
+	}
+	if err := s.persistence.Save(s.cache); err != nil {
+		_ = level.Error(s.loggers.ErrLogger).Log("msg", "Could not update cache after rollback", "err", err)
+	}
+
+	return errors.Wrap(cause, fmt.Sprintf("Could not persist injection on target {%s}, the injection was rolled back", request.Target))
+}
@@ -2,13 +2,16 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"regexp"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/SotirisAlfonsos/gocache"
 
@@ -21,6 +24,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
 )
 
 var (
@@ -255,6 +259,175 @@ func TestServiceWithInvalidAction(t *testing.T) {
 	}
 }
 
+func TestServiceActionWithPolicy(t *testing.T) {
+	dataItems := []TestData{
+		{
+			message: "Should receive forbidden and not update cache if the policy of the job forbids the action",
+			jobMap: map[string]*config.Job{
+				"job name": withPolicy(newServiceJob("service name", "127.0.0.1"), &config.Policy{Job: "job name", ForbiddenActions: []string{"kill"}}),
+			},
+			connectionPool: map[string]*sConnection{
+				"127.0.0.1": withSuccessServiceConnection(),
+			},
+			requestPayload: &RequestPayload{Job: "job name", ServiceName: "service name", Target: "127.0.0.1"},
+			expected:       &expectedResult{cacheSize: 0, response: forbiddenResponse("The action {kill} is not allowed for job {job name}")},
+		},
+		{
+			message: "Should receive forbidden and not update cache if the action is not in the allowed actions of the job",
+			jobMap: map[string]*config.Job{
+				"job name": withPolicy(newServiceJob("service name", "127.0.0.1"), &config.Policy{Job: "job name", AllowedActions: []string{"recover"}}),
+			},
+			connectionPool: map[string]*sConnection{
+				"127.0.0.1": withSuccessServiceConnection(),
+			},
+			requestPayload: &RequestPayload{Job: "job name", ServiceName: "service name", Target: "127.0.0.1"},
+			expected:       &expectedResult{cacheSize: 0, response: forbiddenResponse("The action {kill} is not allowed for job {job name}")},
+		},
+		{
+			message: "Should kill service and update cache if the policy of the job allows the action",
+			jobMap: map[string]*config.Job{
+				"job name": withPolicy(newServiceJob("service name", "127.0.0.1"), &config.Policy{Job: "job name", AllowedActions: []string{"kill", "recover"}}),
+			},
+			connectionPool: map[string]*sConnection{
+				"127.0.0.1": withSuccessServiceConnection(),
+			},
+			requestPayload: &RequestPayload{Job: "job name", ServiceName: "service name", Target: "127.0.0.1"},
+			expected:       &expectedResult{cacheSize: 1, response: okResponse("Response from target {127.0.0.1}, {}, {SUCCESS}")},
+		},
+	}
+
+	t.Log("Action kill")
+	for _, dataItem := range dataItems {
+		assertActionPerformed(t, dataItem, "kill")
+	}
+}
+
+func TestServiceActionOnProductionTarget(t *testing.T) {
+	productionJob := func() *config.Job {
+		return withTargetLabels(newServiceJob("service name", "127.0.0.1", "127.0.0.2"), map[string]config.Labels{
+			"127.0.0.1": {"env": "prod"},
+			"127.0.0.2": {"env": "staging"},
+		})
+	}
+
+	dataItems := []struct {
+		TestData
+		action string
+	}{
+		{
+			TestData: TestData{
+				message:        "Should receive forbidden and not update cache for a kill on a production target without confirmation",
+				jobMap:         map[string]*config.Job{"job name": productionJob()},
+				connectionPool: map[string]*sConnection{"127.0.0.1": withSuccessServiceConnection()},
+				requestPayload: &RequestPayload{Job: "job name", ServiceName: "service name", Target: "127.0.0.1"},
+				expected: &expectedResult{cacheSize: 0, response: forbiddenResponse(
+					"The target {127.0.0.1} is a production target. Set the query parameter confirmProduction=true to inject")},
+			},
+			action: "kill",
+		},
+		{
+			TestData: TestData{
+				message:        "Should kill service on a production target with confirmation",
+				jobMap:         map[string]*config.Job{"job name": productionJob()},
+				connectionPool: map[string]*sConnection{"127.0.0.1": withSuccessServiceConnection()},
+				requestPayload: &RequestPayload{Job: "job name", ServiceName: "service name", Target: "127.0.0.1"},
+				expected:       &expectedResult{cacheSize: 1, response: okResponse("Response from target {127.0.0.1}, {}, {SUCCESS}")},
+			},
+			action: "kill&confirmProduction=true",
+		},
+		{
+			TestData: TestData{
+				message:        "Should kill service on a non production target without confirmation",
+				jobMap:         map[string]*config.Job{"job name": productionJob()},
+				connectionPool: map[string]*sConnection{"127.0.0.2": withSuccessServiceConnection()},
+				requestPayload: &RequestPayload{Job: "job name", ServiceName: "service name", Target: "127.0.0.2"},
+				expected:       &expectedResult{cacheSize: 1, response: okResponse("Response from target {127.0.0.2}, {}, {SUCCESS}")},
+			},
+			action: "kill",
+		},
+		{
+			TestData: TestData{
+				message:        "Should recover service on a production target without confirmation",
+				jobMap:         map[string]*config.Job{"job name": productionJob()},
+				connectionPool: map[string]*sConnection{"127.0.0.1": withSuccessServiceConnection()},
+				requestPayload: &RequestPayload{Job: "job name", ServiceName: "service name", Target: "127.0.0.1"},
+				expected:       &expectedResult{cacheSize: 0, response: okResponse("Response from target {127.0.0.1}, {}, {SUCCESS}")},
+			},
+			action: "recover",
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		assertActionPerformed(t, dataItem.TestData, dataItem.action)
+	}
+}
+
+func TestDelayedKillService(t *testing.T) {
+	connection := &killRecordingConnection{MockConnection: network.MockConnection{Status: new(v1.StatusResponse)}}
+	cacheManager := gocache.New(0)
+	server, err := serviceHTTPTestServerWithCacheItems(
+		map[string]*config.Job{"job name": newServiceJob("service name", "127.0.0.1")},
+		map[string]*sConnection{"127.0.0.1": {connection: connection}},
+		cacheManager,
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	start := time.Now()
+	status, message, err := servicePostCall(server, &RequestPayload{Job: "job name", ServiceName: "service name", Target: "127.0.0.1", DelaySec: 1}, "kill")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "Kill of service {service name} on target {127.0.0.1} is scheduled in 1s", message)
+	assert.Equal(t, 1, cacheManager.ItemCount(), "the recovery should be cached before the kill takes effect")
+	assert.Equal(t, 0, len(connection.killTimes()))
+
+	time.Sleep(1500 * time.Millisecond)
+
+	killTimes := connection.killTimes()
+	assert.Equal(t, 1, len(killTimes))
+	assert.True(t, killTimes[0].Sub(start) >= time.Second, "the kill should happen after the delay")
+	assert.Equal(t, 1, cacheManager.ItemCount())
+}
+
+func TestDelayedKillServiceCanceledByRecover(t *testing.T) {
+	connection := &killRecordingConnection{MockConnection: network.MockConnection{Status: new(v1.StatusResponse)}}
+	cacheManager := gocache.New(0)
+	server, err := serviceHTTPTestServerWithCacheItems(
+		map[string]*config.Job{"job name": newServiceJob("service name", "127.0.0.1")},
+		map[string]*sConnection{"127.0.0.1": {connection: connection}},
+		cacheManager,
+		nil,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	_, _, err = servicePostCall(server, &RequestPayload{Job: "job name", ServiceName: "service name", Target: "127.0.0.1", DelaySec: 1}, "kill")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, message, err := servicePostCall(server, &RequestPayload{Job: "job name", ServiceName: "service name", Target: "127.0.0.1"}, "recover")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 200, status)
+	assert.Equal(t, "Canceled the delayed kill of service {service name} on target {127.0.0.1}", message)
+	assert.Equal(t, 0, cacheManager.ItemCount())
+
+	time.Sleep(1500 * time.Millisecond)
+
+	assert.Equal(t, 0, len(connection.killTimes()), "the kill should not happen after it was canceled")
+}
+
 func assertActionPerformed(t *testing.T, dataItem TestData, action string) {
 	t.Run(dataItem.message, func(t *testing.T) {
 		cacheManager := gocache.New(0)
@@ -284,6 +457,16 @@ func newServiceJob(componentName string, targets ...string) *config.Job {
 	}
 }
 
+func withPolicy(job *config.Job, policy *config.Policy) *config.Job {
+	job.Policies = append(job.Policies, policy)
+	return job
+}
+
+func withTargetLabels(job *config.Job, targetLabels map[string]config.Labels) *config.Job {
+	job.TargetLabels = targetLabels
+	return job
+}
+
 func withSuccessServiceConnection() *sConnection {
 	connection := &network.MockConnection{Status: new(v1.StatusResponse), Err: nil}
 	return &sConnection{
@@ -316,20 +499,50 @@ func withFailureToSetServiceConnection(errorMessage string) *sConnection {
 	}
 }
 
+type killRecordingConnection struct {
+	network.MockConnection
+	mu    sync.Mutex
+	kills []time.Time
+}
+
+func (connection *killRecordingConnection) GetServiceClient() (v1.ServiceClient, error) {
+	serviceClient, err := connection.MockConnection.GetServiceClient()
+	return &killRecordingServiceClient{ServiceClient: serviceClient, connection: connection}, err
+}
+
+func (connection *killRecordingConnection) killTimes() []time.Time {
+	connection.mu.Lock()
+	defer connection.mu.Unlock()
+	return append([]time.Time(nil), connection.kills...)
+}
+
+type killRecordingServiceClient struct {
+	v1.ServiceClient
+	connection *killRecordingConnection
+}
+
+func (client *killRecordingServiceClient) Kill(ctx context.Context, in *v1.ServiceRequest, opts ...grpc.CallOption) (*v1.StatusResponse, error) {
+	client.connection.mu.Lock()
+	client.connection.kills = append(client.connection.kills, time.Now())
+	client.connection.mu.Unlock()
+	return client.ServiceClient.Kill(ctx, in, opts...)
+}
+
 func serviceHTTPTestServerWithCacheItems(
 	jobMap map[string]*config.Job,
 	connectionPool map[string]*sConnection,
-	cache *gocache.Cache,
+	cacheManager *gocache.Cache,
 	cacheItems map[cache.Key]func() (*v1.StatusResponse, error),
 ) (*httptest.Server, error) {
 	for key, val := range cacheItems {
-		cache.Set(key, val)
+		cacheManager.Set(key, val)
 	}
 
 	sController := &SController{
 		jobs:           jobMap,
 		connectionPool: connectionPool,
-		cache:          cache,
+		cache:          cacheManager,
+		pending:        cache.NewPending(),
 		loggers:        loggers,
 	}
 
@@ -356,8 +569,12 @@ func post(requestBody []byte, url string) (int, string, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		b, _ := ioutil.ReadAll(resp.Body)
-		return resp.StatusCode, string(b), nil
+		errPayload := &response.ErrorPayload{}
+		err = json.NewDecoder(resp.Body).Decode(&errPayload)
+		if err != nil {
+			return resp.StatusCode, "", err
+		}
+		return errPayload.Status, errPayload.Error, nil
 	}
 
 	respPayload := &response.Payload{}
@@ -377,14 +594,21 @@ func okResponse(message string) *responseWrapper {
 
 func badRequestResponse(error string) *responseWrapper {
 	return &responseWrapper{
-		message: error + "\n",
+		message: error,
 		status:  400,
 	}
 }
 
+func forbiddenResponse(error string) *responseWrapper {
+	return &responseWrapper{
+		message: error,
+		status:  403,
+	}
+}
+
 func internalServerErrorResponse(error string) *responseWrapper {
 	return &responseWrapper{
-		message: error + "\n",
+		message: error,
 		status:  500,
 	}
 }
@@ -406,3 +630,107 @@ func getLoggers() chaoslogger.Loggers {
 		ErrLogger: chaoslogger.New(allowLevel, os.Stderr),
 	}
 }
+
+func TestActionsMatchActionEnum(t *testing.T) {
+	actions := Actions()
+
+	assert.Equal(t, int(notImplemented), len(actions))
+	for i, name := range actions {
+		a, err := toActionEnum(name, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, action(i), a)
+	}
+}
+
+func TestServiceKillOnRandomTarget(t *testing.T) {
+	dataItems := []struct {
+		message  string
+		jobMap   map[string]*config.Job
+		do       string
+		expected *expectedResult
+	}{
+		{
+			message:  "Should kill service on a random target of the job and add it in cache with the target",
+			jobMap:   map[string]*config.Job{"job name": newServiceJob("service name", "127.0.0.1", "127.0.0.2")},
+			do:       "random",
+			expected: &expectedResult{cacheSize: 1, response: okResponse("Response from target {127.0.0.\\d}, {}, {SUCCESS}")},
+		},
+		{
+			message:  "Should receive bad request and not update cache when job has no targets defined",
+			jobMap:   map[string]*config.Job{"job name": newServiceJob("service name")},
+			do:       "random",
+			expected: &expectedResult{cacheSize: 0, response: badRequestResponse("Could not get random target for job {job name}. err: No targets available")},
+		},
+		{
+			message:  "Should receive bad request and not update cache when job name does not exist",
+			jobMap:   map[string]*config.Job{"job different name": newServiceJob("service name", "127.0.0.1")},
+			do:       "random",
+			expected: &expectedResult{cacheSize: 0, response: badRequestResponse("Could not find job {job name}")},
+		},
+		{
+			message:  "Should receive bad request and not update cache for an unknown do query parameter",
+			jobMap:   map[string]*config.Job{"job name": newServiceJob("service name", "127.0.0.1")},
+			do:       "any",
+			expected: &expectedResult{cacheSize: 0, response: badRequestResponse("Do query parameter {any} not allowed")},
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			c := gocache.New(0)
+			connectionPool := map[string]*sConnection{"127.0.0.1": withSuccessServiceConnection(), "127.0.0.2": withSuccessServiceConnection()}
+			server, err := serviceHTTPTestServerWithCacheItems(dataItem.jobMap, connectionPool, c, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer server.Close()
+
+			requestBody, _ := json.Marshal(&RequestPayload{Job: "job name", ServiceName: "service name"})
+			status, message, err := post(requestBody, fmt.Sprintf("%s/service?do=%s&action=kill", server.URL, dataItem.do))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, dataItem.expected.response.status, status)
+			assert.Regexp(t, regexp.MustCompile(dataItem.expected.response.message), message)
+			assert.Equal(t, dataItem.expected.cacheSize, c.ItemCount())
+			for _, item := range c.GetAll() {
+				key := item.Key.(cache.Key)
+				assert.Contains(t, message, fmt.Sprintf("{%s}", key.Target), "the cache key should have the selected target")
+			}
+		})
+	}
+}
+
+func TestServiceAutomaticRecoveryDoesNotRecoverAKeyInjectedAgain(t *testing.T) {
+	job := newServiceJob("nginx", "127.0.0.1")
+	job.DefaultDuration = 20 * time.Millisecond
+	connection := &recoverCountingConnection{MockConnection: network.MockConnection{Status: new(v1.StatusResponse)}}
+	cacheManager := gocache.New(0)
+	locks := cache.NewKeyLocks()
+	injections := cache.NewInjections()
+	controller := &SController{
+		jobs:           map[string]*config.Job{"job name": job},
+		connectionPool: map[string]*sConnection{"127.0.0.1": {connection: connection}},
+		cache:          cacheManager,
+		locks:          locks,
+		injections:     injections,
+		pending:        cache.NewPending(),
+		loggers:        loggers,
+	}
+
+	if _, err := controller.performActionWithDelay(context.Background(), kill, &RequestPayload{Job: "job name", ServiceName: "nginx", Target: "127.0.0.1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the automatic recovery is due while the key is locked by an injection of the key that is in flight
+	key := cache.Key{Job: "job name", Target: "127.0.0.1"}
+	unlock := locks.Lock(key)
+	time.Sleep(100 * time.Millisecond)
+	injections.Record(key, &cache.Injection{StartsAt: time.Now()})
+	unlock()
+	time.Sleep(50 * time.Millisecond)
+
+	assert.Equal(t, 0, connection.recoverCalls(), "the automatic recovery should not recover the injection that replaced its own")
+	assert.Equal(t, 1, len(cacheManager.GetAll()))
+}
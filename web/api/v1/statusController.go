@@ -12,8 +12,8 @@ import (
 )
 
 type Bots struct {
-	StatusMap map[string]*healthcheck.Details
-	Loggers   chaoslogger.Loggers
+	HealthChecker *healthcheck.HealthChecker
+	Loggers       chaoslogger.Loggers
 }
 
 // CalcExample godoc
@@ -29,8 +29,8 @@ func (bots *Bots) Status(w http.ResponseWriter, _ *http.Request) {
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintln("Bots status:"))
-	for botHost, botDetails := range bots.StatusMap {
-		sb.WriteString(fmt.Sprintln(botHost, botDetails.Status.String()))
+	for botHost, status := range bots.HealthChecker.Statuses() {
+		sb.WriteString(fmt.Sprintln(botHost, status.String()))
 	}
 
 	response, err := w.Write([]byte(sb.String()))
@@ -0,0 +1,95 @@
+package v1
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+)
+
+type handlerTimeout struct {
+	timeout time.Duration
+	loggers chaoslogger.Loggers
+}
+
+func newHandlerTimeout(timeout time.Duration, loggers chaoslogger.Loggers) *handlerTimeout {
+	return &handlerTimeout{
+		timeout: timeout,
+		loggers: loggers,
+	}
+}
+
+// middleware responds with 503 if the handler does not complete within the timeout.
+// The response of the handler is buffered and only written if the handler completes in time.
+// The requests that accept ndjson are not timed out, since their recover messages are streamed
+// to the client as the recoveries complete and can not be buffered
+func (h *handlerTimeout) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if response.AcceptsNDJSON(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+		defer cancel()
+
+		bw := &bufferedWriter{header: make(http.Header)}
+		done := make(chan struct{})
+		panicChan := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicChan <- p
+				}
+			}()
+			next.ServeHTTP(bw, r.WithContext(ctx))
+			close(done)
+		}()
+
+		select {
+		case p := <-panicChan:
+			panic(p)
+		case <-done:
+			bw.writeTo(w)
+		case <-ctx.Done():
+			response.ServiceUnavailable(w, r, "The request did not complete within "+h.timeout.String(), h.loggers)
+		}
+	})
+}
+
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (bw *bufferedWriter) Header() http.Header {
+	return bw.header
+}
+
+func (bw *bufferedWriter) Write(b []byte) (int, error) {
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	return bw.body.Write(b)
+}
+
+func (bw *bufferedWriter) WriteHeader(status int) {
+	if bw.status == 0 {
+		bw.status = status
+	}
+}
+
+func (bw *bufferedWriter) writeTo(w http.ResponseWriter) {
+	for key, values := range bw.header {
+		w.Header()[key] = values
+	}
+	if bw.status == 0 {
+		bw.status = http.StatusOK
+	}
+	w.WriteHeader(bw.status)
+	_, _ = w.Write(bw.body.Bytes())
+}
@@ -0,0 +1,95 @@
+package v1
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandlerTimeout(t *testing.T) {
+	dataItems := []struct {
+		message        string
+		handlerDelay   time.Duration
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			message:        "Should respond with service unavailable if the handler exceeds the timeout",
+			handlerDelay:   200 * time.Millisecond,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   "{\"error\":\"The request did not complete within 20ms\",\"status\":503}\n",
+		},
+		{
+			message:        "Should respond with the response of the handler if it completes within the timeout",
+			expectedStatus: http.StatusAccepted,
+			expectedBody:   "done",
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		// the handler keeps running after the timeout, while the loop moves on to the next item
+		dataItem := dataItem
+		t.Run(dataItem.message, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(dataItem.handlerDelay):
+				case <-r.Context().Done():
+				}
+				w.WriteHeader(http.StatusAccepted)
+				_, _ = w.Write([]byte("done"))
+			})
+
+			server := httptest.NewServer(newHandlerTimeout(20*time.Millisecond, loggers).middleware(handler))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body, _ := ioutil.ReadAll(resp.Body)
+
+			assert.Equal(t, dataItem.expectedStatus, resp.StatusCode)
+			assert.Equal(t, dataItem.expectedBody, string(body))
+		})
+	}
+}
+
+func TestHandlerTimeoutDoesNotBufferTheRecoverStream(t *testing.T) {
+	release := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stream := response.NewRecoverStream(w, false, loggers)
+		stream.Send(response.NewRecoverResult("job", "127.0.0.1", response.SuccessRecoverResponse("first"), 0))
+		<-release
+		stream.Send(response.NewRecoverResult("job", "127.0.0.2", response.SuccessRecoverResponse("second"), 0))
+	})
+
+	server := httptest.NewServer(newHandlerTimeout(20*time.Millisecond, loggers).middleware(handler))
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodPost, server.URL, nil)
+	request.Header.Set("Accept", response.NDJSON)
+	resp, err := http.DefaultClient.Do(request)
+	if err != nil {
+		close(release)
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	first, err := reader.ReadString('\n')
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	rest, _ := ioutil.ReadAll(reader)
+
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "{\"message\":\"first\",\"error\":\"\",\"status\":\"SUCCESS\"}\n", first, "the first recover message should be streamed before the handler completes")
+	assert.Equal(t, "{\"message\":\"second\",\"error\":\"\",\"status\":\"SUCCESS\"}\n", string(rest), "the stream should not be cut by the timeout")
+}
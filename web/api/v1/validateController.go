@@ -0,0 +1,113 @@
+package v1
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/healthcheck"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/go-kit/kit/log/level"
+)
+
+const validateTimeout = 5 * time.Second
+
+// Validation reports whether failures can currently be injected on the target of a job
+type Validation struct {
+	Job        string `json:"job"`
+	Target     string `json:"target"`
+	Status     string `json:"status"`
+	Injectable bool   `json:"injectable"`
+	Error      string `json:"error,omitempty"`
+}
+
+type ValidateController struct {
+	jobs        map[string]*config.Job
+	connections *network.Connections
+	loggers     chaoslogger.Loggers
+}
+
+type targetHealth struct {
+	status v1.HealthCheckResponse_ServingStatus
+	err    error
+}
+
+// Validate godoc
+// @Summary Validate all jobs
+// @Description Health-check the bot of every target of every job, and report which job and target pairs are injectable
+// @Tags Status
+// @Produce json
+// @Success 200 {array} Validation
+// @Router /validate [get]
+func (v *ValidateController) Validate(w http.ResponseWriter, r *http.Request) {
+	health := v.checkTargets()
+
+	validations := make([]*Validation, 0)
+	for jobName, job := range v.jobs {
+		for _, target := range job.Target {
+			validation := &Validation{Job: jobName, Target: target, Status: v1.HealthCheckResponse_UNKNOWN.String()}
+			if h, ok := health[job.Address(target)]; ok {
+				validation.Status = h.status.String()
+				validation.Injectable = h.err == nil && h.status == v1.HealthCheckResponse_SERVING
+				if h.err != nil {
+					validation.Error = h.err.Error()
+				}
+			} else {
+				validation.Error = "No connection to target"
+			}
+			validations = append(validations, validation)
+		}
+	}
+
+	sort.Slice(validations, func(i, j int) bool {
+		if validations[i].Job != validations[j].Job {
+			return validations[i].Job < validations[j].Job
+		}
+		return validations[i].Target < validations[j].Target
+	})
+
+	response.JSONResponse(w, r, http.StatusOK, validations, v.loggers)
+}
+
+// checkTargets health-checks the bot of every target of the jobs once, concurrently
+func (v *ValidateController) checkTargets() map[string]*targetHealth {
+	connections := make(map[string]network.Connection)
+	for _, job := range v.jobs {
+		for _, target := range job.Target {
+			address := job.Address(target)
+			if connection, ok := v.connections.Pool[address]; ok {
+				connections[address] = connection
+			}
+		}
+	}
+
+	health := make(map[string]*targetHealth, len(connections))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for target, connection := range connections {
+		wg.Add(1)
+		go func(target string, connection network.Connection) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), validateTimeout)
+			defer cancel()
+
+			status, err := healthcheck.Check(ctx, connection)
+			if err != nil {
+				_ = level.Warn(v.loggers.OutLogger).Log("msg", "target is not injectable", "target", target, "err", err)
+			}
+
+			mu.Lock()
+			health[target] = &targetHealth{status: status, err: err}
+			mu.Unlock()
+		}(target, connection)
+	}
+
+	wg.Wait()
+	return health
+}
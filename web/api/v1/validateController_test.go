@@ -0,0 +1,55 @@
+package v1
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	v1 "github.com/SotirisAlfonsos/chaos-bot/proto/grpc/v1"
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/pkg/network"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateReportsInjectableTargets(t *testing.T) {
+	jobs := map[string]*config.Job{
+		"cpu job":    {FailureType: config.CPU, Target: []string{"127.0.0.1", "127.0.0.2"}},
+		"docker job": {FailureType: config.Docker, ComponentName: "nginx", Target: []string{"127.0.0.2", "127.0.0.3", "127.0.0.4"}},
+	}
+	connections := &network.Connections{
+		Pool: map[string]network.Connection{
+			"127.0.0.1": &network.MockConnection{Health: v1.HealthCheckResponse_SERVING},
+			"127.0.0.2": &network.MockConnection{Err: errors.New("connection refused")},
+			"127.0.0.3": &network.MockConnection{Health: v1.HealthCheckResponse_NOT_SERVING},
+		},
+	}
+
+	validateController := &ValidateController{jobs: jobs, connections: connections, loggers: loggers}
+	router := mux.NewRouter()
+	router.HandleFunc("/validate", validateController.Validate).Methods("GET")
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/validate")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	validations := make([]*Validation, 0)
+	if err = json.NewDecoder(resp.Body).Decode(&validations); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, []*Validation{
+		{Job: "cpu job", Target: "127.0.0.1", Status: "SERVING", Injectable: true},
+		{Job: "cpu job", Target: "127.0.0.2", Status: "NOT_SERVING", Error: "Failed to get valid response when health-checking: connection refused"},
+		{Job: "docker job", Target: "127.0.0.2", Status: "NOT_SERVING", Error: "Failed to get valid response when health-checking: connection refused"},
+		{Job: "docker job", Target: "127.0.0.3", Status: "NOT_SERVING"},
+		{Job: "docker job", Target: "127.0.0.4", Status: "UNKNOWN", Error: "No connection to target"},
+	}, validations)
+}
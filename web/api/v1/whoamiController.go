@@ -0,0 +1,37 @@
+package v1
+
+import (
+	"net/http"
+
+	"github.com/SotirisAlfonsos/chaos-master/pkg/chaoslogger"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/auth"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+)
+
+// Principal is the identity of the token the request was authenticated with
+type Principal struct {
+	Name          string `json:"name"`
+	Authenticated bool   `json:"authenticated"`
+}
+
+type WhoamiController struct {
+	loggers chaoslogger.Loggers
+}
+
+// Whoami godoc
+// @Summary Get the authenticated principal
+// @Description Get the name of the token the request was authenticated with
+// @Tags Status
+// @Produce json
+// @Success 200 {object} Principal
+// @Failure 401 {object} response.ErrorPayload
+// @Router /whoami [get]
+func (wc *WhoamiController) Whoami(w http.ResponseWriter, r *http.Request) {
+	principal := &Principal{Name: "anonymous"}
+	if name, ok := auth.PrincipalFromContext(r.Context()); ok {
+		principal.Name = name
+		principal.Authenticated = true
+	}
+
+	response.JSONResponse(w, r, http.StatusOK, principal, wc.loggers)
+}
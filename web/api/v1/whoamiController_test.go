@@ -0,0 +1,98 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/SotirisAlfonsos/chaos-master/config"
+	"github.com/SotirisAlfonsos/chaos-master/web/api/v1/response"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWhoami(t *testing.T) {
+	authConf := &config.Auth{Tokens: []*config.APIToken{{Name: "ci-pipeline", Token: "secret"}}}
+
+	dataItems := []struct {
+		message        string
+		auth           *config.Auth
+		token          string
+		expectedStatus int
+		expected       *Principal
+	}{
+		{
+			message:        "Should return the name of the token the request was authenticated with",
+			auth:           authConf,
+			token:          "secret",
+			expectedStatus: http.StatusOK,
+			expected:       &Principal{Name: "ci-pipeline", Authenticated: true},
+		},
+		{
+			message:        "Should return unauthorized for an unknown token",
+			auth:           authConf,
+			token:          "unknown",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			message:        "Should return unauthorized without a token",
+			auth:           authConf,
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			message:        "Should return anonymous when authentication is disabled",
+			expectedStatus: http.StatusOK,
+			expected:       &Principal{Name: "anonymous", Authenticated: false},
+		},
+	}
+
+	for _, dataItem := range dataItems {
+		t.Run(dataItem.message, func(t *testing.T) {
+			server := apiHTTPTestServer(&config.Config{Auth: dataItem.auth})
+			defer server.Close()
+
+			req, err := http.NewRequest("GET", server.URL+"/chaos/api/v1/whoami", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dataItem.token != "" {
+				req.Header.Set("Authorization", "Bearer "+dataItem.token)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			assert.Equal(t, dataItem.expectedStatus, resp.StatusCode)
+			if dataItem.expected == nil {
+				assert.Equal(t, "Bearer", resp.Header.Get("WWW-Authenticate"))
+				payload := &response.ErrorPayload{}
+				if err := json.NewDecoder(resp.Body).Decode(payload); err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, "Missing or unknown bearer token", payload.Error)
+				return
+			}
+
+			principal := &Principal{}
+			if err := json.NewDecoder(resp.Body).Decode(principal); err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, dataItem.expected, principal)
+		})
+	}
+}
+
+func TestAuthenticationAppliesToBotRoutes(t *testing.T) {
+	server := apiHTTPTestServer(&config.Config{Auth: &config.Auth{Tokens: []*config.APIToken{{Name: "ci", Token: "secret"}}}})
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/chaos/api/v1/cpu?action=start", "", nil) //nolint:gosec
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}